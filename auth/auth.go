@@ -0,0 +1,180 @@
+// Package auth authenticates API clients by API key or by HMAC signed JWT
+// bearer token and assigns them a role.
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// APIKeyHeader carries an API key, tokens are sent as
+// "Authorization: Bearer <token>".
+const APIKeyHeader = "X-API-Key"
+
+// minKeySize is the smallest HMAC key accepted, the size of a SHA-256
+// hash.
+const minKeySize = 32
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Role grants access to a set of routes. Every role includes the ones
+// before it: readers read, librarians also change the catalogue and admins
+// may do anything.
+type Role string
+
+const (
+	RoleReader    Role = "reader"
+	RoleLibrarian Role = "librarian"
+	RoleAdmin     Role = "admin"
+)
+
+//nolint:gochecknoglobals
+var roleLevels = map[Role]int{RoleReader: 1, RoleLibrarian: 2, RoleAdmin: 3}
+
+func (role Role) Valid() bool {
+	return roleLevels[role] > 0
+}
+
+// Allows reports whether role includes required.
+func (role Role) Allows(required Role) bool {
+	return role.Valid() && roleLevels[role] >= roleLevels[required]
+}
+
+// Principal is an authenticated client. A client with a Tenant may only
+// access the books of that tenant, the others pick the tenant per request.
+type Principal struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"role"`
+	Tenant  string `json:"tenant,omitempty"`
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal set by WithPrincipal.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+
+	return principal, ok
+}
+
+// apiKey keeps the SHA-256 hash of a key, comparing hashes of the same size
+// doesn't reveal the size of the key.
+type apiKey struct {
+	hash      [sha256.Size]byte
+	principal Principal
+}
+
+// Authenticator checks the credentials of requests against the configured
+// API keys and JWT keys. It has to be set up before it is used.
+type Authenticator struct {
+	apiKeys []apiKey
+	jwtKeys map[string][]byte
+	// Issuer and Audience, if set, have to be the issuer and one of the
+	// audiences of every token.
+	Issuer   string
+	Audience string
+	// Now is the clock tokens are checked against.
+	Now func() time.Time
+}
+
+func NewAuthenticator() *Authenticator {
+	return &Authenticator{
+		jwtKeys: make(map[string][]byte),
+		Now:     time.Now,
+	}
+}
+
+// AddAPIKey lets requests presenting key act as principal.
+func (a *Authenticator) AddAPIKey(key string, principal Principal) error {
+	if key == "" {
+		return fmt.Errorf("API key of %q is empty", principal.Subject)
+	}
+
+	if principal.Subject == "" || !principal.Role.Valid() {
+		return fmt.Errorf("API key needs a name and one of the roles %s, %s or %s",
+			RoleReader, RoleLibrarian, RoleAdmin)
+	}
+
+	a.apiKeys = append(a.apiKeys, apiKey{hash: sha256.Sum256([]byte(key)), principal: principal})
+
+	return nil
+}
+
+// AddJWTKey accepts tokens signed with secret. Tokens naming a key ID in
+// their kid header are only checked against that key.
+func (a *Authenticator) AddJWTKey(kid string, secret []byte) error {
+	if len(secret) < minKeySize {
+		return fmt.Errorf("JWT key %q is shorter than %d bytes", kid, minKeySize)
+	}
+
+	a.jwtKeys[kid] = secret
+
+	return nil
+}
+
+// Empty reports whether no keys are configured, every request fails then.
+func (a *Authenticator) Empty() bool {
+	return len(a.apiKeys) == 0 && len(a.jwtKeys) == 0
+}
+
+// Authenticate returns the principal of the API key or bearer token of the
+// request. It fails with ErrNoCredentials if the request has neither and
+// with ErrInvalidCredentials if they aren't valid.
+func (a *Authenticator) Authenticate(r *http.Request) (Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" {
+		return Principal{}, ErrNoCredentials
+	}
+
+	const bearer = "bearer "
+	if len(authorization) <= len(bearer) || !strings.EqualFold(authorization[:len(bearer)], bearer) {
+		return Principal{}, fmt.Errorf("%w: expected a bearer token", ErrInvalidCredentials)
+	}
+
+	claims, err := a.VerifyToken(strings.TrimSpace(authorization[len(bearer):]))
+	if err != nil {
+		return Principal{}, err
+	}
+
+	return Principal{Subject: claims.Subject, Role: claims.Role, Tenant: claims.Tenant}, nil
+}
+
+// authenticateAPIKey compares key with every configured key in constant
+// time.
+func (a *Authenticator) authenticateAPIKey(key string) (Principal, error) {
+	var (
+		principal Principal
+		found     bool
+	)
+
+	hash := sha256.Sum256([]byte(key))
+
+	for _, candidate := range a.apiKeys {
+		if subtle.ConstantTimeCompare(candidate.hash[:], hash[:]) == 1 {
+			principal, found = candidate.principal, true
+		}
+	}
+
+	if !found {
+		return Principal{}, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+
+	return principal, nil
+}
@@ -0,0 +1,113 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	t.Parallel()
+
+	authenticator := auth.NewAuthenticator()
+	require.NoError(t, authenticator.AddAPIKey("reader-key", auth.Principal{Subject: "kiosk", Role: auth.RoleReader}))
+	require.NoError(t, authenticator.AddAPIKey("librarian-key-that-is-longer",
+		auth.Principal{Subject: "desk", Role: auth.RoleLibrarian, Tenant: "north"}))
+	require.Error(t, authenticator.AddAPIKey("", auth.Principal{Subject: "empty", Role: auth.RoleReader}))
+	require.Error(t, authenticator.AddAPIKey("roleless-key", auth.Principal{Subject: "roleless"}))
+	require.Error(t, authenticator.AddAPIKey("nameless-key", auth.Principal{Role: auth.RoleAdmin}))
+
+	tests := []struct {
+		name      string
+		key       string
+		principal auth.Principal
+		err       error
+	}{
+		{name: "first key", key: "reader-key", principal: auth.Principal{Subject: "kiosk", Role: auth.RoleReader}},
+		{
+			name:      "last key",
+			key:       "librarian-key-that-is-longer",
+			principal: auth.Principal{Subject: "desk", Role: auth.RoleLibrarian, Tenant: "north"},
+		},
+		// keys are compared whole, whatever their size
+		{name: "last byte differs", key: "reader-kez", err: auth.ErrInvalidCredentials},
+		{name: "prefix", key: "reader-ke", err: auth.ErrInvalidCredentials},
+		{name: "extension", key: "reader-key2", err: auth.ErrInvalidCredentials},
+		{name: "case differs", key: "READER-KEY", err: auth.ErrInvalidCredentials},
+		{name: "another key's prefix", key: "librarian-key", err: auth.ErrInvalidCredentials},
+		{name: "unknown", key: "guessed-key", err: auth.ErrInvalidCredentials},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+			req.Header.Set(auth.APIKeyHeader, test.key)
+
+			principal, err := authenticator.Authenticate(req)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.principal, principal)
+		})
+	}
+}
+
+func TestAuthenticateHeaders(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	authenticator := auth.NewAuthenticator()
+	require.True(t, authenticator.Empty())
+	require.Error(t, authenticator.AddJWTKey("short", secret[:31]))
+	require.NoError(t, authenticator.AddJWTKey("k1", secret))
+	require.False(t, authenticator.Empty())
+
+	token := signToken(t, auth.Claims{Subject: "alice", Role: auth.RoleAdmin, ExpiresAt: now.Add(time.Hour).Unix()}, "k1", secret)
+	authenticator.Now = func() time.Time { return now }
+
+	tests := []struct {
+		name          string
+		authorization string
+		err           error
+	}{
+		{name: "bearer", authorization: "Bearer " + token},
+		{name: "lower case bearer", authorization: "bearer  " + token + " "},
+		{name: "none", err: auth.ErrNoCredentials},
+		{name: "basic", authorization: "Basic YWxpY2U6c2VjcmV0", err: auth.ErrInvalidCredentials},
+		{name: "bearer without a token", authorization: "Bearer ", err: auth.ErrInvalidCredentials},
+		{name: "not a token", authorization: "Bearer secret", err: auth.ErrInvalidCredentials},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/v1/books", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			principal, err := authenticator.Authenticate(req)
+			assert.ErrorIs(t, err, test.err)
+			if test.err == nil {
+				assert.Equal(t, auth.Principal{Subject: "alice", Role: auth.RoleAdmin}, principal)
+			}
+		})
+	}
+}
+
+func TestRoleAllows(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, auth.RoleAdmin.Allows(auth.RoleLibrarian))
+	assert.True(t, auth.RoleLibrarian.Allows(auth.RoleLibrarian))
+	assert.False(t, auth.RoleReader.Allows(auth.RoleLibrarian))
+	assert.False(t, auth.Role("owner").Allows(auth.RoleReader))
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/db"
+)
+
+// clockSkew is how far the clocks of token issuers and the server may
+// drift apart.
+const clockSkew = time.Minute
+
+//nolint:gochecknoglobals
+var signingHashes = map[string]func() hash.Hash{
+	"HS256": sha256.New,
+	"HS384": sha512.New384,
+	"HS512": sha512.New,
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// Claims are the claims of a bearer token. Tokens need a subject, a role
+// and an expiry. A tenant has to be a valid tenant name, see
+// db.ValidTenant.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Role      Role     `json:"role"`
+	Tenant    string   `json:"tenant,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+}
+
+// Audience is a single audience or a list of them.
+type Audience []string
+
+func (audience Audience) MarshalJSON() ([]byte, error) {
+	if len(audience) == 1 {
+		return json.Marshal(audience[0])
+	}
+
+	return json.Marshal([]string(audience))
+}
+
+func (audience *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*audience = Audience{single}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]string)(audience))
+}
+
+func (audience Audience) contains(name string) bool {
+	for _, candidate := range audience {
+		if candidate == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignToken signs claims with HS256. The token names kid as its key ID
+// unless it is empty.
+func SignToken(claims Claims, kid string, secret []byte) (string, error) {
+	header, err := json.Marshal(tokenHeader{Algorithm: "HS256", Type: "JWT", KeyID: kid})
+	if err != nil {
+		return "", fmt.Errorf("can't encode a token header: %w", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("can't encode token claims: %w", err)
+	}
+
+	signed := encodeSegment(header) + "." + encodeSegment(payload)
+
+	return signed + "." + encodeSegment(sign(sha256.New, secret, signed)), nil
+}
+
+// VerifyToken checks the signature and the claims of a compact serialized
+// JWT. Only HMAC signatures are accepted.
+func (a *Authenticator) VerifyToken(token string) (Claims, error) {
+	var claims Claims
+
+	segments := strings.Split(token, ".")
+	if len(segments) != 3 {
+		return claims, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	var header tokenHeader
+	if err := decodeSegment(segments[0], &header); err != nil {
+		return claims, err
+	}
+
+	newHash, ok := signingHashes[header.Algorithm]
+	if !ok {
+		return claims, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidCredentials, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(segments[2])
+	if err != nil {
+		return claims, fmt.Errorf("%w: malformed signature", ErrInvalidCredentials)
+	}
+
+	if !a.verifySignature(newHash, header.KeyID, segments[0]+"."+segments[1], signature) {
+		return claims, fmt.Errorf("%w: bad signature", ErrInvalidCredentials)
+	}
+
+	if err := decodeSegment(segments[1], &claims); err != nil {
+		return claims, err
+	}
+
+	return claims, a.checkClaims(claims)
+}
+
+// verifySignature tries the key named by kid, or every key if the token
+// doesn't name one.
+func (a *Authenticator) verifySignature(newHash func() hash.Hash, kid, signed string, signature []byte) bool {
+	if kid != "" {
+		secret, ok := a.jwtKeys[kid]
+
+		return ok && hmac.Equal(sign(newHash, secret, signed), signature)
+	}
+
+	for _, secret := range a.jwtKeys {
+		if hmac.Equal(sign(newHash, secret, signed), signature) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (a *Authenticator) checkClaims(claims Claims) error {
+	now := a.Now()
+
+	switch {
+	case claims.Subject == "":
+		return fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	case !claims.Role.Valid():
+		return fmt.Errorf("%w: token has no valid role", ErrInvalidCredentials)
+	case claims.Tenant != "" && !db.ValidTenant(claims.Tenant):
+		return fmt.Errorf("%w: token has an invalid tenant %q", ErrInvalidCredentials, claims.Tenant)
+	case claims.ExpiresAt == 0:
+		return fmt.Errorf("%w: token doesn't expire", ErrInvalidCredentials)
+	case now.Add(-clockSkew).After(time.Unix(claims.ExpiresAt, 0)):
+		return fmt.Errorf("%w: token expired", ErrInvalidCredentials)
+	case claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidCredentials)
+	case a.Issuer != "" && claims.Issuer != a.Issuer:
+		return fmt.Errorf("%w: token issued by %q", ErrInvalidCredentials, claims.Issuer)
+	case a.Audience != "" && !claims.Audience.contains(a.Audience):
+		return fmt.Errorf("%w: token not meant for %q", ErrInvalidCredentials, a.Audience)
+	}
+
+	return nil
+}
+
+func sign(newHash func() hash.Hash, secret []byte, signed string) []byte {
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(signed))
+
+	return mac.Sum(nil)
+}
+
+func encodeSegment(segment []byte) string {
+	return base64.RawURLEncoding.EncodeToString(segment)
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package auth_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"hash"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//nolint:gochecknoglobals
+var now = time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+func signToken(t *testing.T, claims auth.Claims, kid string, secret []byte) string {
+	t.Helper()
+
+	token, err := auth.SignToken(claims, kid, secret)
+	require.NoError(t, err)
+
+	return token
+}
+
+// forgeToken signs header and payload, both JSON, with newHash, or leaves
+// the signature empty if newHash is nil.
+func forgeToken(header, payload string, newHash func() hash.Hash, secret []byte) string {
+	signed := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+
+	if newHash == nil {
+		return signed + "."
+	}
+
+	mac := hmac.New(newHash, secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifyToken(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	other := []byte("fedcba9876543210fedcba9876543210")
+	authenticator := auth.NewAuthenticator()
+	authenticator.Issuer = "https://id.example.org"
+	authenticator.Audience = "booksdb"
+	authenticator.Now = func() time.Time { return now }
+	require.NoError(t, authenticator.AddJWTKey("k1", secret))
+	require.NoError(t, authenticator.AddJWTKey("k2", other))
+
+	valid := auth.Claims{
+		Subject:   "alice",
+		Role:      auth.RoleLibrarian,
+		Tenant:    "north",
+		Issuer:    "https://id.example.org",
+		Audience:  auth.Audience{"booksdb"},
+		ExpiresAt: now.Add(time.Hour).Unix(),
+		IssuedAt:  now.Unix(),
+	}
+	with := func(change func(claims *auth.Claims)) auth.Claims {
+		claims := valid
+		change(&claims)
+
+		return claims
+	}
+	payload := `{"sub":"alice","role":"librarian","iss":"https://id.example.org","aud":"booksdb","exp":` +
+		strconv.FormatInt(valid.ExpiresAt, 10) + `}`
+	signed := signToken(t, valid, "k1", secret)
+	segments := strings.Split(signed, ".")
+
+	tests := []struct {
+		name  string
+		token string
+		err   bool
+	}{
+		{name: "valid", token: signed},
+		{name: "second key", token: signToken(t, valid, "k2", other)},
+		{name: "no key ID", token: signToken(t, valid, "", other)},
+		{name: "audience list", token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Audience = auth.Audience{"catalogue", "booksdb"}
+		}), "k1", secret)},
+		{name: "HS384", token: forgeToken(`{"alg":"HS384","kid":"k1"}`, payload, sha512.New384, secret)},
+		{name: "HS512", token: forgeToken(`{"alg":"HS512","kid":"k1"}`, payload, sha512.New, secret)},
+		{name: "expired within the clock skew", token: signToken(t, with(func(claims *auth.Claims) {
+			claims.ExpiresAt = now.Add(-30 * time.Second).Unix()
+		}), "k1", secret)},
+		{name: "not before within the clock skew", token: signToken(t, with(func(claims *auth.Claims) {
+			claims.NotBefore = now.Add(30 * time.Second).Unix()
+		}), "k1", secret)},
+
+		{name: "expired", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.ExpiresAt = now.Add(-2 * time.Minute).Unix()
+		}), "k1", secret)},
+		{name: "no expiry", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.ExpiresAt = 0
+		}), "k1", secret)},
+		{name: "not before", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.NotBefore = now.Add(2 * time.Minute).Unix()
+		}), "k1", secret)},
+		{name: "wrong issuer", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Issuer = "https://evil.example.org"
+		}), "k1", secret)},
+		{name: "no issuer", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Issuer = ""
+		}), "k1", secret)},
+		{name: "wrong audience", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Audience = auth.Audience{"catalogue", "loans"}
+		}), "k1", secret)},
+		{name: "no audience", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Audience = nil
+		}), "k1", secret)},
+		{name: "no subject", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Subject = ""
+		}), "k1", secret)},
+		{name: "malformed tenant", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Tenant = "North/../south"
+		}), "k1", secret)},
+		{name: "upper case tenant", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Tenant = "North"
+		}), "k1", secret)},
+		{name: "unknown role", err: true, token: signToken(t, with(func(claims *auth.Claims) {
+			claims.Role = "owner"
+		}), "k1", secret)},
+		{name: "unknown key ID", err: true, token: signToken(t, valid, "k3", secret)},
+		{name: "key ID of another key", err: true, token: signToken(t, valid, "k2", secret)},
+		{name: "unknown key", err: true, token: signToken(t, valid, "", []byte("not the secret of any key at all"))},
+		{name: "alg none", err: true, token: forgeToken(`{"alg":"none","kid":"k1"}`, payload, nil, nil)},
+		{name: "alg none signed", err: true, token: forgeToken(`{"alg":"none","kid":"k1"}`, payload, sha256.New, secret)},
+		{name: "RS256", err: true, token: forgeToken(`{"alg":"RS256","kid":"k1"}`, payload, sha256.New, secret)},
+		{name: "HS384 header, HS256 signature", err: true,
+			token: forgeToken(`{"alg":"HS384","kid":"k1"}`, payload, sha256.New, secret)},
+		{name: "tampered payload", err: true, token: segments[0] + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(payload, "librarian", "admin", 1))) +
+			"." + segments[2]},
+		{name: "tampered signature", err: true, token: segments[0] + "." + segments[1] + "." +
+			base64.RawURLEncoding.EncodeToString([]byte("signature"))},
+		{name: "no signature", err: true, token: segments[0] + "." + segments[1] + "."},
+		{name: "two segments", err: true, token: segments[0] + "." + segments[1]},
+		{name: "malformed header", err: true, token: "e30x." + segments[1] + "." + segments[2]},
+		{name: "empty", err: true, token: ""},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			claims, err := authenticator.VerifyToken(test.token)
+			if test.err {
+				assert.ErrorIs(t, err, auth.ErrInvalidCredentials)
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, "alice", claims.Subject)
+				assert.Equal(t, auth.RoleLibrarian, claims.Role)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package bookio_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/bookio"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testBooks() []*models.Book {
+	created := time.Date(2021, time.July, 1, 12, 0, 0, 0, time.UTC)
+
+	return []*models.Book{
+		{
+			ID:    primitive.NewObjectID(),
+			ISBN:  "9780261103344",
+			Title: "The Hobbit, or \"There and Back Again\"",
+			// the contributors credit the author
+			Author: "Tolkien, J. R. R.",
+			Contributors: []models.Contributor{
+				{Name: "Tolkien, J. R. R.", Role: models.RoleAuthor},
+				{Name: "Alan Lee", Role: models.RoleIllustrator},
+			},
+			Publisher: "Allen & Unwin",
+			Genres:    []string{"fantasy", "children's"},
+			Tags:      []string{"classic"},
+			Dewey:     "823.912",
+			LCC:       "PR6039.O32",
+			Rating:    5,
+			Status:    models.CheckedIn,
+			CreatedAt: created,
+			UpdatedAt: created.Add(time.Hour),
+		},
+		{
+			ID:           primitive.NewObjectID(),
+			Title:        "Notes\nacross lines",
+			Author:       "Anonymous",
+			Contributors: []models.Contributor{{Name: "Anonymous", Role: models.RoleAuthor}},
+			Status:       models.CheckedOut,
+			CreatedAt:    created,
+			UpdatedAt:    created,
+		},
+	}
+}
+
+func write(t *testing.T, format bookio.Format, books []*models.Book) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+
+	writer, err := bookio.NewWriter(&buffer, format)
+	require.NoError(t, err)
+
+	for _, book := range books {
+		require.NoError(t, writer.Write(book))
+	}
+
+	require.NoError(t, writer.Close())
+
+	return buffer.Bytes()
+}
+
+func readAll(t *testing.T, format bookio.Format, input string) []bookio.Record {
+	t.Helper()
+
+	reader, err := bookio.NewReader(strings.NewReader(input), format)
+	require.NoError(t, err)
+
+	var records []bookio.Record
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	books := testBooks()
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+
+		records := readAll(t, bookio.FormatCSV, string(write(t, bookio.FormatCSV, books)))
+		require.Len(t, records, len(books))
+
+		// the second book spans two lines
+		for i, line := range []int{2, 3} {
+			assert.Equal(t, line, records[i].Line)
+			assert.NoError(t, records[i].Err)
+
+			// the fields set by the server are ignored
+			want := *books[i]
+			want.ID, want.CreatedAt, want.UpdatedAt = primitive.NilObjectID, time.Time{}, time.Time{}
+			assert.Equal(t, &want, records[i].Book)
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		t.Parallel()
+
+		records := readAll(t, bookio.FormatNDJSON, string(write(t, bookio.FormatNDJSON, books)))
+		require.Len(t, records, len(books))
+
+		for i, book := range books {
+			assert.Equal(t, i+1, records[i].Line)
+			assert.NoError(t, records[i].Err)
+			assert.Equal(t, book, records[i].Book)
+		}
+	})
+}
+
+func TestWriteEmpty(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, strings.Join(bookio.ExportColumns, ",")+"\n", string(write(t, bookio.FormatCSV, nil)))
+	assert.Empty(t, write(t, bookio.FormatNDJSON, nil))
+}
+
+func TestReadCSV(t *testing.T) {
+	t.Parallel()
+
+	input := "Title, Rating ,contributors\n" +
+		"Beowulf,,Seamus Heaney (Translator); Anonymous\n" +
+		"Dune,five,\n" +
+		"Emma\n" +
+		"\"Ulysses,3,\n" +
+		"Middlemarch,4,\n"
+	records := readAll(t, bookio.FormatCSV, input)
+
+	require.Len(t, records, 4)
+
+	assert.Equal(t, 2, records[0].Line)
+	assert.NoError(t, records[0].Err)
+	assert.Equal(t, &models.Book{Title: "Beowulf", Contributors: []models.Contributor{
+		{Name: "Seamus Heaney", Role: models.RoleTranslator},
+		{Name: "Anonymous", Role: models.RoleAuthor},
+	}}, records[0].Book)
+
+	assert.Equal(t, 3, records[1].Line)
+	assert.EqualError(t, records[1].Err, "rating must be an integer")
+	assert.Nil(t, records[1].Book)
+
+	assert.Equal(t, 4, records[2].Line)
+	assert.EqualError(t, records[2].Err, "expected 3 fields, got 1")
+
+	// the unterminated quote swallows the rest of the input
+	assert.Equal(t, 5, records[3].Line)
+	assert.Error(t, records[3].Err)
+	assert.Nil(t, records[3].Book)
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{name: "empty", input: "", err: "CSV header is missing"},
+		{name: "blank", input: "\n\n", err: "CSV header is missing"},
+		{name: "unknown column", input: "title,pages\nx,1\n", err: `unknown CSV column "pages"`},
+		{name: "malformed", input: "\"title\n", err: "can't read CSV header"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := bookio.NewReader(strings.NewReader(test.input), bookio.FormatCSV)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), test.err)
+		})
+	}
+
+	// a header alone has no records, the read-only columns are ignored
+	records := readAll(t, bookio.FormatCSV, strings.Join(bookio.ExportColumns, ",")+"\n")
+	assert.Empty(t, records)
+}
+
+func TestReadNDJSON(t *testing.T) {
+	t.Parallel()
+
+	input := "{\"title\": \"Beowulf\", \"rating\": 4}\n" +
+		"\n" +
+		"{\"title\": \"Dune\"\n" +
+		"[1, 2]\n" +
+		"  \n" +
+		"{\"title\": \"Emma\"}"
+	records := readAll(t, bookio.FormatNDJSON, input)
+
+	require.Len(t, records, 4)
+
+	assert.Equal(t, 1, records[0].Line)
+	assert.NoError(t, records[0].Err)
+	assert.Equal(t, &models.Book{Title: "Beowulf", Rating: 4}, records[0].Book)
+
+	for i, line := range []int{3, 4} {
+		assert.Equal(t, line, records[i+1].Line)
+		assert.Error(t, records[i+1].Err)
+		assert.Contains(t, records[i+1].Err.Error(), "invalid JSON")
+		assert.Nil(t, records[i+1].Book)
+	}
+
+	// the last line needs no newline
+	assert.Equal(t, 6, records[3].Line)
+	assert.Equal(t, &models.Book{Title: "Emma"}, records[3].Book)
+}
+
+func TestFormats(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{"csv", "NDJSON", "xlsx"} {
+		format, err := bookio.ParseFormat(name)
+		assert.NoError(t, err)
+		assert.Equal(t, bookio.Format(strings.ToLower(name)), format)
+
+		mimeFormat, err := bookio.FormatFromMIMEType(format.MIMEType() + "; charset=utf-8")
+		assert.NoError(t, err)
+		assert.Equal(t, format, mimeFormat)
+	}
+
+	format, err := bookio.FormatFromMIMEType("application/jsonl")
+	assert.NoError(t, err)
+	assert.Equal(t, bookio.FormatNDJSON, format)
+
+	_, err = bookio.ParseFormat("pdf")
+	assert.ErrorIs(t, err, bookio.ErrUnknownFormat)
+
+	_, err = bookio.FormatFromMIMEType("application/json")
+	assert.ErrorIs(t, err, bookio.ErrUnknownFormat)
+
+	// XLSX can only be written
+	_, err = bookio.NewReader(strings.NewReader(""), bookio.FormatXLSX)
+	assert.ErrorIs(t, err, bookio.ErrUnknownFormat)
+
+	_, err = bookio.NewReader(strings.NewReader(""), "pdf")
+	assert.ErrorIs(t, err, bookio.ErrUnknownFormat)
+
+	_, err = bookio.NewWriter(io.Discard, "pdf")
+	assert.ErrorIs(t, err, bookio.ErrUnknownFormat)
+}
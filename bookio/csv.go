@@ -0,0 +1,222 @@
+package bookio
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/iho/booksdb/models"
+)
+
+// CSVColumns are the columns of the CSV format, named like the JSON fields
+// of a book. Lists are separated by semicolons and contributors are written
+// as "Name (Role)", the role defaulting to Author.
+//
+//nolint:gochecknoglobals
+var CSVColumns = []string{
+	"isbn", "title", "author", "contributors", "publisher", "genres", "tags", "dewey", "lcc", "rating", "status",
+}
+
+const listSeparator = ";"
+
+// csvReader reads a book per record after a header naming the columns,
+// which may come in any order and be a subset of ExportColumns.
+type csvReader struct {
+	reader  *csv.Reader
+	columns []string
+	// line is the line the next record starts on. Quoted fields may span
+	// lines, blank lines are skipped by csv.Reader and not accounted for.
+	line int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("CSV header is missing")
+	} else if err != nil {
+		return nil, fmt.Errorf("can't read CSV header: %w", err)
+	}
+
+	columns := make([]string, len(header))
+
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !isCSVColumn(name) && !isReadOnlyColumn(name) {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+
+		columns[i] = name
+	}
+
+	return &csvReader{reader: reader, columns: columns, line: 2}, nil
+}
+
+func isCSVColumn(name string) bool {
+	for _, column := range CSVColumns {
+		if column == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *csvReader) Read() (Record, error) {
+	fields, err := r.reader.Read()
+
+	var parseErr *csv.ParseError
+
+	switch {
+	case errors.Is(err, io.EOF):
+		return Record{}, io.EOF
+	case errors.As(err, &parseErr):
+		// the reader carries on with the next line
+		record := Record{Line: parseErr.StartLine, Err: parseErr.Err}
+		r.line = parseErr.Line + 1
+
+		return record, nil
+	case err != nil:
+		return Record{}, fmt.Errorf("can't read CSV record: %w", err)
+	}
+
+	record := Record{Line: r.line}
+
+	for _, field := range fields {
+		r.line += strings.Count(field, "\n")
+	}
+
+	r.line++
+
+	if len(fields) != len(r.columns) {
+		record.Err = fmt.Errorf("expected %d fields, got %d", len(r.columns), len(fields))
+		return record, nil
+	}
+
+	record.Book, record.Err = r.decode(fields)
+
+	return record, nil
+}
+
+func (r *csvReader) decode(fields []string) (*models.Book, error) {
+	book := new(models.Book)
+
+	for i, value := range fields {
+		value = strings.TrimSpace(value)
+
+		switch r.columns[i] {
+		case "isbn":
+			book.ISBN = value
+		case "title":
+			book.Title = value
+		case "author":
+			book.Author = value
+		case "contributors":
+			book.Contributors = parseContributors(value)
+		case "publisher":
+			book.Publisher = value
+		case "genres":
+			book.Genres = splitList(value)
+		case "tags":
+			book.Tags = splitList(value)
+		case "dewey":
+			book.Dewey = value
+		case "lcc":
+			book.LCC = value
+		case "rating":
+			if value == "" {
+				continue
+			}
+
+			rating, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, errors.New("rating must be an integer")
+			}
+
+			book.Rating = rating
+		case "status":
+			book.Status = models.BookStatusType(value)
+		}
+	}
+
+	return book, nil
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+
+	var list []string
+
+	for _, item := range strings.Split(value, listSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func parseContributors(value string) []models.Contributor {
+	var contributors []models.Contributor
+
+	for _, item := range splitList(value) {
+		contributor := models.Contributor{Name: item, Role: models.RoleAuthor}
+
+		if strings.HasSuffix(item, ")") {
+			if open := strings.LastIndex(item, "("); open > 0 {
+				contributor.Name = strings.TrimSpace(item[:open])
+				contributor.Role = models.ContributorRole(strings.TrimSpace(item[open+1 : len(item)-1]))
+			}
+		}
+
+		contributors = append(contributors, contributor)
+	}
+
+	return contributors
+}
+
+// csvWriter writes a header of ExportColumns and a record per book.
+type csvWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(ExportColumns); err != nil {
+		return nil, fmt.Errorf("can't write CSV header: %w", err)
+	}
+
+	return &csvWriter{writer: writer, record: make([]string, len(ExportColumns))}, nil
+}
+
+func (w *csvWriter) Write(book *models.Book) error {
+	for i, column := range ExportColumns {
+		w.record[i] = columnValue(book, column)
+	}
+
+	if err := w.writer.Write(w.record); err != nil {
+		return fmt.Errorf("can't write CSV record: %w", err)
+	}
+
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+
+	if err := w.writer.Error(); err != nil {
+		return fmt.Errorf("can't write CSV record: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package bookio
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// DefaultBatchSize is the number of books Import stores at once.
+const DefaultBatchSize = 500
+
+// ImportRow reports the outcome of a single line of the input.
+type ImportRow struct {
+	Line   int                 `json:"line"`
+	ID     string              `json:"id,omitempty"`
+	Error  string              `json:"error,omitempty"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// ImportReport lists the rows in input order.
+type ImportReport struct {
+	Imported int         `json:"imported"`
+	Failed   int         `json:"failed"`
+	Rows     []ImportRow `json:"rows"`
+}
+
+// fail records the error of the row at index i of Rows.
+func (report *ImportReport) fail(i int, err error) {
+	report.Failed++
+	report.Rows[i].Error = err.Error()
+
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		report.Rows[i].Errors = validationErr.Fields
+	}
+}
+
+// Import validates and normalizes every book read from reader like a
+// created book and stores the valid ones in batches of batchSize. A failing
+// row doesn't stop the import. The report covers every row handled before
+// an error that ends the import early.
+func Import(ctx context.Context, repo db.BookRepository, reader Reader, batchSize int) (*ImportReport, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	report := &ImportReport{Rows: make([]ImportRow, 0)}
+	batch := make([]*models.Book, 0, batchSize)
+	// rows holds the index of the report row of every book in the batch
+	rows := make([]int, 0, batchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		errs, err := repo.AddBooks(ctx, batch, db.BatchBestEffort)
+		if err != nil {
+			for _, row := range rows {
+				report.fail(row, err)
+			}
+
+			return err
+		}
+
+		for i, book := range batch {
+			if errs[i] != nil {
+				report.fail(rows[i], errs[i])
+			} else {
+				report.Imported++
+				report.Rows[rows[i]].ID = book.ID.Hex()
+			}
+		}
+
+		batch, rows = batch[:0], rows[:0]
+
+		return nil
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return report, err
+		}
+
+		report.Rows = append(report.Rows, ImportRow{Line: record.Line})
+
+		if record.Err == nil {
+			record.Err = record.Book.Validate()
+		}
+
+		if record.Err != nil {
+			report.fail(len(report.Rows)-1, record.Err)
+			continue
+		}
+
+		record.Book.Normalize()
+		batch = append(batch, record.Book)
+		rows = append(rows, len(report.Rows)-1)
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
@@ -0,0 +1,75 @@
+package bookio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/iho/booksdb/models"
+)
+
+// ndjsonReader reads a book per line. Blank lines are skipped.
+type ndjsonReader struct {
+	reader *bufio.Reader
+	line   int
+}
+
+func newNDJSONReader(r io.Reader) *ndjsonReader {
+	return &ndjsonReader{reader: bufio.NewReader(r)}
+}
+
+func (r *ndjsonReader) Read() (Record, error) {
+	for {
+		line, err := r.reader.ReadBytes('\n')
+		if len(line) == 0 && errors.Is(err, io.EOF) {
+			return Record{}, io.EOF
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return Record{}, fmt.Errorf("can't read line %d: %w", r.line+1, err)
+		}
+
+		r.line++
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		record := Record{Line: r.line, Book: new(models.Book)}
+		if err := json.Unmarshal(line, record.Book); err != nil {
+			record.Book, record.Err = nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+
+		return record, nil
+	}
+}
+
+// ndjsonWriter writes a book per line in the JSON shape of the API.
+type ndjsonWriter struct {
+	buffer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buffer := bufio.NewWriter(w)
+
+	return &ndjsonWriter{buffer: buffer, encoder: json.NewEncoder(buffer)}
+}
+
+func (w *ndjsonWriter) Write(book *models.Book) error {
+	if err := w.encoder.Encode(book); err != nil {
+		return fmt.Errorf("can't write a book: %w", err)
+	}
+
+	return nil
+}
+
+func (w *ndjsonWriter) Close() error {
+	if err := w.buffer.Flush(); err != nil {
+		return fmt.Errorf("can't write a book: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+// Package bookio reads and writes books in the file formats used for bulk
+// transfers.
+package bookio
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/iho/booksdb/models"
+)
+
+type Format string
+
+// Formats. XLSX can only be written.
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatXLSX   Format = "xlsx"
+)
+
+var ErrUnknownFormat = errors.New("unknown format")
+
+const (
+	CSVMIMEType    = "text/csv"
+	NDJSONMIMEType = "application/x-ndjson"
+	XLSXMIMEType   = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+)
+
+// FormatFromMIMEType maps a media type, parameters allowed, onto a format.
+func FormatFromMIMEType(mimeType string) (Format, error) {
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+
+	switch strings.ToLower(mimeType) {
+	case CSVMIMEType:
+		return FormatCSV, nil
+	case NDJSONMIMEType, "application/jsonl", "application/x-jsonlines":
+		return FormatNDJSON, nil
+	case XLSXMIMEType:
+		return FormatXLSX, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, mimeType)
+}
+
+// MIMEType returns the media type of files in the format.
+func (format Format) MIMEType() string {
+	switch format {
+	case FormatCSV:
+		return CSVMIMEType
+	case FormatNDJSON:
+		return NDJSONMIMEType
+	case FormatXLSX:
+		return XLSXMIMEType
+	}
+
+	return "application/octet-stream"
+}
+
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case FormatCSV, FormatNDJSON, FormatXLSX:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// Record is a book read from the line Line of the input. Err is set instead
+// of Book if the line can't be decoded.
+type Record struct {
+	Line int
+	Book *models.Book
+	Err  error
+}
+
+// Reader streams books. Read returns io.EOF after the last record and any
+// other error if the input can't be read any further.
+type Reader interface {
+	Read() (Record, error)
+}
+
+// NewReader fails with ErrUnknownFormat for formats that can't be read.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVReader(r)
+	case FormatNDJSON:
+		return newNDJSONReader(r), nil
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
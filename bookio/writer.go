@@ -0,0 +1,100 @@
+package bookio
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+)
+
+// ExportColumns are the columns written to CSV and XLSX files: CSVColumns
+// framed by the fields set by the server, which are ignored on import.
+//
+//nolint:gochecknoglobals
+var ExportColumns = append(append([]string{"id"}, CSVColumns...), "created_at", "updated_at")
+
+// Writer streams books. Close writes whatever the format needs after the
+// last book and must be called for the output to be complete. It doesn't
+// close the underlying io.Writer.
+type Writer interface {
+	Write(book *models.Book) error
+	Close() error
+}
+
+func NewWriter(w io.Writer, format Format) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatNDJSON:
+		return newNDJSONWriter(w), nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+func isReadOnlyColumn(name string) bool {
+	switch name {
+	case "id", "created_at", "updated_at":
+		return true
+	}
+
+	return false
+}
+
+// columnValue formats a field of the book the way the CSV reader parses it.
+func columnValue(book *models.Book, column string) string {
+	switch column {
+	case "id":
+		return book.ID.Hex()
+	case "isbn":
+		return book.ISBN
+	case "title":
+		return book.Title
+	case "author":
+		return book.Author
+	case "contributors":
+		return formatContributors(book.Contributors)
+	case "publisher":
+		return book.Publisher
+	case "genres":
+		return strings.Join(book.Genres, listSeparator+" ")
+	case "tags":
+		return strings.Join(book.Tags, listSeparator+" ")
+	case "dewey":
+		return book.Dewey
+	case "lcc":
+		return book.LCC
+	case "rating":
+		return strconv.Itoa(book.Rating)
+	case "status":
+		return string(book.Status)
+	case "created_at":
+		return formatTime(book.CreatedAt)
+	case "updated_at":
+		return formatTime(book.UpdatedAt)
+	}
+
+	return ""
+}
+
+func formatContributors(contributors []models.Contributor) string {
+	items := make([]string, 0, len(contributors))
+	for _, contributor := range contributors {
+		items = append(items, contributor.Name+" ("+string(contributor.Role)+")")
+	}
+
+	return strings.Join(items, listSeparator+" ")
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,164 @@
+package bookio
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/iho/booksdb/models"
+)
+
+// The smallest package spreadsheet applications open: a workbook with a
+// single sheet. Strings are stored inline, so there is no shared string
+// table that would have to be built before the sheet.
+const (
+	xlsxContentTypes = xml.Header +
+		`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ` +
+		`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`
+	xlsxRelationships = xml.Header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" ` +
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" ` +
+		`Target="xl/workbook.xml"/>` +
+		`</Relationships>`
+	xlsxWorkbook = xml.Header +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Books" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`
+	xlsxWorkbookRelationships = xml.Header +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" ` +
+		`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" ` +
+		`Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`
+	xlsxSheetStart = xml.Header +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxWriter writes the sheet as the last entry of the archive, row by row.
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	row     int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRelationships},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	}
+
+	for _, part := range parts {
+		entry, err := archive.Create(part.name)
+		if err != nil {
+			return nil, fmt.Errorf("can't write XLSX: %w", err)
+		}
+
+		if _, err := io.WriteString(entry, part.content); err != nil {
+			return nil, fmt.Errorf("can't write XLSX: %w", err)
+		}
+	}
+
+	entry, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	writer := &xlsxWriter{archive: archive, sheet: bufio.NewWriter(entry)}
+
+	if _, err := writer.sheet.WriteString(xlsxSheetStart); err != nil {
+		return nil, fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	if err := writer.writeRow(ExportColumns, nil); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+func (w *xlsxWriter) Write(book *models.Book) error {
+	values := make([]string, len(ExportColumns))
+	for i, column := range ExportColumns {
+		values[i] = columnValue(book, column)
+	}
+
+	return w.writeRow(values, func(column string) bool { return column == "rating" })
+}
+
+// writeRow writes the values as strings, except for the columns numeric
+// reports as numbers.
+func (w *xlsxWriter) writeRow(values []string, numeric func(column string) bool) error {
+	w.row++
+	row := strconv.Itoa(w.row)
+
+	var builder strings.Builder
+
+	builder.WriteString(`<row r="` + row + `">`)
+
+	for i, value := range values {
+		ref := xlsxColumn(i) + row
+
+		if numeric != nil && numeric(ExportColumns[i]) {
+			builder.WriteString(`<c r="` + ref + `"><v>` + value + `</v></c>`)
+			continue
+		}
+
+		builder.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t xml:space="preserve">`)
+		// EscapeText replaces the characters XML can't carry and only fails
+		// if writing does, which a strings.Builder never does
+		_ = xml.EscapeText(&builder, []byte(value))
+		builder.WriteString(`</t></is></c>`)
+	}
+
+	builder.WriteString(`</row>`)
+
+	if _, err := w.sheet.WriteString(builder.String()); err != nil {
+		return fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	return nil
+}
+
+// xlsxColumn returns the letters naming the column at index i: A to Z,
+// then AA and so on.
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+
+	return name
+}
+
+func (w *xlsxWriter) Close() error {
+	if _, err := w.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	if err := w.sheet.Flush(); err != nil {
+		return fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	if err := w.archive.Close(); err != nil {
+		return fmt.Errorf("can't write XLSX: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/iho/booksdb"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/db"
+)
+
+var errNoKeys = errors.New("no API keys or JWT keys are configured, " +
+	"set APP_AUTH_DISABLED=true to run without authentication")
+
+// newAuthenticator sets up the keys of the configuration. Authentication
+// is only left out if the configuration disables it explicitly.
+func newAuthenticator(config booksdb.Config) (*auth.Authenticator, error) {
+	authenticator := auth.NewAuthenticator()
+	authenticator.Issuer = config.JWTIssuer
+	authenticator.Audience = config.JWTAudience
+
+	for name, entry := range config.APIKeys {
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("API key %q has to be given as name:role:key or name:role@tenant:key", name)
+		}
+
+		// a key given as name:role@tenant:key is bound to the tenant
+		role := strings.SplitN(parts[0], "@", 2)
+		principal := auth.Principal{Subject: name, Role: auth.Role(role[0])}
+
+		if len(role) == 2 {
+			if !db.ValidTenant(role[1]) {
+				return nil, fmt.Errorf("API key %q has an invalid tenant %q", name, role[1])
+			}
+
+			principal.Tenant = role[1]
+		}
+
+		if err := authenticator.AddAPIKey(parts[1], principal); err != nil {
+			return nil, err
+		}
+	}
+
+	for kid, secret := range config.JWTKeys {
+		if err := authenticator.AddJWTKey(kid, []byte(secret)); err != nil {
+			return nil, err
+		}
+	}
+
+	if authenticator.Empty() {
+		return nil, errNoKeys
+	}
+
+	return authenticator, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/iho/booksdb"
+	"github.com/iho/booksdb/bookio"
+	"github.com/iho/booksdb/db"
+)
+
+// runImport implements
+//
+//	booksdb import [-format csv|ndjson] [-batch 500] [-tenant default] FILE
+//
+// which imports books straight into the configured backend. FILE may be
+// "-" for the standard input, the format defaults to the file extension.
+func runImport(config booksdb.Config, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	formatName := flags.String("format", "", "input format, csv or ndjson")
+	batchSize := flags.Int("batch", bookio.DefaultBatchSize, "number of books stored at once")
+	tenant := flags.String("tenant", db.DefaultTenant, "tenant the books belong to")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: booksdb import [-format csv|ndjson] [-batch n] [-tenant name] FILE")
+	}
+
+	if !db.ValidTenant(*tenant) {
+		return fmt.Errorf("invalid tenant %q", *tenant)
+	}
+
+	path := flags.Arg(0)
+
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+
+	format, err := bookio.ParseFormat(*formatName)
+	if err != nil {
+		return fmt.Errorf("%w, pass -format", err)
+	}
+
+	var input io.Reader = os.Stdin
+
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("can't open input: %w", err)
+		}
+		defer file.Close()
+
+		input = file
+	}
+
+	reader, err := bookio.NewReader(input, format)
+	if err != nil {
+		return err
+	}
+
+	ctx := db.WithTenant(context.Background(), *tenant)
+
+	repositories, err := newRepositories(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer repositories.Close(ctx)
+
+	report, err := bookio.Import(ctx, repositories.Books, reader, *batchSize)
+
+	for _, row := range report.Rows {
+		if row.Error != "" {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", row.Line, row.Error)
+		}
+	}
+
+	fmt.Printf("imported %d books, %d failed\n", report.Imported, report.Failed)
+
+	if err != nil {
+		return err
+	}
+
+	if report.Failed > 0 {
+		return fmt.Errorf("%d rows failed", report.Failed)
+	}
+
+	return nil
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -11,6 +15,8 @@ import (
 	"github.com/iho/booksdb"
 	"github.com/iho/booksdb/db"
 	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	_ "github.com/lib/pq"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.uber.org/zap"
@@ -24,28 +30,120 @@ func main() {
 	}
 	config := booksdb.GetConfig()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(config, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// a second signal stops the process right away
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	startCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.MongoDBURL))
+
+	repositories, err := newRepositories(startCtx, config)
 	if err != nil {
-		panic(fmt.Errorf("can't connect to database: %w", err))
+		panic(err)
 	}
 
 	log = zapr.NewLogger(zapLog)
 	app := handlers.NewApp(
-		db.NewMongoDBBookRepository(client),
+		repositories,
 		log,
 	)
 
+	app.FinePolicy = models.FinePolicy{
+		DailyRate:   config.FineDailyRate,
+		GracePeriod: config.FineGracePeriod,
+		Cap:         config.FineCap,
+	}
+	app.TrashRetention = config.TrashRetention
+	app.TenantDomain = config.TenantDomain
+	app.StreamTimeout = config.StreamTimeout
+
+	if config.AuthDisabled {
+		log.Info("authentication is disabled, every request is made by an admin")
+	} else if app.Authenticator, err = newAuthenticator(config); err != nil {
+		panic(err)
+	}
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	waitJobs := runScheduler(jobsCtx, log,
+		job{name: "expire holds", interval: config.HoldExpiryInterval, run: app.ExpireHolds},
+		job{name: "assess fines", interval: config.FineInterval, run: app.AssessFines},
+		job{name: "purge trash", interval: config.PurgeInterval, run: app.PurgeTrash},
+	)
+
 	server := &http.Server{
 		Addr:         fmt.Sprintf("0.0.0.0:%d", config.Port),
 		Handler:      handlers.SetupRouter(app),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		// lets imports and exports outlast the timeouts
+		ConnContext: handlers.ConnContext,
 	}
 
-	err = server.ListenAndServe()
-	if err != nil {
-		panic(err.Error())
+	serveErr := serve(ctx, log, server, config.DrainPeriod)
+
+	// the repositories are closed once nothing uses them anymore
+	stopJobs()
+	waitJobs()
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), config.DrainPeriod)
+	defer cancelClose()
+
+	if err := repositories.Close(closeCtx); err != nil {
+		log.Error(err, "can't close the repositories")
+	}
+
+	if serveErr != nil {
+		log.Error(serveErr, "server stopped")
+		os.Exit(1) //nolint:gocritic
+	}
+
+	log.Info("server stopped")
+}
+
+func newRepositories(ctx context.Context, config booksdb.Config) (db.Repositories, error) {
+	var repos db.Repositories
+
+	switch config.Backend {
+	case booksdb.BackendMongoDB:
+		client, err := mongo.Connect(ctx, options.Client().ApplyURI(config.MongoDBURL))
+		if err != nil {
+			return repos, fmt.Errorf("can't connect to database: %w", err)
+		}
+
+		repos = db.NewMongoDBRepositories(client)
+	case booksdb.BackendPostgres:
+		sqlDB, err := sql.Open("postgres", config.PostgresURL)
+		if err != nil {
+			return repos, fmt.Errorf("can't connect to database: %w", err)
+		}
+
+		repos = db.NewPostgresRepositories(sqlDB)
+	case booksdb.BackendFile:
+		return db.NewFileRepositories(config.DataDir)
+	default:
+		return repos, fmt.Errorf("unknown backend %q", config.Backend)
 	}
+
+	if err := repos.Migrate(ctx); err != nil {
+		// the repositories own the client by now
+		repos.Close(context.Background()) //nolint:errcheck
+
+		return repos, err
+	}
+
+	return repos, nil
 }
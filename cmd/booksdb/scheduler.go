@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// job is a background task run by the scheduler. run returns the number of
+// records it changed.
+type job struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context, now time.Time) (int, error)
+}
+
+// runScheduler starts every job on its own interval. The jobs stop when ctx
+// is done, a run in progress is cancelled. The returned function waits
+// until every job has stopped.
+func runScheduler(ctx context.Context, log logr.Logger, jobs ...job) (wait func()) {
+	var wg sync.WaitGroup
+
+	for _, job := range jobs {
+		job := job
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			job.loop(ctx, log.WithValues("job", job.name))
+		}()
+	}
+
+	return wg.Wait
+}
+
+func (job job) loop(ctx context.Context, log logr.Logger) {
+	ticker := time.NewTicker(job.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			changed, err := job.run(ctx, now.UTC())
+			if err != nil && ctx.Err() != nil {
+				log.Info("job cancelled")
+			} else if err != nil {
+				log.Error(err, "job failed")
+			} else if changed > 0 {
+				log.Info("job done", "changed", changed)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// serve runs server until ctx is done and then shuts it down: it stops
+// accepting connections and waits up to drain for the requests in flight.
+// Connections still busy after that are closed.
+func serve(ctx context.Context, log logr.Logger, server *http.Server, drain time.Duration) error {
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	log.Info("listening", "addr", server.Addr)
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("can't serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	log.Info("shutting down, draining requests", "drain", drain)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close() //nolint:errcheck
+
+		return fmt.Errorf("requests still running after %s: %w", drain, err)
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("can't serve: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/iho/booksdb/models"
+)
+
+// MaxBatchSize is the largest number of items a batch may have.
+const MaxBatchSize = 1000
+
+// BatchMode decides what happens to a batch when one of its items fails.
+type BatchMode string
+
+const (
+	// BatchAtomic applies every item or none. The first failing item stops
+	// the batch, the items after it aren't attempted.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies every item that can be applied.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+// ErrBatchAborted is returned by an atomic batch that was rolled back. It
+// is also the error of every item that didn't fail itself.
+var ErrBatchAborted = errors.New("batch aborted")
+
+func (mode BatchMode) Valid() bool {
+	return mode == BatchAtomic || mode == BatchBestEffort
+}
+
+// BookChange updates a single book of a batch like UpdateBook.
+type BookChange struct {
+	ID     ID
+	Update func(book *models.Book) (*models.Book, error)
+}
+
+// BookDeletion deletes a single book of a batch. A Version other than 0
+// deletes the book only if it is still at that version.
+type BookDeletion struct {
+	ID      ID
+	Version int64
+}
+
+// abortBatch returns the item errors of a rolled back batch: the error of
+// the failing item and ErrBatchAborted for the others.
+func abortBatch(errs []error) []error {
+	for i, err := range errs {
+		if err == nil {
+			errs[i] = ErrBatchAborted
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/iho/booksdb/models"
+)
+
+// ContributorQuery lists the distinct contributors of all books, people
+// are told apart by name. A Role restricts the list to the books the
+// contributors have that role in.
+type ContributorQuery struct {
+	Role   models.ContributorRole
+	Limit  int
+	Cursor string
+}
+
+type ContributorCount struct {
+	Name     string
+	SortName string
+	// Roles lists the roles of the contributor in alphabetical order.
+	Roles []models.ContributorRole
+	Books int
+}
+
+// ContributorPage holds contributors ordered by sort name and name.
+type ContributorPage struct {
+	Contributors []ContributorCount
+	NextCursor   string
+}
+
+func (query ContributorQuery) normalize() (ContributorQuery, int, error) {
+	query.Limit = pageLimit(query.Limit)
+
+	offset, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return query, 0, err
+	}
+
+	return query, offset, nil
+}
+
+// matchesContributor reports whether the book credits a contributor with
+// the name and role of the filter.
+func matchesContributor(book *models.Book, filter BookFilter) bool {
+	if filter.Contributor == "" && filter.Role == "" {
+		return true
+	}
+
+	for _, contributor := range book.Credits() {
+		if (filter.Contributor == "" || contributor.Name == filter.Contributor) &&
+			(filter.Role == "" || contributor.Role == filter.Role) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countContributors groups the credits of books by contributor name. Books
+// may file a contributor under different sort names, the lowest one is
+// used.
+func countContributors(books []*models.Book, role models.ContributorRole) []ContributorCount {
+	counts := make(map[string]*ContributorCount)
+	roles := make(map[string]map[models.ContributorRole]bool)
+
+	for _, book := range books {
+		credited := make(map[string]bool)
+
+		for _, contributor := range book.Credits() {
+			if role != "" && contributor.Role != role {
+				continue
+			}
+
+			count, ok := counts[contributor.Name]
+			if !ok {
+				count = &ContributorCount{Name: contributor.Name, SortName: contributor.SortName}
+				counts[contributor.Name] = count
+				roles[contributor.Name] = make(map[models.ContributorRole]bool)
+			}
+
+			if contributor.SortName < count.SortName {
+				count.SortName = contributor.SortName
+			}
+
+			if !roles[contributor.Name][contributor.Role] {
+				roles[contributor.Name][contributor.Role] = true
+				count.Roles = append(count.Roles, contributor.Role)
+			}
+
+			// a book crediting someone twice, e.g. as author and
+			// illustrator, counts once
+			if !credited[contributor.Name] {
+				credited[contributor.Name] = true
+				count.Books++
+			}
+		}
+	}
+
+	contributors := make([]ContributorCount, 0, len(counts))
+
+	for _, count := range counts {
+		sort.Slice(count.Roles, func(i, j int) bool { return count.Roles[i] < count.Roles[j] })
+		contributors = append(contributors, *count)
+	}
+
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].SortName != contributors[j].SortName {
+			return contributors[i].SortName < contributors[j].SortName
+		}
+
+		return contributors[i].Name < contributors[j].Name
+	})
+
+	return contributors
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/iho/booksdb/models"
+)
+
+// FacetCount is the number of books having a value of a facet.
+type FacetCount struct {
+	Value string
+	Count int
+}
+
+// BookFacets holds the counts of every facet ordered by descending count
+// and value. Books without a publisher or status aren't counted for those.
+type BookFacets struct {
+	Genres     []FacetCount
+	Tags       []FacetCount
+	Publishers []FacetCount
+	Statuses   []FacetCount
+}
+
+// facetCounter counts the values of a single facet.
+type facetCounter map[string]int
+
+func (counter facetCounter) add(values ...string) {
+	for _, value := range values {
+		if value != "" {
+			counter[value]++
+		}
+	}
+}
+
+func (counter facetCounter) counts() []FacetCount {
+	counts := make([]FacetCount, 0, len(counter))
+	for value, count := range counter {
+		counts = append(counts, FacetCount{Value: value, Count: count})
+	}
+
+	sortFacet(counts)
+
+	return counts
+}
+
+func sortFacet(counts []FacetCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+
+		return counts[i].Value < counts[j].Value
+	})
+}
+
+func countFacets(books []*models.Book) *BookFacets {
+	genres, tags := make(facetCounter), make(facetCounter)
+	publishers, statuses := make(facetCounter), make(facetCounter)
+
+	for _, book := range books {
+		genres.add(book.Genres...)
+		tags.add(book.Tags...)
+		publishers.add(book.Publisher)
+		statuses.add(string(book.Status))
+	}
+
+	return &BookFacets{
+		Genres:     genres.counts(),
+		Tags:       tags.counts(),
+		Publishers: publishers.counts(),
+		Statuses:   statuses.counts(),
+	}
+}
+
+func containsTerm(terms []string, term string) bool {
+	for _, candidate := range terms {
+		if candidate == term {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,310 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/iho/booksdb/models"
+)
+
+// SystemActor is recorded for changes made without an actor in the context,
+// e.g. by scheduled jobs.
+const SystemActor = "system"
+
+// maxDeleteAttempts bounds how often DeleteBook retries when the book
+// changes between reading and deleting it.
+const maxDeleteAttempts = 5
+
+type historyContextKey int
+
+const (
+	actorKey historyContextKey = iota
+	revertKey
+)
+
+// WithActor returns a context whose book changes are recorded as made by
+// actor.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor set by WithActor or SystemActor.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+
+	return SystemActor
+}
+
+// WithRevert returns a context whose book updates are recorded as reverts
+// to the given version.
+func WithRevert(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, revertKey, version)
+}
+
+// HistoryBookRepository records a revision in History for every book it
+// creates, updates, deletes or restores. A change is recorded after it has
+// been stored, if that fails the change stays and the error is returned.
+// Purged books keep their history.
+type HistoryBookRepository struct {
+	BookRepository
+	History HistoryRepository
+}
+
+func NewHistoryBookRepository(books BookRepository, history HistoryRepository) HistoryBookRepository {
+	return HistoryBookRepository{
+		BookRepository: books,
+		History:        history,
+	}
+}
+
+// Migrate and Close pass through to the wrapped repository.
+func (repo HistoryBookRepository) Migrate(ctx context.Context) error {
+	if books, ok := repo.BookRepository.(migrator); ok {
+		return books.Migrate(ctx)
+	}
+
+	return nil
+}
+
+func (repo HistoryBookRepository) Close() error {
+	if books, ok := repo.BookRepository.(closer); ok {
+		return books.Close()
+	}
+
+	return nil
+}
+
+func (repo HistoryBookRepository) AddBook(ctx context.Context, book *models.Book) (ID, error) {
+	id, err := repo.BookRepository.AddBook(ctx, book)
+	if err != nil {
+		return id, err
+	}
+
+	return id, repo.record(ctx, models.RevisionCreate, nil, book)
+}
+
+func (repo HistoryBookRepository) AddBooks(ctx context.Context, books []*models.Book, mode BatchMode) ([]error, error) {
+	errs, err := repo.BookRepository.AddBooks(ctx, books, mode)
+	if err != nil {
+		return errs, err
+	}
+
+	for i, book := range books {
+		if errs[i] != nil {
+			continue
+		}
+
+		if err := repo.record(ctx, models.RevisionCreate, nil, book); err != nil {
+			return errs, err
+		}
+	}
+
+	return errs, nil
+}
+
+func (repo HistoryBookRepository) UpdateBook(
+	ctx context.Context,
+	id ID,
+	updateFn func(book *models.Book) (*models.Book, error),
+) (*models.Book, error) {
+	var before *models.Book
+
+	book, err := repo.BookRepository.UpdateBook(ctx, id, func(book *models.Book) (*models.Book, error) {
+		// updateFn may change book, and runs again if the update is retried
+		before = copyBook(book)
+
+		return updateFn(book)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return book, repo.record(ctx, models.RevisionUpdate, before, book)
+}
+
+func (repo HistoryBookRepository) UpdateBooks(
+	ctx context.Context,
+	changes []BookChange,
+	mode BatchMode,
+) ([]*models.Book, []error, error) {
+	befores := make([]*models.Book, len(changes))
+	wrapped := make([]BookChange, len(changes))
+
+	for i, change := range changes {
+		i, update := i, change.Update
+		wrapped[i] = BookChange{
+			ID: change.ID,
+			Update: func(book *models.Book) (*models.Book, error) {
+				befores[i] = copyBook(book)
+
+				return update(book)
+			},
+		}
+	}
+
+	books, errs, err := repo.BookRepository.UpdateBooks(ctx, wrapped, mode)
+	if err != nil {
+		return books, errs, err
+	}
+
+	for i, book := range books {
+		if errs[i] != nil {
+			continue
+		}
+
+		if err := repo.record(ctx, models.RevisionUpdate, befores[i], book); err != nil {
+			return books, errs, err
+		}
+	}
+
+	return books, errs, nil
+}
+
+// DeleteBook deletes the book at the version it was read at, so that the
+// revision holds exactly the state that went to the trash. It starts over
+// if the book changes in between.
+func (repo HistoryBookRepository) DeleteBook(ctx context.Context, id ID) error {
+	for attempt := 1; ; attempt++ {
+		book, err := repo.BookRepository.GetBook(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		err = repo.BookRepository.DeleteBookVersion(ctx, id, book.Version)
+		if errors.Is(err, ErrVersionMismatch) && attempt < maxDeleteAttempts {
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		return repo.recordDeletion(ctx, book)
+	}
+}
+
+func (repo HistoryBookRepository) DeleteBookVersion(ctx context.Context, id ID, version int64) error {
+	book, err := repo.BookRepository.GetBook(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if book.Version != version {
+		return ErrVersionMismatch
+	}
+
+	if err := repo.BookRepository.DeleteBookVersion(ctx, id, version); err != nil {
+		return err
+	}
+
+	return repo.recordDeletion(ctx, book)
+}
+
+// DeleteBooks pins deletions without a version to the version read before
+// the batch, a book changed in between fails with ErrVersionMismatch.
+func (repo HistoryBookRepository) DeleteBooks(ctx context.Context, deletions []BookDeletion, mode BatchMode) ([]error, error) {
+	books := make([]*models.Book, len(deletions))
+	pinned := make([]BookDeletion, len(deletions))
+
+	for i, deletion := range deletions {
+		pinned[i] = deletion
+
+		// missing books are left for the batch to report
+		book, err := repo.BookRepository.GetBook(ctx, deletion.ID)
+		if err != nil {
+			continue
+		}
+
+		books[i] = book
+
+		if deletion.Version == 0 {
+			pinned[i].Version = book.Version
+		}
+	}
+
+	errs, err := repo.BookRepository.DeleteBooks(ctx, pinned, mode)
+	if err != nil {
+		return errs, err
+	}
+
+	for i, book := range books {
+		if errs[i] != nil || book == nil {
+			continue
+		}
+
+		if err := repo.recordDeletion(ctx, book); err != nil {
+			return errs, err
+		}
+	}
+
+	return errs, nil
+}
+
+func (repo HistoryBookRepository) RestoreBook(ctx context.Context, id ID) (*models.Book, error) {
+	book, err := repo.BookRepository.RestoreBook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return book, repo.record(ctx, models.RevisionRestore, book, book)
+}
+
+// recordDeletion records the deletion of book, which was at the version
+// that got deleted.
+func (repo HistoryBookRepository) recordDeletion(ctx context.Context, book *models.Book) error {
+	now := time.Now().UTC()
+	deleted := copyBook(book)
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+
+	return repo.record(ctx, models.RevisionDelete, book, deleted)
+}
+
+// record adds the revision that took a book from before to after, before is
+// nil for new books.
+func (repo HistoryBookRepository) record(ctx context.Context, action models.RevisionAction, before, after *models.Book) error {
+	changes, err := models.DiffBooks(before, after)
+	if err != nil {
+		return fmt.Errorf("can't record a revision: %w", err)
+	}
+
+	revision := &models.Revision{
+		BookID:  after.ID,
+		Version: after.Version,
+		Action:  action,
+		Actor:   ActorFromContext(ctx),
+		At:      after.UpdatedAt,
+		Changes: changes,
+		Book:    copyBook(after),
+		Tenant:  TenantFromContext(ctx),
+	}
+
+	if revision.At.IsZero() {
+		revision.At = time.Now().UTC()
+	}
+
+	if version, ok := ctx.Value(revertKey).(int64); ok && action == models.RevisionUpdate {
+		revision.Action = models.RevisionRevert
+		revision.RevertedTo = version
+	}
+
+	if _, err := repo.History.AddRevision(ctx, revision); err != nil {
+		return fmt.Errorf("can't record a revision: %w", err)
+	}
+
+	return nil
+}
+
+// copyBook copies a book together with its lists, so that changes to either
+// copy don't show in the other.
+func copyBook(book *models.Book) *models.Book {
+	copied := *book
+	copied.Contributors = append([]models.Contributor(nil), book.Contributors...)
+	copied.Genres = append([]string(nil), book.Genres...)
+	copied.Tags = append([]string(nil), book.Tags...)
+
+	return &copied
+}
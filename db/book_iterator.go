@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+
+	"github.com/iho/booksdb/models"
+)
+
+// BookIterator streams books one at a time:
+//
+//	for it.Next(ctx) {
+//		book := it.Book()
+//	}
+//	if err := it.Err(); err != nil {
+//
+// Close releases the underlying cursor and must be called even when the
+// iteration didn't run to the end.
+type BookIterator interface {
+	Next(ctx context.Context) bool
+	Book() *models.Book
+	Err() error
+	Close(ctx context.Context) error
+}
+
+// sliceBookIterator iterates over books that are already in memory.
+type sliceBookIterator struct {
+	books []*models.Book
+	book  *models.Book
+	err   error
+}
+
+func (it *sliceBookIterator) Next(ctx context.Context) bool {
+	if it.err = ctx.Err(); it.err != nil || len(it.books) == 0 {
+		it.book = nil
+		return false
+	}
+
+	it.book, it.books = it.books[0], it.books[1:]
+
+	return true
+}
+
+func (it *sliceBookIterator) Book() *models.Book {
+	return it.book
+}
+
+func (it *sliceBookIterator) Err() error {
+	return it.err
+}
+
+func (it *sliceBookIterator) Close(ctx context.Context) error {
+	it.books, it.book = nil, nil
+
+	return nil
+}
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DefaultQueryLimit = 50
+	MaxQueryLimit     = 1000
+)
+
+// Fields books can be sorted by. The values are the names used in the API
+// and map onto the document fields of every backend.
+const (
+	SortByTitle     = "title"
+	SortByAuthor    = "author"
+	SortByPublisher = "publisher"
+	SortByRating    = "rating"
+	SortByStatus    = "status"
+	SortByCreatedAt = "created_at"
+	SortByUpdatedAt = "updated_at"
+	SortByDeletedAt = "deleted_at"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// BookFilter narrows a query down. Zero values mean "no restriction".
+type BookFilter struct {
+	Author string
+	// Contributor and Role match books crediting a contributor by that
+	// name, in that role, or both.
+	Contributor   string
+	Role          models.ContributorRole
+	Genre         string
+	Tag           string
+	Publisher     string
+	Status        models.BookStatusType
+	MinRating     *int
+	MaxRating     *int
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	// Deleted matches the books in the trash instead of the others.
+	Deleted bool
+}
+
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// BookQuery describes a single page of books. Cursor is the opaque value
+// returned as NextCursor of the previous page, it only works with the same
+// Sort.
+type BookQuery struct {
+	Filter BookFilter
+	Sort   []SortField
+	Limit  int
+	Cursor string
+}
+
+type BookPage struct {
+	Books      []*models.Book
+	NextCursor string
+}
+
+// ParseSort parses a comma separated list of fields, each optionally
+// prefixed with "-" for descending order, e.g. "-rating,title".
+func ParseSort(value string) ([]SortField, error) {
+	var fields []SortField
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		field := SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field = SortField{Field: part[1:], Descending: true}
+		}
+
+		if !isSortableField(field.Field) {
+			return nil, fmt.Errorf("can't sort by %q", field.Field)
+		}
+
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func isSortableField(field string) bool {
+	switch field {
+	case SortByTitle, SortByAuthor, SortByPublisher, SortByRating,
+		SortByStatus, SortByCreatedAt, SortByUpdatedAt, SortByDeletedAt:
+		return true
+	}
+
+	return false
+}
+
+// normalize validates the query and fills in defaults. after holds the
+// sort keys and ID of the last book of the previous page, it is nil for the
+// first page.
+func (query BookQuery) normalize() (BookQuery, *models.Book, error) {
+	query.Limit = pageLimit(query.Limit)
+
+	if err := checkSort(query.Sort); err != nil {
+		return query, nil, err
+	}
+
+	if query.Cursor == "" {
+		return query, nil, nil
+	}
+
+	after, err := decodeBookCursor(query.Cursor, query.Sort)
+	if err != nil {
+		return query, nil, err
+	}
+
+	return query, after, nil
+}
+
+func checkSort(fields []SortField) error {
+	for _, field := range fields {
+		if !isSortableField(field.Field) {
+			return fmt.Errorf("can't sort by %q", field.Field)
+		}
+	}
+
+	return nil
+}
+
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return DefaultQueryLimit
+	case limit > MaxQueryLimit:
+		return MaxQueryLimit
+	}
+
+	return limit
+}
+
+// bookCursor is the position of the last book of a page: its sort keys in
+// the order of Sort and its ID. The next page starts right after it, so
+// books added or removed in the meantime don't shift the following pages.
+type bookCursor struct {
+	Sort string   `json:"sort"`
+	Keys []string `json:"keys"`
+	ID   string   `json:"id"`
+}
+
+// nextBookCursor returns the cursor of the page following books, which
+// were fetched with one book more than the limit of query, or an empty
+// string if there is nothing left.
+func nextBookCursor(books []*models.Book, query BookQuery) string {
+	if len(books) <= query.Limit {
+		return ""
+	}
+
+	last := books[query.Limit-1]
+	cursor := bookCursor{Sort: formatSort(query.Sort), Keys: make([]string, 0, len(query.Sort)), ID: last.ID.Hex()}
+
+	for _, field := range query.Sort {
+		cursor.Keys = append(cursor.Keys, formatSortKey(last, field.Field))
+	}
+
+	raw, _ := json.Marshal(cursor)
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeBookCursor returns a book holding just the sort keys and ID of the
+// cursor. A cursor of another sort order is invalid.
+func decodeBookCursor(value string, sort []SortField) (*models.Book, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor bookCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != formatSort(sort) || len(cursor.Keys) != len(sort) {
+		return nil, ErrInvalidCursor
+	}
+
+	after := &models.Book{}
+	if after.ID, err = primitive.ObjectIDFromHex(cursor.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	for i, field := range sort {
+		if err := parseSortKey(after, field.Field, cursor.Keys[i]); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+
+	return after, nil
+}
+
+func formatSort(fields []SortField) string {
+	names := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		if field.Descending {
+			names = append(names, "-"+field.Field)
+		} else {
+			names = append(names, field.Field)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+func formatSortKey(book *models.Book, field string) string {
+	switch field {
+	case SortByRating:
+		return strconv.Itoa(book.Rating)
+	case SortByCreatedAt, SortByUpdatedAt, SortByDeletedAt:
+		if key, ok := sortKey(book, field).(time.Time); ok {
+			return key.Format(time.RFC3339Nano)
+		}
+
+		return ""
+	}
+
+	key, _ := sortKey(book, field).(string)
+
+	return key
+}
+
+func parseSortKey(book *models.Book, field, key string) error {
+	var (
+		at  time.Time
+		err error
+	)
+
+	switch field {
+	case SortByRating:
+		book.Rating, err = strconv.Atoi(key)
+	case SortByCreatedAt:
+		book.CreatedAt, err = time.Parse(time.RFC3339Nano, key)
+	case SortByUpdatedAt:
+		book.UpdatedAt, err = time.Parse(time.RFC3339Nano, key)
+	case SortByDeletedAt:
+		if key != "" {
+			at, err = time.Parse(time.RFC3339Nano, key)
+			book.DeletedAt = &at
+		}
+	case SortByTitle:
+		book.Title = key
+	case SortByAuthor:
+		book.Author = key
+	case SortByPublisher:
+		book.Publisher = key
+	case SortByStatus:
+		book.Status = models.BookStatusType(key)
+	}
+
+	return err
+}
+
+// sortKey returns the value of a sort field of book as the backends compare
+// it. The deleted_at of a book that isn't in the trash is nil.
+func sortKey(book *models.Book, field string) interface{} {
+	switch field {
+	case SortByTitle:
+		return book.Title
+	case SortByAuthor:
+		return book.Author
+	case SortByPublisher:
+		return book.Publisher
+	case SortByStatus:
+		return string(book.Status)
+	case SortByRating:
+		return book.Rating
+	case SortByCreatedAt:
+		return book.CreatedAt
+	case SortByUpdatedAt:
+		return book.UpdatedAt
+	case SortByDeletedAt:
+		if book.DeletedAt != nil {
+			return *book.DeletedAt
+		}
+	}
+
+	return nil
+}
+
+// Search and author cursors are opaque to clients; internally they carry
+// the offset of the next page.
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+
+	offset, err := strconv.Atoi(string(raw))
+	if err != nil || offset < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return offset, nil
+}
+
+// nextCursor returns the cursor of the page following one that started at
+// offset, or an empty string if there is nothing left.
+func nextCursor(offset, limit, fetched int) string {
+	if fetched <= limit {
+		return ""
+	}
+
+	return encodeCursor(offset + limit)
+}
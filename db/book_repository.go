@@ -2,16 +2,62 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/iho/booksdb/models"
 )
 
+// BookRepository stores the books of every tenant. Each method only sees
+// and changes the books of the tenant of its context, see WithTenant, and
+// ISBNs are unique per tenant.
 type BookRepository interface {
+	// AddBook stores a new book. The repository assigns its ID, version,
+	// timestamps and tenant and sets them on book, whatever it carried.
 	AddBook(ctx context.Context, book *models.Book) (ID, error)
+	// AddBooks, UpdateBooks and DeleteBooks apply a batch of changes. They
+	// return an error for every item, nil for the ones that were applied,
+	// and fail as a whole if the batch couldn't be processed at all or with
+	// ErrBatchAborted if an atomic batch was rolled back.
+	AddBooks(ctx context.Context, books []*models.Book, mode BatchMode) ([]error, error)
+	UpdateBooks(ctx context.Context, changes []BookChange, mode BatchMode) ([]*models.Book, []error, error)
+	DeleteBooks(ctx context.Context, deletions []BookDeletion, mode BatchMode) ([]error, error)
 	GetBook(ctx context.Context, ID ID) (*models.Book, error)
+	// GetBookByISBN looks a book up by its normalized ISBN-13. ISBNs are
+	// unique, storing a second book with the same ISBN fails with
+	// ErrConflict.
+	GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error)
+	// DeleteBook moves a book to the trash: it gets a DeletedAt tombstone
+	// and a new version, and is left out by every other method unless a
+	// filter asks for deleted books. Its ISBN can be used by other books.
 	DeleteBook(ctx context.Context, ID ID) error
+	// DeleteBookVersion deletes the book only if it is still at the given
+	// version and returns ErrVersionMismatch otherwise.
+	DeleteBookVersion(ctx context.Context, ID ID, version int64) error
+	// RestoreBook takes a book out of the trash. It fails with ErrConflict
+	// if another book got its ISBN in the meantime.
+	RestoreBook(ctx context.Context, ID ID) (*models.Book, error)
+	// PurgeBooks removes the books deleted before the given time for good
+	// and returns how many there were. It empties the trash of every tenant.
+	PurgeBooks(ctx context.Context, deletedBefore time.Time) (int, error)
 	AllBooks(ctx context.Context) ([]*models.Book, error)
+	QueryBooks(ctx context.Context, query BookQuery) (*BookPage, error)
+	// IterateBooks returns an iterator over all books matching the filter
+	// in the given order, for results too large to be paged through.
+	IterateBooks(ctx context.Context, filter BookFilter, sort []SortField) (BookIterator, error)
+	SearchBooks(ctx context.Context, search BookSearch) (*SearchPage, error)
+	// Contributors lists the distinct contributors of all books together
+	// with the number of books crediting them.
+	Contributors(ctx context.Context, query ContributorQuery) (*ContributorPage, error)
+	// Facets counts the books matching the filter by genre, tag, publisher
+	// and status.
+	Facets(ctx context.Context, filter BookFilter) (*BookFacets, error)
 	RemoveAllBooks(ctx context.Context) error
+	// UpdateBook applies updateFn to the current state of the book and stores
+	// the result with the version incremented. The store is only changed if
+	// nobody else updated the book in the meantime. updateFn may return a
+	// book of its own, the ID, creation time, tenant and trash state of the
+	// stored book are kept and the version and update time are set by the
+	// repository.
 	UpdateBook(
 		ctx context.Context,
 		ID ID,
@@ -0,0 +1,28 @@
+package db
+
+import "github.com/iho/booksdb/models"
+
+const BookJournalName = "books.log"
+
+// FileBookRepository keeps every book in memory and makes changes durable
+// in an append-only journal inside dataDir. Reads never touch the disk.
+type FileBookRepository struct {
+	MemoryBookRepository
+	fileStore
+}
+
+func NewFileBookRepository(dataDir string) (FileBookRepository, error) {
+	memory := NewMemoryBookRepository()
+
+	journal, file, err := openFileStore(dataDir, BookJournalName, memory.StoreRW, memory.Store,
+		func(id ID, doc interface{}) {
+			memory.storeBook(id, doc.(*models.Book))
+		}, memory.dropBook)
+	if err != nil {
+		return FileBookRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileBookRepository{MemoryBookRepository: memory, fileStore: file}, nil
+}
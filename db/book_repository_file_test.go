@@ -0,0 +1,182 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileBookRepositoryReopen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repo, err := db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	kept, err := repo.AddBook(ctx, &models.Book{Title: "kept", ISBN: "9780306406157"})
+	require.NoError(t, err)
+
+	removed, err := repo.AddBook(ctx, &models.Book{Title: "removed"})
+	require.NoError(t, err)
+
+	_, err = repo.UpdateBook(ctx, kept, func(book *models.Book) (*models.Book, error) {
+		book.Title = "updated"
+		return book, nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteBook(ctx, removed))
+	require.NoError(t, repo.Close())
+
+	repo, err = db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	book, err := repo.GetBook(ctx, kept)
+	require.NoError(t, err)
+	require.Equal(t, "updated", book.Title)
+
+	book, err = repo.GetBookByISBN(ctx, "9780306406157")
+	require.NoError(t, err)
+	require.Equal(t, kept, db.ID(book.ID.Hex()))
+
+	_, err = repo.GetBook(ctx, removed)
+	require.Error(t, err)
+
+	// the deleted book is still in the trash
+	book, err = repo.RestoreBook(ctx, removed)
+	require.NoError(t, err)
+	require.Equal(t, "removed", book.Title)
+}
+
+func TestFileBookRepositoryTornWrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repo, err := db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	id, err := repo.AddBook(ctx, &models.Book{Title: "survivor"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	// simulate a crash in the middle of writing the next record
+	path := filepath.Join(dataDir, db.BookJournalName)
+	journal, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = journal.WriteString(`{"op":"put","id":"6106`)
+	require.NoError(t, err)
+	require.NoError(t, journal.Close())
+
+	repo, err = db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	_, err = repo.GetBook(ctx, id)
+	require.NoError(t, err)
+
+	_, err = repo.AddBook(ctx, &models.Book{Title: "after crash"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	repo, err = db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	books, err := repo.AllBooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, books, 2)
+}
+
+func TestFileBookRepositoryCompaction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repo, err := db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	id, err := repo.AddBook(ctx, &models.Book{Title: "rewritten"})
+	require.NoError(t, err)
+
+	for i := 0; i < 3000; i++ {
+		_, err = repo.UpdateBook(ctx, id, func(book *models.Book) (*models.Book, error) {
+			book.Rating = i
+			return book, nil
+		})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.Close())
+
+	journal, err := ioutil.ReadFile(filepath.Join(dataDir, db.BookJournalName))
+	require.NoError(t, err)
+	// without compaction there would be a record per update
+	require.Less(t, bytes.Count(journal, []byte("\n")), 1100)
+
+	repo, err = db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	book, err := repo.GetBook(ctx, id)
+	require.NoError(t, err)
+	require.Equal(t, 2999, book.Rating)
+}
+
+func TestFileBookRepositoryAtomicBatch(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	path := filepath.Join(dataDir, db.BookJournalName)
+
+	repo, err := db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	_, err = repo.AddBook(ctx, &models.Book{Title: "Beowulf", ISBN: "9780306406157"})
+	require.NoError(t, err)
+
+	before, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+
+	// a rolled back batch leaves the journal alone
+	_, err = repo.AddBooks(ctx, []*models.Book{
+		{Title: "Grendel"},
+		{Title: "Beowulf again", ISBN: "9780306406157"},
+	}, db.BatchAtomic)
+	require.ErrorIs(t, err, db.ErrBatchAborted)
+
+	after, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, before, after)
+
+	// an applied batch is a single line
+	_, err = repo.AddBooks(ctx, []*models.Book{{Title: "Grendel"}, {Title: "The Hobbit"}}, db.BatchAtomic)
+	require.NoError(t, err)
+
+	after, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, bytes.Count(before, []byte("\n"))+1, bytes.Count(after, []byte("\n")))
+	require.NoError(t, repo.Close())
+
+	// a crash in the middle of the batch loses all of it
+	batch := after[len(before):]
+	require.NoError(t, ioutil.WriteFile(path, append(before, batch[:len(batch)/2]...), 0o600))
+
+	repo, err = db.NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	books, err := repo.AllBooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+}
@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/iho/booksdb/models"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,67 +15,527 @@ import (
 type MemoryBookRepository struct {
 	Store   map[ID]*models.Book
 	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+	// isbns and search index Store by tenant and ISBN and by tenant and
+	// text. They are only changed through storeBook and dropBook.
+	isbns  map[string]ID
+	search map[string]*searchIndex
 }
 
 func NewMemoryBookRepository() MemoryBookRepository {
 	return MemoryBookRepository{
 		StoreRW: &sync.RWMutex{},
 		Store:   make(map[ID]*models.Book),
+		isbns:   make(map[string]ID),
+		search:  make(map[string]*searchIndex),
 	}
 }
 
 func (repo MemoryBookRepository) AddBook(ctx context.Context, book *models.Book) (ID, error) {
-	id := ID(primitive.NewObjectID().Hex())
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	return repo.addBook(TenantFromContext(ctx), book)
+}
+
+func (repo MemoryBookRepository) AddBooks(ctx context.Context, books []*models.Book, mode BatchMode) ([]error, error) {
+	tenant := TenantFromContext(ctx)
 
 	repo.StoreRW.Lock()
 	defer repo.StoreRW.Unlock()
 
-	repo.Store[id] = book
+	return repo.runBatch(len(books), mode, func(repo MemoryBookRepository, i int) (ID, *models.Book, error) {
+		id, err := repo.addBook(tenant, books[i])
+
+		return id, nil, err
+	})
+}
+
+func (repo MemoryBookRepository) UpdateBooks(
+	ctx context.Context,
+	changes []BookChange,
+	mode BatchMode,
+) ([]*models.Book, []error, error) {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	books := make([]*models.Book, len(changes))
+	errs, err := repo.runBatch(len(changes), mode, func(repo MemoryBookRepository, i int) (ID, *models.Book, error) {
+		change := changes[i]
+		if _, err := parseID(change.ID); err != nil {
+			return change.ID, nil, err
+		}
+
+		previous := repo.Store[change.ID]
+
+		var err error
+		books[i], err = repo.updateBook(tenant, change.ID, change.Update)
+
+		return change.ID, previous, err
+	})
+	if err != nil {
+		return nil, errs, err
+	}
+
+	return books, errs, nil
+}
+
+func (repo MemoryBookRepository) DeleteBooks(ctx context.Context, deletions []BookDeletion, mode BatchMode) ([]error, error) {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	return repo.runBatch(len(deletions), mode, func(repo MemoryBookRepository, i int) (ID, *models.Book, error) {
+		deletion := deletions[i]
+		if _, err := parseID(deletion.ID); err != nil {
+			return deletion.ID, nil, err
+		}
+
+		previous := repo.Store[deletion.ID]
+
+		return deletion.ID, previous, repo.deleteBook(tenant, deletion.ID, deletion.Version)
+	})
+}
+
+// runBatch applies n items of a batch with StoreRW held. apply changes the
+// repository it is given and returns the ID of the book it changed and the
+// book as it was before, nil if the book is new.
+//
+// Every item of a best-effort batch is journaled on its own. An atomic
+// batch is applied to the store first, with its journal records held back,
+// and then journaled in a single write. If an item or the write fails the
+// store is put back the way it was, the journal never sees half a batch.
+// The journal is compacted before the batch is applied, a snapshot taken
+// later would hold the batch whether the write succeeds or not.
+func (repo MemoryBookRepository) runBatch(
+	n int,
+	mode BatchMode,
+	apply func(repo MemoryBookRepository, i int) (ID, *models.Book, error),
+) ([]error, error) {
+	errs := make([]error, n)
+
+	if mode != BatchAtomic {
+		for i := range errs {
+			_, _, errs[i] = apply(repo, i)
+		}
+
+		return errs, nil
+	}
+
+	type change struct {
+		id       ID
+		previous *models.Book
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.compactIfNeeded(); err != nil {
+			return nil, err
+		}
+	}
+
+	pending := &pendingJournal{}
+	staged := repo
+	staged.journal = pending
+	applied := make([]change, 0, n)
+
+	rollBack := func() {
+		for j := len(applied) - 1; j >= 0; j-- {
+			if applied[j].previous == nil {
+				repo.dropBook(applied[j].id)
+			} else {
+				repo.storeBook(applied[j].id, applied[j].previous)
+			}
+		}
+	}
+
+	for i := range errs {
+		id, previous, err := apply(staged, i)
+		if err != nil {
+			errs[i] = err
+			rollBack()
+
+			return abortBatch(errs), ErrBatchAborted
+		}
+
+		applied = append(applied, change{id: id, previous: previous})
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(pending.changes); err != nil {
+			rollBack()
+
+			return nil, err
+		}
+	}
+
+	return errs, nil
+}
+
+// putBook journals and stores a book, or drops it if it is nil. It is
+// called with StoreRW held.
+func (repo MemoryBookRepository) putBook(id ID, book *models.Book) error {
+	if book == nil {
+		if repo.journal != nil {
+			if err := repo.journal.delete(id); err != nil {
+				return err
+			}
+		}
+
+		repo.dropBook(id)
+
+		return nil
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, book); err != nil {
+			return err
+		}
+	}
+
+	repo.storeBook(id, book)
+
+	return nil
+}
+
+// addBook is called with StoreRW held.
+func (repo MemoryBookRepository) addBook(tenant string, book *models.Book) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	now := time.Now().UTC()
+	book.ID = objectID
+	book.Version = 1
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.DeletedAt = nil
+	book.Tenant = tenant
+
+	if err := repo.checkISBN(tenant, id, book.ISBN); err != nil {
+		return ID(""), err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, book); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.storeBook(id, book)
 
 	return id, nil
 }
 
+func (repo MemoryBookRepository) GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	if id, ok := repo.isbns[scopedKey(TenantFromContext(ctx), isbn)]; ok {
+		return repo.Store[id], nil
+	}
+
+	return nil, errBookNotFound
+}
+
 func (repo MemoryBookRepository) GetBook(ctx context.Context, id ID) (*models.Book, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
 	repo.StoreRW.RLock()
 	defer repo.StoreRW.RUnlock()
 
 	book, ok := repo.Store[id]
-	if ok {
+	if ok && book.DeletedAt == nil && book.Tenant == TenantFromContext(ctx) {
 		return book, nil
 	}
 
-	return nil, errors.New("book not found")
+	return nil, errBookNotFound
 }
 
 func (repo MemoryBookRepository) DeleteBook(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
 	repo.StoreRW.Lock()
 	defer repo.StoreRW.Unlock()
 
-	_, ok := repo.Store[id]
-	if ok {
-		delete(repo.Store, id)
-		return nil
+	return repo.deleteBook(TenantFromContext(ctx), id, 0)
+}
+
+func (repo MemoryBookRepository) DeleteBookVersion(ctx context.Context, id ID, version int64) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	if version == 0 {
+		return ErrVersionMismatch
+	}
+
+	return repo.deleteBook(TenantFromContext(ctx), id, version)
+}
+
+// deleteBook moves the book to the trash if it is at version, at any
+// version if it is 0. It is called with StoreRW held.
+func (repo MemoryBookRepository) deleteBook(tenant string, id ID, version int64) error {
+	book, ok := repo.Store[id]
+	if !ok || book.DeletedAt != nil || book.Tenant != tenant {
+		return errBookNotFound
+	}
+
+	if version != 0 && book.Version != version {
+		return ErrVersionMismatch
+	}
+
+	now := time.Now().UTC()
+	deleted := *book
+	deleted.Version++
+	deleted.UpdatedAt = now
+	deleted.DeletedAt = &now
+
+	return repo.putBook(id, &deleted)
+}
+
+func (repo MemoryBookRepository) RestoreBook(ctx context.Context, id ID) (*models.Book, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
 	}
 
-	return errors.New("book not found")
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	book, ok := repo.Store[id]
+	if !ok || book.DeletedAt == nil || book.Tenant != TenantFromContext(ctx) {
+		return nil, fmt.Errorf("nothing to restore: %w", errBookNotFound)
+	}
+
+	if err := repo.checkISBN(book.Tenant, id, book.ISBN); err != nil {
+		return nil, err
+	}
+
+	restored := *book
+	restored.Version++
+	restored.UpdatedAt = time.Now().UTC()
+	restored.DeletedAt = nil
+
+	if err := repo.putBook(id, &restored); err != nil {
+		return nil, err
+	}
+
+	return &restored, nil
+}
+
+func (repo MemoryBookRepository) PurgeBooks(ctx context.Context, deletedBefore time.Time) (int, error) {
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	purged := 0
+
+	for id, book := range repo.Store {
+		if book.DeletedAt == nil || !book.DeletedAt.Before(deletedBefore) {
+			continue
+		}
+
+		if err := repo.putBook(id, nil); err != nil {
+			return purged, err
+		}
+
+		purged++
+	}
+
+	return purged, nil
 }
 
 func (repo MemoryBookRepository) AllBooks(ctx context.Context) ([]*models.Book, error) {
 	var books []*models.Book
+	tenant := TenantFromContext(ctx)
 	repo.StoreRW.RLock()
 	defer repo.StoreRW.RUnlock()
 	for _, book := range repo.Store {
-		books = append(books, book)
+		if book.DeletedAt == nil && book.Tenant == tenant {
+			books = append(books, book)
+		}
 	}
 
 	return books, nil
 }
 
+func (repo MemoryBookRepository) QueryBooks(ctx context.Context, query BookQuery) (*BookPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	books := make([]*models.Book, 0)
+	for _, book := range repo.Store {
+		if book.Tenant == tenant && matchesFilter(book, query.Filter) {
+			books = append(books, book)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sortBooks(books, query.Sort)
+
+	if after != nil {
+		// skip the books up to the last one of the previous page
+		seen := sort.Search(len(books), func(i int) bool {
+			return compareSorted(books[i], after, query.Sort) > 0
+		})
+		books = books[seen:]
+	}
+
+	page := &BookPage{NextCursor: nextBookCursor(books, query)}
+
+	if len(books) > query.Limit {
+		books = books[:query.Limit]
+	}
+
+	page.Books = books
+
+	return page, nil
+}
+
+// IterateBooks iterates over a snapshot of the matching books taken when it
+// is called. Stored books are never changed in place, so they can be handed
+// out after the lock is released.
+func (repo MemoryBookRepository) IterateBooks(
+	ctx context.Context,
+	filter BookFilter,
+	sort []SortField,
+) (BookIterator, error) {
+	if err := checkSort(sort); err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	books := make([]*models.Book, 0)
+	for _, book := range repo.Store {
+		if book.Tenant == tenant && matchesFilter(book, filter) {
+			books = append(books, book)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sortBooks(books, sort)
+
+	return &sliceBookIterator{books: books}, nil
+}
+
+func (repo MemoryBookRepository) SearchBooks(ctx context.Context, search BookSearch) (*SearchPage, error) {
+	search, offset, err := search.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	page := &SearchPage{Results: make([]SearchResult, 0)}
+
+	index, ok := repo.search[TenantFromContext(ctx)]
+	if !ok {
+		return page, nil
+	}
+
+	matches := index.search(search.Text, len(index.terms))
+	if offset >= len(matches) {
+		return page, nil
+	}
+
+	matches = matches[offset:]
+	page.NextCursor = nextCursor(offset, search.Limit, len(matches))
+
+	if len(matches) > search.Limit {
+		matches = matches[:search.Limit]
+	}
+
+	for _, match := range matches {
+		page.Results = append(page.Results, SearchResult{Book: repo.Store[match.id], Score: match.score})
+	}
+
+	return page, nil
+}
+
+func (repo MemoryBookRepository) Contributors(ctx context.Context, query ContributorQuery) (*ContributorPage, error) {
+	query, offset, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	books := make([]*models.Book, 0, len(repo.Store))
+	for _, book := range repo.Store {
+		if book.DeletedAt == nil && book.Tenant == tenant {
+			books = append(books, book)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	contributors := countContributors(books, query.Role)
+
+	page := &ContributorPage{Contributors: make([]ContributorCount, 0)}
+	if offset >= len(contributors) {
+		return page, nil
+	}
+
+	contributors = contributors[offset:]
+	page.NextCursor = nextCursor(offset, query.Limit, len(contributors))
+
+	if len(contributors) > query.Limit {
+		contributors = contributors[:query.Limit]
+	}
+
+	page.Contributors = contributors
+
+	return page, nil
+}
+
+func (repo MemoryBookRepository) Facets(ctx context.Context, filter BookFilter) (*BookFacets, error) {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	books := make([]*models.Book, 0)
+	for _, book := range repo.Store {
+		if book.Tenant == tenant && matchesFilter(book, filter) {
+			books = append(books, book)
+		}
+	}
+
+	return countFacets(books), nil
+}
+
 func (repo MemoryBookRepository) RemoveAllBooks(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
 	repo.StoreRW.Lock()
 	defer repo.StoreRW.Unlock()
 
-	repo.Store = make(map[ID]*models.Book)
+	for id, book := range repo.Store {
+		if book.Tenant != tenant {
+			continue
+		}
+
+		if err := repo.putBook(id, nil); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -82,22 +544,194 @@ func (repo MemoryBookRepository) UpdateBook(
 	bookID ID,
 	updateFn func(book *models.Book) (*models.Book, error),
 ) (*models.Book, error) {
-	var updatedBook *models.Book
-
-	book, err := repo.GetBook(ctx, bookID)
-	if err != nil {
+	if _, err := parseID(bookID); err != nil {
 		return nil, err
 	}
 
 	repo.StoreRW.Lock()
 	defer repo.StoreRW.Unlock()
 
-	updatedBook, err = updateFn(book)
+	return repo.updateBook(TenantFromContext(ctx), bookID, updateFn)
+}
+
+// updateBook is called with StoreRW held.
+func (repo MemoryBookRepository) updateBook(
+	tenant string,
+	bookID ID,
+	updateFn func(book *models.Book) (*models.Book, error),
+) (*models.Book, error) {
+	book, ok := repo.Store[bookID]
+	if !ok || book.DeletedAt != nil || book.Tenant != tenant {
+		return nil, errBookNotFound
+	}
+
+	// updateFn gets a copy so that a failed update leaves the store intact
+	updatedBook, err := updateFn(copyBook(book))
 	if err != nil {
 		return nil, fmt.Errorf("failed to update book: %w", err)
 	}
 
-	repo.Store[bookID] = updatedBook
+	updatedBook.ID = book.ID
+	updatedBook.Version = book.Version + 1
+	updatedBook.CreatedAt = book.CreatedAt
+	updatedBook.UpdatedAt = time.Now().UTC()
+	updatedBook.DeletedAt = nil
+	updatedBook.Tenant = book.Tenant
+
+	if err := repo.checkISBN(tenant, bookID, updatedBook.ISBN); err != nil {
+		return nil, err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(bookID, updatedBook); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.storeBook(bookID, updatedBook)
 
 	return updatedBook, nil
 }
+
+// checkISBN reports a conflict if another book of the tenant already has
+// the ISBN.
+func (repo MemoryBookRepository) checkISBN(tenant string, id ID, isbn string) error {
+	if owner, ok := repo.isbns[scopedKey(tenant, isbn)]; isbn != "" && ok && owner != id {
+		return fmt.Errorf("%w: a book with ISBN %s already exists", ErrConflict, isbn)
+	}
+
+	return nil
+}
+
+// storeBook and dropBook change the store together with its indexes. They
+// are called with StoreRW held.
+func (repo MemoryBookRepository) storeBook(id ID, book *models.Book) {
+	repo.dropBook(id)
+
+	repo.Store[id] = book
+
+	// books in the trash can't be found and give up their ISBN
+	if book.DeletedAt != nil {
+		return
+	}
+
+	if book.ISBN != "" {
+		repo.isbns[scopedKey(book.Tenant, book.ISBN)] = id
+	}
+
+	index, ok := repo.search[book.Tenant]
+	if !ok {
+		index = newSearchIndex()
+		repo.search[book.Tenant] = index
+	}
+
+	index.add(id, book)
+}
+
+func (repo MemoryBookRepository) dropBook(id ID) {
+	book, ok := repo.Store[id]
+	if !ok {
+		return
+	}
+
+	if key := scopedKey(book.Tenant, book.ISBN); book.ISBN != "" && repo.isbns[key] == id {
+		delete(repo.isbns, key)
+	}
+
+	if index, ok := repo.search[book.Tenant]; ok {
+		index.remove(id)
+	}
+
+	delete(repo.Store, id)
+}
+
+func matchesFilter(book *models.Book, filter BookFilter) bool {
+	switch {
+	case filter.Deleted != (book.DeletedAt != nil),
+		filter.Author != "" && book.Author != filter.Author,
+		filter.Genre != "" && !containsTerm(book.Genres, filter.Genre),
+		filter.Tag != "" && !containsTerm(book.Tags, filter.Tag),
+		filter.Publisher != "" && book.Publisher != filter.Publisher,
+		filter.Status != "" && book.Status != filter.Status,
+		filter.MinRating != nil && book.Rating < *filter.MinRating,
+		filter.MaxRating != nil && book.Rating > *filter.MaxRating,
+		filter.CreatedAfter != nil && book.CreatedAt.Before(*filter.CreatedAfter),
+		filter.CreatedBefore != nil && !book.CreatedAt.Before(*filter.CreatedBefore),
+		filter.UpdatedAfter != nil && book.UpdatedAt.Before(*filter.UpdatedAfter),
+		filter.UpdatedBefore != nil && !book.UpdatedAt.Before(*filter.UpdatedBefore),
+		!matchesContributor(book, filter):
+		return false
+	}
+
+	return true
+}
+
+// sortBooks orders books by the given fields, falling back to the ID so
+// that pagination is stable.
+func sortBooks(books []*models.Book, fields []SortField) {
+	sort.SliceStable(books, func(i, j int) bool {
+		return compareSorted(books[i], books[j], fields) < 0
+	})
+}
+
+// compareSorted compares two books in the order of fields, ties are broken
+// by ID.
+func compareSorted(a, b *models.Book, fields []SortField) int {
+	for _, field := range fields {
+		cmp := compareBooks(a, b, field.Field)
+		if cmp == 0 {
+			continue
+		}
+
+		if field.Descending {
+			return -cmp
+		}
+
+		return cmp
+	}
+
+	return strings.Compare(a.ID.Hex(), b.ID.Hex())
+}
+
+func compareBooks(a, b *models.Book, field string) int {
+	switch field {
+	case SortByTitle:
+		return strings.Compare(a.Title, b.Title)
+	case SortByAuthor:
+		return strings.Compare(a.Author, b.Author)
+	case SortByPublisher:
+		return strings.Compare(a.Publisher, b.Publisher)
+	case SortByStatus:
+		return strings.Compare(string(a.Status), string(b.Status))
+	case SortByRating:
+		return a.Rating - b.Rating
+	case SortByCreatedAt:
+		return compareTimes(a.CreatedAt, b.CreatedAt)
+	case SortByUpdatedAt:
+		return compareTimes(a.UpdatedAt, b.UpdatedAt)
+	case SortByDeletedAt:
+		var deletedA, deletedB time.Time
+		if a.DeletedAt != nil {
+			deletedA = *a.DeletedAt
+		}
+
+		if b.DeletedAt != nil {
+			deletedB = *b.DeletedAt
+		}
+
+		return compareTimes(deletedA, deletedB)
+	}
+
+	return 0
+}
+
+func compareTimes(a, b time.Time) int {
+	switch {
+	case a.Before(b):
+		return -1
+	case a.After(b):
+		return 1
+	}
+
+	return 0
+}
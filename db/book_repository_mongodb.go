@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/iho/booksdb/models"
 	"go.mongodb.org/mongo-driver/bson"
@@ -26,66 +28,343 @@ func (repo MongoDBBookRepository) getBookCollection() *mongo.Collection {
 	return repo.Client.Database(DatabaseName).Collection(BookCollectionName)
 }
 
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBBookRepository) Migrate(ctx context.Context) error {
+	// replaced by the tenant indexes below, books in the trash give up
+	// their ISBN and ISBNs are only unique per tenant
+	err := migrateToTenants(ctx, repo.getBookCollection(), "isbn_unique", "isbn_live_unique", "text_search")
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.getBookCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			// live books have no deleted_at, so their ISBNs have to be
+			// unique, the ones of deleted books differ by the time
+			Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "isbn", Value: 1}, {Key: "deleted_at", Value: 1}},
+			// books without an ISBN don't have the field at all
+			Options: options.Index().
+				SetName("tenant_isbn_live_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"isbn": bson.M{"$type": "string"}}),
+		},
+		{
+			// every text search is limited to a tenant
+			Keys: bson.D{
+				{Key: "tenant", Value: 1},
+				{Key: "title", Value: "text"},
+				{Key: "author", Value: "text"},
+				{Key: "publisher", Value: "text"},
+			},
+			Options: options.Index().
+				SetName("tenant_text_search").
+				SetDefaultLanguage("english").
+				SetWeights(bson.M{
+					"title":     titleSearchWeight,
+					"author":    authorSearchWeight,
+					"publisher": publisherSearchWeight,
+				}),
+		},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "deleted_at", Value: 1}}},
+		{Keys: bson.D{{Key: "contributors.name", Value: 1}, {Key: "contributors.role", Value: 1}}},
+		{Keys: bson.D{{Key: "genres", Value: 1}}},
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
 func (repo MongoDBBookRepository) AddBook(ctx context.Context, book *models.Book) (ID, error) {
 	BookID := ID("")
 
+	now := time.Now().UTC()
+	book.ID = primitive.NewObjectID()
+	book.Version = 1
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.DeletedAt = nil
+	book.Tenant = TenantFromContext(ctx)
+
 	result, err := repo.getBookCollection().InsertOne(ctx, book)
 	if err != nil {
-		return BookID, fmt.Errorf("can't insert a book: %w", err)
+		return BookID, fmt.Errorf("can't insert a book: %w", classifyMongoError(err))
 	}
 
 	return ID(result.InsertedID.(primitive.ObjectID).Hex()), nil
 }
 
+// AddBooks inserts a best-effort batch with a single unordered InsertMany,
+// so that a failing book doesn't stop the ones after it. Atomic batches are
+// inserted in a transaction.
+func (repo MongoDBBookRepository) AddBooks(ctx context.Context, books []*models.Book, mode BatchMode) ([]error, error) {
+	if mode == BatchAtomic {
+		return repo.runBatch(ctx, len(books), mode, func(ctx context.Context, i int) error {
+			_, err := repo.AddBook(ctx, books[i])
+
+			return err
+		})
+	}
+
+	errs := make([]error, len(books))
+	if len(books) == 0 {
+		return errs, nil
+	}
+
+	now := time.Now().UTC()
+	tenant := TenantFromContext(ctx)
+	documents := make([]interface{}, len(books))
+
+	for i, book := range books {
+		book.ID = primitive.NewObjectID()
+		book.Version = 1
+		book.CreatedAt = now
+		book.UpdatedAt = now
+		book.DeletedAt = nil
+		book.Tenant = tenant
+		documents[i] = book
+	}
+
+	_, err := repo.getBookCollection().InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+
+	var bulkErr mongo.BulkWriteException
+
+	switch {
+	case err == nil:
+		return errs, nil
+	case errors.As(err, &bulkErr) && bulkErr.WriteConcernError == nil:
+		for _, writeErr := range bulkErr.WriteErrors {
+			errs[writeErr.Index] = fmt.Errorf("can't insert a book: %w", classifyWriteError(writeErr.WriteError))
+		}
+
+		return errs, nil
+	}
+
+	return nil, fmt.Errorf("can't insert books: %w", classifyMongoError(err))
+}
+
+func (repo MongoDBBookRepository) UpdateBooks(
+	ctx context.Context,
+	changes []BookChange,
+	mode BatchMode,
+) ([]*models.Book, []error, error) {
+	books := make([]*models.Book, len(changes))
+
+	errs, err := repo.runBatch(ctx, len(changes), mode, func(ctx context.Context, i int) error {
+		var err error
+		books[i], err = repo.UpdateBook(ctx, changes[i].ID, changes[i].Update)
+
+		return err
+	})
+	if err != nil {
+		return nil, errs, err
+	}
+
+	return books, errs, nil
+}
+
+func (repo MongoDBBookRepository) DeleteBooks(ctx context.Context, deletions []BookDeletion, mode BatchMode) ([]error, error) {
+	return repo.runBatch(ctx, len(deletions), mode, func(ctx context.Context, i int) error {
+		if deletions[i].Version == 0 {
+			return repo.DeleteBook(ctx, deletions[i].ID)
+		}
+
+		return repo.DeleteBookVersion(ctx, deletions[i].ID, deletions[i].Version)
+	})
+}
+
+// runBatch applies n items of a batch one by one. An atomic batch runs in
+// a transaction that is aborted by the first failing item. The driver runs
+// the whole transaction again after transient errors, e.g. a write
+// conflict with another transaction.
+func (repo MongoDBBookRepository) runBatch(
+	ctx context.Context,
+	n int,
+	mode BatchMode,
+	apply func(ctx context.Context, i int) error,
+) ([]error, error) {
+	errs := make([]error, n)
+
+	if mode != BatchAtomic {
+		for i := range errs {
+			errs[i] = apply(ctx, i)
+		}
+
+		return errs, nil
+	}
+
+	session, err := repo.Client.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("can't start a session: %w", classifyMongoError(err))
+	}
+	defer session.EndSession(ctx)
+
+	failed := -1
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		errs, failed = make([]error, n), -1
+
+		for i := range errs {
+			if errs[i] = apply(sessCtx, i); errs[i] != nil {
+				failed = i
+
+				return nil, errs[i]
+			}
+		}
+
+		return nil, nil
+	})
+
+	switch {
+	case err == nil:
+		return errs, nil
+	case failed >= 0 && err == errs[failed]: //nolint:errorlint
+		return abortBatch(errs), ErrBatchAborted
+	}
+
+	return nil, fmt.Errorf("can't apply a batch: %w", classifyMongoError(err))
+}
+
 func (repo MongoDBBookRepository) GetBook(ctx context.Context, id ID) (*models.Book, error) {
 	book := &models.Book{}
 
-	bookID, err := primitive.ObjectIDFromHex(string(id))
+	bookID, err := parseID(id)
 	if err != nil {
-		return book, fmt.Errorf("ID is not a valid hex string: %w", err)
+		return book, err
 	}
 
-	filter := bson.M{"_id": bookID}
+	filter := bson.M{"_id": bookID, "tenant": TenantFromContext(ctx), "deleted_at": nil}
 
 	err = repo.getBookCollection().FindOne(ctx, filter).Decode(book)
-	if errors.Is(err, mongo.ErrNoDocuments) {
-		return book, fmt.Errorf("can't find a book: %w", err)
-	} else if err != nil {
-		return book, fmt.Errorf("some database error has occurred: %w", err)
+	if err != nil {
+		return book, fmt.Errorf("can't find a book: %w", classifyMongoError(err))
+	}
+
+	return book, nil
+}
+
+func (repo MongoDBBookRepository) GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	book := &models.Book{}
+
+	filter := bson.M{"isbn": isbn, "tenant": TenantFromContext(ctx), "deleted_at": nil}
+
+	err := repo.getBookCollection().FindOne(ctx, filter).Decode(book)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a book: %w", classifyMongoError(err))
 	}
 
 	return book, nil
 }
 
 func (repo MongoDBBookRepository) DeleteBook(ctx context.Context, id ID) error {
-	bookID, err := primitive.ObjectIDFromHex(string(id))
+	bookID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	filter := bson.M{"_id": bookID, "tenant": TenantFromContext(ctx), "deleted_at": nil}
+
+	result, err := repo.getBookCollection().UpdateOne(ctx, filter, trashUpdate(time.Now().UTC()))
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("nothing to delete: %w", errBookNotFound)
+	}
+
+	return nil
+}
+
+func (repo MongoDBBookRepository) DeleteBookVersion(ctx context.Context, id ID, version int64) error {
+	bookID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	filter := versionFilter(bookID, version)
+	filter["tenant"] = TenantFromContext(ctx)
+	filter["deleted_at"] = nil
+
+	result, err := repo.getBookCollection().UpdateOne(ctx, filter, trashUpdate(time.Now().UTC()))
 	if err != nil {
-		return fmt.Errorf("ID is not a valid hex string: %w", err)
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
 	}
 
-	filter := bson.M{"_id": bookID}
+	if result.MatchedCount > 0 {
+		return nil
+	}
 
-	result, err := repo.getBookCollection().DeleteOne(ctx, filter)
+	count, err := repo.getBookCollection().CountDocuments(ctx,
+		bson.M{"_id": bookID, "tenant": TenantFromContext(ctx), "deleted_at": nil})
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	if count == 0 {
+		return fmt.Errorf("nothing to delete: %w", errBookNotFound)
+	}
+
+	return ErrVersionMismatch
+}
+
+// trashUpdate moves a book to the trash.
+func trashUpdate(now time.Time) bson.M {
+	return bson.M{
+		"$set": bson.M{"deleted_at": now, "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	}
+}
+
+func (repo MongoDBBookRepository) RestoreBook(ctx context.Context, id ID) (*models.Book, error) {
+	bookID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	book := &models.Book{}
+
+	err = repo.getBookCollection().FindOneAndUpdate(ctx,
+		bson.M{"_id": bookID, "tenant": TenantFromContext(ctx), "deleted_at": bson.M{"$ne": nil}},
+		bson.M{
+			"$unset": bson.M{"deleted_at": ""},
+			"$set":   bson.M{"updated_at": time.Now().UTC()},
+			"$inc":   bson.M{"version": 1},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(book)
 	if errors.Is(err, mongo.ErrNoDocuments) {
-		return fmt.Errorf("can't find a book: %w", err)
+		return nil, fmt.Errorf("nothing to restore: %w", errBookNotFound)
 	} else if err != nil {
-		return fmt.Errorf("some database error has occurred: %w", err)
+		return nil, fmt.Errorf("can't restore a book: %w", classifyMongoError(err))
 	}
-	if result.DeletedCount == 0 {
-		return errors.New("book not found. Nothing to delete")
+
+	return book, nil
+}
+
+func (repo MongoDBBookRepository) PurgeBooks(ctx context.Context, deletedBefore time.Time) (int, error) {
+	result, err := repo.getBookCollection().DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": deletedBefore}})
+	if err != nil {
+		return 0, fmt.Errorf("can't purge books: %w", classifyMongoError(err))
 	}
 
-	return nil
+	return int(result.DeletedCount), nil
 }
 
 func (repo MongoDBBookRepository) AllBooks(ctx context.Context) ([]*models.Book, error) {
 	var books []*models.Book
 
-	filter := bson.M{}
+	filter := bson.M{"tenant": TenantFromContext(ctx), "deleted_at": nil}
 
 	cur, err := repo.getBookCollection().Find(ctx, filter)
 	if err != nil {
-		return nil, fmt.Errorf("some database error has occurred: %w", err)
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
 	}
 
 	defer cur.Close(ctx)
@@ -95,22 +374,328 @@ func (repo MongoDBBookRepository) AllBooks(ctx context.Context) ([]*models.Book,
 
 		err := cur.Decode(&result)
 		if err != nil {
-			return nil, fmt.Errorf("can't decode a book: %w", err)
+			return nil, fmt.Errorf("can't decode a book: %w", classifyMongoError(err))
 		}
 		books = append(books, result)
 	}
 
 	if err := cur.Err(); err != nil {
-		return nil, fmt.Errorf("some database error has occurred: %w", err)
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
 	}
 
 	return books, nil
 }
 
+func (repo MongoDBBookRepository) QueryBooks(ctx context.Context, query BookQuery) (*BookPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	filter := mongoFilter(TenantFromContext(ctx), query.Filter)
+	if after != nil {
+		filter = bson.M{"$and": bson.A{filter, mongoAfter(after, query.Sort)}}
+	}
+
+	opts := options.Find().
+		SetSort(mongoSort(query.Sort)).
+		SetLimit(int64(query.Limit + 1))
+
+	cur, err := repo.getBookCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	books := make([]*models.Book, 0, query.Limit+1)
+	if err := cur.All(ctx, &books); err != nil {
+		return nil, fmt.Errorf("can't decode books: %w", classifyMongoError(err))
+	}
+
+	page := &BookPage{
+		Books:      books,
+		NextCursor: nextBookCursor(books, query),
+	}
+	if len(books) > query.Limit {
+		page.Books = books[:query.Limit]
+	}
+
+	return page, nil
+}
+
+// IterateBooks streams the books from a cursor, which fetches them from
+// the server in batches as the iteration goes on.
+func (repo MongoDBBookRepository) IterateBooks(
+	ctx context.Context,
+	filter BookFilter,
+	sort []SortField,
+) (BookIterator, error) {
+	if err := checkSort(sort); err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(mongoSort(sort))
+
+	cur, err := repo.getBookCollection().Find(ctx, mongoFilter(TenantFromContext(ctx), filter), opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	return &mongoBookIterator{cur: cur}, nil
+}
+
+type mongoBookIterator struct {
+	cur  *mongo.Cursor
+	book *models.Book
+	err  error
+}
+
+func (it *mongoBookIterator) Next(ctx context.Context) bool {
+	it.book = nil
+
+	if it.err != nil || !it.cur.Next(ctx) {
+		return false
+	}
+
+	book := &models.Book{}
+	if err := it.cur.Decode(book); err != nil {
+		it.err = fmt.Errorf("can't decode a book: %w", classifyMongoError(err))
+		return false
+	}
+
+	it.book = book
+
+	return true
+}
+
+func (it *mongoBookIterator) Book() *models.Book {
+	return it.book
+}
+
+func (it *mongoBookIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+
+	if err := it.cur.Err(); err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (it *mongoBookIterator) Close(ctx context.Context) error {
+	if err := it.cur.Close(ctx); err != nil {
+		return fmt.Errorf("can't close a cursor: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+// SearchBooks relies on the text index created by Migrate and ranks books
+// by MongoDB's text score.
+func (repo MongoDBBookRepository) SearchBooks(ctx context.Context, search BookSearch) (*SearchPage, error) {
+	search, offset, err := search.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(bson.D{{Key: "score", Value: score}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(search.Limit + 1))
+
+	filter := bson.M{"tenant": TenantFromContext(ctx), "$text": bson.M{"$search": search.Text}, "deleted_at": nil}
+
+	cur, err := repo.getBookCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	var found []struct {
+		models.Book `bson:",inline"`
+		Score       float64 `bson:"score"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("can't decode books: %w", classifyMongoError(err))
+	}
+
+	page := &SearchPage{
+		Results:    make([]SearchResult, 0, len(found)),
+		NextCursor: nextCursor(offset, search.Limit, len(found)),
+	}
+
+	for i := range found {
+		if i == search.Limit {
+			break
+		}
+
+		book := found[i].Book
+		page.Results = append(page.Results, SearchResult{Book: &book, Score: found[i].Score})
+	}
+
+	return page, nil
+}
+
+// Contributors unwinds the credits of every book and groups them by name.
+func (repo MongoDBBookRepository) Contributors(ctx context.Context, query ContributorQuery) (*ContributorPage, error) {
+	query, offset, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	match := bson.M{"credits.name": bson.M{"$type": "string"}}
+	if query.Role != "" {
+		match["credits.role"] = query.Role
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant": TenantFromContext(ctx), "deleted_at": nil}}},
+		{{Key: "$project", Value: bson.M{"credits": mongoCredits}}},
+		{{Key: "$unwind", Value: "$credits"}},
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$credits.name"},
+			{Key: "sort_name", Value: bson.M{"$min": "$credits.sort_name"}},
+			{Key: "roles", Value: bson.M{"$addToSet": "$credits.role"}},
+			{Key: "books", Value: bson.M{"$addToSet": "$_id"}},
+		}}},
+		{{Key: "$project", Value: bson.M{"sort_name": 1, "roles": 1, "books": bson.M{"$size": "$books"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "sort_name", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$skip", Value: offset}},
+		{{Key: "$limit", Value: query.Limit + 1}},
+	}
+
+	cur, err := repo.getBookCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	var found []struct {
+		Name     string                   `bson:"_id"`
+		SortName string                   `bson:"sort_name"`
+		Roles    []models.ContributorRole `bson:"roles"`
+		Books    int                      `bson:"books"`
+	}
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("can't decode contributors: %w", classifyMongoError(err))
+	}
+
+	page := &ContributorPage{
+		Contributors: make([]ContributorCount, 0, len(found)),
+		NextCursor:   nextCursor(offset, query.Limit, len(found)),
+	}
+
+	for i, contributor := range found {
+		if i == query.Limit {
+			break
+		}
+
+		// $addToSet doesn't keep any order
+		sort.Slice(contributor.Roles, func(a, b int) bool { return contributor.Roles[a] < contributor.Roles[b] })
+
+		page.Contributors = append(page.Contributors, ContributorCount{
+			Name:     contributor.Name,
+			SortName: contributor.SortName,
+			Roles:    contributor.Roles,
+			Books:    contributor.Books,
+		})
+	}
+
+	return page, nil
+}
+
+// Facets counts all facets in a single $facet stage over the filtered
+// books.
+func (repo MongoDBBookRepository) Facets(ctx context.Context, filter BookFilter) (*BookFacets, error) {
+	countValues := func(field string) bson.A {
+		return bson.A{
+			bson.M{"$unwind": "$" + field},
+			bson.M{"$match": bson.M{field: bson.M{"$nin": bson.A{"", nil}}}},
+			bson.M{"$sortByCount": "$" + field},
+		}
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: mongoFilter(TenantFromContext(ctx), filter)}},
+		{{Key: "$facet", Value: bson.M{
+			"genres":     countValues("genres"),
+			"tags":       countValues("tags"),
+			"publishers": countValues("publisher"),
+			"statuses":   countValues("status"),
+		}}},
+	}
+
+	cur, err := repo.getBookCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	type facetCount struct {
+		Value string `bson:"_id"`
+		Count int    `bson:"count"`
+	}
+
+	type facets struct {
+		Genres     []facetCount `bson:"genres"`
+		Tags       []facetCount `bson:"tags"`
+		Publishers []facetCount `bson:"publishers"`
+		Statuses   []facetCount `bson:"statuses"`
+	}
+
+	// $facet returns a single document
+	found := make([]facets, 0, 1)
+	if err := cur.All(ctx, &found); err != nil {
+		return nil, fmt.Errorf("can't decode facets: %w", classifyMongoError(err))
+	}
+
+	// $sortByCount leaves the order of equal counts open
+	counts := func(found []facetCount) []FacetCount {
+		counts := make([]FacetCount, 0, len(found))
+		for _, count := range found {
+			counts = append(counts, FacetCount{Value: count.Value, Count: count.Count})
+		}
+
+		sortFacet(counts)
+
+		return counts
+	}
+
+	var result facets
+	if len(found) > 0 {
+		result = found[0]
+	}
+
+	return &BookFacets{
+		Genres:     counts(result.Genres),
+		Tags:       counts(result.Tags),
+		Publishers: counts(result.Publishers),
+		Statuses:   counts(result.Statuses),
+	}, nil
+}
+
+// mongoCredits evaluates to the contributors of a book the way
+// models.Book.Credits does: books without contributors credit their author.
+var mongoCredits = bson.M{"$ifNull": bson.A{ //nolint:gochecknoglobals
+	"$contributors",
+	bson.A{bson.M{"name": "$author", "role": models.RoleAuthor, "sort_name": "$author"}},
+}}
+
+// RemoveAllBooks deletes the documents of the tenant, the collection is
+// shared with the other tenants.
 func (repo MongoDBBookRepository) RemoveAllBooks(ctx context.Context) error {
-	err := repo.getBookCollection().Drop(ctx)
+	_, err := repo.getBookCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
 	if err != nil {
-		return fmt.Errorf("can't drop a book collection: %w", err)
+		return fmt.Errorf("can't remove books: %w", classifyMongoError(err))
 	}
 
 	return nil
@@ -121,44 +706,245 @@ func (repo MongoDBBookRepository) UpdateBook(
 	bookID ID,
 	updateFn func(book *models.Book) (*models.Book, error),
 ) (*models.Book, error) {
-	var updatedBook *models.Book
-	err := repo.Client.UseSession(ctx, func(sessionContext mongo.SessionContext) error {
-		err := sessionContext.StartTransaction()
-		if err != nil {
-			return err
-		}
+	book, err := repo.GetBook(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
 
-		book, err := repo.GetBook(ctx, bookID)
-		if err != nil {
-			sessionContext.AbortTransaction(ctx)
+	version := book.Version
 
-			return err
+	updatedBook, err := updateFn(book)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", err)
+	}
+
+	updatedBook.ID = book.ID
+	updatedBook.Version = version + 1
+	updatedBook.CreatedAt = book.CreatedAt
+	updatedBook.UpdatedAt = time.Now().UTC()
+	updatedBook.DeletedAt = nil
+	updatedBook.Tenant = book.Tenant
+
+	// compare-and-swap: the replace only matches if nobody bumped the
+	// version since we read the book
+	result, err := repo.getBookCollection().ReplaceOne(ctx, versionFilter(book.ID, version), updatedBook)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", classifyMongoError(err))
+	}
+
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("failed to update book: %w", ErrVersionMismatch)
+	}
+
+	return updatedBook, nil
+}
+
+// versionFilter matches a book at the given version. Books stored before
+// versioning have no version field and count as version 0.
+func versionFilter(id primitive.ObjectID, version int64) bson.M {
+	if version == 0 {
+		return bson.M{"_id": id, "version": bson.M{"$in": bson.A{0, nil}}}
+	}
+
+	return bson.M{"_id": id, "version": version}
+}
+
+// migrateToTenants assigns the documents stored before there were tenants
+// to the default tenant and drops the indexes that tenant indexes replace.
+func migrateToTenants(ctx context.Context, collection *mongo.Collection, replaced ...string) error {
+	_, err := collection.UpdateMany(ctx,
+		bson.M{"tenant": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"tenant": DefaultTenant}},
+	)
+	if err != nil {
+		return fmt.Errorf("can't assign documents to the default tenant: %w", classifyMongoError(err))
+	}
+
+	for _, name := range replaced {
+		_, err := collection.Indexes().DropOne(ctx, name)
+
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) { // no collection, no index
+			err = nil
 		}
 
-		updatedBook, err = updateFn(book)
 		if err != nil {
-			sessionContext.AbortTransaction(ctx)
+			return fmt.Errorf("can't drop an index: %w", classifyMongoError(err))
+		}
+	}
 
-			return err
+	return nil
+}
+
+func mongoFilter(tenant string, filter BookFilter) bson.M {
+	query := bson.M{"tenant": tenant, "deleted_at": nil}
+
+	if filter.Deleted {
+		query["deleted_at"] = bson.M{"$ne": nil}
+	}
+
+	if filter.Author != "" {
+		query["author"] = filter.Author
+	}
+
+	if filter.Contributor != "" || filter.Role != "" {
+		query["$or"] = mongoContributorFilter(filter)
+	}
+
+	if filter.Genre != "" {
+		query["genres"] = filter.Genre
+	}
+
+	if filter.Tag != "" {
+		query["tags"] = filter.Tag
+	}
+
+	if filter.Publisher != "" {
+		query["publisher"] = filter.Publisher
+	}
+
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+
+	// Zero ratings are not stored (omitempty), so a missing field has to be
+	// treated as 0 when comparing.
+	var rating bson.A
+	if filter.MinRating != nil {
+		if *filter.MinRating > 0 {
+			rating = append(rating, bson.M{"rating": bson.M{"$gte": *filter.MinRating}})
+		} else {
+			rating = append(rating, bson.M{"rating": bson.M{"$not": bson.M{"$lt": *filter.MinRating}}})
 		}
-		filter := bson.M{"_id": bson.M{"$eq": book.ID}}
+	}
 
-		opts := options.Replace().SetUpsert(true)
-		_, err = repo.getBookCollection().ReplaceOne(ctx, filter, updatedBook, opts)
-		if err != nil {
-			return err
+	if filter.MaxRating != nil {
+		if *filter.MaxRating < 0 {
+			rating = append(rating, bson.M{"rating": bson.M{"$lte": *filter.MaxRating}})
+		} else {
+			rating = append(rating, bson.M{"rating": bson.M{"$not": bson.M{"$gt": *filter.MaxRating}}})
 		}
+	}
 
-		err = sessionContext.CommitTransaction(ctx)
-		if err != nil {
-			return err
+	if len(rating) > 0 {
+		query["$and"] = rating
+	}
+
+	if createdAt := timeRange(filter.CreatedAfter, filter.CreatedBefore); len(createdAt) > 0 {
+		query["created_at"] = createdAt
+	}
+
+	if updatedAt := timeRange(filter.UpdatedAfter, filter.UpdatedBefore); len(updatedAt) > 0 {
+		query["updated_at"] = updatedAt
+	}
+
+	return query
+}
+
+func mongoContributorFilter(filter BookFilter) bson.A {
+	credit := bson.M{}
+
+	if filter.Contributor != "" {
+		credit["name"] = filter.Contributor
+	}
+
+	if filter.Role != "" {
+		credit["role"] = filter.Role
+	}
+
+	or := bson.A{bson.M{"contributors": bson.M{"$elemMatch": credit}}}
+
+	// books stored before contributors were introduced credit their author,
+	// see models.Book.Credits
+	if filter.Role == "" || filter.Role == models.RoleAuthor {
+		legacy := bson.M{"contributors": bson.M{"$exists": false}, "author": bson.M{"$exists": true}}
+		if filter.Contributor != "" {
+			legacy["author"] = filter.Contributor
 		}
 
+		or = append(or, legacy)
+	}
+
+	return or
+}
+
+func timeRange(after, before *time.Time) bson.M {
+	query := bson.M{}
+
+	if after != nil {
+		query["$gte"] = *after
+	}
+
+	if before != nil {
+		query["$lt"] = *before
+	}
+
+	return query
+}
+
+// mongoAfter matches the books following after in the order of mongoSort:
+// those past it in the first sort field, those equal in the first and past
+// it in the second and so on, and finally those equal in every field with
+// a greater ID.
+func mongoAfter(after *models.Book, fields []SortField) bson.M {
+	var (
+		or    bson.A
+		equal bson.A
+	)
+
+	for _, field := range fields {
+		key := sortKey(after, field.Field)
+
+		if past := mongoPast(field, key); past != nil {
+			or = append(or, bson.M{"$and": append(equal[:len(equal):len(equal)], past)})
+		}
+
+		equal = append(equal, mongoEqual(field.Field, key))
+	}
+
+	or = append(or, bson.M{"$and": append(equal, bson.M{"_id": bson.M{"$gt": after.ID}})})
+
+	return bson.M{"$or": or}
+}
+
+// mongoPast matches the values of field past key. Zero values aren't stored
+// (omitempty) and sort first, nothing sorts before them.
+func mongoPast(field SortField, key interface{}) bson.M {
+	switch {
+	case isZeroSortKey(key) && field.Descending:
 		return nil
-	})
-	if err != nil {
-		return updatedBook, fmt.Errorf("failed to update book: %w", err)
+	case isZeroSortKey(key):
+		return bson.M{field.Field: bson.M{"$nin": bson.A{nil, key}}}
+	case field.Descending:
+		return bson.M{field.Field: bson.M{"$not": bson.M{"$gte": key}}}
 	}
 
-	return updatedBook, nil
+	return bson.M{field.Field: bson.M{"$gt": key}}
+}
+
+func mongoEqual(field string, key interface{}) bson.M {
+	if isZeroSortKey(key) {
+		return bson.M{field: bson.M{"$in": bson.A{nil, key}}}
+	}
+
+	return bson.M{field: key}
+}
+
+func isZeroSortKey(key interface{}) bool {
+	return key == nil || key == "" || key == 0
+}
+
+func mongoSort(fields []SortField) bson.D {
+	sort := bson.D{}
+
+	for _, field := range fields {
+		direction := 1
+		if field.Descending {
+			direction = -1
+		}
+
+		sort = append(sort, bson.E{Key: field.Field, Value: direction})
+	}
+
+	return append(sort, bson.E{Key: "_id", Value: 1})
 }
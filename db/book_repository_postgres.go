@@ -0,0 +1,1009 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// postgresSearchWeights are the ts_rank weights of the D, C, B and A
+// labels, they mirror the weights of the other backends.
+const postgresSearchWeights = "{0, 0.1, 0.5, 1}"
+
+const bookColumns = "id, isbn, title, author, contributors, publisher, genres, tags, dewey, lcc, " +
+	"rating, status, version, created_at, updated_at, deleted_at, tenant"
+
+// postgresCredits evaluates to the contributors of a book the way
+// models.Book.Credits does: books without contributors credit their author.
+const postgresCredits = "CASE WHEN jsonb_array_length(contributors) = 0 AND author <> '' " +
+	"THEN jsonb_build_array(jsonb_build_object('name', author, 'role', '" + string(models.RoleAuthor) + "', " +
+	"'sort_name', author)) ELSE contributors END"
+
+type PostgresBookRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresBookRepository(db *sql.DB) PostgresBookRepository {
+	return PostgresBookRepository{
+		DB: db,
+	}
+}
+
+// Migrate creates the tables and indexes the repository relies on. It is
+// safe to run on every start.
+func (repo PostgresBookRepository) Migrate(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, postgresSchema)
+	if err != nil {
+		return fmt.Errorf("can't migrate database schema: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresBookRepository) AddBook(ctx context.Context, book *models.Book) (ID, error) {
+	return insertBook(ctx, repo.DB, book)
+}
+
+// postgresExecutor runs statements on the database or in a transaction.
+type postgresExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func insertBook(ctx context.Context, exec postgresExecutor, book *models.Book) (ID, error) {
+	now := time.Now().UTC()
+	book.ID = primitive.NewObjectID()
+	book.Version = 1
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.DeletedAt = nil
+	book.Tenant = TenantFromContext(ctx)
+
+	lists, err := bookListsJSON(book)
+	if err != nil {
+		return ID(""), err
+	}
+
+	_, err = exec.ExecContext(ctx,
+		"INSERT INTO "+BookTableName+" ("+bookColumns+") VALUES "+
+			"($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULL, $16)",
+		book.ID.Hex(), book.ISBN, book.Title, book.Author, lists.contributors, book.Publisher, lists.genres,
+		lists.tags, book.Dewey, book.LCC, book.Rating, book.Status, book.Version, book.CreatedAt, book.UpdatedAt,
+		book.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a book: %w", classifyPostgresError(err))
+	}
+
+	return ID(book.ID.Hex()), nil
+}
+
+// AddBooks inserts the batch in a single transaction. In a best-effort
+// batch a book whose ISBN is taken is skipped with ON CONFLICT so that it
+// doesn't abort the others.
+func (repo PostgresBookRepository) AddBooks(ctx context.Context, books []*models.Book, mode BatchMode) ([]error, error) {
+	if mode == BatchAtomic {
+		return repo.runBatch(ctx, len(books), mode, func(tx *sql.Tx, i int) error {
+			_, err := insertBook(ctx, tx, books[i])
+
+			return err
+		})
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	stmt, err := tx.PrepareContext(ctx,
+		"INSERT INTO "+BookTableName+" ("+bookColumns+") VALUES "+
+			"($1, NULLIF($2, ''), $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, NULL, $16) "+
+			"ON CONFLICT DO NOTHING")
+	if err != nil {
+		return nil, fmt.Errorf("can't insert books: %w", classifyPostgresError(err))
+	}
+
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	tenant := TenantFromContext(ctx)
+	errs := make([]error, len(books))
+
+	for i, book := range books {
+		book.ID = primitive.NewObjectID()
+		book.Version = 1
+		book.CreatedAt = now
+		book.UpdatedAt = now
+		book.DeletedAt = nil
+		book.Tenant = tenant
+
+		lists, err := bookListsJSON(book)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		result, err := stmt.ExecContext(ctx,
+			book.ID.Hex(), book.ISBN, book.Title, book.Author, lists.contributors, book.Publisher, lists.genres,
+			lists.tags, book.Dewey, book.LCC, book.Rating, book.Status, book.Version, book.CreatedAt, book.UpdatedAt,
+			book.Tenant,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("can't insert books: %w", classifyPostgresError(err))
+		}
+
+		if inserted, err := result.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("can't insert books: %w", classifyPostgresError(err))
+		} else if inserted == 0 {
+			errs[i] = fmt.Errorf("%w: a book with ISBN %s already exists", ErrConflict, book.ISBN)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("can't insert books: %w", classifyPostgresError(err))
+	}
+
+	return errs, nil
+}
+
+func (repo PostgresBookRepository) UpdateBooks(
+	ctx context.Context,
+	changes []BookChange,
+	mode BatchMode,
+) ([]*models.Book, []error, error) {
+	books := make([]*models.Book, len(changes))
+
+	errs, err := repo.runBatch(ctx, len(changes), mode, func(tx *sql.Tx, i int) error {
+		var err error
+		books[i], err = updateBook(ctx, tx, changes[i].ID, changes[i].Update)
+
+		return err
+	})
+	if err != nil {
+		return nil, errs, err
+	}
+
+	return books, errs, nil
+}
+
+func (repo PostgresBookRepository) DeleteBooks(ctx context.Context, deletions []BookDeletion, mode BatchMode) ([]error, error) {
+	return repo.runBatch(ctx, len(deletions), mode, func(tx *sql.Tx, i int) error {
+		if deletions[i].Version == 0 {
+			return deleteBook(ctx, tx, deletions[i].ID)
+		}
+
+		return deleteBookVersion(ctx, tx, deletions[i].ID, deletions[i].Version)
+	})
+}
+
+// runBatch applies n items of a batch. An atomic batch runs in a single
+// transaction that is rolled back by the first failing item, every item of
+// a best-effort batch runs in a transaction of its own.
+func (repo PostgresBookRepository) runBatch(
+	ctx context.Context,
+	n int,
+	mode BatchMode,
+	apply func(tx *sql.Tx, i int) error,
+) ([]error, error) {
+	errs := make([]error, n)
+
+	if mode != BatchAtomic {
+		for i := range errs {
+			tx, err := repo.DB.BeginTx(ctx, nil)
+			if err != nil {
+				return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+			}
+
+			if errs[i] = apply(tx, i); errs[i] != nil {
+				tx.Rollback() //nolint:errcheck
+				continue
+			}
+
+			if err := tx.Commit(); err != nil {
+				errs[i] = fmt.Errorf("can't apply a change: %w", classifyPostgresError(err))
+			}
+		}
+
+		return errs, nil
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	for i := range errs {
+		if errs[i] = apply(tx, i); errs[i] != nil {
+			return abortBatch(errs), ErrBatchAborted
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("can't apply a batch: %w", classifyPostgresError(err))
+	}
+
+	return errs, nil
+}
+
+func (repo PostgresBookRepository) GetBook(ctx context.Context, id ID) (*models.Book, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+bookColumns+" FROM "+BookTableName+" WHERE id = $1 AND tenant = $2 AND deleted_at IS NULL",
+		string(id), TenantFromContext(ctx))
+
+	return scanBook(row)
+}
+
+func (repo PostgresBookRepository) GetBookByISBN(ctx context.Context, isbn string) (*models.Book, error) {
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+bookColumns+" FROM "+BookTableName+" WHERE isbn = $1 AND tenant = $2 AND deleted_at IS NULL",
+		isbn, TenantFromContext(ctx))
+
+	return scanBook(row)
+}
+
+func (repo PostgresBookRepository) DeleteBook(ctx context.Context, id ID) error {
+	return deleteBook(ctx, repo.DB, id)
+}
+
+func deleteBook(ctx context.Context, exec postgresExecutor, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	result, err := exec.ExecContext(ctx,
+		"UPDATE "+BookTableName+" SET "+postgresTrash+" WHERE id = $1 AND tenant = $3 AND deleted_at IS NULL",
+		string(id), time.Now().UTC(), TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("nothing to delete: %w", errBookNotFound)
+	}
+
+	return nil
+}
+
+func (repo PostgresBookRepository) DeleteBookVersion(ctx context.Context, id ID, version int64) error {
+	return deleteBookVersion(ctx, repo.DB, id, version)
+}
+
+func deleteBookVersion(ctx context.Context, exec postgresExecutor, id ID, version int64) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	var deleted, exists bool
+
+	// a single statement so that the existence check sees the same snapshot
+	err := exec.QueryRowContext(ctx,
+		"WITH deleted AS (UPDATE "+BookTableName+" SET "+postgresTrash+
+			" WHERE id = $1 AND version = $3 AND tenant = $4 AND deleted_at IS NULL RETURNING id) "+
+			"SELECT EXISTS (SELECT 1 FROM deleted), "+
+			"EXISTS (SELECT 1 FROM "+BookTableName+" WHERE id = $1 AND tenant = $4 AND deleted_at IS NULL)",
+		string(id), time.Now().UTC(), version, TenantFromContext(ctx),
+	).Scan(&deleted, &exists)
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	switch {
+	case deleted:
+		return nil
+	case exists:
+		return ErrVersionMismatch
+	}
+
+	return fmt.Errorf("nothing to delete: %w", errBookNotFound)
+}
+
+// postgresTrash moves a book to the trash at the time $2.
+const postgresTrash = "deleted_at = $2, updated_at = $2, version = version + 1"
+
+func (repo PostgresBookRepository) RestoreBook(ctx context.Context, id ID) (*models.Book, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"UPDATE "+BookTableName+" SET deleted_at = NULL, updated_at = $2, version = version + 1 "+
+			"WHERE id = $1 AND tenant = $3 AND deleted_at IS NOT NULL RETURNING "+bookColumns,
+		string(id), time.Now().UTC(), TenantFromContext(ctx))
+
+	book, err := scanBook(row)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("nothing to restore: %w", errBookNotFound)
+	}
+
+	return book, err
+}
+
+func (repo PostgresBookRepository) PurgeBooks(ctx context.Context, deletedBefore time.Time) (int, error) {
+	result, err := repo.DB.ExecContext(ctx, "DELETE FROM "+BookTableName+" WHERE deleted_at < $1", deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("can't purge books: %w", classifyPostgresError(err))
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("can't purge books: %w", classifyPostgresError(err))
+	}
+
+	return int(purged), nil
+}
+
+func (repo PostgresBookRepository) AllBooks(ctx context.Context) ([]*models.Book, error) {
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+bookColumns+" FROM "+BookTableName+" WHERE tenant = $1 AND deleted_at IS NULL",
+		TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return scanBooks(rows)
+}
+
+func (repo PostgresBookRepository) QueryBooks(ctx context.Context, query BookQuery) (*BookPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := postgresFilter(TenantFromContext(ctx), query.Filter)
+	if after != nil {
+		where += " AND " + postgresAfter(after, query.Sort, &args)
+	}
+
+	args = append(args, query.Limit+1)
+
+	statement := "SELECT " + bookColumns + " FROM " + BookTableName + where +
+		postgresOrderBy(query.Sort) +
+		fmt.Sprintf(" LIMIT $%d", len(args))
+
+	rows, err := repo.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	books, err := scanBooks(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &BookPage{
+		Books:      books,
+		NextCursor: nextBookCursor(books, query),
+	}
+	if len(books) > query.Limit {
+		page.Books = books[:query.Limit]
+	}
+
+	return page, nil
+}
+
+// IterateBooks streams the rows of a single query, the driver reads them
+// from the connection as the iteration goes on.
+func (repo PostgresBookRepository) IterateBooks(
+	ctx context.Context,
+	filter BookFilter,
+	sort []SortField,
+) (BookIterator, error) {
+	if err := checkSort(sort); err != nil {
+		return nil, err
+	}
+
+	where, args := postgresFilter(TenantFromContext(ctx), filter)
+	statement := "SELECT " + bookColumns + " FROM " + BookTableName + where + postgresOrderBy(sort)
+
+	rows, err := repo.DB.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return &postgresBookIterator{rows: rows}, nil
+}
+
+type postgresBookIterator struct {
+	rows *sql.Rows
+	book *models.Book
+	err  error
+}
+
+// Next ignores ctx, the rows are bound to the context of the query.
+func (it *postgresBookIterator) Next(ctx context.Context) bool {
+	it.book = nil
+
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+
+	it.book, it.err = scanBook(it.rows)
+
+	return it.err == nil
+}
+
+func (it *postgresBookIterator) Book() *models.Book {
+	return it.book
+}
+
+func (it *postgresBookIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+
+	if err := it.rows.Err(); err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (it *postgresBookIterator) Close(ctx context.Context) error {
+	if err := it.rows.Close(); err != nil {
+		return fmt.Errorf("can't close rows: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+// SearchBooks matches books containing any of the words of the search and
+// ranks them with ts_rank.
+func (repo PostgresBookRepository) SearchBooks(ctx context.Context, search BookSearch) (*SearchPage, error) {
+	search, offset, err := search.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SearchPage{Results: make([]SearchResult, 0)}
+
+	words := searchWords(search.Text)
+	if len(words) == 0 {
+		return page, nil
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+bookColumns+", ts_rank('"+postgresSearchWeights+"', search, query) AS score "+
+			"FROM "+BookTableName+", to_tsquery('english', $1) query "+
+			"WHERE search @@ query AND tenant = $4 AND deleted_at IS NULL "+
+			"ORDER BY score DESC, id ASC LIMIT $2 OFFSET $3",
+		strings.Join(words, " | "), search.Limit+1, offset, TenantFromContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var score float64
+
+		book, err := scanBook(scoredRow{row: rows, score: &score})
+		if err != nil {
+			return nil, err
+		}
+
+		page.Results = append(page.Results, SearchResult{Book: book, Score: score})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	page.NextCursor = nextCursor(offset, search.Limit, len(page.Results))
+	if len(page.Results) > search.Limit {
+		page.Results = page.Results[:search.Limit]
+	}
+
+	return page, nil
+}
+
+func (repo PostgresBookRepository) Contributors(ctx context.Context, query ContributorQuery) (*ContributorPage, error) {
+	query, offset, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT credit->>'name' AS name, min(credit->>'sort_name') AS sort_name, "+
+			"string_agg(DISTINCT credit->>'role', ',' ORDER BY credit->>'role'), count(DISTINCT id) "+
+			"FROM "+BookTableName+", jsonb_array_elements("+postgresCredits+") credit "+
+			"WHERE tenant = $4 AND deleted_at IS NULL AND ($1 = '' OR credit->>'role' = $1) "+
+			"GROUP BY credit->>'name' ORDER BY sort_name, name LIMIT $2 OFFSET $3",
+		query.Role, query.Limit+1, offset, TenantFromContext(ctx),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	page := &ContributorPage{Contributors: make([]ContributorCount, 0)}
+
+	for rows.Next() {
+		var (
+			contributor ContributorCount
+			roles       string
+		)
+
+		if err := rows.Scan(&contributor.Name, &contributor.SortName, &roles, &contributor.Books); err != nil {
+			return nil, fmt.Errorf("can't decode contributors: %w", classifyPostgresError(err))
+		}
+
+		for _, role := range strings.Split(roles, ",") {
+			contributor.Roles = append(contributor.Roles, models.ContributorRole(role))
+		}
+
+		page.Contributors = append(page.Contributors, contributor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	page.NextCursor = nextCursor(offset, query.Limit, len(page.Contributors))
+	if len(page.Contributors) > query.Limit {
+		page.Contributors = page.Contributors[:query.Limit]
+	}
+
+	return page, nil
+}
+
+// Facets counts all facets in a single statement over the filtered books.
+func (repo PostgresBookRepository) Facets(ctx context.Context, filter BookFilter) (*BookFacets, error) {
+	where, args := postgresFilter(TenantFromContext(ctx), filter)
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"WITH filtered AS (SELECT genres, tags, publisher, status FROM "+BookTableName+where+") "+
+			"SELECT 'genres', value, count(*) FROM filtered, jsonb_array_elements_text(genres) value GROUP BY value "+
+			"UNION ALL SELECT 'tags', value, count(*) FROM filtered, jsonb_array_elements_text(tags) value GROUP BY value "+
+			"UNION ALL SELECT 'publishers', publisher, count(*) FROM filtered WHERE publisher <> '' GROUP BY publisher "+
+			"UNION ALL SELECT 'statuses', status, count(*) FROM filtered WHERE status <> '' GROUP BY status",
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	facets := &BookFacets{
+		Genres:     make([]FacetCount, 0),
+		Tags:       make([]FacetCount, 0),
+		Publishers: make([]FacetCount, 0),
+		Statuses:   make([]FacetCount, 0),
+	}
+	lists := map[string]*[]FacetCount{
+		"genres":     &facets.Genres,
+		"tags":       &facets.Tags,
+		"publishers": &facets.Publishers,
+		"statuses":   &facets.Statuses,
+	}
+
+	for rows.Next() {
+		var (
+			facet string
+			count FacetCount
+		)
+
+		if err := rows.Scan(&facet, &count.Value, &count.Count); err != nil {
+			return nil, fmt.Errorf("can't decode facets: %w", classifyPostgresError(err))
+		}
+
+		*lists[facet] = append(*lists[facet], count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	for _, list := range lists {
+		sortFacet(*list)
+	}
+
+	return facets, nil
+}
+
+func (repo PostgresBookRepository) RemoveAllBooks(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+BookTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove books: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresBookRepository) UpdateBook(
+	ctx context.Context,
+	bookID ID,
+	updateFn func(book *models.Book) (*models.Book, error),
+) (*models.Book, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	updatedBook, err := updateBook(ctx, tx, bookID, updateFn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", classifyPostgresError(err))
+	}
+
+	return updatedBook, nil
+}
+
+// updateBook locks the row of the book for the rest of the transaction.
+func updateBook(
+	ctx context.Context,
+	tx *sql.Tx,
+	bookID ID,
+	updateFn func(book *models.Book) (*models.Book, error),
+) (*models.Book, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+bookColumns+" FROM "+BookTableName+" WHERE id = $1 AND tenant = $2 AND deleted_at IS NULL FOR UPDATE",
+		string(bookID), TenantFromContext(ctx))
+
+	book, err := scanBook(row)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedBook, err := updateFn(book)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", err)
+	}
+
+	version := book.Version
+	updatedBook.ID = book.ID
+	updatedBook.Version = version + 1
+	updatedBook.CreatedAt = book.CreatedAt
+	updatedBook.UpdatedAt = time.Now().UTC()
+	updatedBook.DeletedAt = nil
+	updatedBook.Tenant = book.Tenant
+
+	lists, err := bookListsJSON(updatedBook)
+	if err != nil {
+		return nil, err
+	}
+
+	// the row is locked FOR UPDATE, the version check keeps the statement
+	// correct on its own
+	result, err := tx.ExecContext(ctx,
+		"UPDATE "+BookTableName+" SET isbn = NULLIF($3, ''), title = $4, author = $5, contributors = $6, "+
+			"publisher = $7, genres = $8, tags = $9, dewey = $10, lcc = $11, rating = $12, status = $13, "+
+			"version = $14, updated_at = $15 WHERE id = $1 AND version = $2",
+		string(bookID), version, updatedBook.ISBN, updatedBook.Title, updatedBook.Author, lists.contributors,
+		updatedBook.Publisher, lists.genres, lists.tags, updatedBook.Dewey, updatedBook.LCC, updatedBook.Rating,
+		updatedBook.Status, updatedBook.Version, updatedBook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", classifyPostgresError(err))
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update book: %w", classifyPostgresError(err))
+	} else if updated == 0 {
+		return nil, fmt.Errorf("failed to update book: %w", ErrVersionMismatch)
+	}
+
+	return updatedBook, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scoredRow scans a search score selected after the book columns.
+type scoredRow struct {
+	row   rowScanner
+	score *float64
+}
+
+func (row scoredRow) Scan(dest ...interface{}) error {
+	return row.row.Scan(append(dest, row.score)...)
+}
+
+func scanBook(row rowScanner) (*models.Book, error) {
+	book := &models.Book{}
+
+	var (
+		id                         string
+		isbn                       sql.NullString
+		deletedAt                  sql.NullTime
+		contributors, genres, tags []byte
+	)
+
+	err := row.Scan(&id, &isbn, &book.Title, &book.Author, &contributors, &book.Publisher, &genres, &tags,
+		&book.Dewey, &book.LCC, &book.Rating, &book.Status, &book.Version, &book.CreatedAt, &book.UpdatedAt,
+		&deletedAt, &book.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a book: %w", classifyPostgresError(err))
+	}
+
+	book.ISBN = isbn.String
+
+	if deletedAt.Valid {
+		book.DeletedAt = &deletedAt.Time
+	}
+
+	if err := decodeBookLists(book, contributors, genres, tags); err != nil {
+		return nil, fmt.Errorf("can't decode a book: %w", err)
+	}
+
+	book.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode a book: %w", err)
+	}
+
+	return book, nil
+}
+
+// bookLists holds the list fields of a book encoded for their JSONB
+// columns, which hold empty arrays rather than null.
+type bookLists struct {
+	contributors, genres, tags []byte
+}
+
+func bookListsJSON(book *models.Book) (bookLists, error) {
+	var (
+		lists bookLists
+		err   error
+	)
+
+	contributors := book.Contributors
+	if contributors == nil {
+		contributors = []models.Contributor{}
+	}
+
+	if lists.contributors, err = json.Marshal(contributors); err != nil {
+		return lists, fmt.Errorf("can't encode contributors: %w", err)
+	}
+
+	if lists.genres, err = json.Marshal(append([]string{}, book.Genres...)); err != nil {
+		return lists, fmt.Errorf("can't encode genres: %w", err)
+	}
+
+	if lists.tags, err = json.Marshal(append([]string{}, book.Tags...)); err != nil {
+		return lists, fmt.Errorf("can't encode tags: %w", err)
+	}
+
+	return lists, nil
+}
+
+// decodeBookLists leaves empty lists nil, the way the other backends return
+// them.
+func decodeBookLists(book *models.Book, contributors, genres, tags []byte) error {
+	if err := json.Unmarshal(contributors, &book.Contributors); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(genres, &book.Genres); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(tags, &book.Tags); err != nil {
+		return err
+	}
+
+	if len(book.Contributors) == 0 {
+		book.Contributors = nil
+	}
+
+	if len(book.Genres) == 0 {
+		book.Genres = nil
+	}
+
+	if len(book.Tags) == 0 {
+		book.Tags = nil
+	}
+
+	return nil
+}
+
+func scanBooks(rows *sql.Rows) ([]*models.Book, error) {
+	defer rows.Close()
+
+	books := make([]*models.Book, 0)
+
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		books = append(books, book)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return books, nil
+}
+
+func postgresFilter(tenant string, filter BookFilter) (string, []interface{}) {
+	var (
+		conditions = []string{"tenant = $1"}
+		args       = []interface{}{tenant}
+	)
+
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if filter.Author != "" {
+		add("author = $%d", filter.Author)
+	}
+
+	if filter.Contributor != "" || filter.Role != "" {
+		conditions = append(conditions, postgresContributorFilter(filter, &args))
+	}
+
+	if filter.Genre != "" {
+		add("genres @> $%d::jsonb", jsonArray(filter.Genre))
+	}
+
+	if filter.Tag != "" {
+		add("tags @> $%d::jsonb", jsonArray(filter.Tag))
+	}
+
+	if filter.Publisher != "" {
+		add("publisher = $%d", filter.Publisher)
+	}
+
+	if filter.Status != "" {
+		add("status = $%d", filter.Status)
+	}
+
+	if filter.MinRating != nil {
+		add("rating >= $%d", *filter.MinRating)
+	}
+
+	if filter.MaxRating != nil {
+		add("rating <= $%d", *filter.MaxRating)
+	}
+
+	if filter.CreatedAfter != nil {
+		add("created_at >= $%d", *filter.CreatedAfter)
+	}
+
+	if filter.CreatedBefore != nil {
+		add("created_at < $%d", *filter.CreatedBefore)
+	}
+
+	if filter.UpdatedAfter != nil {
+		add("updated_at >= $%d", *filter.UpdatedAfter)
+	}
+
+	if filter.UpdatedBefore != nil {
+		add("updated_at < $%d", *filter.UpdatedBefore)
+	}
+
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func postgresContributorFilter(filter BookFilter, args *[]interface{}) string {
+	credit := map[string]interface{}{}
+
+	if filter.Contributor != "" {
+		credit["name"] = filter.Contributor
+	}
+
+	if filter.Role != "" {
+		credit["role"] = filter.Role
+	}
+
+	*args = append(*args, jsonArray(credit))
+	condition := fmt.Sprintf("contributors @> $%d::jsonb", len(*args))
+
+	// books stored before contributors were introduced credit their author,
+	// see models.Book.Credits
+	if filter.Role == "" || filter.Role == models.RoleAuthor {
+		legacy := "jsonb_array_length(contributors) = 0 AND author <> ''"
+
+		if filter.Contributor != "" {
+			*args = append(*args, filter.Contributor)
+			legacy += fmt.Sprintf(" AND author = $%d", len(*args))
+		}
+
+		condition = "(" + condition + " OR (" + legacy + "))"
+	}
+
+	return condition
+}
+
+// jsonArray encodes a single value as a JSON array for containment checks.
+func jsonArray(value interface{}) string {
+	raw, _ := json.Marshal([]interface{}{value})
+
+	return string(raw)
+}
+
+// postgresAfter matches the rows following after in the order of
+// postgresOrderBy, see mongoAfter. deleted_at is NULL in every row or in
+// none, so a NULL key only ever compares equal.
+func postgresAfter(after *models.Book, fields []SortField, args *[]interface{}) string {
+	var or, equal []string
+
+	for _, field := range fields {
+		key := sortKey(after, field.Field)
+		if key == nil {
+			equal = append(equal, field.Field+" IS NULL")
+			continue
+		}
+
+		*args = append(*args, key)
+		param := fmt.Sprintf("$%d", len(*args))
+
+		operator := " > "
+		if field.Descending {
+			operator = " < "
+		}
+
+		or = append(or, "("+strings.Join(append(equal[:len(equal):len(equal)], field.Field+operator+param), " AND ")+")")
+		equal = append(equal, field.Field+" = "+param)
+	}
+
+	*args = append(*args, after.ID.Hex())
+	or = append(or, "("+strings.Join(append(equal, fmt.Sprintf("id > $%d", len(*args))), " AND ")+")")
+
+	return "(" + strings.Join(or, " OR ") + ")"
+}
+
+// postgresOrderBy relies on the sort fields being validated by
+// BookQuery.normalize, the API names are the column names.
+func postgresOrderBy(fields []SortField) string {
+	order := make([]string, 0, len(fields)+1)
+
+	for _, field := range fields {
+		direction := "ASC"
+		if field.Descending {
+			direction = "DESC"
+		}
+
+		order = append(order, field.Field+" "+direction)
+	}
+
+	order = append(order, "id ASC")
+
+	return " ORDER BY " + strings.Join(order, ", ")
+}
@@ -3,6 +3,7 @@ package db_test
 import (
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/iho/booksdb/common"
 	"github.com/iho/booksdb/db"
@@ -71,7 +72,390 @@ func (suite *BookRepositoryDBTestSuite) TestGetBookFailed() {
 			t.Parallel()
 			id := db.ID(primitive.NewObjectID().Hex())
 			_, err := repo.Repo.GetBook(suite.Context, id)
-			suite.Assert().Error(err)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			_, err = repo.Repo.GetBook(suite.Context, "wrong_id")
+			suite.Assert().ErrorIs(err, db.ErrInvalidID)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestGetBookByISBN() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("GetBookByISBN"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			isbn := common.CreateRandomISBN()
+			book := &models.Book{
+				Title: "catalogued",
+				ISBN:  isbn,
+			}
+			id, err := repo.Repo.AddBook(suite.Context, book)
+			suite.Assert().NoError(err)
+
+			found, err := repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(found) {
+				suite.Assert().Equal(book.ID, found.ID)
+			}
+
+			_, err = repo.Repo.AddBook(suite.Context, &models.Book{Title: "duplicate", ISBN: isbn})
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			other := &models.Book{Title: "other"}
+			otherID, err := repo.Repo.AddBook(suite.Context, other)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repo.UpdateBook(suite.Context, otherID, func(book *models.Book) (*models.Book, error) {
+				book.ISBN = isbn
+				return book, nil
+			})
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			// the ISBN is released once its book is gone
+			err = repo.Repo.DeleteBook(suite.Context, id)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			_, err = repo.Repo.UpdateBook(suite.Context, otherID, func(book *models.Book) (*models.Book, error) {
+				book.ISBN = isbn
+				return book, nil
+			})
+			suite.Assert().NoError(err)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestAddBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			isbn := common.CreateRandomISBN()
+			books := []*models.Book{
+				{Title: "first", ISBN: isbn},
+				{Title: "duplicate", ISBN: isbn},
+				{Title: "third"},
+			}
+
+			errs, err := repo.Repo.AddBooks(suite.Context, books, db.BatchBestEffort)
+			suite.Assert().NoError(err)
+			if !suite.Assert().Len(errs, 3) {
+				return
+			}
+
+			suite.Assert().NoError(errs[0])
+			suite.Assert().ErrorIs(errs[1], db.ErrConflict)
+			suite.Assert().NoError(errs[2])
+
+			for _, i := range []int{0, 2} {
+				stored, err := repo.Repo.GetBook(suite.Context, db.ID(books[i].ID.Hex()))
+				suite.Assert().NoError(err)
+				suite.Assert().Equal(books[i].Title, stored.Title)
+				suite.Assert().EqualValues(1, stored.Version)
+			}
+
+			found, err := repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(found) {
+				suite.Assert().Equal("first", found.Title)
+			}
+
+			errs, err = repo.Repo.AddBooks(suite.Context, nil, db.BatchBestEffort)
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(errs)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestAddBooksAtomic() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddBooksAtomic"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			isbn := common.CreateRandomISBN()
+			books := []*models.Book{
+				{Title: "first", ISBN: isbn},
+				{Title: "duplicate", ISBN: isbn},
+				{Title: "third"},
+			}
+
+			errs, err := repo.Repo.AddBooks(suite.Context, books, db.BatchAtomic)
+			suite.Assert().ErrorIs(err, db.ErrBatchAborted)
+			if suite.Assert().Len(errs, 3) {
+				suite.Assert().ErrorIs(errs[0], db.ErrBatchAborted)
+				suite.Assert().ErrorIs(errs[1], db.ErrConflict)
+				suite.Assert().ErrorIs(errs[2], db.ErrBatchAborted)
+			}
+
+			_, err = repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repo.GetBook(suite.Context, db.ID(books[0].ID.Hex()))
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			books = []*models.Book{{Title: "first", ISBN: isbn}, {Title: "second"}}
+			errs, err = repo.Repo.AddBooks(suite.Context, books, db.BatchAtomic)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal([]error{nil, nil}, errs)
+
+			found, err := repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(found) {
+				suite.Assert().Equal(books[0].ID, found.ID)
+			}
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestUpdateBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	rename := func(title string) func(book *models.Book) (*models.Book, error) {
+		return func(book *models.Book) (*models.Book, error) {
+			book.Title = title
+			return book, nil
+		}
+	}
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("UpdateBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			first, err := repo.Repo.AddBook(suite.Context, &models.Book{Title: "first"})
+			suite.Assert().NoError(err)
+			second, err := repo.Repo.AddBook(suite.Context, &models.Book{Title: "second"})
+			suite.Assert().NoError(err)
+			missing := db.ID(primitive.NewObjectID().Hex())
+
+			changes := []db.BookChange{
+				{ID: first, Update: rename("first, revised")},
+				{ID: missing, Update: rename("missing")},
+				{ID: second, Update: rename("second, revised")},
+			}
+
+			books, errs, err := repo.Repo.UpdateBooks(suite.Context, changes, db.BatchAtomic)
+			suite.Assert().ErrorIs(err, db.ErrBatchAborted)
+			suite.Assert().Nil(books)
+			if suite.Assert().Len(errs, 3) {
+				suite.Assert().ErrorIs(errs[0], db.ErrBatchAborted)
+				suite.Assert().ErrorIs(errs[1], db.ErrNotFound)
+				suite.Assert().ErrorIs(errs[2], db.ErrBatchAborted)
+			}
+
+			book, err := repo.Repo.GetBook(suite.Context, first)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal("first", book.Title)
+			suite.Assert().EqualValues(1, book.Version)
+
+			books, errs, err = repo.Repo.UpdateBooks(suite.Context, changes, db.BatchBestEffort)
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(errs, 3) && suite.Assert().Len(books, 3) {
+				suite.Assert().NoError(errs[0])
+				suite.Assert().ErrorIs(errs[1], db.ErrNotFound)
+				suite.Assert().NoError(errs[2])
+				suite.Assert().Equal("first, revised", books[0].Title)
+				suite.Assert().Nil(books[1])
+				suite.Assert().EqualValues(2, books[2].Version)
+			}
+
+			book, err = repo.Repo.GetBook(suite.Context, second)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal("second, revised", book.Title)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestDeleteBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("DeleteBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			isbn := common.CreateRandomISBN()
+			first, err := repo.Repo.AddBook(suite.Context, &models.Book{Title: "first", ISBN: isbn})
+			suite.Assert().NoError(err)
+			second, err := repo.Repo.AddBook(suite.Context, &models.Book{Title: "second"})
+			suite.Assert().NoError(err)
+
+			deletions := []db.BookDeletion{{ID: first}, {ID: second, Version: 2}}
+
+			errs, err := repo.Repo.DeleteBooks(suite.Context, deletions, db.BatchAtomic)
+			suite.Assert().ErrorIs(err, db.ErrBatchAborted)
+			if suite.Assert().Len(errs, 2) {
+				suite.Assert().ErrorIs(errs[0], db.ErrBatchAborted)
+				suite.Assert().ErrorIs(errs[1], db.ErrVersionMismatch)
+			}
+
+			// the rolled back book keeps its ISBN
+			found, err := repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(found) {
+				suite.Assert().Equal("first", found.Title)
+			}
+
+			errs, err = repo.Repo.DeleteBooks(suite.Context, deletions, db.BatchBestEffort)
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(errs, 2) {
+				suite.Assert().NoError(errs[0])
+				suite.Assert().ErrorIs(errs[1], db.ErrVersionMismatch)
+			}
+
+			_, err = repo.Repo.GetBook(suite.Context, first)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			errs, err = repo.Repo.DeleteBooks(suite.Context, []db.BookDeletion{{ID: second, Version: 1}}, db.BatchAtomic)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal([]error{nil}, errs)
+
+			_, err = repo.Repo.GetBook(suite.Context, second)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestTrash() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Trash"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			isbn := common.CreateRandomISBN()
+			book := &models.Book{Title: "trashed", Publisher: publisher, ISBN: isbn}
+			id, err := repo.Repo.AddBook(suite.Context, book)
+			suite.Assert().NoError(err)
+
+			suite.Assert().NoError(repo.Repo.DeleteBook(suite.Context, id))
+			suite.Assert().ErrorIs(repo.Repo.DeleteBook(suite.Context, id), db.ErrNotFound)
+
+			_, err = repo.Repo.GetBook(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repo.UpdateBook(suite.Context, id, func(book *models.Book) (*models.Book, error) {
+				return book, nil
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			filter := db.BookFilter{Publisher: publisher}
+			page, err := repo.Repo.QueryBooks(suite.Context, db.BookQuery{Filter: filter})
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(page.Books)
+
+			filter.Deleted = true
+			page, err = repo.Repo.QueryBooks(suite.Context, db.BookQuery{Filter: filter})
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(page.Books, 1) {
+				suite.Assert().NotNil(page.Books[0].DeletedAt)
+				suite.Assert().EqualValues(2, page.Books[0].Version)
+			}
+
+			// the ISBN is free while the book is in the trash
+			other := &models.Book{Title: "other", Publisher: publisher, ISBN: isbn}
+			otherID, err := repo.Repo.AddBook(suite.Context, other)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repo.RestoreBook(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			suite.Assert().NoError(repo.Repo.DeleteBookVersion(suite.Context, otherID, 1))
+
+			restored, err := repo.Repo.RestoreBook(suite.Context, id)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(restored) {
+				suite.Assert().Nil(restored.DeletedAt)
+				suite.Assert().EqualValues(3, restored.Version)
+				suite.Assert().Equal("trashed", restored.Title)
+			}
+
+			_, err = repo.Repo.RestoreBook(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			found, err := repo.Repo.GetBookByISBN(suite.Context, isbn)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(found) {
+				suite.Assert().Equal(book.ID, found.ID)
+			}
+
+			// other books may be purged by parallel tests, so only the
+			// ones of this test are checked
+			_, err = repo.Repo.PurgeBooks(suite.Context, time.Now().Add(-time.Hour))
+			suite.Assert().NoError(err)
+			page, err = repo.Repo.QueryBooks(suite.Context, db.BookQuery{Filter: filter})
+			suite.Assert().NoError(err)
+			suite.Assert().Len(page.Books, 1)
+
+			purged, err := repo.Repo.PurgeBooks(suite.Context, time.Now().Add(time.Hour))
+			suite.Assert().NoError(err)
+			suite.Assert().GreaterOrEqual(purged, 1)
+			page, err = repo.Repo.QueryBooks(suite.Context, db.BookQuery{Filter: filter})
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(page.Books)
+
+			_, err = repo.Repo.RestoreBook(suite.Context, otherID)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestSearchBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("SearchBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			word := "w" + primitive.NewObjectID().Hex()
+			books := []*models.Book{
+				{Title: "Gardening " + word},
+				{Title: "Cooking", Author: word + " Smith"},
+				{Title: "Sailing", Publisher: word + " Press"},
+				{Title: "Libraries of the world " + word},
+			}
+			for _, book := range books {
+				_, err := repo.Repo.AddBook(suite.Context, book)
+				suite.Assert().NoError(err)
+			}
+
+			page, err := repo.Repo.SearchBooks(suite.Context, db.BookSearch{Text: word, Limit: 2})
+			suite.Assert().NoError(err)
+			suite.Assert().Len(page.Results, 2)
+			suite.Assert().NotEmpty(page.NextCursor)
+			for _, result := range page.Results {
+				suite.Assert().Contains(result.Book.Title, word)
+			}
+
+			page, err = repo.Repo.SearchBooks(suite.Context, db.BookSearch{Text: word, Limit: 2, Cursor: page.NextCursor})
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(page.Results, 2) {
+				// title matches outweigh author matches, which outweigh publisher ones
+				suite.Assert().Equal(books[1].ID, page.Results[0].Book.ID)
+				suite.Assert().Equal(books[2].ID, page.Results[1].Book.ID)
+				suite.Assert().Greater(page.Results[0].Score, page.Results[1].Score)
+			}
+			suite.Assert().Empty(page.NextCursor)
+
+			// words are matched by their stems
+			page, err = repo.Repo.SearchBooks(suite.Context, db.BookSearch{Text: "library " + word})
+			suite.Assert().NoError(err)
+			if suite.Assert().NotEmpty(page.Results) {
+				suite.Assert().Equal(books[3].ID, page.Results[0].Book.ID)
+			}
 		})
 	}
 }
@@ -100,6 +484,336 @@ func (suite *BookRepositoryDBTestSuite) TestAllBooks() {
 	}
 }
 
+func (suite *BookRepositoryDBTestSuite) TestQueryBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("QueryBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			author := primitive.NewObjectID().Hex()
+			for rating := 1; rating <= 5; rating++ {
+				book := &models.Book{
+					Title:  "query book",
+					Author: author,
+					Rating: rating,
+					Status: models.CheckedIn,
+				}
+				_, err := repo.Repo.AddBook(suite.Context, book)
+				suite.Assert().NoError(err)
+			}
+
+			minRating := 2
+			query := db.BookQuery{
+				Filter: db.BookFilter{Author: author, MinRating: &minRating},
+				Sort:   []db.SortField{{Field: db.SortByRating, Descending: true}},
+				Limit:  3,
+			}
+
+			page, err := repo.Repo.QueryBooks(suite.Context, query)
+			suite.Assert().NoError(err)
+			suite.Assert().Len(page.Books, 3)
+			suite.Assert().NotEmpty(page.NextCursor)
+			suite.Assert().Equal(5, page.Books[0].Rating)
+			suite.Assert().Equal(3, page.Books[2].Rating)
+
+			query.Cursor = page.NextCursor
+			page, err = repo.Repo.QueryBooks(suite.Context, query)
+			suite.Assert().NoError(err)
+			suite.Assert().Len(page.Books, 1)
+			suite.Assert().Empty(page.NextCursor)
+			suite.Assert().Equal(2, page.Books[0].Rating)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestIterateBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("IterateBooks"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			for i, title := range []string{"b", "c", "a"} {
+				_, err := repo.Repo.AddBook(suite.Context, &models.Book{Title: title, Publisher: publisher, Rating: i})
+				suite.Assert().NoError(err)
+			}
+
+			filter := db.BookFilter{Publisher: publisher}
+			iterator, err := repo.Repo.IterateBooks(suite.Context, filter, []db.SortField{{Field: db.SortByTitle}})
+			if !suite.Assert().NoError(err) {
+				return
+			}
+
+			var titles []string
+			for iterator.Next(suite.Context) {
+				titles = append(titles, iterator.Book().Title)
+			}
+
+			suite.Assert().NoError(iterator.Err())
+			suite.Assert().NoError(iterator.Close(suite.Context))
+			suite.Assert().Equal([]string{"a", "b", "c"}, titles)
+
+			_, err = repo.Repo.IterateBooks(suite.Context, filter, []db.SortField{{Field: "pages"}})
+			suite.Assert().Error(err)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestQueryBooksKeyset() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("QueryBooksKeyset"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			add := func(author, title string, rating int) primitive.ObjectID {
+				book := &models.Book{Title: title, Author: author, Rating: rating}
+				_, err := repo.Repo.AddBook(suite.Context, book)
+				suite.Require().NoError(err)
+
+				return book.ID
+			}
+			ratings := func(page *db.BookPage) []int {
+				var ratings []int
+				for _, book := range page.Books {
+					ratings = append(ratings, book.Rating)
+				}
+
+				return ratings
+			}
+
+			author := primitive.NewObjectID().Hex()
+			for rating := 1; rating <= 4; rating++ {
+				add(author, "keyset book", rating)
+			}
+
+			query := db.BookQuery{
+				Filter: db.BookFilter{Author: author},
+				Sort:   []db.SortField{{Field: db.SortByRating, Descending: true}},
+				Limit:  2,
+			}
+			page, err := repo.Repo.QueryBooks(suite.Context, query)
+			suite.Require().NoError(err)
+			suite.Assert().Equal([]int{4, 3}, ratings(page))
+
+			// a book before the cursor doesn't push one of the first page
+			// onto the next, a tie with its last book comes after it
+			add(author, "keyset book", 5)
+			tie := add(author, "keyset book", 3)
+
+			query.Cursor = page.NextCursor
+			page, err = repo.Repo.QueryBooks(suite.Context, query)
+			suite.Require().NoError(err)
+			suite.Assert().Equal([]int{3, 2}, ratings(page))
+			if suite.Assert().Len(page.Books, 2) {
+				suite.Assert().Equal(tie, page.Books[0].ID)
+			}
+
+			query.Cursor = page.NextCursor
+			page, err = repo.Repo.QueryBooks(suite.Context, query)
+			suite.Require().NoError(err)
+			suite.Assert().Equal([]int{1}, ratings(page))
+			suite.Assert().Empty(page.NextCursor)
+
+			// every page continues in mixed directions, zero keys included
+			author = primitive.NewObjectID().Hex()
+			expected := []primitive.ObjectID{add(author, "a", 2), add(author, "a", 0), add(author, "b", 1), add(author, "b", 0)}
+			query = db.BookQuery{
+				Filter: db.BookFilter{Author: author},
+				Sort:   []db.SortField{{Field: db.SortByTitle}, {Field: db.SortByRating, Descending: true}},
+				Limit:  1,
+			}
+
+			var ids []primitive.ObjectID
+			for {
+				page, err = repo.Repo.QueryBooks(suite.Context, query)
+				suite.Require().NoError(err)
+				for _, book := range page.Books {
+					ids = append(ids, book.ID)
+				}
+
+				if page.NextCursor == "" || len(ids) > len(expected) {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+			suite.Assert().Equal(expected, ids)
+
+			query.Sort = query.Sort[:1]
+			_, err = repo.Repo.QueryBooks(suite.Context, query)
+			suite.Assert().ErrorIs(err, db.ErrInvalidCursor)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestQueryBooksInvalidCursor() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("QueryBooksInvalidCursor"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			_, err := repo.Repo.QueryBooks(suite.Context, db.BookQuery{Cursor: "not a cursor"})
+			suite.Assert().ErrorIs(err, db.ErrInvalidCursor)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestContributors() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Contributors"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			prefix := primitive.NewObjectID().Hex()
+			author, translator, legacy := prefix+" author", prefix+" translator", prefix+" legacy"
+
+			for _, book := range []*models.Book{
+				{Title: "translated", Contributors: []models.Contributor{
+					{Name: author, Role: models.RoleAuthor, SortName: author},
+					{Name: translator, Role: models.RoleTranslator, SortName: translator},
+				}},
+				{Title: "illustrated", Contributors: []models.Contributor{
+					{Name: author, Role: models.RoleAuthor, SortName: author},
+					{Name: author, Role: models.RoleIllustrator, SortName: author},
+				}},
+				// stored before books had contributors
+				{Title: "legacy", Author: legacy},
+			} {
+				_, err := repo.Repo.AddBook(suite.Context, book)
+				suite.Assert().NoError(err)
+			}
+
+			for _, test := range []struct {
+				filter db.BookFilter
+				books  int
+			}{
+				{db.BookFilter{Contributor: author}, 2},
+				{db.BookFilter{Contributor: author, Role: models.RoleIllustrator}, 1},
+				{db.BookFilter{Contributor: translator, Role: models.RoleTranslator}, 1},
+				{db.BookFilter{Contributor: translator, Role: models.RoleAuthor}, 0},
+				{db.BookFilter{Contributor: legacy}, 1},
+				{db.BookFilter{Contributor: legacy, Role: models.RoleAuthor}, 1},
+				{db.BookFilter{Contributor: legacy, Role: models.RoleEditor}, 0},
+			} {
+				page, err := repo.Repo.QueryBooks(suite.Context, db.BookQuery{Filter: test.filter})
+				suite.Assert().NoError(err)
+				suite.Assert().Len(page.Books, test.books, "%+v", test.filter)
+			}
+
+			contributors := suite.allContributors(repo.Repo, "")
+			suite.Assert().Equal(db.ContributorCount{
+				Name:     author,
+				SortName: author,
+				Roles:    []models.ContributorRole{models.RoleAuthor, models.RoleIllustrator},
+				Books:    2,
+			}, contributors[author])
+			suite.Assert().Equal(1, contributors[translator].Books)
+			suite.Assert().Equal(db.ContributorCount{
+				Name:     legacy,
+				SortName: legacy,
+				Roles:    []models.ContributorRole{models.RoleAuthor},
+				Books:    1,
+			}, contributors[legacy])
+
+			translators := suite.allContributors(repo.Repo, models.RoleTranslator)
+			suite.Assert().NotContains(translators, author)
+			suite.Assert().Contains(translators, translator)
+		})
+	}
+}
+
+// allContributors pages through the contributors of all books, other tests
+// add theirs concurrently.
+func (suite *BookRepositoryDBTestSuite) allContributors(
+	repo db.BookRepository,
+	role models.ContributorRole,
+) map[string]db.ContributorCount {
+	contributors := make(map[string]db.ContributorCount)
+	query := db.ContributorQuery{Role: role, Limit: db.MaxQueryLimit}
+
+	for {
+		page, err := repo.Contributors(suite.Context, query)
+		if !suite.Assert().NoError(err) {
+			return contributors
+		}
+
+		for _, contributor := range page.Contributors {
+			contributors[contributor.Name] = contributor
+		}
+
+		if page.NextCursor == "" {
+			return contributors
+		}
+
+		query.Cursor = page.NextCursor
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestFacets() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Facets"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+
+			for _, book := range []*models.Book{
+				{
+					Title: "first", Publisher: publisher, Status: models.CheckedIn,
+					Genres: []string{"Fantasy", "Classic"}, Tags: []string{"dragons", "magic"},
+				},
+				{
+					Title: "second", Publisher: publisher, Status: models.CheckedOut,
+					Genres: []string{"Fantasy"}, Tags: []string{"magic"},
+				},
+				{Title: "third", Publisher: publisher, Status: models.CheckedIn},
+			} {
+				_, err := repo.Repo.AddBook(suite.Context, book)
+				suite.Assert().NoError(err)
+			}
+
+			facets, err := repo.Repo.Facets(suite.Context, db.BookFilter{Publisher: publisher})
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(&db.BookFacets{
+				Genres:     []db.FacetCount{{Value: "Fantasy", Count: 2}, {Value: "Classic", Count: 1}},
+				Tags:       []db.FacetCount{{Value: "magic", Count: 2}, {Value: "dragons", Count: 1}},
+				Publishers: []db.FacetCount{{Value: publisher, Count: 3}},
+				Statuses: []db.FacetCount{
+					{Value: string(models.CheckedIn), Count: 2},
+					{Value: string(models.CheckedOut), Count: 1},
+				},
+			}, facets)
+
+			facets, err = repo.Repo.Facets(suite.Context, db.BookFilter{Publisher: publisher, Genre: "Classic"})
+			suite.Assert().NoError(err)
+			suite.Assert().Equal([]db.FacetCount{{Value: "dragons", Count: 1}, {Value: "magic", Count: 1}}, facets.Tags)
+			suite.Assert().Equal([]db.FacetCount{{Value: string(models.CheckedIn), Count: 1}}, facets.Statuses)
+
+			page, err := repo.Repo.QueryBooks(suite.Context, db.BookQuery{
+				Filter: db.BookFilter{Publisher: publisher, Tag: "magic"},
+			})
+			suite.Assert().NoError(err)
+			suite.Assert().Len(page.Books, 2)
+
+			facets, err = repo.Repo.Facets(suite.Context, db.BookFilter{Publisher: publisher, Tag: "none"})
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(facets.Genres)
+			suite.Assert().Empty(facets.Publishers)
+		})
+	}
+}
+
 func (suite *BookRepositoryDBTestSuite) TestUpdateBook() {
 	t := suite.T()
 	t.Parallel()
@@ -135,6 +849,86 @@ func (suite *BookRepositoryDBTestSuite) TestUpdateBook() {
 	}
 }
 
+func (suite *BookRepositoryDBTestSuite) TestReplaceBook() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("ReplaceBook"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			book := &models.Book{Title: "original"}
+			id, err := repo.Repo.AddBook(suite.Context, book)
+			suite.Require().NoError(err)
+
+			// a replacement built from scratch keeps the identity of the
+			// stored book, whatever it claims
+			deletedAt := time.Now().UTC()
+			replace := func(title string) func(*models.Book) (*models.Book, error) {
+				return func(*models.Book) (*models.Book, error) {
+					return &models.Book{
+						ID:        primitive.NewObjectID(),
+						Title:     title,
+						Version:   42,
+						DeletedAt: &deletedAt,
+						Tenant:    "other",
+					}, nil
+				}
+			}
+
+			_, err = repo.Repo.UpdateBook(suite.Context, id, replace("replaced"))
+			suite.Require().NoError(err)
+			books, errs, err := repo.Repo.UpdateBooks(suite.Context,
+				[]db.BookChange{{ID: id, Update: replace("replaced again")}}, db.BatchAtomic)
+			suite.Require().NoError(err)
+			suite.Require().NoError(errs[0])
+
+			stored, err := repo.Repo.GetBook(suite.Context, id)
+			suite.Require().NoError(err)
+
+			for _, replaced := range []*models.Book{books[0], stored} {
+				suite.Assert().Equal(book.ID, replaced.ID)
+				suite.Assert().Equal("replaced again", replaced.Title)
+				suite.Assert().EqualValues(3, replaced.Version)
+				suite.Assert().True(book.CreatedAt.Equal(replaced.CreatedAt))
+				suite.Assert().Nil(replaced.DeletedAt)
+				suite.Assert().Equal(db.DefaultTenant, replaced.Tenant)
+			}
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestUpdateBookVersion() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("UpdateBookVersion"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			book := &models.Book{
+				Title: "versioned",
+			}
+			id, err := repo.Repo.AddBook(suite.Context, book)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(int64(1), book.Version)
+
+			updatedBook, err := repo.Repo.UpdateBook(suite.Context, id,
+				func(book *models.Book) (*models.Book, error) {
+					book.Version = 42 // ignored, versions are managed by the repository
+					return book, nil
+				},
+			)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(int64(2), updatedBook.Version)
+
+			storedBook, err := repo.Repo.GetBook(suite.Context, id)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(int64(2), storedBook.Version)
+		})
+	}
+}
+
 func (suite *BookRepositoryDBTestSuite) TestUpdateBookFailed() {
 	t := suite.T()
 	t.Parallel()
@@ -151,7 +945,7 @@ func (suite *BookRepositoryDBTestSuite) TestUpdateBookFailed() {
 					return book, nil
 				},
 			)
-			suite.Assert().Error(err)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
 			suite.Assert().NotEmpty(wrongId)
 		})
 	}
@@ -239,7 +1033,107 @@ func (suite *BookRepositoryDBTestSuite) TestDeleteBookFailed() {
 
 			wrongId := db.ID(primitive.NewObjectID().Hex())
 			err := repo.Repo.DeleteBook(suite.Context, wrongId)
-			suite.Assert().Error(err)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestDeleteBookVersion() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("DeleteBookVersion"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			book := &models.Book{
+				Title: "versioned",
+			}
+			id, err := repo.Repo.AddBook(suite.Context, book)
+			suite.Assert().NoError(err)
+
+			err = repo.Repo.DeleteBookVersion(suite.Context, id, book.Version+1)
+			suite.Assert().ErrorIs(err, db.ErrVersionMismatch)
+
+			err = repo.Repo.DeleteBookVersion(suite.Context, id, book.Version)
+			suite.Assert().NoError(err)
+
+			err = repo.Repo.DeleteBookVersion(suite.Context, id, book.Version)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *BookRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			word := "w" + primitive.NewObjectID().Hex()
+			isbn := common.CreateRandomISBN()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+
+			northBook := &models.Book{Title: "Beowulf " + word, Publisher: word, ISBN: isbn}
+			northID, err := repo.Repo.AddBook(north, northBook)
+			suite.Require().NoError(err)
+
+			// ISBNs are only unique within a tenant
+			southBook := &models.Book{Title: "Grendel " + word, Publisher: word, ISBN: isbn}
+			southID, err := repo.Repo.AddBook(south, southBook)
+			suite.Require().NoError(err)
+			_, err = repo.Repo.AddBook(south, &models.Book{Title: "Grendel", ISBN: isbn})
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			_, err = repo.Repo.GetBook(south, northID)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repo.GetBook(suite.Context, northID)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			book, err := repo.Repo.GetBookByISBN(south, isbn)
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal(southBook.ID, book.ID)
+			}
+
+			page, err := repo.Repo.QueryBooks(north, db.BookQuery{Filter: db.BookFilter{Publisher: word}})
+			if suite.Assert().NoError(err) && suite.Assert().Len(page.Books, 1) {
+				suite.Assert().Equal(northBook.ID, page.Books[0].ID)
+			}
+
+			results, err := repo.Repo.SearchBooks(south, db.BookSearch{Text: word})
+			if suite.Assert().NoError(err) && suite.Assert().Len(results.Results, 1) {
+				suite.Assert().Equal(southBook.ID, results.Results[0].Book.ID)
+			}
+
+			facets, err := repo.Repo.Facets(north, db.BookFilter{Publisher: word})
+			if suite.Assert().NoError(err) && suite.Assert().Len(facets.Publishers, 1) {
+				suite.Assert().Equal(1, facets.Publishers[0].Count)
+			}
+
+			// other tenants can neither change nor delete the book
+			_, err = repo.Repo.UpdateBook(south, northID, func(book *models.Book) (*models.Book, error) {
+				book.Title = "Stolen"
+				return book, nil
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			suite.Assert().ErrorIs(repo.Repo.DeleteBook(south, northID), db.ErrNotFound)
+			suite.Assert().ErrorIs(repo.Repo.DeleteBookVersion(south, northID, 1), db.ErrNotFound)
+
+			suite.Require().NoError(repo.Repo.DeleteBook(north, northID))
+			_, err = repo.Repo.RestoreBook(south, northID)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			restored, err := repo.Repo.RestoreBook(north, northID)
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal("Beowulf "+word, restored.Title)
+			}
+
+			suite.Require().NoError(repo.Repo.RemoveAllBooks(south))
+			_, err = repo.Repo.GetBook(south, southID)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repo.GetBook(north, northID)
+			suite.Assert().NoError(err)
 		})
 	}
 }
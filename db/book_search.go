@@ -0,0 +1,44 @@
+package db
+
+import (
+	"github.com/iho/booksdb/models"
+)
+
+// Relative weights of the searchable fields. A match in the title counts
+// ten times as much as one in the publisher.
+const (
+	titleSearchWeight     = 10
+	authorSearchWeight    = 5
+	publisherSearchWeight = 1
+)
+
+// BookSearch is a full-text search over titles, authors and publishers. A
+// book matches if it contains any of the words of Text; books matching more
+// and rarer words rank higher.
+type BookSearch struct {
+	Text   string
+	Limit  int
+	Cursor string
+}
+
+type SearchResult struct {
+	Book  *models.Book
+	Score float64
+}
+
+// SearchPage holds results ordered by descending score.
+type SearchPage struct {
+	Results    []SearchResult
+	NextCursor string
+}
+
+func (search BookSearch) normalize() (BookSearch, int, error) {
+	search.Limit = pageLimit(search.Limit)
+
+	offset, err := decodeCursor(search.Cursor)
+	if err != nil {
+		return search, 0, err
+	}
+
+	return search, offset, nil
+}
@@ -1,8 +1,21 @@
 package db
 
 const (
-	DatabaseName       = "main"
-	BookCollectionName = "book_collection"
+	DatabaseName          = "main"
+	BookCollectionName    = "book_collection"
+	BookTableName         = "books"
+	LoanCollectionName    = "loan_collection"
+	LoanTableName         = "loans"
+	MemberCollectionName  = "member_collection"
+	MemberTableName       = "members"
+	HoldCollectionName    = "hold_collection"
+	HoldTableName         = "holds"
+	FineCollectionName    = "fine_collection"
+	FineTableName         = "fines"
+	CopyCollectionName    = "copy_collection"
+	CopyTableName         = "copies"
+	HistoryCollectionName = "history_collection"
+	HistoryTableName      = "book_revisions"
 )
 
 type ID string
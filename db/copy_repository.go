@@ -0,0 +1,28 @@
+package db
+
+import (
+	"context"
+
+	"github.com/iho/booksdb/models"
+)
+
+// CopyRepository stores the physical copies of books. Like BookRepository,
+// every method is limited to the tenant of its context. Barcodes are unique
+// per tenant, storing a second copy with the same barcode fails with
+// ErrConflict.
+type CopyRepository interface {
+	AddCopy(ctx context.Context, item *models.Copy) (ID, error)
+	GetCopy(ctx context.Context, ID ID) (*models.Copy, error)
+	GetCopyByBarcode(ctx context.Context, barcode string) (*models.Copy, error)
+	DeleteCopy(ctx context.Context, ID ID) error
+	// BookCopies lists the copies of a book ordered by barcode.
+	BookCopies(ctx context.Context, bookID ID) ([]*models.Copy, error)
+	RemoveAllCopies(ctx context.Context) error
+	// UpdateCopy works like BookRepository.UpdateBook. The book of a copy
+	// can't be changed.
+	UpdateCopy(
+		ctx context.Context,
+		ID ID,
+		updateFn func(item *models.Copy) (*models.Copy, error),
+	) (*models.Copy, error)
+}
@@ -0,0 +1,27 @@
+package db
+
+import "github.com/iho/booksdb/models"
+
+const CopyJournalName = "copies.log"
+
+// FileCopyRepository is the copy counterpart of FileBookRepository.
+type FileCopyRepository struct {
+	MemoryCopyRepository
+	fileStore
+}
+
+func NewFileCopyRepository(dataDir string) (FileCopyRepository, error) {
+	memory := NewMemoryCopyRepository()
+
+	journal, file, err := openFileStore(dataDir, CopyJournalName, memory.StoreRW, memory.Store,
+		func(id ID, doc interface{}) {
+			memory.storeCopy(id, doc.(*models.Copy))
+		}, memory.dropCopy)
+	if err != nil {
+		return FileCopyRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileCopyRepository{MemoryCopyRepository: memory, fileStore: file}, nil
+}
@@ -0,0 +1,237 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryCopyRepository struct {
+	Store   map[ID]*models.Copy
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+	// barcodes is a unique index of Store by tenant and barcode. It is only
+	// changed through storeCopy and dropCopy.
+	barcodes map[string]ID
+}
+
+func NewMemoryCopyRepository() MemoryCopyRepository {
+	return MemoryCopyRepository{
+		StoreRW:  &sync.RWMutex{},
+		Store:    make(map[ID]*models.Copy),
+		barcodes: make(map[string]ID),
+	}
+}
+
+func (repo MemoryCopyRepository) AddCopy(ctx context.Context, item *models.Copy) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	now := time.Now().UTC()
+	item.ID = objectID
+	item.Version = 1
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	item.Tenant = TenantFromContext(ctx)
+
+	if err := repo.checkBarcode(item.Tenant, id, item.Barcode); err != nil {
+		return ID(""), err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, item); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.storeCopy(id, item)
+
+	return id, nil
+}
+
+func (repo MemoryCopyRepository) GetCopy(ctx context.Context, id ID) (*models.Copy, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	item, ok := repo.Store[id]
+	if !ok || item.Tenant != TenantFromContext(ctx) {
+		return nil, errCopyNotFound
+	}
+
+	return item, nil
+}
+
+func (repo MemoryCopyRepository) GetCopyByBarcode(ctx context.Context, barcode string) (*models.Copy, error) {
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	id, ok := repo.barcodes[scopedKey(TenantFromContext(ctx), barcode)]
+	if !ok {
+		return nil, errCopyNotFound
+	}
+
+	return repo.Store[id], nil
+}
+
+func (repo MemoryCopyRepository) DeleteCopy(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	if item, ok := repo.Store[id]; !ok || item.Tenant != TenantFromContext(ctx) {
+		return errCopyNotFound
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.delete(id); err != nil {
+			return err
+		}
+	}
+
+	repo.dropCopy(id)
+
+	return nil
+}
+
+func (repo MemoryCopyRepository) BookCopies(ctx context.Context, bookID ID) ([]*models.Copy, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	copies := make([]*models.Copy, 0)
+	for _, item := range repo.Store {
+		if item.BookID == objectID && item.Tenant == tenant {
+			copies = append(copies, item)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sort.Slice(copies, func(i, j int) bool { return copies[i].Barcode < copies[j].Barcode })
+
+	return copies, nil
+}
+
+func (repo MemoryCopyRepository) RemoveAllCopies(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	removed := make([]documentChange, 0)
+	for id, item := range repo.Store {
+		if item.Tenant == tenant {
+			removed = append(removed, documentChange{id: id})
+		}
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(removed); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range removed {
+		repo.dropCopy(change.id)
+	}
+
+	return nil
+}
+
+func (repo MemoryCopyRepository) UpdateCopy(
+	ctx context.Context,
+	copyID ID,
+	updateFn func(item *models.Copy) (*models.Copy, error),
+) (*models.Copy, error) {
+	if _, err := parseID(copyID); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	item, ok := repo.Store[copyID]
+	if !ok || item.Tenant != TenantFromContext(ctx) {
+		return nil, errCopyNotFound
+	}
+
+	// updateFn gets a copy so that a failed update leaves the store intact
+	current := *item
+
+	updatedCopy, err := updateFn(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", err)
+	}
+
+	updatedCopy.ID = item.ID
+	updatedCopy.BookID = item.BookID
+	updatedCopy.Version = item.Version + 1
+	updatedCopy.CreatedAt = item.CreatedAt
+	updatedCopy.UpdatedAt = time.Now().UTC()
+	updatedCopy.Tenant = item.Tenant
+
+	if err := repo.checkBarcode(item.Tenant, copyID, updatedCopy.Barcode); err != nil {
+		return nil, err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(copyID, updatedCopy); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.storeCopy(copyID, updatedCopy)
+
+	return updatedCopy, nil
+}
+
+// checkBarcode reports a conflict if another copy of the tenant already has
+// the barcode.
+func (repo MemoryCopyRepository) checkBarcode(tenant string, id ID, barcode string) error {
+	if owner, ok := repo.barcodes[scopedKey(tenant, barcode)]; ok && owner != id {
+		return fmt.Errorf("%w: a copy with barcode %s already exists", ErrConflict, barcode)
+	}
+
+	return nil
+}
+
+// storeCopy and dropCopy change the store together with its index. They are
+// called with StoreRW held.
+func (repo MemoryCopyRepository) storeCopy(id ID, item *models.Copy) {
+	repo.dropCopy(id)
+
+	repo.Store[id] = item
+	repo.barcodes[scopedKey(item.Tenant, item.Barcode)] = id
+}
+
+func (repo MemoryCopyRepository) dropCopy(id ID) {
+	item, ok := repo.Store[id]
+	if !ok {
+		return
+	}
+
+	if key := scopedKey(item.Tenant, item.Barcode); repo.barcodes[key] == id {
+		delete(repo.barcodes, key)
+	}
+
+	delete(repo.Store, id)
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBCopyRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBCopyRepository(client *mongo.Client) MongoDBCopyRepository {
+	return MongoDBCopyRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBCopyRepository) getCopyCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(CopyCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBCopyRepository) Migrate(ctx context.Context) error {
+	// barcodes are only unique per tenant
+	if err := migrateToTenants(ctx, repo.getCopyCollection(), "barcode_unique"); err != nil {
+		return err
+	}
+
+	_, err := repo.getCopyCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "barcode", Value: 1}},
+			Options: options.Index().SetName("tenant_barcode_unique").SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "barcode", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBCopyRepository) AddCopy(ctx context.Context, item *models.Copy) (ID, error) {
+	now := time.Now().UTC()
+	item.ID = primitive.NewObjectID()
+	item.Version = 1
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	item.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.getCopyCollection().InsertOne(ctx, item)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a copy: %w", classifyMongoError(err))
+	}
+
+	return ID(item.ID.Hex()), nil
+}
+
+func (repo MongoDBCopyRepository) GetCopy(ctx context.Context, id ID) (*models.Copy, error) {
+	copyID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findCopy(ctx, bson.M{"_id": copyID})
+}
+
+func (repo MongoDBCopyRepository) GetCopyByBarcode(ctx context.Context, barcode string) (*models.Copy, error) {
+	return repo.findCopy(ctx, bson.M{"barcode": barcode})
+}
+
+func (repo MongoDBCopyRepository) findCopy(ctx context.Context, filter bson.M) (*models.Copy, error) {
+	item := &models.Copy{}
+	filter["tenant"] = TenantFromContext(ctx)
+
+	err := repo.getCopyCollection().FindOne(ctx, filter).Decode(item)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a copy: %w", classifyMongoError(err))
+	}
+
+	return item, nil
+}
+
+func (repo MongoDBCopyRepository) DeleteCopy(ctx context.Context, id ID) error {
+	copyID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := repo.getCopyCollection().DeleteOne(ctx, bson.M{"_id": copyID, "tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("nothing to delete: %w", errCopyNotFound)
+	}
+
+	return nil
+}
+
+func (repo MongoDBCopyRepository) BookCopies(ctx context.Context, bookID ID) ([]*models.Copy, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "barcode", Value: 1}})
+
+	cur, err := repo.getCopyCollection().Find(ctx, bson.M{"book_id": objectID, "tenant": TenantFromContext(ctx)}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	copies := make([]*models.Copy, 0)
+	if err := cur.All(ctx, &copies); err != nil {
+		return nil, fmt.Errorf("can't decode copies: %w", classifyMongoError(err))
+	}
+
+	return copies, nil
+}
+
+func (repo MongoDBCopyRepository) RemoveAllCopies(ctx context.Context) error {
+	_, err := repo.getCopyCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove copies: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBCopyRepository) UpdateCopy(
+	ctx context.Context,
+	copyID ID,
+	updateFn func(item *models.Copy) (*models.Copy, error),
+) (*models.Copy, error) {
+	item, err := repo.GetCopy(ctx, copyID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, bookID, version, createdAt, tenant := item.ID, item.BookID, item.Version, item.CreatedAt, item.Tenant
+
+	updatedCopy, err := updateFn(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", err)
+	}
+
+	updatedCopy.ID = id
+	updatedCopy.BookID = bookID
+	updatedCopy.Version = version + 1
+	updatedCopy.CreatedAt = createdAt
+	updatedCopy.UpdatedAt = time.Now().UTC()
+	updatedCopy.Tenant = tenant
+
+	result, err := repo.getCopyCollection().ReplaceOne(ctx, versionFilter(id, version), updatedCopy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", classifyMongoError(err))
+	}
+
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("failed to update copy: %w", ErrVersionMismatch)
+	}
+
+	return updatedCopy, nil
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const copyColumns = "id, book_id, barcode, location, condition, status, acquired_at, version, created_at, updated_at, tenant"
+
+// PostgresCopyRepository uses the schema created by
+// PostgresBookRepository.Migrate.
+type PostgresCopyRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresCopyRepository(db *sql.DB) PostgresCopyRepository {
+	return PostgresCopyRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresCopyRepository) AddCopy(ctx context.Context, item *models.Copy) (ID, error) {
+	now := time.Now().UTC()
+	item.ID = primitive.NewObjectID()
+	item.Version = 1
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	item.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO "+CopyTableName+" ("+copyColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)",
+		item.ID.Hex(), item.BookID.Hex(), item.Barcode, item.Location, item.Condition, item.Status,
+		item.AcquiredAt, item.Version, item.CreatedAt, item.UpdatedAt, item.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a copy: %w", classifyPostgresError(err))
+	}
+
+	return ID(item.ID.Hex()), nil
+}
+
+func (repo PostgresCopyRepository) GetCopy(ctx context.Context, id ID) (*models.Copy, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+copyColumns+" FROM "+CopyTableName+" WHERE id = $1 AND tenant = $2",
+		string(id), TenantFromContext(ctx))
+
+	return scanCopy(row)
+}
+
+func (repo PostgresCopyRepository) GetCopyByBarcode(ctx context.Context, barcode string) (*models.Copy, error) {
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+copyColumns+" FROM "+CopyTableName+" WHERE barcode = $1 AND tenant = $2",
+		barcode, TenantFromContext(ctx))
+
+	return scanCopy(row)
+}
+
+func (repo PostgresCopyRepository) DeleteCopy(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	result, err := repo.DB.ExecContext(ctx,
+		"DELETE FROM "+CopyTableName+" WHERE id = $1 AND tenant = $2", string(id), TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("nothing to delete: %w", errCopyNotFound)
+	}
+
+	return nil
+}
+
+func (repo PostgresCopyRepository) BookCopies(ctx context.Context, bookID ID) ([]*models.Copy, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+copyColumns+" FROM "+CopyTableName+" WHERE book_id = $1 AND tenant = $2 ORDER BY barcode",
+		string(bookID), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	copies := make([]*models.Copy, 0)
+
+	for rows.Next() {
+		item, err := scanCopy(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		copies = append(copies, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return copies, nil
+}
+
+func (repo PostgresCopyRepository) RemoveAllCopies(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+CopyTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove copies: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresCopyRepository) UpdateCopy(
+	ctx context.Context,
+	copyID ID,
+	updateFn func(item *models.Copy) (*models.Copy, error),
+) (*models.Copy, error) {
+	if _, err := parseID(copyID); err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+copyColumns+" FROM "+CopyTableName+" WHERE id = $1 AND tenant = $2 FOR UPDATE",
+		string(copyID), TenantFromContext(ctx))
+
+	item, err := scanCopy(row)
+	if err != nil {
+		return nil, err
+	}
+
+	id, bookID, version, createdAt, tenant := item.ID, item.BookID, item.Version, item.CreatedAt, item.Tenant
+
+	updatedCopy, err := updateFn(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", err)
+	}
+
+	updatedCopy.ID = id
+	updatedCopy.BookID = bookID
+	updatedCopy.Version = version + 1
+	updatedCopy.CreatedAt = createdAt
+	updatedCopy.UpdatedAt = time.Now().UTC()
+	updatedCopy.Tenant = tenant
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE "+CopyTableName+" SET barcode = $3, location = $4, condition = $5, status = $6, "+
+			"acquired_at = $7, version = $8, updated_at = $9 WHERE id = $1 AND version = $2",
+		string(copyID), version, updatedCopy.Barcode, updatedCopy.Location, updatedCopy.Condition,
+		updatedCopy.Status, updatedCopy.AcquiredAt, updatedCopy.Version, updatedCopy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", classifyPostgresError(err))
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", classifyPostgresError(err))
+	} else if updated == 0 {
+		return nil, fmt.Errorf("failed to update copy: %w", ErrVersionMismatch)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update copy: %w", classifyPostgresError(err))
+	}
+
+	return updatedCopy, nil
+}
+
+func scanCopy(row rowScanner) (*models.Copy, error) {
+	item := &models.Copy{}
+
+	var (
+		id, bookID string
+		acquiredAt sql.NullTime
+	)
+
+	err := row.Scan(&id, &bookID, &item.Barcode, &item.Location, &item.Condition, &item.Status,
+		&acquiredAt, &item.Version, &item.CreatedAt, &item.UpdatedAt, &item.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a copy: %w", classifyPostgresError(err))
+	}
+
+	item.AcquiredAt = nullTime(acquiredAt)
+
+	if item.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id)); err != nil {
+		return nil, fmt.Errorf("can't decode a copy: %w", err)
+	}
+
+	if item.BookID, err = primitive.ObjectIDFromHex(strings.TrimSpace(bookID)); err != nil {
+		return nil, fmt.Errorf("can't decode a copy: %w", err)
+	}
+
+	return item, nil
+}
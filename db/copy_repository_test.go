@@ -0,0 +1,185 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CopyRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *CopyRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func newCopy(bookID primitive.ObjectID, barcode string) *models.Copy {
+	acquiredAt := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	return &models.Copy{
+		BookID:     bookID,
+		Barcode:    barcode,
+		Location:   "A-12",
+		Condition:  models.ConditionGood,
+		Status:     models.CopyAvailable,
+		AcquiredAt: &acquiredAt,
+	}
+}
+
+func (suite *CopyRepositoryDBTestSuite) TestAddCopy() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddCopy"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := primitive.NewObjectID()
+			prefix := bookID.Hex()
+
+			second := newCopy(bookID, prefix+"-2")
+			_, err := repo.Repositories.Copies.AddCopy(suite.Context, second)
+			suite.Assert().NoError(err)
+
+			first := newCopy(bookID, prefix+"-1")
+			id, err := repo.Repositories.Copies.AddCopy(suite.Context, first)
+			suite.Assert().NoError(err)
+
+			stored, err := repo.Repositories.Copies.GetCopyByBarcode(suite.Context, prefix+"-1")
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(id, db.ID(stored.ID.Hex()))
+				suite.Assert().Equal(bookID, stored.BookID)
+				suite.Assert().Equal(models.ConditionGood, stored.Condition)
+				suite.Assert().True(first.AcquiredAt.Equal(*stored.AcquiredAt))
+			}
+
+			_, err = repo.Repositories.Copies.AddCopy(suite.Context, newCopy(primitive.NewObjectID(), prefix+"-1"))
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			copies, err := repo.Repositories.Copies.BookCopies(suite.Context, db.ID(bookID.Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(copies, 2) {
+				suite.Assert().Equal(first.ID, copies[0].ID)
+				suite.Assert().Equal(second.ID, copies[1].ID)
+			}
+
+			suite.Assert().NoError(repo.Repositories.Copies.DeleteCopy(suite.Context, id))
+
+			_, err = repo.Repositories.Copies.GetCopy(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			_, err = repo.Repositories.Copies.GetCopyByBarcode(suite.Context, prefix+"-1")
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *CopyRepositoryDBTestSuite) TestUpdateCopy() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("UpdateCopy"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := primitive.NewObjectID()
+			prefix := bookID.Hex()
+
+			id, err := repo.Repositories.Copies.AddCopy(suite.Context, newCopy(bookID, prefix+"-1"))
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Copies.AddCopy(suite.Context, newCopy(bookID, prefix+"-2"))
+			suite.Assert().NoError(err)
+
+			updated, err := repo.Repositories.Copies.UpdateCopy(suite.Context, id,
+				func(item *models.Copy) (*models.Copy, error) {
+					item.BookID = primitive.NewObjectID()
+					item.Barcode = prefix + "-3"
+					item.Status = models.CopyInRepair
+
+					return item, nil
+				})
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(updated) {
+				suite.Assert().Equal(bookID, updated.BookID)
+				suite.Assert().Equal(int64(2), updated.Version)
+			}
+
+			stored, err := repo.Repositories.Copies.GetCopyByBarcode(suite.Context, prefix+"-3")
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(models.CopyInRepair, stored.Status)
+			}
+
+			_, err = repo.Repositories.Copies.UpdateCopy(suite.Context, id,
+				func(item *models.Copy) (*models.Copy, error) {
+					item.Barcode = prefix + "-2"
+
+					return item, nil
+				})
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+		})
+	}
+}
+
+func (suite *CopyRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+			bookID := primitive.NewObjectID()
+			barcode := "B" + primitive.NewObjectID().Hex()
+
+			id, err := repo.Repositories.Copies.AddCopy(north, newCopy(bookID, barcode))
+			suite.Require().NoError(err)
+
+			// barcodes are only unique within a tenant
+			twin := newCopy(bookID, barcode)
+			_, err = repo.Repositories.Copies.AddCopy(south, twin)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Copies.GetCopy(south, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			item, err := repo.Repositories.Copies.GetCopyByBarcode(south, barcode)
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal(twin.ID, item.ID)
+			}
+			copies, err := repo.Repositories.Copies.BookCopies(south, db.ID(bookID.Hex()))
+			if suite.Assert().NoError(err) && suite.Assert().Len(copies, 1) {
+				suite.Assert().Equal(twin.ID, copies[0].ID)
+			}
+			_, err = repo.Repositories.Copies.UpdateCopy(south, id, func(item *models.Copy) (*models.Copy, error) {
+				item.Status = models.CopyLost
+				return item, nil
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			suite.Assert().ErrorIs(repo.Repositories.Copies.DeleteCopy(south, id), db.ErrNotFound)
+
+			suite.Require().NoError(repo.Repositories.Copies.RemoveAllCopies(south))
+			item, err = repo.Repositories.Copies.GetCopyByBarcode(north, barcode)
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal(models.CopyAvailable, item.Status)
+			}
+		})
+	}
+}
+
+func (suite *CopyRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestCopyRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(CopyRepositoryDBTestSuite))
+}
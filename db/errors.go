@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/lib/pq"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Every repository reports failures as one of these, so callers can tell
+// them apart with errors.Is regardless of the backend.
+var (
+	ErrNotFound    = errors.New("not found")
+	ErrInvalidID   = errors.New("invalid ID")
+	ErrConflict    = errors.New("conflict")
+	ErrUnavailable = errors.New("database unavailable")
+)
+
+// ErrVersionMismatch is returned when a book changed since the version the
+// caller based its change on. It is a kind of ErrConflict.
+var ErrVersionMismatch = fmt.Errorf("%w: book version mismatch", ErrConflict)
+
+// kindError attaches one of the sentinel errors to a backend error while
+// keeping the original error in the chain.
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e kindError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e kindError) Unwrap() error {
+	return e.err
+}
+
+func (e kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func withKind(kind, err error) error {
+	return kindError{kind: kind, err: err}
+}
+
+var errBookNotFound = fmt.Errorf("book %w", ErrNotFound)
+
+func invalidID(id ID, err error) error {
+	return fmt.Errorf("%q is not a valid hex string: %w", id, withKind(ErrInvalidID, err))
+}
+
+// parseID checks that id is a hex encoded ObjectID, the format all
+// backends use for identifiers.
+func parseID(id ID) (primitive.ObjectID, error) {
+	objectID, err := primitive.ObjectIDFromHex(string(id))
+	if err != nil {
+		return objectID, invalidID(id, err)
+	}
+
+	return objectID, nil
+}
+
+// classifyMongoError maps driver errors onto the sentinel errors.
+func classifyMongoError(err error) error {
+	var selection topology.ServerSelectionError
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return withKind(ErrNotFound, err)
+	case mongo.IsDuplicateKeyError(err):
+		return withKind(ErrConflict, err)
+	case mongo.IsNetworkError(err), mongo.IsTimeout(err), errors.As(err, &selection),
+		errors.Is(err, mongo.ErrClientDisconnected):
+		return withKind(ErrUnavailable, err)
+	}
+
+	return err
+}
+
+// classifyWriteError maps a single failed write of a bulk write onto the
+// sentinel errors.
+func classifyWriteError(writeErr mongo.WriteError) error {
+	switch writeErr.Code {
+	case 11000, 11001: // duplicate key
+		return withKind(ErrConflict, writeErr)
+	}
+
+	return writeErr
+}
+
+// classifyPostgresError maps database/sql and lib/pq errors onto the
+// sentinel errors.
+func classifyPostgresError(err error) error {
+	var (
+		pqErr  *pq.Error
+		netErr net.Error
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, sql.ErrNoRows):
+		return withKind(ErrNotFound, err)
+	case errors.As(err, &pqErr):
+		switch {
+		case pqErr.Code == "23505", // unique_violation
+			pqErr.Code == "40001", // serialization_failure
+			pqErr.Code == "40P01": // deadlock_detected
+			return withKind(ErrConflict, err)
+		case pqErr.Code.Class() == "08", // connection_exception
+			pqErr.Code.Class() == "53", // insufficient_resources
+			pqErr.Code.Class() == "57": // operator_intervention, e.g. admin_shutdown
+			return withKind(ErrUnavailable, err)
+		}
+	case errors.Is(err, driver.ErrBadConn), errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr):
+		return withKind(ErrUnavailable, err)
+	}
+
+	return err
+}
+
+var errLoanNotFound = fmt.Errorf("loan %w", ErrNotFound)
+
+var errMemberNotFound = fmt.Errorf("member %w", ErrNotFound)
+
+var errHoldNotFound = fmt.Errorf("hold %w", ErrNotFound)
+
+var errFineNotFound = fmt.Errorf("fine %w", ErrNotFound)
+
+var errCopyNotFound = fmt.Errorf("copy %w", ErrNotFound)
+
+var errRevisionNotFound = fmt.Errorf("revision %w", ErrNotFound)
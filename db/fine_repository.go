@@ -0,0 +1,26 @@
+package db
+
+import (
+	"context"
+
+	"github.com/iho/booksdb/models"
+)
+
+// FineRepository stores the fines of late loans. Like BookRepository, every
+// method is limited to the tenant of its context. A loan has at most one
+// fine, adding a second one fails with ErrConflict.
+type FineRepository interface {
+	AddFine(ctx context.Context, fine *models.Fine) (ID, error)
+	GetFine(ctx context.Context, ID ID) (*models.Fine, error)
+	// LoanFine returns the fine of a loan or ErrNotFound.
+	LoanFine(ctx context.Context, loanID ID) (*models.Fine, error)
+	// MemberFines lists the fines of a member, the latest first.
+	MemberFines(ctx context.Context, memberID ID) ([]*models.Fine, error)
+	RemoveAllFines(ctx context.Context) error
+	// UpdateFine works like BookRepository.UpdateBook.
+	UpdateFine(
+		ctx context.Context,
+		ID ID,
+		updateFn func(fine *models.Fine) (*models.Fine, error),
+	) (*models.Fine, error)
+}
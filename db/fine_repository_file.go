@@ -0,0 +1,27 @@
+package db
+
+import "github.com/iho/booksdb/models"
+
+const FineJournalName = "fines.log"
+
+// FileFineRepository is the fine counterpart of FileBookRepository.
+type FileFineRepository struct {
+	MemoryFineRepository
+	fileStore
+}
+
+func NewFileFineRepository(dataDir string) (FileFineRepository, error) {
+	memory := NewMemoryFineRepository()
+
+	journal, file, err := openFileStore(dataDir, FineJournalName, memory.StoreRW, memory.Store,
+		func(id ID, doc interface{}) {
+			memory.storeFine(id, doc.(*models.Fine))
+		}, memory.dropFine)
+	if err != nil {
+		return FileFineRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileFineRepository{MemoryFineRepository: memory, fileStore: file}, nil
+}
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryFineRepository struct {
+	Store   map[ID]*models.Fine
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+	// loans is a unique index of Store by tenant and loan. It is only
+	// changed through storeFine and dropFine.
+	loans map[string]ID
+}
+
+func NewMemoryFineRepository() MemoryFineRepository {
+	return MemoryFineRepository{
+		StoreRW: &sync.RWMutex{},
+		Store:   make(map[ID]*models.Fine),
+		loans:   make(map[string]ID),
+	}
+}
+
+func (repo MemoryFineRepository) AddFine(ctx context.Context, fine *models.Fine) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	tenant := TenantFromContext(ctx)
+	if _, ok := repo.loans[scopedKey(tenant, fine.LoanID.Hex())]; ok {
+		return ID(""), fmt.Errorf("%w: loan %s already has a fine", ErrConflict, fine.LoanID.Hex())
+	}
+
+	fine.ID = objectID
+	fine.Version = 1
+	fine.Tenant = tenant
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, fine); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.storeFine(id, fine)
+
+	return id, nil
+}
+
+func (repo MemoryFineRepository) GetFine(ctx context.Context, id ID) (*models.Fine, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	fine, ok := repo.Store[id]
+	if !ok || fine.Tenant != TenantFromContext(ctx) {
+		return nil, errFineNotFound
+	}
+
+	return fine, nil
+}
+
+func (repo MemoryFineRepository) LoanFine(ctx context.Context, loanID ID) (*models.Fine, error) {
+	if _, err := parseID(loanID); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	id, ok := repo.loans[scopedKey(TenantFromContext(ctx), string(loanID))]
+	if !ok {
+		return nil, errFineNotFound
+	}
+
+	return repo.Store[id], nil
+}
+
+func (repo MemoryFineRepository) MemberFines(ctx context.Context, memberID ID) ([]*models.Fine, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	fines := make([]*models.Fine, 0)
+	for _, fine := range repo.Store {
+		if fine.MemberID == objectID && fine.Tenant == tenant {
+			fines = append(fines, fine)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	// ObjectIDs start with their creation time
+	sort.Slice(fines, func(i, j int) bool { return fines[i].ID.Hex() > fines[j].ID.Hex() })
+
+	return fines, nil
+}
+
+func (repo MemoryFineRepository) RemoveAllFines(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	removed := make([]documentChange, 0)
+	for id, fine := range repo.Store {
+		if fine.Tenant == tenant {
+			removed = append(removed, documentChange{id: id})
+		}
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(removed); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range removed {
+		repo.dropFine(change.id)
+	}
+
+	return nil
+}
+
+func (repo MemoryFineRepository) UpdateFine(
+	ctx context.Context,
+	fineID ID,
+	updateFn func(fine *models.Fine) (*models.Fine, error),
+) (*models.Fine, error) {
+	if _, err := parseID(fineID); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	fine, ok := repo.Store[fineID]
+	if !ok || fine.Tenant != TenantFromContext(ctx) {
+		return nil, errFineNotFound
+	}
+
+	// updateFn gets a copy so that a failed update leaves the store intact
+	current := *fine
+
+	updatedFine, err := updateFn(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", err)
+	}
+
+	updatedFine.ID = fine.ID
+	updatedFine.LoanID = fine.LoanID
+	updatedFine.Version = fine.Version + 1
+	updatedFine.Tenant = fine.Tenant
+
+	if repo.journal != nil {
+		if err := repo.journal.put(fineID, updatedFine); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.storeFine(fineID, updatedFine)
+
+	return updatedFine, nil
+}
+
+// storeFine and dropFine change the store together with its index. They are
+// called with StoreRW held.
+func (repo MemoryFineRepository) storeFine(id ID, fine *models.Fine) {
+	repo.dropFine(id)
+
+	repo.Store[id] = fine
+	repo.loans[scopedKey(fine.Tenant, fine.LoanID.Hex())] = id
+}
+
+func (repo MemoryFineRepository) dropFine(id ID) {
+	fine, ok := repo.Store[id]
+	if !ok {
+		return
+	}
+
+	if key := scopedKey(fine.Tenant, fine.LoanID.Hex()); repo.loans[key] == id {
+		delete(repo.loans, key)
+	}
+
+	delete(repo.Store, id)
+}
@@ -0,0 +1,156 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBFineRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBFineRepository(client *mongo.Client) MongoDBFineRepository {
+	return MongoDBFineRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBFineRepository) getFineCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(FineCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBFineRepository) Migrate(ctx context.Context) error {
+	if err := migrateToTenants(ctx, repo.getFineCollection(), "loan_id_unique"); err != nil {
+		return err
+	}
+
+	_, err := repo.getFineCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "loan_id", Value: 1}},
+			Options: options.Index().SetName("tenant_loan_id_unique").SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "member_id", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBFineRepository) AddFine(ctx context.Context, fine *models.Fine) (ID, error) {
+	fine.ID = primitive.NewObjectID()
+	fine.Version = 1
+	fine.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.getFineCollection().InsertOne(ctx, fine)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a fine: %w", classifyMongoError(err))
+	}
+
+	return ID(fine.ID.Hex()), nil
+}
+
+func (repo MongoDBFineRepository) GetFine(ctx context.Context, id ID) (*models.Fine, error) {
+	fineID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findFine(ctx, bson.M{"_id": fineID})
+}
+
+func (repo MongoDBFineRepository) LoanFine(ctx context.Context, loanID ID) (*models.Fine, error) {
+	objectID, err := parseID(loanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findFine(ctx, bson.M{"loan_id": objectID})
+}
+
+func (repo MongoDBFineRepository) findFine(ctx context.Context, filter bson.M) (*models.Fine, error) {
+	fine := &models.Fine{}
+	filter["tenant"] = TenantFromContext(ctx)
+
+	err := repo.getFineCollection().FindOne(ctx, filter).Decode(fine)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a fine: %w", classifyMongoError(err))
+	}
+
+	return fine, nil
+}
+
+func (repo MongoDBFineRepository) MemberFines(ctx context.Context, memberID ID) ([]*models.Fine, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}})
+
+	cur, err := repo.getFineCollection().Find(ctx, bson.M{"member_id": objectID, "tenant": TenantFromContext(ctx)}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	fines := make([]*models.Fine, 0)
+	if err := cur.All(ctx, &fines); err != nil {
+		return nil, fmt.Errorf("can't decode fines: %w", classifyMongoError(err))
+	}
+
+	return fines, nil
+}
+
+func (repo MongoDBFineRepository) RemoveAllFines(ctx context.Context) error {
+	_, err := repo.getFineCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove fines: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBFineRepository) UpdateFine(
+	ctx context.Context,
+	fineID ID,
+	updateFn func(fine *models.Fine) (*models.Fine, error),
+) (*models.Fine, error) {
+	fine, err := repo.GetFine(ctx, fineID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, loanID, version, tenant := fine.ID, fine.LoanID, fine.Version, fine.Tenant
+
+	updatedFine, err := updateFn(fine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", err)
+	}
+
+	updatedFine.ID = id
+	updatedFine.LoanID = loanID
+	updatedFine.Version = version + 1
+	updatedFine.Tenant = tenant
+
+	result, err := repo.getFineCollection().ReplaceOne(ctx, versionFilter(id, version), updatedFine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", classifyMongoError(err))
+	}
+
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("failed to update fine: %w", ErrVersionMismatch)
+	}
+
+	return updatedFine, nil
+}
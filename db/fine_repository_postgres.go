@@ -0,0 +1,198 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const fineColumns = "id, loan_id, member_id, book_id, amount, paid, status, waiver_reason, assessed_at, settled_at, version, tenant"
+
+// PostgresFineRepository uses the schema created by
+// PostgresBookRepository.Migrate.
+type PostgresFineRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresFineRepository(db *sql.DB) PostgresFineRepository {
+	return PostgresFineRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresFineRepository) AddFine(ctx context.Context, fine *models.Fine) (ID, error) {
+	fine.ID = primitive.NewObjectID()
+	fine.Version = 1
+	fine.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO "+FineTableName+" ("+fineColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)",
+		fine.ID.Hex(), fine.LoanID.Hex(), fine.MemberID.Hex(), fine.BookID.Hex(), fine.Amount, fine.Paid,
+		fine.Status, fine.WaiverReason, fine.AssessedAt, fine.SettledAt, fine.Version, fine.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a fine: %w", classifyPostgresError(err))
+	}
+
+	return ID(fine.ID.Hex()), nil
+}
+
+func (repo PostgresFineRepository) GetFine(ctx context.Context, id ID) (*models.Fine, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+fineColumns+" FROM "+FineTableName+" WHERE id = $1 AND tenant = $2",
+		string(id), TenantFromContext(ctx))
+
+	return scanFine(row)
+}
+
+func (repo PostgresFineRepository) LoanFine(ctx context.Context, loanID ID) (*models.Fine, error) {
+	if _, err := parseID(loanID); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+fineColumns+" FROM "+FineTableName+" WHERE loan_id = $1 AND tenant = $2",
+		string(loanID), TenantFromContext(ctx))
+
+	return scanFine(row)
+}
+
+func (repo PostgresFineRepository) MemberFines(ctx context.Context, memberID ID) ([]*models.Fine, error) {
+	if _, err := parseID(memberID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+fineColumns+" FROM "+FineTableName+" WHERE member_id = $1 AND tenant = $2 ORDER BY id DESC",
+		string(memberID), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	fines := make([]*models.Fine, 0)
+
+	for rows.Next() {
+		fine, err := scanFine(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		fines = append(fines, fine)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return fines, nil
+}
+
+func (repo PostgresFineRepository) RemoveAllFines(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+FineTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove fines: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresFineRepository) UpdateFine(
+	ctx context.Context,
+	fineID ID,
+	updateFn func(fine *models.Fine) (*models.Fine, error),
+) (*models.Fine, error) {
+	if _, err := parseID(fineID); err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+fineColumns+" FROM "+FineTableName+" WHERE id = $1 AND tenant = $2 FOR UPDATE",
+		string(fineID), TenantFromContext(ctx))
+
+	fine, err := scanFine(row)
+	if err != nil {
+		return nil, err
+	}
+
+	id, loanID, version, tenant := fine.ID, fine.LoanID, fine.Version, fine.Tenant
+
+	updatedFine, err := updateFn(fine)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", err)
+	}
+
+	updatedFine.ID = id
+	updatedFine.LoanID = loanID
+	updatedFine.Version = version + 1
+	updatedFine.Tenant = tenant
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE "+FineTableName+" SET member_id = $3, book_id = $4, amount = $5, paid = $6, status = $7, "+
+			"waiver_reason = $8, assessed_at = $9, settled_at = $10, version = $11 WHERE id = $1 AND version = $2",
+		string(fineID), version, updatedFine.MemberID.Hex(), updatedFine.BookID.Hex(), updatedFine.Amount,
+		updatedFine.Paid, updatedFine.Status, updatedFine.WaiverReason, updatedFine.AssessedAt,
+		updatedFine.SettledAt, updatedFine.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", classifyPostgresError(err))
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", classifyPostgresError(err))
+	} else if updated == 0 {
+		return nil, fmt.Errorf("failed to update fine: %w", ErrVersionMismatch)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update fine: %w", classifyPostgresError(err))
+	}
+
+	return updatedFine, nil
+}
+
+func scanFine(row rowScanner) (*models.Fine, error) {
+	fine := &models.Fine{}
+
+	var (
+		id, loanID, memberID, bookID string
+		settledAt                    sql.NullTime
+	)
+
+	err := row.Scan(&id, &loanID, &memberID, &bookID, &fine.Amount, &fine.Paid, &fine.Status,
+		&fine.WaiverReason, &fine.AssessedAt, &settledAt, &fine.Version, &fine.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a fine: %w", classifyPostgresError(err))
+	}
+
+	fine.SettledAt = nullTime(settledAt)
+
+	for _, field := range []struct {
+		hex    string
+		target *primitive.ObjectID
+	}{
+		{id, &fine.ID}, {loanID, &fine.LoanID}, {memberID, &fine.MemberID}, {bookID, &fine.BookID},
+	} {
+		if *field.target, err = primitive.ObjectIDFromHex(strings.TrimSpace(field.hex)); err != nil {
+			return nil, fmt.Errorf("can't decode a fine: %w", err)
+		}
+	}
+
+	return fine, nil
+}
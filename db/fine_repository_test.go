@@ -0,0 +1,168 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FineRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *FineRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func (suite *FineRepositoryDBTestSuite) TestAddFine() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddFine"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			memberID := primitive.NewObjectID()
+			newFine := func() *models.Fine {
+				return &models.Fine{
+					LoanID:     primitive.NewObjectID(),
+					MemberID:   memberID,
+					BookID:     primitive.NewObjectID(),
+					Amount:     100,
+					Status:     models.FineOutstanding,
+					AssessedAt: now,
+				}
+			}
+
+			fine := newFine()
+			id, err := repo.Repositories.Fines.AddFine(suite.Context, fine)
+			suite.Assert().NoError(err)
+
+			stored, err := repo.Repositories.Fines.LoanFine(suite.Context, db.ID(fine.LoanID.Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(id, db.ID(stored.ID.Hex()))
+				suite.Assert().Equal(int64(100), stored.Amount)
+				suite.Assert().Nil(stored.SettledAt)
+			}
+
+			duplicate := newFine()
+			duplicate.LoanID = fine.LoanID
+			_, err = repo.Repositories.Fines.AddFine(suite.Context, duplicate)
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			second := newFine()
+			_, err = repo.Repositories.Fines.AddFine(suite.Context, second)
+			suite.Assert().NoError(err)
+
+			fines, err := repo.Repositories.Fines.MemberFines(suite.Context, db.ID(memberID.Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(fines, 2) {
+				suite.Assert().Equal(second.ID, fines[0].ID)
+			}
+
+			_, err = repo.Repositories.Fines.LoanFine(suite.Context, db.ID(primitive.NewObjectID().Hex()))
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *FineRepositoryDBTestSuite) TestUpdateFine() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("UpdateFine"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			id, err := repo.Repositories.Fines.AddFine(suite.Context, &models.Fine{
+				LoanID:     primitive.NewObjectID(),
+				MemberID:   primitive.NewObjectID(),
+				BookID:     primitive.NewObjectID(),
+				Amount:     100,
+				Status:     models.FineOutstanding,
+				AssessedAt: now,
+			})
+			suite.Assert().NoError(err)
+
+			pay := func(fine *models.Fine) (*models.Fine, error) {
+				return fine, fine.Pay(models.Payment{Amount: 100}, now)
+			}
+
+			paid, err := repo.Repositories.Fines.UpdateFine(suite.Context, id, pay)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(paid) {
+				suite.Assert().Equal(models.FinePaid, paid.Status)
+				suite.Assert().Equal(int64(2), paid.Version)
+			}
+
+			_, err = repo.Repositories.Fines.UpdateFine(suite.Context, id, pay)
+			suite.Assert().ErrorIs(err, models.ErrFineSettled)
+
+			stored, err := repo.Repositories.Fines.GetFine(suite.Context, id)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(int64(100), stored.Paid)
+				suite.Assert().NotNil(stored.SettledAt)
+			}
+		})
+	}
+}
+
+func (suite *FineRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+
+			fine := &models.Fine{
+				LoanID:     primitive.NewObjectID(),
+				MemberID:   primitive.NewObjectID(),
+				BookID:     primitive.NewObjectID(),
+				Amount:     100,
+				Status:     models.FineOutstanding,
+				AssessedAt: time.Now().UTC().Truncate(time.Millisecond),
+			}
+			id, err := repo.Repositories.Fines.AddFine(north, fine)
+			suite.Require().NoError(err)
+
+			_, err = repo.Repositories.Fines.GetFine(south, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repositories.Fines.LoanFine(south, db.ID(fine.LoanID.Hex()))
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			fines, err := repo.Repositories.Fines.MemberFines(south, db.ID(fine.MemberID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(fines)
+			_, err = repo.Repositories.Fines.UpdateFine(south, id, func(fine *models.Fine) (*models.Fine, error) {
+				return fine, fine.Waive(models.Waiver{Reason: "stolen"}, time.Now())
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			suite.Require().NoError(repo.Repositories.Fines.RemoveAllFines(south))
+			stored, err := repo.Repositories.Fines.LoanFine(north, db.ID(fine.LoanID.Hex()))
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal(models.FineOutstanding, stored.Status)
+			}
+		})
+	}
+}
+
+func (suite *FineRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestFineRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(FineRepositoryDBTestSuite))
+}
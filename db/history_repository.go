@@ -0,0 +1,20 @@
+package db
+
+import (
+	"context"
+
+	"github.com/iho/booksdb/models"
+)
+
+// HistoryRepository stores the revisions of books. Revisions are only ever
+// added, they outlive the books they belong to. Like BookRepository, every
+// method is limited to the tenant of its context.
+type HistoryRepository interface {
+	AddRevision(ctx context.Context, revision *models.Revision) (ID, error)
+	// BookRevisions lists the revisions of a book, the latest first.
+	BookRevisions(ctx context.Context, bookID ID) ([]*models.Revision, error)
+	// BookRevision returns the revision that produced the given version of
+	// a book.
+	BookRevision(ctx context.Context, bookID ID, version int64) (*models.Revision, error)
+	RemoveAllRevisions(ctx context.Context) error
+}
@@ -0,0 +1,22 @@
+package db
+
+const HistoryJournalName = "history.log"
+
+// FileHistoryRepository is the history counterpart of FileBookRepository.
+type FileHistoryRepository struct {
+	MemoryHistoryRepository
+	fileStore
+}
+
+func NewFileHistoryRepository(dataDir string) (FileHistoryRepository, error) {
+	memory := NewMemoryHistoryRepository()
+
+	journal, file, err := openFileStore(dataDir, HistoryJournalName, memory.StoreRW, memory.Store, nil, nil)
+	if err != nil {
+		return FileHistoryRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileHistoryRepository{MemoryHistoryRepository: memory, fileStore: file}, nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryHistoryRepository struct {
+	Store   map[ID]*models.Revision
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+}
+
+func NewMemoryHistoryRepository() MemoryHistoryRepository {
+	return MemoryHistoryRepository{
+		StoreRW: &sync.RWMutex{},
+		Store:   make(map[ID]*models.Revision),
+	}
+}
+
+func (repo MemoryHistoryRepository) AddRevision(ctx context.Context, revision *models.Revision) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	revision.ID = objectID
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, revision); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.Store[id] = revision
+
+	return id, nil
+}
+
+func (repo MemoryHistoryRepository) BookRevisions(ctx context.Context, bookID ID) ([]*models.Revision, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	revisions := make([]*models.Revision, 0)
+	for _, revision := range repo.Store {
+		if revision.BookID == objectID && revision.Tenant == tenant {
+			revisions = append(revisions, revision)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sortRevisions(revisions)
+
+	return revisions, nil
+}
+
+func (repo MemoryHistoryRepository) BookRevision(ctx context.Context, bookID ID, version int64) (*models.Revision, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	for _, revision := range repo.Store {
+		if revision.BookID == objectID && revision.Version == version && revision.Tenant == tenant {
+			return revision, nil
+		}
+	}
+
+	return nil, errRevisionNotFound
+}
+
+func (repo MemoryHistoryRepository) RemoveAllRevisions(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	for id, revision := range repo.Store {
+		if revision.Tenant != tenant {
+			continue
+		}
+
+		if repo.journal != nil {
+			if err := repo.journal.delete(id); err != nil {
+				return err
+			}
+		}
+
+		delete(repo.Store, id)
+	}
+
+	return nil
+}
+
+// sortRevisions orders revisions by version, the latest first.
+func sortRevisions(revisions []*models.Revision) {
+	sort.Slice(revisions, func(i, j int) bool {
+		if revisions[i].Version != revisions[j].Version {
+			return revisions[i].Version > revisions[j].Version
+		}
+
+		return revisions[i].ID.Hex() > revisions[j].ID.Hex()
+	})
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBHistoryRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBHistoryRepository(client *mongo.Client) MongoDBHistoryRepository {
+	return MongoDBHistoryRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBHistoryRepository) getHistoryCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(HistoryCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBHistoryRepository) Migrate(ctx context.Context) error {
+	if err := migrateToTenants(ctx, repo.getHistoryCollection()); err != nil {
+		return err
+	}
+
+	_, err := repo.getHistoryCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "tenant", Value: 1}, {Key: "version", Value: -1}},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBHistoryRepository) AddRevision(ctx context.Context, revision *models.Revision) (ID, error) {
+	revision.ID = primitive.NewObjectID()
+
+	_, err := repo.getHistoryCollection().InsertOne(ctx, revision)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a revision: %w", classifyMongoError(err))
+	}
+
+	return ID(revision.ID.Hex()), nil
+}
+
+func (repo MongoDBHistoryRepository) BookRevisions(ctx context.Context, bookID ID) ([]*models.Revision, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}, {Key: "_id", Value: -1}})
+
+	cur, err := repo.getHistoryCollection().Find(ctx, bson.M{"book_id": objectID, "tenant": TenantFromContext(ctx)}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	revisions := make([]*models.Revision, 0)
+	if err := cur.All(ctx, &revisions); err != nil {
+		return nil, fmt.Errorf("can't decode revisions: %w", classifyMongoError(err))
+	}
+
+	return revisions, nil
+}
+
+func (repo MongoDBHistoryRepository) BookRevision(ctx context.Context, bookID ID, version int64) (*models.Revision, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	revision := &models.Revision{}
+
+	filter := bson.M{"book_id": objectID, "tenant": TenantFromContext(ctx), "version": version}
+
+	err = repo.getHistoryCollection().FindOne(ctx, filter).Decode(revision)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a revision: %w", classifyMongoError(err))
+	}
+
+	return revision, nil
+}
+
+func (repo MongoDBHistoryRepository) RemoveAllRevisions(ctx context.Context) error {
+	_, err := repo.getHistoryCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove revisions: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const revisionColumns = "id, book_id, version, action, actor, at, reverted_to, changes, book, tenant"
+
+// PostgresHistoryRepository uses the schema created by
+// PostgresBookRepository.Migrate. The changes and the book snapshot are
+// kept as JSONB.
+type PostgresHistoryRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresHistoryRepository(db *sql.DB) PostgresHistoryRepository {
+	return PostgresHistoryRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresHistoryRepository) AddRevision(ctx context.Context, revision *models.Revision) (ID, error) {
+	revision.ID = primitive.NewObjectID()
+
+	changes := revision.Changes
+	if changes == nil {
+		changes = []models.FieldChange{}
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't encode changes: %w", err)
+	}
+
+	bookJSON, err := json.Marshal(revision.Book)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't encode a book: %w", err)
+	}
+
+	_, err = repo.DB.ExecContext(ctx,
+		"INSERT INTO "+HistoryTableName+" ("+revisionColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		revision.ID.Hex(), revision.BookID.Hex(), revision.Version, revision.Action, revision.Actor,
+		revision.At, revision.RevertedTo, changesJSON, bookJSON, revision.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a revision: %w", classifyPostgresError(err))
+	}
+
+	return ID(revision.ID.Hex()), nil
+}
+
+func (repo PostgresHistoryRepository) BookRevisions(ctx context.Context, bookID ID) ([]*models.Revision, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+revisionColumns+" FROM "+HistoryTableName+" WHERE book_id = $1 AND tenant = $2 "+
+			"ORDER BY version DESC, id DESC",
+		string(bookID), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	revisions := make([]*models.Revision, 0)
+
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return revisions, nil
+}
+
+func (repo PostgresHistoryRepository) BookRevision(ctx context.Context, bookID ID, version int64) (*models.Revision, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+revisionColumns+" FROM "+HistoryTableName+" WHERE book_id = $1 AND version = $2 AND tenant = $3 "+
+			"ORDER BY id DESC LIMIT 1",
+		string(bookID), version, TenantFromContext(ctx))
+
+	return scanRevision(row)
+}
+
+func (repo PostgresHistoryRepository) RemoveAllRevisions(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+HistoryTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove revisions: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func scanRevision(row rowScanner) (*models.Revision, error) {
+	revision := &models.Revision{}
+
+	var (
+		id, bookID    string
+		changes, book []byte
+	)
+
+	err := row.Scan(&id, &bookID, &revision.Version, &revision.Action, &revision.Actor, &revision.At,
+		&revision.RevertedTo, &changes, &book, &revision.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a revision: %w", classifyPostgresError(err))
+	}
+
+	if err := json.Unmarshal(changes, &revision.Changes); err != nil {
+		return nil, fmt.Errorf("can't decode a revision: %w", err)
+	}
+
+	if err := json.Unmarshal(book, &revision.Book); err != nil {
+		return nil, fmt.Errorf("can't decode a revision: %w", err)
+	}
+
+	// the snapshot is encoded as JSON, which leaves the tenant out
+	if revision.Book != nil {
+		revision.Book.Tenant = revision.Tenant
+	}
+
+	if revision.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id)); err != nil {
+		return nil, fmt.Errorf("can't decode a revision: %w", err)
+	}
+
+	if revision.BookID, err = primitive.ObjectIDFromHex(strings.TrimSpace(bookID)); err != nil {
+		return nil, fmt.Errorf("can't decode a revision: %w", err)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,186 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HistoryRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *HistoryRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func (suite *HistoryRepositoryDBTestSuite) TestBookHistory() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("BookHistory"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			ctx := db.WithActor(suite.Context, "alice")
+			books, history := repo.Repositories.Books, repo.Repositories.History
+
+			id, err := books.AddBook(ctx, &models.Book{Title: "Beowulf", Genres: []string{"epic"}})
+			suite.Require().NoError(err)
+
+			_, err = books.UpdateBook(ctx, id, func(book *models.Book) (*models.Book, error) {
+				book.Title = "Beowulf, translated"
+				book.Rating = 4
+				book.Genres = nil
+
+				return book, nil
+			})
+			suite.Require().NoError(err)
+
+			_, err = books.UpdateBook(db.WithRevert(ctx, 1), id, func(book *models.Book) (*models.Book, error) {
+				book.Title = "Beowulf"
+				return book, nil
+			})
+			suite.Require().NoError(err)
+
+			suite.Require().NoError(books.DeleteBook(suite.Context, id))
+			_, err = books.RestoreBook(suite.Context, id)
+			suite.Require().NoError(err)
+
+			revisions, err := history.BookRevisions(suite.Context, id)
+			suite.Require().NoError(err)
+			suite.Require().Len(revisions, 5)
+
+			actions := make([]models.RevisionAction, 0, len(revisions))
+			for i, revision := range revisions {
+				actions = append(actions, revision.Action)
+				suite.Assert().EqualValues(5-i, revision.Version)
+				suite.Assert().EqualValues(5-i, revision.Book.Version)
+			}
+			suite.Assert().Equal([]models.RevisionAction{
+				models.RevisionRestore, models.RevisionDelete, models.RevisionRevert,
+				models.RevisionUpdate, models.RevisionCreate,
+			}, actions)
+			suite.Assert().Equal(db.SystemActor, revisions[0].Actor)
+			suite.Assert().Equal("alice", revisions[2].Actor)
+			suite.Assert().EqualValues(1, revisions[2].RevertedTo)
+			suite.Assert().Empty(revisions[1].Changes)
+			suite.Assert().NotNil(revisions[1].Book.DeletedAt)
+
+			update := revisions[3]
+			suite.Assert().Equal([]models.FieldChange{
+				{Field: "title", From: []byte(`"Beowulf"`), To: []byte(`"Beowulf, translated"`)},
+				{Field: "genres", From: []byte(`["epic"]`)},
+				{Field: "rating", To: []byte(`4`)},
+			}, update.Changes)
+
+			revision, err := history.BookRevision(suite.Context, id, 1)
+			suite.Require().NoError(err)
+			suite.Assert().Equal(models.RevisionCreate, revision.Action)
+			suite.Assert().Equal("Beowulf", revision.Book.Title)
+			suite.Assert().Equal([]string{"epic"}, revision.Book.Genres)
+
+			_, err = history.BookRevision(suite.Context, id, 6)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			revisions, err = history.BookRevisions(suite.Context, db.ID(primitive.NewObjectID().Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(revisions)
+
+			_, err = history.BookRevisions(suite.Context, "not an id")
+			suite.Assert().ErrorIs(err, db.ErrInvalidID)
+
+			// the history belongs to the tenant of the book
+			other := db.WithTenant(suite.Context, "other")
+			revisions, err = history.BookRevisions(other, id)
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(revisions)
+			_, err = history.BookRevision(other, id, 1)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *HistoryRepositoryDBTestSuite) TestBatchHistory() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("BatchHistory"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			books, history := repo.Repositories.Books, repo.Repositories.History
+			isbn := common.CreateRandomISBN()
+
+			added := []*models.Book{{Title: "Beowulf", ISBN: isbn}, {Title: "Grendel", ISBN: isbn}}
+			errs, err := books.AddBooks(suite.Context, added, db.BatchBestEffort)
+			suite.Require().NoError(err)
+			suite.Require().NoError(errs[0])
+			suite.Require().ErrorIs(errs[1], db.ErrConflict)
+			id := db.ID(added[0].ID.Hex())
+
+			_, errs, err = books.UpdateBooks(suite.Context, []db.BookChange{{
+				ID: id,
+				Update: func(book *models.Book) (*models.Book, error) {
+					book.Rating = 3
+					return book, nil
+				},
+			}}, db.BatchAtomic)
+			suite.Require().NoError(err)
+			suite.Require().NoError(errs[0])
+
+			errs, err = books.DeleteBooks(suite.Context, []db.BookDeletion{{ID: id}}, db.BatchAtomic)
+			suite.Require().NoError(err)
+			suite.Require().NoError(errs[0])
+
+			revisions, err := history.BookRevisions(suite.Context, id)
+			suite.Require().NoError(err)
+			if suite.Assert().Len(revisions, 3) {
+				suite.Assert().Equal(models.RevisionDelete, revisions[0].Action)
+				suite.Assert().Equal(3, revisions[0].Book.Rating)
+				suite.Assert().Equal([]models.FieldChange{{Field: "rating", To: []byte(`3`)}}, revisions[1].Changes)
+				suite.Assert().Equal(models.RevisionCreate, revisions[2].Action)
+			}
+		})
+	}
+}
+
+func (suite *HistoryRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestHistoryRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(HistoryRepositoryDBTestSuite))
+}
+
+func TestFileHistoryRepositoryReopen(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repos, err := db.NewFileRepositories(dataDir)
+	require.NoError(t, err)
+
+	id, err := repos.Books.AddBook(ctx, &models.Book{Title: "Beowulf", Tags: []string{"old english"}})
+	require.NoError(t, err)
+	require.NoError(t, repos.Close(context.Background()))
+
+	repos, err = db.NewFileRepositories(dataDir)
+	require.NoError(t, err)
+	defer repos.Close(context.Background())
+
+	revision, err := repos.History.BookRevision(ctx, id, 1)
+	require.NoError(t, err)
+	require.Equal(t, models.RevisionCreate, revision.Action)
+	require.Equal(t, []models.FieldChange{
+		{Field: "title", To: []byte(`"Beowulf"`)},
+		{Field: "tags", To: []byte(`["old english"]`)},
+	}, revision.Changes)
+}
@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/iho/booksdb/models"
+)
+
+// HoldRepository stores the holds members place on books. The active holds
+// of a book form its queue, ordered by the time they were placed. Like
+// BookRepository, every method is limited to the tenant of its context. A
+// member has at most one active hold on a book, AddHold fails with
+// ErrConflict for a second one.
+type HoldRepository interface {
+	AddHold(ctx context.Context, hold *models.Hold) (ID, error)
+	GetHold(ctx context.Context, ID ID) (*models.Hold, error)
+	// BookHolds lists the active holds on a book in queue order.
+	BookHolds(ctx context.Context, bookID ID) ([]*models.Hold, error)
+	// MemberHolds lists every hold of a member, the latest first.
+	MemberHolds(ctx context.Context, memberID ID) ([]*models.Hold, error)
+	// OverdueHolds lists the ready holds whose pickup period ended before
+	// now. Unlike the other methods it covers every tenant, the holds tell
+	// which one they belong to.
+	OverdueHolds(ctx context.Context, now time.Time) ([]*models.Hold, error)
+	RemoveAllHolds(ctx context.Context) error
+	// UpdateHold works like BookRepository.UpdateBook.
+	UpdateHold(
+		ctx context.Context,
+		ID ID,
+		updateFn func(hold *models.Hold) (*models.Hold, error),
+	) (*models.Hold, error)
+}
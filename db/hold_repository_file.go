@@ -0,0 +1,22 @@
+package db
+
+const HoldJournalName = "holds.log"
+
+// FileHoldRepository is the hold counterpart of FileBookRepository.
+type FileHoldRepository struct {
+	MemoryHoldRepository
+	fileStore
+}
+
+func NewFileHoldRepository(dataDir string) (FileHoldRepository, error) {
+	memory := NewMemoryHoldRepository()
+
+	journal, file, err := openFileStore(dataDir, HoldJournalName, memory.StoreRW, memory.Store, nil, nil)
+	if err != nil {
+		return FileHoldRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileHoldRepository{MemoryHoldRepository: memory, fileStore: file}, nil
+}
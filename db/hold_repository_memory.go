@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryHoldRepository struct {
+	Store   map[ID]*models.Hold
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+}
+
+func NewMemoryHoldRepository() MemoryHoldRepository {
+	return MemoryHoldRepository{
+		StoreRW: &sync.RWMutex{},
+		Store:   make(map[ID]*models.Hold),
+	}
+}
+
+func (repo MemoryHoldRepository) AddHold(ctx context.Context, hold *models.Hold) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	if hold.Active() {
+		for _, stored := range repo.Store {
+			if stored.Tenant == tenant && stored.BookID == hold.BookID && stored.MemberID == hold.MemberID && stored.Active() {
+				return ID(""), fmt.Errorf("%w: member %s already has a hold on book %s",
+					ErrConflict, hold.MemberID.Hex(), hold.BookID.Hex())
+			}
+		}
+	}
+
+	hold.ID = objectID
+	hold.Version = 1
+	hold.Tenant = tenant
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, hold); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.Store[id] = hold
+
+	return id, nil
+}
+
+func (repo MemoryHoldRepository) GetHold(ctx context.Context, id ID) (*models.Hold, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	hold, ok := repo.Store[id]
+	if !ok || hold.Tenant != TenantFromContext(ctx) {
+		return nil, errHoldNotFound
+	}
+
+	return hold, nil
+}
+
+func (repo MemoryHoldRepository) BookHolds(ctx context.Context, bookID ID) ([]*models.Hold, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := repo.findHolds(ctx, func(hold *models.Hold) bool { return hold.BookID == objectID && hold.Active() })
+	sortHoldQueue(holds)
+
+	return holds, nil
+}
+
+func (repo MemoryHoldRepository) MemberHolds(ctx context.Context, memberID ID) ([]*models.Hold, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := repo.findHolds(ctx, func(hold *models.Hold) bool { return hold.MemberID == objectID })
+	sortHoldQueue(holds)
+
+	// the latest first
+	for i, j := 0, len(holds)-1; i < j; i, j = i+1, j-1 {
+		holds[i], holds[j] = holds[j], holds[i]
+	}
+
+	return holds, nil
+}
+
+func (repo MemoryHoldRepository) OverdueHolds(ctx context.Context, now time.Time) ([]*models.Hold, error) {
+	holds := repo.matchHolds(func(hold *models.Hold) bool { return hold.Overdue(now) })
+	sortHoldQueue(holds)
+
+	return holds, nil
+}
+
+// findHolds lists the matching holds of the tenant in no particular order.
+func (repo MemoryHoldRepository) findHolds(ctx context.Context, match func(hold *models.Hold) bool) []*models.Hold {
+	tenant := TenantFromContext(ctx)
+
+	return repo.matchHolds(func(hold *models.Hold) bool { return hold.Tenant == tenant && match(hold) })
+}
+
+// matchHolds lists the matching holds of every tenant in no particular
+// order.
+func (repo MemoryHoldRepository) matchHolds(match func(hold *models.Hold) bool) []*models.Hold {
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	holds := make([]*models.Hold, 0)
+	for _, hold := range repo.Store {
+		if match(hold) {
+			holds = append(holds, hold)
+		}
+	}
+
+	return holds
+}
+
+func (repo MemoryHoldRepository) RemoveAllHolds(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	removed := make([]documentChange, 0)
+	for id, hold := range repo.Store {
+		if hold.Tenant == tenant {
+			removed = append(removed, documentChange{id: id})
+		}
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(removed); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range removed {
+		delete(repo.Store, change.id)
+	}
+
+	return nil
+}
+
+func (repo MemoryHoldRepository) UpdateHold(
+	ctx context.Context,
+	holdID ID,
+	updateFn func(hold *models.Hold) (*models.Hold, error),
+) (*models.Hold, error) {
+	if _, err := parseID(holdID); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	hold, ok := repo.Store[holdID]
+	if !ok || hold.Tenant != TenantFromContext(ctx) {
+		return nil, errHoldNotFound
+	}
+
+	// updateFn gets a copy so that a failed update leaves the store intact
+	current := *hold
+
+	updatedHold, err := updateFn(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	updatedHold.ID = hold.ID
+	updatedHold.BookID = hold.BookID
+	updatedHold.Version = hold.Version + 1
+	updatedHold.Tenant = hold.Tenant
+
+	if repo.journal != nil {
+		if err := repo.journal.put(holdID, updatedHold); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.Store[holdID] = updatedHold
+
+	return updatedHold, nil
+}
+
+// sortHoldQueue orders holds the way they queue up, the earliest first.
+func sortHoldQueue(holds []*models.Hold) {
+	sort.Slice(holds, func(i, j int) bool {
+		if !holds[i].PlacedAt.Equal(holds[j].PlacedAt) {
+			return holds[i].PlacedAt.Before(holds[j].PlacedAt)
+		}
+
+		return holds[i].ID.Hex() < holds[j].ID.Hex()
+	})
+}
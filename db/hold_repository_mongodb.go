@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBHoldRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBHoldRepository(client *mongo.Client) MongoDBHoldRepository {
+	return MongoDBHoldRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBHoldRepository) getHoldCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(HoldCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBHoldRepository) Migrate(ctx context.Context) error {
+	if err := migrateToTenants(ctx, repo.getHoldCollection()); err != nil {
+		return err
+	}
+
+	_, err := repo.getHoldCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "status", Value: 1}, {Key: "placed_at", Value: 1}}},
+		{Keys: bson.D{{Key: "member_id", Value: 1}, {Key: "placed_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "pickup_by", Value: 1}}},
+		{
+			Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "book_id", Value: 1}, {Key: "member_id", Value: 1}},
+			// a member has at most one active hold on a book
+			Options: options.Index().
+				SetName("tenant_active_hold_unique").
+				SetUnique(true).
+				SetPartialFilterExpression(bson.M{"status": bson.M{"$in": bson.A{models.HoldWaiting, models.HoldReady}}}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBHoldRepository) AddHold(ctx context.Context, hold *models.Hold) (ID, error) {
+	hold.ID = primitive.NewObjectID()
+	hold.Version = 1
+	hold.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.getHoldCollection().InsertOne(ctx, hold)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a hold: %w", classifyMongoError(err))
+	}
+
+	return ID(hold.ID.Hex()), nil
+}
+
+func (repo MongoDBHoldRepository) GetHold(ctx context.Context, id ID) (*models.Hold, error) {
+	holdID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	hold := &models.Hold{}
+
+	err = repo.getHoldCollection().FindOne(ctx, bson.M{"_id": holdID, "tenant": TenantFromContext(ctx)}).Decode(hold)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a hold: %w", classifyMongoError(err))
+	}
+
+	return hold, nil
+}
+
+func (repo MongoDBHoldRepository) BookHolds(ctx context.Context, bookID ID) ([]*models.Hold, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findHolds(ctx, bson.M{
+		"book_id": objectID,
+		"tenant":  TenantFromContext(ctx),
+		"status":  bson.M{"$in": bson.A{models.HoldWaiting, models.HoldReady}},
+	}, 1)
+}
+
+func (repo MongoDBHoldRepository) MemberHolds(ctx context.Context, memberID ID) ([]*models.Hold, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findHolds(ctx, bson.M{"member_id": objectID, "tenant": TenantFromContext(ctx)}, -1)
+}
+
+func (repo MongoDBHoldRepository) OverdueHolds(ctx context.Context, now time.Time) ([]*models.Hold, error) {
+	return repo.findHolds(ctx, bson.M{
+		"status":    models.HoldReady,
+		"pickup_by": bson.M{"$lte": now.UTC()},
+	}, 1)
+}
+
+// findHolds lists the matching holds by the time they were placed, in
+// ascending (1) or descending (-1) order.
+func (repo MongoDBHoldRepository) findHolds(ctx context.Context, filter bson.M, order int) ([]*models.Hold, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "placed_at", Value: order}, {Key: "_id", Value: order}})
+
+	cur, err := repo.getHoldCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	holds := make([]*models.Hold, 0)
+	if err := cur.All(ctx, &holds); err != nil {
+		return nil, fmt.Errorf("can't decode holds: %w", classifyMongoError(err))
+	}
+
+	return holds, nil
+}
+
+func (repo MongoDBHoldRepository) RemoveAllHolds(ctx context.Context) error {
+	_, err := repo.getHoldCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove holds: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBHoldRepository) UpdateHold(
+	ctx context.Context,
+	holdID ID,
+	updateFn func(hold *models.Hold) (*models.Hold, error),
+) (*models.Hold, error) {
+	hold, err := repo.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, bookID, version, tenant := hold.ID, hold.BookID, hold.Version, hold.Tenant
+
+	updatedHold, err := updateFn(hold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	updatedHold.ID = id
+	updatedHold.BookID = bookID
+	updatedHold.Version = version + 1
+	updatedHold.Tenant = tenant
+
+	result, err := repo.getHoldCollection().ReplaceOne(ctx, versionFilter(id, version), updatedHold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", classifyMongoError(err))
+	}
+
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("failed to update hold: %w", ErrVersionMismatch)
+	}
+
+	return updatedHold, nil
+}
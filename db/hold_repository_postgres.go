@@ -0,0 +1,216 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const holdColumns = "id, book_id, member_id, status, placed_at, ready_at, pickup_by, closed_at, version, tenant"
+
+// PostgresHoldRepository uses the schema created by
+// PostgresBookRepository.Migrate.
+type PostgresHoldRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresHoldRepository(db *sql.DB) PostgresHoldRepository {
+	return PostgresHoldRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresHoldRepository) AddHold(ctx context.Context, hold *models.Hold) (ID, error) {
+	hold.ID = primitive.NewObjectID()
+	hold.Version = 1
+	hold.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO "+HoldTableName+" ("+holdColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		hold.ID.Hex(), hold.BookID.Hex(), hold.MemberID.Hex(), hold.Status, hold.PlacedAt,
+		hold.ReadyAt, hold.PickupBy, hold.ClosedAt, hold.Version, hold.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a hold: %w", classifyPostgresError(err))
+	}
+
+	return ID(hold.ID.Hex()), nil
+}
+
+func (repo PostgresHoldRepository) GetHold(ctx context.Context, id ID) (*models.Hold, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+holdColumns+" FROM "+HoldTableName+" WHERE id = $1 AND tenant = $2",
+		string(id), TenantFromContext(ctx))
+
+	return scanHold(row)
+}
+
+func (repo PostgresHoldRepository) BookHolds(ctx context.Context, bookID ID) ([]*models.Hold, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	return repo.findHolds(ctx,
+		"WHERE tenant = $1 AND book_id = $2 AND status IN ($3, $4) ORDER BY placed_at, id",
+		TenantFromContext(ctx), string(bookID), models.HoldWaiting, models.HoldReady)
+}
+
+func (repo PostgresHoldRepository) MemberHolds(ctx context.Context, memberID ID) ([]*models.Hold, error) {
+	if _, err := parseID(memberID); err != nil {
+		return nil, err
+	}
+
+	return repo.findHolds(ctx, "WHERE tenant = $1 AND member_id = $2 ORDER BY placed_at DESC, id DESC",
+		TenantFromContext(ctx), string(memberID))
+}
+
+func (repo PostgresHoldRepository) OverdueHolds(ctx context.Context, now time.Time) ([]*models.Hold, error) {
+	return repo.findHolds(ctx,
+		"WHERE status = $1 AND pickup_by <= $2 ORDER BY placed_at, id",
+		models.HoldReady, now.UTC())
+}
+
+func (repo PostgresHoldRepository) findHolds(ctx context.Context, where string, args ...interface{}) ([]*models.Hold, error) {
+	rows, err := repo.DB.QueryContext(ctx, "SELECT "+holdColumns+" FROM "+HoldTableName+" "+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	defer rows.Close()
+
+	holds := make([]*models.Hold, 0)
+
+	for rows.Next() {
+		hold, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		holds = append(holds, hold)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return holds, nil
+}
+
+func (repo PostgresHoldRepository) RemoveAllHolds(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+HoldTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove holds: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresHoldRepository) UpdateHold(
+	ctx context.Context,
+	holdID ID,
+	updateFn func(hold *models.Hold) (*models.Hold, error),
+) (*models.Hold, error) {
+	if _, err := parseID(holdID); err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+holdColumns+" FROM "+HoldTableName+" WHERE id = $1 AND tenant = $2 FOR UPDATE",
+		string(holdID), TenantFromContext(ctx))
+
+	hold, err := scanHold(row)
+	if err != nil {
+		return nil, err
+	}
+
+	id, bookID, version, tenant := hold.ID, hold.BookID, hold.Version, hold.Tenant
+
+	updatedHold, err := updateFn(hold)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", err)
+	}
+
+	updatedHold.ID = id
+	updatedHold.BookID = bookID
+	updatedHold.Version = version + 1
+	updatedHold.Tenant = tenant
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE "+HoldTableName+" SET member_id = $3, status = $4, placed_at = $5, ready_at = $6, "+
+			"pickup_by = $7, closed_at = $8, version = $9 WHERE id = $1 AND version = $2",
+		string(holdID), version, updatedHold.MemberID.Hex(), updatedHold.Status, updatedHold.PlacedAt,
+		updatedHold.ReadyAt, updatedHold.PickupBy, updatedHold.ClosedAt, updatedHold.Version,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", classifyPostgresError(err))
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", classifyPostgresError(err))
+	} else if updated == 0 {
+		return nil, fmt.Errorf("failed to update hold: %w", ErrVersionMismatch)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update hold: %w", classifyPostgresError(err))
+	}
+
+	return updatedHold, nil
+}
+
+func scanHold(row rowScanner) (*models.Hold, error) {
+	hold := &models.Hold{}
+
+	var (
+		id, bookID, memberID        string
+		readyAt, pickupBy, closedAt sql.NullTime
+	)
+
+	err := row.Scan(&id, &bookID, &memberID, &hold.Status, &hold.PlacedAt,
+		&readyAt, &pickupBy, &closedAt, &hold.Version, &hold.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a hold: %w", classifyPostgresError(err))
+	}
+
+	hold.ReadyAt = nullTime(readyAt)
+	hold.PickupBy = nullTime(pickupBy)
+	hold.ClosedAt = nullTime(closedAt)
+
+	if hold.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id)); err != nil {
+		return nil, fmt.Errorf("can't decode a hold: %w", err)
+	}
+
+	if hold.BookID, err = primitive.ObjectIDFromHex(strings.TrimSpace(bookID)); err != nil {
+		return nil, fmt.Errorf("can't decode a hold: %w", err)
+	}
+
+	if hold.MemberID, err = primitive.ObjectIDFromHex(strings.TrimSpace(memberID)); err != nil {
+		return nil, fmt.Errorf("can't decode a hold: %w", err)
+	}
+
+	return hold, nil
+}
+
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+
+	return &value.Time
+}
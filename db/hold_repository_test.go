@@ -0,0 +1,196 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type HoldRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *HoldRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func (suite *HoldRepositoryDBTestSuite) TestBookHolds() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("BookHolds"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := primitive.NewObjectID()
+			memberIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()}
+			start := time.Now().UTC().Truncate(time.Millisecond)
+
+			ids := make([]db.ID, 3)
+			for i := range ids {
+				var err error
+				// placed in reverse order to check that the queue is sorted
+				ids[i], err = repo.Repositories.Holds.AddHold(suite.Context, &models.Hold{
+					BookID:   bookID,
+					MemberID: memberIDs[i],
+					Status:   models.HoldWaiting,
+					PlacedAt: start.Add(-time.Duration(i) * time.Minute),
+				})
+				suite.Assert().NoError(err)
+			}
+
+			// a member can't queue up twice for a book
+			_, err := repo.Repositories.Holds.AddHold(suite.Context, &models.Hold{
+				BookID:   bookID,
+				MemberID: memberIDs[1],
+				Status:   models.HoldWaiting,
+				PlacedAt: start,
+			})
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			cancelled, err := repo.Repositories.Holds.UpdateHold(suite.Context, ids[1], func(hold *models.Hold) (*models.Hold, error) {
+				return hold, hold.Cancel(start)
+			})
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(cancelled) {
+				suite.Assert().Equal(models.HoldCancelled, cancelled.Status)
+				suite.Assert().Equal(int64(2), cancelled.Version)
+			}
+
+			_, err = repo.Repositories.Holds.UpdateHold(suite.Context, ids[1], func(hold *models.Hold) (*models.Hold, error) {
+				return hold, hold.Cancel(start)
+			})
+			suite.Assert().ErrorIs(err, models.ErrHoldClosed)
+
+			// once the hold is cancelled the member can queue up again
+			again, err := repo.Repositories.Holds.AddHold(suite.Context, &models.Hold{
+				BookID:   bookID,
+				MemberID: memberIDs[1],
+				Status:   models.HoldWaiting,
+				PlacedAt: start.Add(time.Minute),
+			})
+			suite.Assert().NoError(err)
+
+			queue, err := repo.Repositories.Holds.BookHolds(suite.Context, db.ID(bookID.Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(queue, 3) {
+				suite.Assert().Equal(ids[2], db.ID(queue[0].ID.Hex()))
+				suite.Assert().Equal(ids[0], db.ID(queue[1].ID.Hex()))
+				suite.Assert().Equal(again, db.ID(queue[2].ID.Hex()))
+			}
+
+			holds, err := repo.Repositories.Holds.MemberHolds(suite.Context, db.ID(memberIDs[1].Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(holds, 2) {
+				suite.Assert().Equal(again, db.ID(holds[0].ID.Hex()))
+				suite.Assert().Equal(ids[1], db.ID(holds[1].ID.Hex()))
+			}
+
+			_, err = repo.Repositories.Holds.GetHold(suite.Context, db.ID(primitive.NewObjectID().Hex()))
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *HoldRepositoryDBTestSuite) TestOverdueHolds() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("OverdueHolds"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+
+			id, err := repo.Repositories.Holds.AddHold(suite.Context, &models.Hold{
+				BookID:   primitive.NewObjectID(),
+				MemberID: primitive.NewObjectID(),
+				Status:   models.HoldWaiting,
+				PlacedAt: now,
+			})
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Holds.UpdateHold(suite.Context, id, func(hold *models.Hold) (*models.Hold, error) {
+				return hold, hold.MakeReady(now, time.Hour)
+			})
+			suite.Assert().NoError(err)
+
+			overdue := func(at time.Time) bool {
+				holds, err := repo.Repositories.Holds.OverdueHolds(suite.Context, at)
+				suite.Assert().NoError(err)
+
+				for _, hold := range holds {
+					if db.ID(hold.ID.Hex()) == id {
+						return true
+					}
+				}
+
+				return false
+			}
+
+			suite.Assert().False(overdue(now.Add(time.Minute)))
+			suite.Assert().True(overdue(now.Add(2 * time.Hour)))
+		})
+	}
+}
+
+func (suite *HoldRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+
+			hold := &models.Hold{
+				BookID:   primitive.NewObjectID(),
+				MemberID: primitive.NewObjectID(),
+				Status:   models.HoldWaiting,
+				PlacedAt: time.Now().UTC().Truncate(time.Millisecond),
+			}
+			id, err := repo.Repositories.Holds.AddHold(north, hold)
+			suite.Require().NoError(err)
+
+			_, err = repo.Repositories.Holds.GetHold(south, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			holds, err := repo.Repositories.Holds.BookHolds(south, db.ID(hold.BookID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(holds)
+			holds, err = repo.Repositories.Holds.MemberHolds(south, db.ID(hold.MemberID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(holds)
+			_, err = repo.Repositories.Holds.UpdateHold(south, id, func(hold *models.Hold) (*models.Hold, error) {
+				return hold, hold.Cancel(time.Now())
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			suite.Require().NoError(repo.Repositories.Holds.RemoveAllHolds(south))
+			holds, err = repo.Repositories.Holds.BookHolds(north, db.ID(hold.BookID.Hex()))
+			if suite.Assert().NoError(err) && suite.Assert().Len(holds, 1) {
+				suite.Assert().Equal(models.HoldWaiting, holds[0].Status)
+			}
+
+			// the same member may queue up for the same book in another
+			// tenant
+			twin := *hold
+			_, err = repo.Repositories.Holds.AddHold(south, &twin)
+			suite.Assert().NoError(err)
+		})
+	}
+}
+
+func (suite *HoldRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestHoldRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(HoldRepositoryDBTestSuite))
+}
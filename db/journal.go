@@ -0,0 +1,474 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// compaction kicks in once the log holds this many records and more
+	// than compactionRatio records per live document.
+	compactionMinRecords = 1024
+	compactionRatio      = 2
+)
+
+type journalOp string
+
+const (
+	journalPut    journalOp = "put"
+	journalDelete journalOp = "delete"
+	// journalBatch holds records that are replayed all together or, if its
+	// line is torn, not at all.
+	journalBatch journalOp = "batch"
+)
+
+type journalRecord struct {
+	Op    journalOp       `json:"op"`
+	ID    ID              `json:"id,omitempty"`
+	Doc   json.RawMessage `json:"doc,omitempty"`
+	Batch []journalRecord `json:"batch,omitempty"`
+}
+
+// fileJournal is an append-only log of JSON lines. Every append is fsynced
+// before it returns, so a change reported as successful survives a crash.
+// A torn last line left by a crash in the middle of a write is dropped on
+// open, one left by a failed write is cut off right away.
+type fileJournal struct {
+	path    string
+	file    *os.File
+	records int
+	// broken is set if a failed write couldn't be undone, the journal
+	// refuses every write from then on.
+	broken error
+	// syncDir makes the rename of a snapshot durable.
+	syncDir func(path string) error
+}
+
+func openFileJournal(path string, apply func(record journalRecord) error) (*fileJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("can't create data directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("can't open journal: %w", err)
+	}
+
+	journal := &fileJournal{path: path, file: file, syncDir: syncDir}
+
+	valid, err := journal.replay(apply)
+	if err != nil {
+		file.Close()
+
+		return nil, err
+	}
+
+	// cut off a torn write so that new records start on a clean line
+	if err := file.Truncate(valid); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("can't truncate journal: %w", err)
+	}
+
+	if _, err := file.Seek(valid, io.SeekStart); err != nil {
+		file.Close()
+
+		return nil, fmt.Errorf("can't seek journal: %w", err)
+	}
+
+	return journal, nil
+}
+
+// replay applies every complete record and returns the length of the valid
+// prefix of the file.
+func (journal *fileJournal) replay(apply func(record journalRecord) error) (int64, error) {
+	reader := bufio.NewReader(journal.file)
+
+	var valid int64
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// an unterminated line is a write that never completed
+			return valid, nil
+		} else if err != nil {
+			return 0, fmt.Errorf("can't read journal: %w", err)
+		}
+
+		var record journalRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &record); err != nil {
+			return 0, fmt.Errorf("journal %s is corrupted at offset %d: %w", journal.path, valid, err)
+		}
+
+		batch := []journalRecord{record}
+		if record.Op == journalBatch {
+			batch = record.Batch
+		}
+
+		for _, record := range batch {
+			if err := apply(record); err != nil {
+				return 0, err
+			}
+		}
+
+		valid += int64(len(line))
+		journal.records++
+	}
+}
+
+// append writes records at once. If that fails the journal is cut back to
+// where it was, so that no partial line is left for later records to
+// follow.
+func (journal *fileJournal) append(records ...journalRecord) error {
+	if journal.broken != nil {
+		return fmt.Errorf("journal %s is unusable after a failed write: %w", journal.path, journal.broken)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("can't encode journal record: %w", err)
+		}
+	}
+
+	end, err := journal.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("can't seek journal: %w", err)
+	}
+
+	if _, err := journal.file.Write(buf.Bytes()); err != nil {
+		return journal.undo(end, fmt.Errorf("can't write journal: %w", err))
+	}
+
+	// the records may have reached the file even if the sync failed
+	if err := journal.file.Sync(); err != nil {
+		return journal.undo(end, fmt.Errorf("can't sync journal: %w", err))
+	}
+
+	journal.records += len(records)
+
+	return nil
+}
+
+// undo truncates the journal to end after a failed write and returns err.
+// The journal is marked broken if that fails too.
+func (journal *fileJournal) undo(end int64, err error) error {
+	if truncErr := journal.file.Truncate(end); truncErr != nil {
+		journal.broken = err
+
+		return fmt.Errorf("%w, and can't truncate journal: %v", err, truncErr) //nolint:errorlint
+	}
+
+	if _, seekErr := journal.file.Seek(end, io.SeekStart); seekErr != nil {
+		journal.broken = err
+
+		return fmt.Errorf("%w, and can't seek journal: %v", err, seekErr) //nolint:errorlint
+	}
+
+	return err
+}
+
+func (journal *fileJournal) needsCompaction(live int) bool {
+	return journal.records >= compactionMinRecords && journal.records > compactionRatio*live
+}
+
+// compact replaces the log with a snapshot of the live documents. The
+// snapshot is written next to the log and renamed over it, so a crash
+// leaves either the old or the new file in place. Once it is renamed the
+// journal writes to the snapshot, even if the rename can't be synced: the
+// old file isn't linked anymore, records appended to it would be lost.
+func (journal *fileJournal) compact(snapshot []journalRecord) error {
+	tmpPath := journal.path + ".compact"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("can't create snapshot: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+
+	for _, record := range snapshot {
+		if err := encoder.Encode(record); err != nil {
+			tmp.Close()
+
+			return fmt.Errorf("can't encode journal record: %w", err)
+		}
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("can't write snapshot: %w", err)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("can't sync snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, journal.path); err != nil {
+		tmp.Close()
+
+		return fmt.Errorf("can't replace journal: %w", err)
+	}
+
+	journal.file.Close()
+	journal.file = tmp
+	journal.records = len(snapshot)
+
+	return journal.syncDir(filepath.Dir(journal.path))
+}
+
+func (journal *fileJournal) close() error {
+	if err := journal.file.Close(); err != nil {
+		return fmt.Errorf("can't close journal: %w", err)
+	}
+
+	return nil
+}
+
+// syncDir makes a rename durable.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("can't open data directory: %w", err)
+	}
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("can't sync data directory: %w", err)
+	}
+
+	return nil
+}
+
+// documentJournal persists the changes of a memory repository before they
+// are applied to its store. It is called with the store lock held.
+type documentJournal interface {
+	put(id ID, doc interface{}) error
+	delete(id ID) error
+	// write persists all changes or none of them. It doesn't compact, the
+	// store may already hold the changes.
+	write(changes []documentChange) error
+	// compactIfNeeded compacts the journal if it grew too long. It has to
+	// run while the store matches the journal.
+	compactIfNeeded() error
+}
+
+// documentChange stores doc under id, or deletes the document if doc is
+// nil.
+type documentChange struct {
+	id  ID
+	doc interface{}
+}
+
+// pendingJournal collects changes instead of persisting them, so that they
+// can be written at once later.
+type pendingJournal struct {
+	changes []documentChange
+}
+
+func (j *pendingJournal) put(id ID, doc interface{}) error {
+	j.changes = append(j.changes, documentChange{id: id, doc: doc})
+
+	return nil
+}
+
+func (j *pendingJournal) delete(id ID) error {
+	j.changes = append(j.changes, documentChange{id: id})
+
+	return nil
+}
+
+func (j *pendingJournal) write(changes []documentChange) error {
+	j.changes = append(j.changes, changes...)
+
+	return nil
+}
+
+func (j *pendingJournal) compactIfNeeded() error {
+	return nil
+}
+
+// fileDocumentJournal writes documents to a fileJournal as relaxed extended
+// JSON using the bson field names, the same shape they have in MongoDB. The
+// log is compacted into a snapshot of the store once it grows too long.
+type fileDocumentJournal struct {
+	journal *fileJournal
+	// count and each read the store the journal belongs to.
+	count func() int
+	each  func(fn func(id ID, doc interface{}) error) error
+}
+
+func (j fileDocumentJournal) put(id ID, doc interface{}) error {
+	raw, err := bson.MarshalExtJSON(doc, false, false)
+	if err != nil {
+		return fmt.Errorf("can't encode a document: %w", err)
+	}
+
+	if err := j.compactIfNeeded(); err != nil {
+		return err
+	}
+
+	return j.journal.append(journalRecord{Op: journalPut, ID: id, Doc: raw})
+}
+
+func (j fileDocumentJournal) delete(id ID) error {
+	if err := j.compactIfNeeded(); err != nil {
+		return err
+	}
+
+	return j.journal.append(journalRecord{Op: journalDelete, ID: id})
+}
+
+// write appends the changes as a single batch record, a crash in the
+// middle of it leaves a torn line that is dropped on open.
+func (j fileDocumentJournal) write(changes []documentChange) error {
+	records := make([]journalRecord, len(changes))
+
+	for i, change := range changes {
+		records[i] = journalRecord{Op: journalDelete, ID: change.id}
+
+		if change.doc != nil {
+			raw, err := bson.MarshalExtJSON(change.doc, false, false)
+			if err != nil {
+				return fmt.Errorf("can't encode a document: %w", err)
+			}
+
+			records[i] = journalRecord{Op: journalPut, ID: change.id, Doc: raw}
+		}
+	}
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	return j.journal.append(journalRecord{Op: journalBatch, Batch: records})
+}
+
+// compactIfNeeded runs before put and delete append a record, while the
+// store still matches the journal.
+func (j fileDocumentJournal) compactIfNeeded() error {
+	if !j.journal.needsCompaction(j.count()) {
+		return nil
+	}
+
+	snapshot := make([]journalRecord, 0, j.count())
+
+	err := j.each(func(id ID, doc interface{}) error {
+		raw, err := bson.MarshalExtJSON(doc, false, false)
+		if err != nil {
+			return fmt.Errorf("can't encode a document: %w", err)
+		}
+
+		snapshot = append(snapshot, journalRecord{Op: journalPut, ID: id, Doc: raw})
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return j.journal.compact(snapshot)
+}
+
+// fileStore is embedded by the file repositories. They keep every document
+// in the store of the memory repository they wrap and make changes durable
+// in a journal inside the data directory, reads never touch the disk.
+type fileStore struct {
+	lock *sync.RWMutex
+	file *fileJournal
+}
+
+// openFileStore opens the journal name inside dataDir, replays it into
+// store, the map[ID]*T of a memory repository guarded by lock, and returns
+// the journal the repository writes its changes to. put and drop replace
+// the plain map updates for repositories that index their store, they may
+// be nil.
+func openFileStore(
+	dataDir, name string,
+	lock *sync.RWMutex,
+	store interface{},
+	put func(id ID, doc interface{}),
+	drop func(id ID),
+) (fileDocumentJournal, fileStore, error) {
+	docs := reflect.ValueOf(store)
+	docType := docs.Type().Elem().Elem()
+
+	replay := func(record journalRecord) error {
+		switch record.Op {
+		case journalPut:
+			doc := reflect.New(docType)
+			if err := bson.UnmarshalExtJSON(record.Doc, false, doc.Interface()); err != nil {
+				return fmt.Errorf("can't decode a document of %s: %w", name, err)
+			}
+
+			// documents journaled before there were tenants
+			if tenant := doc.Elem().FieldByName("Tenant"); tenant.IsValid() && tenant.String() == "" {
+				tenant.SetString(DefaultTenant)
+			}
+
+			if put != nil {
+				put(record.ID, doc.Interface())
+			} else {
+				docs.SetMapIndex(reflect.ValueOf(record.ID), doc)
+			}
+
+			return nil
+		case journalDelete:
+			if drop != nil {
+				drop(record.ID)
+			} else {
+				docs.SetMapIndex(reflect.ValueOf(record.ID), reflect.Value{})
+			}
+
+			return nil
+		}
+
+		return fmt.Errorf("unknown journal operation %q", record.Op)
+	}
+
+	journal, err := openFileJournal(filepath.Join(dataDir, name), replay)
+	if err != nil {
+		return fileDocumentJournal{}, fileStore{}, err
+	}
+
+	documents := fileDocumentJournal{
+		journal: journal,
+		count:   docs.Len,
+		each: func(fn func(id ID, doc interface{}) error) error {
+			iter := docs.MapRange()
+			for iter.Next() {
+				if err := fn(ID(iter.Key().String()), iter.Value().Interface()); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return documents, fileStore{lock: lock, file: journal}, nil
+}
+
+// Close closes the journal, the repository must not be used afterwards.
+func (store fileStore) Close() error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	return store.file.close()
+}
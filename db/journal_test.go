@@ -0,0 +1,94 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAtomicBatchCompactionFailedWrite(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repo, err := NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	id, err := repo.AddBook(ctx, &models.Book{Title: "Beowulf"})
+	require.NoError(t, err)
+
+	// the next write compacts the journal, and the append after it fails
+	repo.file.records = compactionMinRecords
+	repo.file.broken = errors.New("disk full")
+
+	_, err = repo.AddBooks(ctx, []*models.Book{{Title: "Grendel"}, {Title: "The Hobbit"}}, BatchAtomic)
+	require.Error(t, err)
+	require.NoError(t, repo.Close())
+
+	journal, err := ioutil.ReadFile(filepath.Join(dataDir, BookJournalName))
+	require.NoError(t, err)
+	require.Equal(t, 1, bytes.Count(journal, []byte("\n")))
+
+	repo, err = NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	books, err := repo.AllBooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, books, 1)
+	require.Equal(t, id, ID(books[0].ID.Hex()))
+}
+
+func TestCompactionFailedDirSync(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dataDir := t.TempDir()
+
+	repo, err := NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+
+	id, err := repo.AddBook(ctx, &models.Book{Title: "Beowulf"})
+	require.NoError(t, err)
+
+	// the write that compacts the journal fails, the ones after it go to
+	// the snapshot
+	repo.file.records = compactionMinRecords
+	repo.file.syncDir = func(path string) error { return errors.New("I/O error") }
+
+	_, err = repo.AddBook(ctx, &models.Book{Title: "Grendel"})
+	require.Error(t, err)
+
+	written, err := repo.file.file.Stat()
+	require.NoError(t, err)
+	linked, err := os.Stat(filepath.Join(dataDir, BookJournalName))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(written, linked))
+
+	repo.file.syncDir = syncDir
+	added, err := repo.AddBook(ctx, &models.Book{Title: "The Hobbit"})
+	require.NoError(t, err)
+	require.NoError(t, repo.Close())
+
+	repo, err = NewFileBookRepository(dataDir)
+	require.NoError(t, err)
+	defer repo.Close()
+
+	books, err := repo.AllBooks(ctx)
+	require.NoError(t, err)
+	require.Len(t, books, 2)
+
+	_, err = repo.GetBook(ctx, id)
+	require.NoError(t, err)
+
+	_, err = repo.GetBook(ctx, added)
+	require.NoError(t, err)
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/iho/booksdb/models"
+)
+
+// LoanRepository stores the checkout history of books. Double checkouts are
+// prevented by the compare-and-swap on the status of the copy, or of the
+// book if it has no copies, a copy or a book without copies is expected to
+// have at most one open loan. Like BookRepository, every method is limited
+// to the tenant of its context.
+type LoanRepository interface {
+	AddLoan(ctx context.Context, loan *models.Loan) (ID, error)
+	GetLoan(ctx context.Context, ID ID) (*models.Loan, error)
+	DeleteLoan(ctx context.Context, ID ID) error
+	// ReturnLoan closes the open loan of the copy copyID of the book, or of
+	// the book itself if copyID is empty, and returns it. It fails with
+	// ErrNotFound if there is no such loan.
+	ReturnLoan(ctx context.Context, bookID, copyID ID, returnedAt time.Time) (*models.Loan, error)
+	// BookLoans lists the loans of a book, the latest first.
+	BookLoans(ctx context.Context, bookID ID) ([]*models.Loan, error)
+	// MemberLoans lists the loans of a member, the latest first.
+	MemberLoans(ctx context.Context, memberID ID) ([]*models.Loan, error)
+	// OverdueLoans lists the open loans that were due before now, the
+	// longest overdue first. Unlike the other methods it covers every
+	// tenant, the loans tell which one they belong to.
+	OverdueLoans(ctx context.Context, now time.Time) ([]*models.Loan, error)
+	RemoveAllLoans(ctx context.Context) error
+}
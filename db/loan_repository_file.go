@@ -0,0 +1,22 @@
+package db
+
+const LoanJournalName = "loans.log"
+
+// FileLoanRepository is the loan counterpart of FileBookRepository.
+type FileLoanRepository struct {
+	MemoryLoanRepository
+	fileStore
+}
+
+func NewFileLoanRepository(dataDir string) (FileLoanRepository, error) {
+	memory := NewMemoryLoanRepository()
+
+	journal, file, err := openFileStore(dataDir, LoanJournalName, memory.StoreRW, memory.Store, nil, nil)
+	if err != nil {
+		return FileLoanRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileLoanRepository{MemoryLoanRepository: memory, fileStore: file}, nil
+}
@@ -0,0 +1,217 @@
+package db
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryLoanRepository struct {
+	Store   map[ID]*models.Loan
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+}
+
+func NewMemoryLoanRepository() MemoryLoanRepository {
+	return MemoryLoanRepository{
+		StoreRW: &sync.RWMutex{},
+		Store:   make(map[ID]*models.Loan),
+	}
+}
+
+func (repo MemoryLoanRepository) AddLoan(ctx context.Context, loan *models.Loan) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	loan.ID = objectID
+	loan.Tenant = TenantFromContext(ctx)
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, loan); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.Store[id] = loan
+
+	return id, nil
+}
+
+func (repo MemoryLoanRepository) GetLoan(ctx context.Context, id ID) (*models.Loan, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	loan, ok := repo.Store[id]
+	if !ok || loan.Tenant != TenantFromContext(ctx) {
+		return nil, errLoanNotFound
+	}
+
+	return loan, nil
+}
+
+func (repo MemoryLoanRepository) DeleteLoan(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	if loan, ok := repo.Store[id]; !ok || loan.Tenant != TenantFromContext(ctx) {
+		return errLoanNotFound
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.delete(id); err != nil {
+			return err
+		}
+	}
+
+	delete(repo.Store, id)
+
+	return nil
+}
+
+func (repo MemoryLoanRepository) ReturnLoan(
+	ctx context.Context,
+	bookID, copyID ID,
+	returnedAt time.Time,
+) (*models.Loan, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	var copyObjectID primitive.ObjectID
+
+	if copyID != "" {
+		if copyObjectID, err = parseID(copyID); err != nil {
+			return nil, err
+		}
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	for id, loan := range repo.Store {
+		if loan.BookID != objectID || loan.CopyID != copyObjectID || loan.Tenant != tenant || !loan.Open() {
+			continue
+		}
+
+		returned := *loan
+		returnedAt := returnedAt.UTC()
+		returned.ReturnedAt = &returnedAt
+
+		if repo.journal != nil {
+			if err := repo.journal.put(id, &returned); err != nil {
+				return nil, err
+			}
+		}
+
+		repo.Store[id] = &returned
+
+		return &returned, nil
+	}
+
+	return nil, errLoanNotFound
+}
+
+func (repo MemoryLoanRepository) BookLoans(ctx context.Context, bookID ID) ([]*models.Loan, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findLoans(ctx, func(loan *models.Loan) bool { return loan.BookID == objectID }), nil
+}
+
+func (repo MemoryLoanRepository) MemberLoans(ctx context.Context, memberID ID) ([]*models.Loan, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findLoans(ctx, func(loan *models.Loan) bool { return loan.MemberID == objectID }), nil
+}
+
+func (repo MemoryLoanRepository) OverdueLoans(ctx context.Context, now time.Time) ([]*models.Loan, error) {
+	loans := repo.matchLoans(func(loan *models.Loan) bool { return loan.Open() && loan.DueAt.Before(now) })
+
+	sort.SliceStable(loans, func(i, j int) bool { return loans[i].DueAt.Before(loans[j].DueAt) })
+
+	return loans, nil
+}
+
+// findLoans lists the matching loans of the tenant, the latest first.
+func (repo MemoryLoanRepository) findLoans(ctx context.Context, match func(loan *models.Loan) bool) []*models.Loan {
+	tenant := TenantFromContext(ctx)
+
+	return repo.matchLoans(func(loan *models.Loan) bool { return loan.Tenant == tenant && match(loan) })
+}
+
+// matchLoans lists the matching loans of every tenant, the latest first.
+func (repo MemoryLoanRepository) matchLoans(match func(loan *models.Loan) bool) []*models.Loan {
+	repo.StoreRW.RLock()
+	loans := make([]*models.Loan, 0)
+	for _, loan := range repo.Store {
+		if match(loan) {
+			loans = append(loans, loan)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sortLoans(loans)
+
+	return loans
+}
+
+func (repo MemoryLoanRepository) RemoveAllLoans(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	removed := make([]documentChange, 0)
+	for id, loan := range repo.Store {
+		if loan.Tenant == tenant {
+			removed = append(removed, documentChange{id: id})
+		}
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(removed); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range removed {
+		delete(repo.Store, change.id)
+	}
+
+	return nil
+}
+
+// sortLoans orders loans by checkout time, the latest first.
+func sortLoans(loans []*models.Loan) {
+	sort.Slice(loans, func(i, j int) bool {
+		if !loans[i].CheckedOutAt.Equal(loans[j].CheckedOutAt) {
+			return loans[i].CheckedOutAt.After(loans[j].CheckedOutAt)
+		}
+
+		return loans[i].ID.Hex() > loans[j].ID.Hex()
+	})
+}
@@ -0,0 +1,185 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var latestLoansFirst = bson.D{{Key: "checked_out_at", Value: -1}, {Key: "_id", Value: -1}} //nolint:gochecknoglobals
+
+type MongoDBLoanRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBLoanRepository(client *mongo.Client) MongoDBLoanRepository {
+	return MongoDBLoanRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBLoanRepository) getLoanCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(LoanCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBLoanRepository) Migrate(ctx context.Context) error {
+	if err := migrateToTenants(ctx, repo.getLoanCollection()); err != nil {
+		return err
+	}
+
+	_, err := repo.getLoanCollection().Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "checked_out_at", Value: -1}}},
+		{Keys: bson.D{{Key: "member_id", Value: 1}, {Key: "checked_out_at", Value: -1}}},
+		{Keys: bson.D{{Key: "due_at", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBLoanRepository) AddLoan(ctx context.Context, loan *models.Loan) (ID, error) {
+	loan.ID = primitive.NewObjectID()
+	loan.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.getLoanCollection().InsertOne(ctx, loan)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a loan: %w", classifyMongoError(err))
+	}
+
+	return ID(loan.ID.Hex()), nil
+}
+
+func (repo MongoDBLoanRepository) GetLoan(ctx context.Context, id ID) (*models.Loan, error) {
+	loanID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	loan := &models.Loan{}
+
+	err = repo.getLoanCollection().FindOne(ctx, bson.M{"_id": loanID, "tenant": TenantFromContext(ctx)}).Decode(loan)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a loan: %w", classifyMongoError(err))
+	}
+
+	return loan, nil
+}
+
+func (repo MongoDBLoanRepository) DeleteLoan(ctx context.Context, id ID) error {
+	loanID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := repo.getLoanCollection().DeleteOne(ctx, bson.M{"_id": loanID, "tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("nothing to delete: %w", errLoanNotFound)
+	}
+
+	return nil
+}
+
+func (repo MongoDBLoanRepository) ReturnLoan(
+	ctx context.Context,
+	bookID, copyID ID,
+	returnedAt time.Time,
+) (*models.Loan, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := bson.M{
+		"book_id":     objectID,
+		"tenant":      TenantFromContext(ctx),
+		"returned_at": bson.M{"$exists": false},
+		// loans of books without copies have none
+		"copy_id": bson.M{"$exists": false},
+	}
+
+	if copyID != "" {
+		if filter["copy_id"], err = parseID(copyID); err != nil {
+			return nil, err
+		}
+	}
+
+	loan := &models.Loan{}
+
+	err = repo.getLoanCollection().FindOneAndUpdate(ctx,
+		filter,
+		bson.M{"$set": bson.M{"returned_at": returnedAt.UTC()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(loan)
+	if err != nil {
+		return nil, fmt.Errorf("can't return a loan: %w", classifyMongoError(err))
+	}
+
+	return loan, nil
+}
+
+func (repo MongoDBLoanRepository) BookLoans(ctx context.Context, bookID ID) ([]*models.Loan, error) {
+	objectID, err := parseID(bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findLoans(ctx, bson.M{"book_id": objectID, "tenant": TenantFromContext(ctx)}, latestLoansFirst)
+}
+
+func (repo MongoDBLoanRepository) MemberLoans(ctx context.Context, memberID ID) ([]*models.Loan, error) {
+	objectID, err := parseID(memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	return repo.findLoans(ctx, bson.M{"member_id": objectID, "tenant": TenantFromContext(ctx)}, latestLoansFirst)
+}
+
+func (repo MongoDBLoanRepository) OverdueLoans(ctx context.Context, now time.Time) ([]*models.Loan, error) {
+	return repo.findLoans(ctx,
+		bson.M{"returned_at": bson.M{"$exists": false}, "due_at": bson.M{"$lt": now.UTC()}},
+		bson.D{{Key: "due_at", Value: 1}, {Key: "_id", Value: 1}},
+	)
+}
+
+// findLoans lists the matching loans in the given order.
+func (repo MongoDBLoanRepository) findLoans(ctx context.Context, filter bson.M, sort bson.D) ([]*models.Loan, error) {
+	opts := options.Find().SetSort(sort)
+
+	cur, err := repo.getLoanCollection().Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	loans := make([]*models.Loan, 0)
+	if err := cur.All(ctx, &loans); err != nil {
+		return nil, fmt.Errorf("can't decode loans: %w", classifyMongoError(err))
+	}
+
+	return loans, nil
+}
+
+func (repo MongoDBLoanRepository) RemoveAllLoans(ctx context.Context) error {
+	_, err := repo.getLoanCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove loans: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
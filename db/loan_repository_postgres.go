@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const loanColumns = "id, book_id, member_id, checked_out_at, due_at, returned_at, tenant, copy_id"
+
+// PostgresLoanRepository uses the schema created by
+// PostgresBookRepository.Migrate.
+type PostgresLoanRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresLoanRepository(db *sql.DB) PostgresLoanRepository {
+	return PostgresLoanRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresLoanRepository) AddLoan(ctx context.Context, loan *models.Loan) (ID, error) {
+	loan.ID = primitive.NewObjectID()
+	loan.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO "+LoanTableName+" ("+loanColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		loan.ID.Hex(), loan.BookID.Hex(), loan.MemberID.Hex(), loan.CheckedOutAt, loan.DueAt, loan.ReturnedAt,
+		loan.Tenant, postgresCopyID(loan.CopyID),
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a loan: %w", classifyPostgresError(err))
+	}
+
+	return ID(loan.ID.Hex()), nil
+}
+
+func (repo PostgresLoanRepository) GetLoan(ctx context.Context, id ID) (*models.Loan, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+loanColumns+" FROM "+LoanTableName+" WHERE id = $1 AND tenant = $2",
+		string(id), TenantFromContext(ctx))
+
+	return scanLoan(row)
+}
+
+func (repo PostgresLoanRepository) DeleteLoan(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	result, err := repo.DB.ExecContext(ctx,
+		"DELETE FROM "+LoanTableName+" WHERE id = $1 AND tenant = $2", string(id), TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("nothing to delete: %w", errLoanNotFound)
+	}
+
+	return nil
+}
+
+func (repo PostgresLoanRepository) ReturnLoan(
+	ctx context.Context,
+	bookID, copyID ID,
+	returnedAt time.Time,
+) (*models.Loan, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	var copyObjectID primitive.ObjectID
+
+	if copyID != "" {
+		var err error
+		if copyObjectID, err = parseID(copyID); err != nil {
+			return nil, err
+		}
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"UPDATE "+LoanTableName+" SET returned_at = $2 WHERE book_id = $1 AND tenant = $3 AND returned_at IS NULL "+
+			"AND copy_id IS NOT DISTINCT FROM $4 RETURNING "+loanColumns,
+		string(bookID), returnedAt.UTC(), TenantFromContext(ctx), postgresCopyID(copyObjectID),
+	)
+
+	return scanLoan(row)
+}
+
+// postgresCopyID stores the copy of a loan, NULL for books without copies.
+func postgresCopyID(id primitive.ObjectID) sql.NullString {
+	if id.IsZero() {
+		return sql.NullString{}
+	}
+
+	return sql.NullString{String: id.Hex(), Valid: true}
+}
+
+func (repo PostgresLoanRepository) BookLoans(ctx context.Context, bookID ID) ([]*models.Loan, error) {
+	if _, err := parseID(bookID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+loanColumns+" FROM "+LoanTableName+" WHERE book_id = $1 AND tenant = $2 "+
+			"ORDER BY checked_out_at DESC, id DESC",
+		string(bookID), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return scanLoans(rows)
+}
+
+func (repo PostgresLoanRepository) MemberLoans(ctx context.Context, memberID ID) ([]*models.Loan, error) {
+	if _, err := parseID(memberID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+loanColumns+" FROM "+LoanTableName+" WHERE member_id = $1 AND tenant = $2 "+
+			"ORDER BY checked_out_at DESC, id DESC",
+		string(memberID), TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return scanLoans(rows)
+}
+
+func (repo PostgresLoanRepository) OverdueLoans(ctx context.Context, now time.Time) ([]*models.Loan, error) {
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+loanColumns+" FROM "+LoanTableName+" WHERE returned_at IS NULL AND due_at < $1 ORDER BY due_at, id",
+		now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return scanLoans(rows)
+}
+
+func (repo PostgresLoanRepository) RemoveAllLoans(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+LoanTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove loans: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func scanLoan(row rowScanner) (*models.Loan, error) {
+	loan := &models.Loan{}
+
+	var (
+		id, bookID, memberID string
+		copyID               sql.NullString
+		returnedAt           sql.NullTime
+	)
+
+	err := row.Scan(&id, &bookID, &memberID, &loan.CheckedOutAt, &loan.DueAt, &returnedAt, &loan.Tenant, &copyID)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a loan: %w", classifyPostgresError(err))
+	}
+
+	if returnedAt.Valid {
+		loan.ReturnedAt = &returnedAt.Time
+	}
+
+	if loan.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id)); err != nil {
+		return nil, fmt.Errorf("can't decode a loan: %w", err)
+	}
+
+	if loan.BookID, err = primitive.ObjectIDFromHex(strings.TrimSpace(bookID)); err != nil {
+		return nil, fmt.Errorf("can't decode a loan: %w", err)
+	}
+
+	if loan.MemberID, err = primitive.ObjectIDFromHex(strings.TrimSpace(memberID)); err != nil {
+		return nil, fmt.Errorf("can't decode a loan: %w", err)
+	}
+
+	if copyID.Valid {
+		if loan.CopyID, err = primitive.ObjectIDFromHex(strings.TrimSpace(copyID.String)); err != nil {
+			return nil, fmt.Errorf("can't decode a loan: %w", err)
+		}
+	}
+
+	return loan, nil
+}
+
+func scanLoans(rows *sql.Rows) ([]*models.Loan, error) {
+	defer rows.Close()
+
+	loans := make([]*models.Loan, 0)
+
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		loans = append(loans, loan)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return loans, nil
+}
@@ -0,0 +1,245 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type LoanRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *LoanRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func (suite *LoanRepositoryDBTestSuite) TestAddLoan() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddLoan"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			now := time.Now().UTC().Truncate(time.Millisecond)
+			loan := &models.Loan{
+				BookID:       primitive.NewObjectID(),
+				MemberID:     primitive.NewObjectID(),
+				CheckedOutAt: now,
+				DueAt:        now.Add(models.DefaultLoanPeriod),
+			}
+			id, err := repo.Repositories.Loans.AddLoan(suite.Context, loan)
+			suite.Assert().NoError(err)
+
+			stored, err := repo.Repositories.Loans.GetLoan(suite.Context, id)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(loan.BookID, stored.BookID)
+				suite.Assert().Equal(loan.MemberID, stored.MemberID)
+				suite.Assert().True(stored.DueAt.Equal(loan.DueAt))
+				suite.Assert().True(stored.Open())
+			}
+
+			err = repo.Repositories.Loans.DeleteLoan(suite.Context, id)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Loans.GetLoan(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+		})
+	}
+}
+
+func (suite *LoanRepositoryDBTestSuite) TestReturnLoan() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("ReturnLoan"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := primitive.NewObjectID()
+			memberID := primitive.NewObjectID()
+			start := time.Now().UTC().Truncate(time.Millisecond)
+
+			for i := 0; i < 2; i++ {
+				checkedOutAt := start.Add(time.Duration(i) * time.Hour)
+				_, err := repo.Repositories.Loans.AddLoan(suite.Context, &models.Loan{
+					BookID:       bookID,
+					MemberID:     memberID,
+					CheckedOutAt: checkedOutAt,
+					DueAt:        checkedOutAt.Add(models.DefaultLoanPeriod),
+				})
+				suite.Assert().NoError(err)
+
+				returned, err := repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(bookID.Hex()), "", checkedOutAt.Add(time.Minute))
+				suite.Assert().NoError(err)
+				if suite.Assert().NotNil(returned) {
+					suite.Assert().False(returned.Open())
+				}
+			}
+
+			_, err := repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(bookID.Hex()), "", time.Now())
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			loans, err := repo.Repositories.Loans.BookLoans(suite.Context, db.ID(bookID.Hex()))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(loans, 2) {
+				suite.Assert().True(loans[0].CheckedOutAt.After(loans[1].CheckedOutAt))
+			}
+
+			loans, err = repo.Repositories.Loans.MemberLoans(suite.Context, db.ID(memberID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Len(loans, 2)
+		})
+	}
+}
+
+func (suite *LoanRepositoryDBTestSuite) TestReturnCopyLoan() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("ReturnCopyLoan"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := primitive.NewObjectID()
+			copyIDs := []primitive.ObjectID{primitive.NewObjectID(), primitive.NewObjectID()}
+			now := time.Now().UTC().Truncate(time.Millisecond)
+
+			for _, copyID := range copyIDs {
+				_, err := repo.Repositories.Loans.AddLoan(suite.Context, &models.Loan{
+					BookID:       bookID,
+					CopyID:       copyID,
+					MemberID:     primitive.NewObjectID(),
+					CheckedOutAt: now,
+					DueAt:        now.Add(models.DefaultLoanPeriod),
+				})
+				suite.Require().NoError(err)
+			}
+
+			_, err := repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(bookID.Hex()), "", now)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			returned, err := repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(bookID.Hex()), db.ID(copyIDs[1].Hex()), now)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(returned) {
+				suite.Assert().Equal(copyIDs[1], returned.CopyID)
+				suite.Assert().False(returned.Open())
+			}
+
+			_, err = repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(bookID.Hex()), db.ID(copyIDs[1].Hex()), now)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			loans, err := repo.Repositories.Loans.BookLoans(suite.Context, db.ID(bookID.Hex()))
+			suite.Assert().NoError(err)
+			open := 0
+			for _, loan := range loans {
+				if loan.Open() {
+					suite.Assert().Equal(copyIDs[0], loan.CopyID)
+					open++
+				}
+			}
+			suite.Assert().Equal(1, open)
+		})
+	}
+}
+
+func (suite *LoanRepositoryDBTestSuite) TestOverdueLoans() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("OverdueLoans"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			// far in the past so that the loans of the other tests aren't due
+			dueAt := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			ids := make([]db.ID, 3)
+
+			for i := range ids {
+				var err error
+				ids[i], err = repo.Repositories.Loans.AddLoan(suite.Context, &models.Loan{
+					BookID:       primitive.NewObjectID(),
+					MemberID:     primitive.NewObjectID(),
+					CheckedOutAt: dueAt.Add(-models.DefaultLoanPeriod),
+					DueAt:        dueAt.Add(time.Duration(2-i) * time.Hour),
+				})
+				suite.Assert().NoError(err)
+			}
+
+			loan, err := repo.Repositories.Loans.GetLoan(suite.Context, ids[1])
+			suite.Assert().NoError(err)
+			_, err = repo.Repositories.Loans.ReturnLoan(suite.Context, db.ID(loan.BookID.Hex()), "", dueAt)
+			suite.Assert().NoError(err)
+
+			loans, err := repo.Repositories.Loans.OverdueLoans(suite.Context, dueAt.Add(90*time.Minute))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(loans, 1) {
+				suite.Assert().Equal(ids[2], db.ID(loans[0].ID.Hex()))
+			}
+
+			loans, err = repo.Repositories.Loans.OverdueLoans(suite.Context, dueAt.Add(3*time.Hour))
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(loans, 2) {
+				suite.Assert().Equal(ids[2], db.ID(loans[0].ID.Hex()))
+				suite.Assert().Equal(ids[0], db.ID(loans[1].ID.Hex()))
+			}
+		})
+	}
+}
+
+func (suite *LoanRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+			now := time.Now().UTC().Truncate(time.Millisecond)
+
+			loan := &models.Loan{
+				BookID:       primitive.NewObjectID(),
+				MemberID:     primitive.NewObjectID(),
+				CheckedOutAt: now.Add(-2 * models.DefaultLoanPeriod),
+				DueAt:        now.Add(-models.DefaultLoanPeriod),
+			}
+			id, err := repo.Repositories.Loans.AddLoan(north, loan)
+			suite.Require().NoError(err)
+
+			_, err = repo.Repositories.Loans.GetLoan(south, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			loans, err := repo.Repositories.Loans.BookLoans(south, db.ID(loan.BookID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(loans)
+			loans, err = repo.Repositories.Loans.MemberLoans(south, db.ID(loan.MemberID.Hex()))
+			suite.Assert().NoError(err)
+			suite.Assert().Empty(loans)
+			_, err = repo.Repositories.Loans.ReturnLoan(south, db.ID(loan.BookID.Hex()), "", now)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			suite.Assert().ErrorIs(repo.Repositories.Loans.DeleteLoan(south, id), db.ErrNotFound)
+
+			suite.Require().NoError(repo.Repositories.Loans.RemoveAllLoans(south))
+			stored, err := repo.Repositories.Loans.GetLoan(north, id)
+			if suite.Assert().NoError(err) {
+				suite.Assert().True(stored.Open())
+			}
+		})
+	}
+}
+
+func (suite *LoanRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestLoanRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(LoanRepositoryDBTestSuite))
+}
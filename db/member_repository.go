@@ -0,0 +1,75 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemberRepository stores library members. Like BookRepository, every
+// method is limited to the tenant of its context. Emails are unique per
+// tenant, storing a second member with the same email fails with
+// ErrConflict.
+type MemberRepository interface {
+	AddMember(ctx context.Context, member *models.Member) (ID, error)
+	GetMember(ctx context.Context, ID ID) (*models.Member, error)
+	DeleteMember(ctx context.Context, ID ID) error
+	AllMembers(ctx context.Context) ([]*models.Member, error)
+	// QueryMembers returns a single page of members.
+	QueryMembers(ctx context.Context, query MemberQuery) (*MemberPage, error)
+	RemoveAllMembers(ctx context.Context) error
+	// UpdateMember works like BookRepository.UpdateBook.
+	UpdateMember(
+		ctx context.Context,
+		ID ID,
+		updateFn func(member *models.Member) (*models.Member, error),
+	) (*models.Member, error)
+}
+
+// MemberQuery describes a single page of members, ordered by ID and so by
+// the time they were added. Cursor is the NextCursor of the previous page.
+type MemberQuery struct {
+	Limit  int
+	Cursor string
+}
+
+type MemberPage struct {
+	Members    []*models.Member
+	NextCursor string
+}
+
+// normalize fills in defaults. after is the ID of the last member of the
+// previous page, or the nil ID that comes before all others on the first
+// page.
+func (query MemberQuery) normalize() (MemberQuery, primitive.ObjectID, error) {
+	query.Limit = pageLimit(query.Limit)
+
+	if query.Cursor == "" {
+		return query, primitive.NilObjectID, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return query, primitive.NilObjectID, ErrInvalidCursor
+	}
+
+	after, err := primitive.ObjectIDFromHex(string(raw))
+	if err != nil {
+		return query, primitive.NilObjectID, ErrInvalidCursor
+	}
+
+	return query, after, nil
+}
+
+// nextMemberCursor returns the cursor of the page following members, which
+// were fetched with one member more than limit, or an empty string if there
+// is nothing left.
+func nextMemberCursor(members []*models.Member, limit int) string {
+	if len(members) <= limit {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(members[limit-1].ID.Hex()))
+}
@@ -0,0 +1,27 @@
+package db
+
+import "github.com/iho/booksdb/models"
+
+const MemberJournalName = "members.log"
+
+// FileMemberRepository is the member counterpart of FileBookRepository.
+type FileMemberRepository struct {
+	MemoryMemberRepository
+	fileStore
+}
+
+func NewFileMemberRepository(dataDir string) (FileMemberRepository, error) {
+	memory := NewMemoryMemberRepository()
+
+	journal, file, err := openFileStore(dataDir, MemberJournalName, memory.StoreRW, memory.Store,
+		func(id ID, doc interface{}) {
+			memory.storeMember(id, doc.(*models.Member))
+		}, memory.dropMember)
+	if err != nil {
+		return FileMemberRepository{}, err
+	}
+
+	memory.journal = journal
+
+	return FileMemberRepository{MemoryMemberRepository: memory, fileStore: file}, nil
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemoryMemberRepository struct {
+	Store   map[ID]*models.Member
+	StoreRW *sync.RWMutex
+	// journal is nil for the plain in-memory repository.
+	journal documentJournal
+	// emails is a unique index of Store by tenant and email. It is only
+	// changed through storeMember and dropMember.
+	emails map[string]ID
+}
+
+func NewMemoryMemberRepository() MemoryMemberRepository {
+	return MemoryMemberRepository{
+		StoreRW: &sync.RWMutex{},
+		Store:   make(map[ID]*models.Member),
+		emails:  make(map[string]ID),
+	}
+}
+
+func (repo MemoryMemberRepository) AddMember(ctx context.Context, member *models.Member) (ID, error) {
+	objectID := primitive.NewObjectID()
+	id := ID(objectID.Hex())
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	now := time.Now().UTC()
+	member.ID = objectID
+	member.Version = 1
+	member.CreatedAt = now
+	member.UpdatedAt = now
+	member.Tenant = TenantFromContext(ctx)
+
+	if err := repo.checkEmail(member.Tenant, id, member.Email); err != nil {
+		return ID(""), err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(id, member); err != nil {
+			return ID(""), err
+		}
+	}
+
+	repo.storeMember(id, member)
+
+	return id, nil
+}
+
+func (repo MemoryMemberRepository) GetMember(ctx context.Context, id ID) (*models.Member, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	member, ok := repo.Store[id]
+	if !ok || member.Tenant != TenantFromContext(ctx) {
+		return nil, errMemberNotFound
+	}
+
+	return member, nil
+}
+
+func (repo MemoryMemberRepository) DeleteMember(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	if member, ok := repo.Store[id]; !ok || member.Tenant != TenantFromContext(ctx) {
+		return errMemberNotFound
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.delete(id); err != nil {
+			return err
+		}
+	}
+
+	repo.dropMember(id)
+
+	return nil
+}
+
+func (repo MemoryMemberRepository) AllMembers(ctx context.Context) ([]*models.Member, error) {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	defer repo.StoreRW.RUnlock()
+
+	members := make([]*models.Member, 0)
+	for _, member := range repo.Store {
+		if member.Tenant == tenant {
+			members = append(members, member)
+		}
+	}
+
+	return members, nil
+}
+
+func (repo MemoryMemberRepository) QueryMembers(ctx context.Context, query MemberQuery) (*MemberPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.RLock()
+	members := make([]*models.Member, 0)
+	for _, member := range repo.Store {
+		if member.Tenant == tenant && member.ID.Hex() > after.Hex() {
+			members = append(members, member)
+		}
+	}
+	repo.StoreRW.RUnlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].ID.Hex() < members[j].ID.Hex()
+	})
+
+	page := &MemberPage{NextCursor: nextMemberCursor(members, query.Limit)}
+
+	if len(members) > query.Limit {
+		members = members[:query.Limit]
+	}
+
+	page.Members = members
+
+	return page, nil
+}
+
+func (repo MemoryMemberRepository) RemoveAllMembers(ctx context.Context) error {
+	tenant := TenantFromContext(ctx)
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	removed := make([]documentChange, 0)
+	for id, member := range repo.Store {
+		if member.Tenant == tenant {
+			removed = append(removed, documentChange{id: id})
+		}
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.write(removed); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range removed {
+		repo.dropMember(change.id)
+	}
+
+	return nil
+}
+
+func (repo MemoryMemberRepository) UpdateMember(
+	ctx context.Context,
+	memberID ID,
+	updateFn func(member *models.Member) (*models.Member, error),
+) (*models.Member, error) {
+	if _, err := parseID(memberID); err != nil {
+		return nil, err
+	}
+
+	repo.StoreRW.Lock()
+	defer repo.StoreRW.Unlock()
+
+	member, ok := repo.Store[memberID]
+	if !ok || member.Tenant != TenantFromContext(ctx) {
+		return nil, errMemberNotFound
+	}
+
+	// updateFn gets a copy so that a failed update leaves the store intact
+	current := *member
+
+	updatedMember, err := updateFn(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+
+	updatedMember.ID = member.ID
+	updatedMember.Version = member.Version + 1
+	updatedMember.CreatedAt = member.CreatedAt
+	updatedMember.UpdatedAt = time.Now().UTC()
+	updatedMember.Tenant = member.Tenant
+
+	if err := repo.checkEmail(member.Tenant, memberID, updatedMember.Email); err != nil {
+		return nil, err
+	}
+
+	if repo.journal != nil {
+		if err := repo.journal.put(memberID, updatedMember); err != nil {
+			return nil, err
+		}
+	}
+
+	repo.storeMember(memberID, updatedMember)
+
+	return updatedMember, nil
+}
+
+// checkEmail reports a conflict if another member of the tenant already has
+// the email.
+func (repo MemoryMemberRepository) checkEmail(tenant string, id ID, email string) error {
+	if owner, ok := repo.emails[scopedKey(tenant, email)]; ok && owner != id {
+		return fmt.Errorf("%w: a member with email %s already exists", ErrConflict, email)
+	}
+
+	return nil
+}
+
+// storeMember and dropMember change the store together with its index. They
+// are called with StoreRW held.
+func (repo MemoryMemberRepository) storeMember(id ID, member *models.Member) {
+	repo.dropMember(id)
+
+	repo.Store[id] = member
+	repo.emails[scopedKey(member.Tenant, member.Email)] = id
+}
+
+func (repo MemoryMemberRepository) dropMember(id ID) {
+	member, ok := repo.Store[id]
+	if !ok {
+		return
+	}
+
+	if key := scopedKey(member.Tenant, member.Email); repo.emails[key] == id {
+		delete(repo.emails, key)
+	}
+
+	delete(repo.Store, id)
+}
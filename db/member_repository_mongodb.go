@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type MongoDBMemberRepository struct {
+	Client *mongo.Client
+}
+
+func NewMongoDBMemberRepository(client *mongo.Client) MongoDBMemberRepository {
+	return MongoDBMemberRepository{
+		Client: client,
+	}
+}
+
+func (repo MongoDBMemberRepository) getMemberCollection() *mongo.Collection {
+	return repo.Client.Database(DatabaseName).Collection(MemberCollectionName)
+}
+
+// Migrate creates the indexes the repository relies on. It is safe to run
+// on every start.
+func (repo MongoDBMemberRepository) Migrate(ctx context.Context) error {
+	// emails are only unique per tenant
+	if err := migrateToTenants(ctx, repo.getMemberCollection(), "email_unique"); err != nil {
+		return err
+	}
+
+	_, err := repo.getMemberCollection().Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "email", Value: 1}},
+		Options: options.Index().SetName("tenant_email_unique").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("can't create indexes: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBMemberRepository) AddMember(ctx context.Context, member *models.Member) (ID, error) {
+	now := time.Now().UTC()
+	member.ID = primitive.NewObjectID()
+	member.Version = 1
+	member.CreatedAt = now
+	member.UpdatedAt = now
+	member.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.getMemberCollection().InsertOne(ctx, member)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a member: %w", classifyMongoError(err))
+	}
+
+	return ID(member.ID.Hex()), nil
+}
+
+func (repo MongoDBMemberRepository) GetMember(ctx context.Context, id ID) (*models.Member, error) {
+	memberID, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	member := &models.Member{}
+
+	err = repo.getMemberCollection().FindOne(ctx, bson.M{"_id": memberID, "tenant": TenantFromContext(ctx)}).Decode(member)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a member: %w", classifyMongoError(err))
+	}
+
+	return member, nil
+}
+
+func (repo MongoDBMemberRepository) DeleteMember(ctx context.Context, id ID) error {
+	memberID, err := parseID(id)
+	if err != nil {
+		return err
+	}
+
+	result, err := repo.getMemberCollection().DeleteOne(ctx, bson.M{"_id": memberID, "tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	if result.DeletedCount == 0 {
+		return fmt.Errorf("nothing to delete: %w", errMemberNotFound)
+	}
+
+	return nil
+}
+
+func (repo MongoDBMemberRepository) AllMembers(ctx context.Context) ([]*models.Member, error) {
+	cur, err := repo.getMemberCollection().Find(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	members := make([]*models.Member, 0)
+	if err := cur.All(ctx, &members); err != nil {
+		return nil, fmt.Errorf("can't decode members: %w", classifyMongoError(err))
+	}
+
+	return members, nil
+}
+
+func (repo MongoDBMemberRepository) QueryMembers(ctx context.Context, query MemberQuery) (*MemberPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetLimit(int64(query.Limit + 1))
+
+	cur, err := repo.getMemberCollection().Find(ctx,
+		bson.M{"tenant": TenantFromContext(ctx), "_id": bson.M{"$gt": after}}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyMongoError(err))
+	}
+
+	defer cur.Close(ctx)
+
+	members := make([]*models.Member, 0, query.Limit+1)
+	if err := cur.All(ctx, &members); err != nil {
+		return nil, fmt.Errorf("can't decode members: %w", classifyMongoError(err))
+	}
+
+	page := &MemberPage{
+		Members:    members,
+		NextCursor: nextMemberCursor(members, query.Limit),
+	}
+	if len(members) > query.Limit {
+		page.Members = members[:query.Limit]
+	}
+
+	return page, nil
+}
+
+func (repo MongoDBMemberRepository) RemoveAllMembers(ctx context.Context) error {
+	_, err := repo.getMemberCollection().DeleteMany(ctx, bson.M{"tenant": TenantFromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("can't remove members: %w", classifyMongoError(err))
+	}
+
+	return nil
+}
+
+func (repo MongoDBMemberRepository) UpdateMember(
+	ctx context.Context,
+	memberID ID,
+	updateFn func(member *models.Member) (*models.Member, error),
+) (*models.Member, error) {
+	member, err := repo.GetMember(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := member.Version
+
+	updatedMember, err := updateFn(member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+
+	updatedMember.ID = member.ID
+	updatedMember.Version = version + 1
+	updatedMember.CreatedAt = member.CreatedAt
+	updatedMember.UpdatedAt = time.Now().UTC()
+	updatedMember.Tenant = member.Tenant
+
+	result, err := repo.getMemberCollection().ReplaceOne(ctx, versionFilter(member.ID, version), updatedMember)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", classifyMongoError(err))
+	}
+
+	if result.MatchedCount == 0 {
+		return nil, fmt.Errorf("failed to update member: %w", ErrVersionMismatch)
+	}
+
+	return updatedMember, nil
+}
@@ -0,0 +1,232 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const memberColumns = "id, name, email, expires_at, borrowing_limit, loans, version, created_at, updated_at, tenant"
+
+// PostgresMemberRepository uses the schema created by
+// PostgresBookRepository.Migrate.
+type PostgresMemberRepository struct {
+	DB *sql.DB
+}
+
+func NewPostgresMemberRepository(db *sql.DB) PostgresMemberRepository {
+	return PostgresMemberRepository{
+		DB: db,
+	}
+}
+
+func (repo PostgresMemberRepository) AddMember(ctx context.Context, member *models.Member) (ID, error) {
+	now := time.Now().UTC()
+	member.ID = primitive.NewObjectID()
+	member.Version = 1
+	member.CreatedAt = now
+	member.UpdatedAt = now
+	member.Tenant = TenantFromContext(ctx)
+
+	_, err := repo.DB.ExecContext(ctx,
+		"INSERT INTO "+MemberTableName+" ("+memberColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+		member.ID.Hex(), member.Name, member.Email, member.ExpiresAt, member.BorrowingLimit, member.Loans,
+		member.Version, member.CreatedAt, member.UpdatedAt, member.Tenant,
+	)
+	if err != nil {
+		return ID(""), fmt.Errorf("can't insert a member: %w", classifyPostgresError(err))
+	}
+
+	return ID(member.ID.Hex()), nil
+}
+
+func (repo PostgresMemberRepository) GetMember(ctx context.Context, id ID) (*models.Member, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+
+	row := repo.DB.QueryRowContext(ctx,
+		"SELECT "+memberColumns+" FROM "+MemberTableName+" WHERE id = $1 AND tenant = $2",
+		string(id), TenantFromContext(ctx))
+
+	return scanMember(row)
+}
+
+func (repo PostgresMemberRepository) DeleteMember(ctx context.Context, id ID) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+
+	result, err := repo.DB.ExecContext(ctx,
+		"DELETE FROM "+MemberTableName+" WHERE id = $1 AND tenant = $2", string(id), TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	if deleted == 0 {
+		return fmt.Errorf("nothing to delete: %w", errMemberNotFound)
+	}
+
+	return nil
+}
+
+func (repo PostgresMemberRepository) AllMembers(ctx context.Context) ([]*models.Member, error) {
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+memberColumns+" FROM "+MemberTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return scanMembers(rows)
+}
+
+func (repo PostgresMemberRepository) QueryMembers(ctx context.Context, query MemberQuery) (*MemberPage, error) {
+	query, after, err := query.normalize()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repo.DB.QueryContext(ctx,
+		"SELECT "+memberColumns+" FROM "+MemberTableName+" WHERE tenant = $1 AND id > $2 ORDER BY id LIMIT $3",
+		TenantFromContext(ctx), after.Hex(), query.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	members, err := scanMembers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &MemberPage{
+		Members:    members,
+		NextCursor: nextMemberCursor(members, query.Limit),
+	}
+	if len(members) > query.Limit {
+		page.Members = members[:query.Limit]
+	}
+
+	return page, nil
+}
+
+// scanMembers reads and closes rows.
+func scanMembers(rows *sql.Rows) ([]*models.Member, error) {
+	defer rows.Close()
+
+	members := make([]*models.Member, 0)
+
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, member)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("some database error has occurred: %w", classifyPostgresError(err))
+	}
+
+	return members, nil
+}
+
+func (repo PostgresMemberRepository) RemoveAllMembers(ctx context.Context) error {
+	_, err := repo.DB.ExecContext(ctx, "DELETE FROM "+MemberTableName+" WHERE tenant = $1", TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("can't remove members: %w", classifyPostgresError(err))
+	}
+
+	return nil
+}
+
+func (repo PostgresMemberRepository) UpdateMember(
+	ctx context.Context,
+	memberID ID,
+	updateFn func(member *models.Member) (*models.Member, error),
+) (*models.Member, error) {
+	if _, err := parseID(memberID); err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("can't start a transaction: %w", classifyPostgresError(err))
+	}
+
+	defer tx.Rollback() //nolint:errcheck
+
+	row := tx.QueryRowContext(ctx,
+		"SELECT "+memberColumns+" FROM "+MemberTableName+" WHERE id = $1 AND tenant = $2 FOR UPDATE",
+		string(memberID), TenantFromContext(ctx))
+
+	member, err := scanMember(row)
+	if err != nil {
+		return nil, err
+	}
+
+	tenant := member.Tenant
+
+	updatedMember, err := updateFn(member)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", err)
+	}
+
+	version := member.Version
+	updatedMember.ID = member.ID
+	updatedMember.Version = version + 1
+	updatedMember.CreatedAt = member.CreatedAt
+	updatedMember.UpdatedAt = time.Now().UTC()
+	updatedMember.Tenant = tenant
+
+	result, err := tx.ExecContext(ctx,
+		"UPDATE "+MemberTableName+" SET name = $3, email = $4, expires_at = $5, borrowing_limit = $6, "+
+			"loans = $7, version = $8, updated_at = $9 WHERE id = $1 AND version = $2",
+		string(memberID), version, updatedMember.Name, updatedMember.Email, updatedMember.ExpiresAt,
+		updatedMember.BorrowingLimit, updatedMember.Loans, updatedMember.Version, updatedMember.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", classifyPostgresError(err))
+	}
+
+	if updated, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", classifyPostgresError(err))
+	} else if updated == 0 {
+		return nil, fmt.Errorf("failed to update member: %w", ErrVersionMismatch)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update member: %w", classifyPostgresError(err))
+	}
+
+	return updatedMember, nil
+}
+
+func scanMember(row rowScanner) (*models.Member, error) {
+	member := &models.Member{}
+
+	var id string
+
+	err := row.Scan(&id, &member.Name, &member.Email, &member.ExpiresAt, &member.BorrowingLimit, &member.Loans,
+		&member.Version, &member.CreatedAt, &member.UpdatedAt, &member.Tenant)
+	if err != nil {
+		return nil, fmt.Errorf("can't find a member: %w", classifyPostgresError(err))
+	}
+
+	member.ID, err = primitive.ObjectIDFromHex(strings.TrimSpace(id))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode a member: %w", err)
+	}
+
+	return member, nil
+}
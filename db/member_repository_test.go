@@ -0,0 +1,198 @@
+package db_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type MemberRepositoryDBTestSuite struct {
+	common.Suite
+}
+
+func (suite *MemberRepositoryDBTestSuite) SetupSuite() {
+	suite.Suite.Setup()
+}
+
+func newMember() *models.Member {
+	member := &models.Member{
+		Name:  "Reader",
+		Email: primitive.NewObjectID().Hex() + "@example.com",
+	}
+	member.Normalize(time.Now())
+
+	return member
+}
+
+func (suite *MemberRepositoryDBTestSuite) TestAddMember() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("AddMember"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			member := newMember()
+			id, err := repo.Repositories.Members.AddMember(suite.Context, member)
+			suite.Assert().NoError(err)
+
+			stored, err := repo.Repositories.Members.GetMember(suite.Context, id)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(member.Email, stored.Email)
+				suite.Assert().Equal(int64(1), stored.Version)
+			}
+
+			duplicate := newMember()
+			duplicate.Email = member.Email
+			_, err = repo.Repositories.Members.AddMember(suite.Context, duplicate)
+			suite.Assert().ErrorIs(err, db.ErrConflict)
+
+			err = repo.Repositories.Members.DeleteMember(suite.Context, id)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Members.GetMember(suite.Context, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+
+			// the email is free again once its member is gone
+			_, err = repo.Repositories.Members.AddMember(suite.Context, duplicate)
+			suite.Assert().NoError(err)
+		})
+	}
+}
+
+func (suite *MemberRepositoryDBTestSuite) TestUpdateMember() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("UpdateMember"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			member := newMember()
+			member.BorrowingLimit = 1
+			id, err := repo.Repositories.Members.AddMember(suite.Context, member)
+			suite.Assert().NoError(err)
+
+			borrow := func(member *models.Member) (*models.Member, error) {
+				return member, member.Borrow(time.Now())
+			}
+
+			updated, err := repo.Repositories.Members.UpdateMember(suite.Context, id, borrow)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(updated) {
+				suite.Assert().Equal(1, updated.Loans)
+				suite.Assert().Equal(int64(2), updated.Version)
+			}
+
+			_, err = repo.Repositories.Members.UpdateMember(suite.Context, id, borrow)
+			suite.Assert().ErrorIs(err, models.ErrBorrowingLimitReached)
+
+			stored, err := repo.Repositories.Members.GetMember(suite.Context, id)
+			suite.Assert().NoError(err)
+			if suite.Assert().NotNil(stored) {
+				suite.Assert().Equal(1, stored.Loans)
+			}
+		})
+	}
+}
+
+func (suite *MemberRepositoryDBTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("Tenants"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			north := db.WithTenant(suite.Context, "n"+primitive.NewObjectID().Hex())
+			south := db.WithTenant(suite.Context, "s"+primitive.NewObjectID().Hex())
+
+			member := newMember()
+			id, err := repo.Repositories.Members.AddMember(north, member)
+			suite.Require().NoError(err)
+
+			// emails are only unique within a tenant
+			twin := newMember()
+			twin.Email = member.Email
+			_, err = repo.Repositories.Members.AddMember(south, twin)
+			suite.Assert().NoError(err)
+
+			_, err = repo.Repositories.Members.GetMember(south, id)
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			_, err = repo.Repositories.Members.UpdateMember(south, id, func(member *models.Member) (*models.Member, error) {
+				member.Name = "Stolen"
+				return member, nil
+			})
+			suite.Assert().ErrorIs(err, db.ErrNotFound)
+			suite.Assert().ErrorIs(repo.Repositories.Members.DeleteMember(south, id), db.ErrNotFound)
+
+			members, err := repo.Repositories.Members.AllMembers(south)
+			if suite.Assert().NoError(err) && suite.Assert().Len(members, 1) {
+				suite.Assert().Equal(twin.ID, members[0].ID)
+			}
+
+			suite.Require().NoError(repo.Repositories.Members.RemoveAllMembers(south))
+			stored, err := repo.Repositories.Members.GetMember(north, id)
+			if suite.Assert().NoError(err) {
+				suite.Assert().Equal(member.Email, stored.Email)
+			}
+		})
+	}
+}
+
+func (suite *MemberRepositoryDBTestSuite) TestQueryMembers() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, repo := range suite.Repositories {
+		repo := repo
+		t.Run("QueryMembers"+repo.Name, func(t *testing.T) {
+			t.Parallel()
+			ctx := db.WithTenant(suite.Context, "q"+primitive.NewObjectID().Hex())
+			var ids []primitive.ObjectID
+			for i := 0; i < 3; i++ {
+				member := newMember()
+				_, err := repo.Repositories.Members.AddMember(ctx, member)
+				suite.Require().NoError(err)
+				ids = append(ids, member.ID)
+			}
+
+			page, err := repo.Repositories.Members.QueryMembers(ctx, db.MemberQuery{Limit: 2})
+			suite.Require().NoError(err)
+			if suite.Assert().Len(page.Members, 2) {
+				suite.Assert().Equal(ids[:2], []primitive.ObjectID{page.Members[0].ID, page.Members[1].ID})
+			}
+			suite.Assert().NotEmpty(page.NextCursor)
+
+			// members added in the meantime come last
+			member := newMember()
+			_, err = repo.Repositories.Members.AddMember(ctx, member)
+			suite.Require().NoError(err)
+
+			page, err = repo.Repositories.Members.QueryMembers(ctx, db.MemberQuery{Limit: 2, Cursor: page.NextCursor})
+			suite.Require().NoError(err)
+			if suite.Assert().Len(page.Members, 2) {
+				suite.Assert().Equal([]primitive.ObjectID{ids[2], member.ID},
+					[]primitive.ObjectID{page.Members[0].ID, page.Members[1].ID})
+			}
+			suite.Assert().Empty(page.NextCursor)
+
+			_, err = repo.Repositories.Members.QueryMembers(ctx, db.MemberQuery{Cursor: "not a cursor"})
+			suite.Assert().ErrorIs(err, db.ErrInvalidCursor)
+		})
+	}
+}
+
+func (suite *MemberRepositoryDBTestSuite) TeardDownTest() {
+	suite.Suite.TeardDown()
+}
+
+func TestMemberRepositoryDBTestSuite(t *testing.T) {
+	suite.Run(t, new(MemberRepositoryDBTestSuite))
+}
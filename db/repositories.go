@@ -0,0 +1,183 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Repositories groups the repositories of one storage backend. Books
+// records every change of a book in History.
+type Repositories struct {
+	Books   BookRepository
+	Loans   LoanRepository
+	Members MemberRepository
+	Holds   HoldRepository
+	Fines   FineRepository
+	Copies  CopyRepository
+	History HistoryRepository
+	// release frees the database client shared by the repositories.
+	release func(ctx context.Context) error
+}
+
+func NewMemoryRepositories() Repositories {
+	history := NewMemoryHistoryRepository()
+
+	return Repositories{
+		Books:   NewHistoryBookRepository(NewMemoryBookRepository(), history),
+		Loans:   NewMemoryLoanRepository(),
+		Members: NewMemoryMemberRepository(),
+		Holds:   NewMemoryHoldRepository(),
+		Fines:   NewMemoryFineRepository(),
+		Copies:  NewMemoryCopyRepository(),
+		History: history,
+	}
+}
+
+// NewFileRepositories opens the journals of every repository inside
+// dataDir.
+func NewFileRepositories(dataDir string) (Repositories, error) {
+	var repos Repositories
+
+	books, err := NewFileBookRepository(dataDir)
+	if err != nil {
+		return repos, err
+	}
+
+	repos.Books = books
+
+	// the journals opened so far are closed again if a later one fails
+	loans, err := NewFileLoanRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.Loans = loans
+
+	members, err := NewFileMemberRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.Members = members
+
+	holds, err := NewFileHoldRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.Holds = holds
+
+	fines, err := NewFileFineRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.Fines = fines
+
+	copies, err := NewFileCopyRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.Copies = copies
+
+	history, err := NewFileHistoryRepository(dataDir)
+	if err != nil {
+		return Repositories{}, closeOnError(repos, err)
+	}
+
+	repos.History = history
+	repos.Books = NewHistoryBookRepository(books, history)
+
+	return repos, nil
+}
+
+func closeOnError(repos Repositories, err error) error {
+	_ = repos.Close(context.Background())
+
+	return err
+}
+
+// NewMongoDBRepositories takes over client, Close disconnects it.
+func NewMongoDBRepositories(client *mongo.Client) Repositories {
+	history := NewMongoDBHistoryRepository(client)
+
+	return Repositories{
+		Books:   NewHistoryBookRepository(NewMongoDBBookRepository(client), history),
+		Loans:   NewMongoDBLoanRepository(client),
+		Members: NewMongoDBMemberRepository(client),
+		Holds:   NewMongoDBHoldRepository(client),
+		Fines:   NewMongoDBFineRepository(client),
+		Copies:  NewMongoDBCopyRepository(client),
+		History: history,
+		release: client.Disconnect,
+	}
+}
+
+// NewPostgresRepositories takes over db, Close closes it.
+func NewPostgresRepositories(db *sql.DB) Repositories {
+	history := NewPostgresHistoryRepository(db)
+
+	return Repositories{
+		Books:   NewHistoryBookRepository(NewPostgresBookRepository(db), history),
+		Loans:   NewPostgresLoanRepository(db),
+		Members: NewPostgresMemberRepository(db),
+		Holds:   NewPostgresHoldRepository(db),
+		Fines:   NewPostgresFineRepository(db),
+		Copies:  NewPostgresCopyRepository(db),
+		History: history,
+		release: func(ctx context.Context) error { return db.Close() },
+	}
+}
+
+type migrator interface {
+	Migrate(ctx context.Context) error
+}
+
+// Migrate prepares the indexes and schema of every repository that needs
+// them. It is safe to run on every start.
+func (repos Repositories) Migrate(ctx context.Context) error {
+	for _, repo := range repos.all() {
+		if repo, ok := repo.(migrator); ok {
+			if err := repo.Migrate(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type closer interface {
+	Close() error
+}
+
+// Close flushes and closes the journals of the file backend and releases
+// the database client of the others. The repositories must not be used
+// afterwards. ctx bounds the time the client gets to finish its work.
+func (repos Repositories) Close(ctx context.Context) error {
+	var firstErr error
+
+	for _, repo := range repos.all() {
+		if repo, ok := repo.(closer); ok {
+			if err := repo.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	if repos.release != nil {
+		if err := repos.release(ctx); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("can't close the database connection: %w", err)
+		}
+	}
+
+	return firstErr
+}
+
+func (repos Repositories) all() []interface{} {
+	return []interface{}{repos.Books, repos.Loans, repos.Members, repos.Holds, repos.Fines, repos.Copies, repos.History}
+}
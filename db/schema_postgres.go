@@ -0,0 +1,166 @@
+package db
+
+// postgresSchema is applied by PostgresBookRepository.Migrate. Every
+// statement has to be idempotent.
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS ` + BookTableName + ` (
+	id         CHAR(24)    PRIMARY KEY,
+	title      TEXT        NOT NULL DEFAULT '',
+	author     TEXT        NOT NULL DEFAULT '',
+	publisher  TEXT        NOT NULL DEFAULT '',
+	rating     INTEGER     NOT NULL DEFAULT 0,
+	status     TEXT        NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS books_author_idx ON ` + BookTableName + ` (author);
+CREATE INDEX IF NOT EXISTS books_publisher_idx ON ` + BookTableName + ` (publisher);
+CREATE INDEX IF NOT EXISTS books_status_idx ON ` + BookTableName + ` (status);
+CREATE INDEX IF NOT EXISTS books_created_at_idx ON ` + BookTableName + ` (created_at);
+CREATE INDEX IF NOT EXISTS books_updated_at_idx ON ` + BookTableName + ` (updated_at);
+
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 0;
+
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS isbn TEXT;
+
+-- weights A, B and C stand for title, author and publisher, see
+-- postgresSearchWeights
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS search TSVECTOR GENERATED ALWAYS AS (
+	setweight(to_tsvector('english', title), 'A') ||
+	setweight(to_tsvector('english', author), 'B') ||
+	setweight(to_tsvector('english', publisher), 'C')
+) STORED;
+CREATE INDEX IF NOT EXISTS books_search_idx ON ` + BookTableName + ` USING GIN (search);
+
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS contributors JSONB NOT NULL DEFAULT '[]';
+CREATE INDEX IF NOT EXISTS books_contributors_idx ON ` + BookTableName + ` USING GIN (contributors jsonb_path_ops);
+
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS genres JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS dewey TEXT NOT NULL DEFAULT '';
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS lcc TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS books_genres_idx ON ` + BookTableName + ` USING GIN (genres jsonb_path_ops);
+CREATE INDEX IF NOT EXISTS books_tags_idx ON ` + BookTableName + ` USING GIN (tags jsonb_path_ops);
+
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS books_deleted_at_idx ON ` + BookTableName + ` (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- books stored before there were tenants belong to the default one
+ALTER TABLE ` + BookTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+
+-- books in the trash give up their ISBN, ISBNs are unique per tenant
+DROP INDEX IF EXISTS books_isbn_idx;
+DROP INDEX IF EXISTS books_live_isbn_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS books_tenant_isbn_idx ON ` + BookTableName + ` (tenant, isbn) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS books_tenant_idx ON ` + BookTableName + ` (tenant, created_at);
+
+CREATE TABLE IF NOT EXISTS ` + LoanTableName + ` (
+	id             CHAR(24)    PRIMARY KEY,
+	book_id        CHAR(24)    NOT NULL,
+	member_id      CHAR(24)    NOT NULL,
+	checked_out_at TIMESTAMPTZ NOT NULL,
+	due_at         TIMESTAMPTZ NOT NULL,
+	returned_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS loans_book_id_idx ON ` + LoanTableName + ` (book_id, checked_out_at);
+CREATE INDEX IF NOT EXISTS loans_member_id_idx ON ` + LoanTableName + ` (member_id, checked_out_at);
+CREATE INDEX IF NOT EXISTS loans_overdue_idx ON ` + LoanTableName + ` (due_at) WHERE returned_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS ` + MemberTableName + ` (
+	id              CHAR(24)    PRIMARY KEY,
+	name            TEXT        NOT NULL,
+	email           TEXT        NOT NULL UNIQUE,
+	expires_at      TIMESTAMPTZ NOT NULL,
+	borrowing_limit INTEGER     NOT NULL,
+	loans           INTEGER     NOT NULL DEFAULT 0,
+	version         BIGINT      NOT NULL DEFAULT 0,
+	created_at      TIMESTAMPTZ NOT NULL,
+	updated_at      TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ` + HoldTableName + ` (
+	id        CHAR(24)    PRIMARY KEY,
+	book_id   CHAR(24)    NOT NULL,
+	member_id CHAR(24)    NOT NULL,
+	status    TEXT        NOT NULL,
+	placed_at TIMESTAMPTZ NOT NULL,
+	ready_at  TIMESTAMPTZ,
+	pickup_by TIMESTAMPTZ,
+	closed_at TIMESTAMPTZ,
+	version   BIGINT      NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS holds_book_id_idx ON ` + HoldTableName + ` (book_id, status, placed_at);
+CREATE INDEX IF NOT EXISTS holds_member_id_idx ON ` + HoldTableName + ` (member_id, placed_at);
+CREATE INDEX IF NOT EXISTS holds_pickup_by_idx ON ` + HoldTableName + ` (status, pickup_by);
+
+CREATE TABLE IF NOT EXISTS ` + FineTableName + ` (
+	id            CHAR(24)    PRIMARY KEY,
+	loan_id       CHAR(24)    NOT NULL UNIQUE,
+	member_id     CHAR(24)    NOT NULL,
+	book_id       CHAR(24)    NOT NULL,
+	amount        BIGINT      NOT NULL,
+	paid          BIGINT      NOT NULL DEFAULT 0,
+	status        TEXT        NOT NULL,
+	waiver_reason TEXT        NOT NULL DEFAULT '',
+	assessed_at   TIMESTAMPTZ NOT NULL,
+	settled_at    TIMESTAMPTZ,
+	version       BIGINT      NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS fines_member_id_idx ON ` + FineTableName + ` (member_id);
+
+CREATE TABLE IF NOT EXISTS ` + CopyTableName + ` (
+	id          CHAR(24)    PRIMARY KEY,
+	book_id     CHAR(24)    NOT NULL,
+	barcode     TEXT        NOT NULL UNIQUE,
+	location    TEXT        NOT NULL DEFAULT '',
+	condition   TEXT        NOT NULL DEFAULT '',
+	status      TEXT        NOT NULL,
+	acquired_at TIMESTAMPTZ,
+	version     BIGINT      NOT NULL DEFAULT 0,
+	created_at  TIMESTAMPTZ NOT NULL,
+	updated_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS copies_book_id_idx ON ` + CopyTableName + ` (book_id, barcode);
+
+CREATE TABLE IF NOT EXISTS ` + HistoryTableName + ` (
+	id          CHAR(24)    PRIMARY KEY,
+	book_id     CHAR(24)    NOT NULL,
+	version     BIGINT      NOT NULL,
+	action      TEXT        NOT NULL,
+	actor       TEXT        NOT NULL,
+	at          TIMESTAMPTZ NOT NULL,
+	reverted_to BIGINT      NOT NULL DEFAULT 0,
+	changes     JSONB       NOT NULL,
+	book        JSONB       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS book_revisions_book_id_idx ON ` + HistoryTableName + ` (book_id, version);
+
+ALTER TABLE ` + HistoryTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+
+ALTER TABLE ` + LoanTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+ALTER TABLE ` + MemberTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+ALTER TABLE ` + HoldTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+ALTER TABLE ` + FineTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+ALTER TABLE ` + CopyTableName + ` ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '` + DefaultTenant + `';
+
+-- emails, barcodes and the fine of a loan are unique per tenant
+ALTER TABLE ` + MemberTableName + ` DROP CONSTRAINT IF EXISTS members_email_key;
+ALTER TABLE ` + CopyTableName + ` DROP CONSTRAINT IF EXISTS copies_barcode_key;
+ALTER TABLE ` + FineTableName + ` DROP CONSTRAINT IF EXISTS fines_loan_id_key;
+CREATE UNIQUE INDEX IF NOT EXISTS members_tenant_email_idx ON ` + MemberTableName + ` (tenant, email);
+CREATE UNIQUE INDEX IF NOT EXISTS copies_tenant_barcode_idx ON ` + CopyTableName + ` (tenant, barcode);
+CREATE UNIQUE INDEX IF NOT EXISTS fines_tenant_loan_id_idx ON ` + FineTableName + ` (tenant, loan_id);
+
+-- the copy a loan lends, NULL for books without copies
+ALTER TABLE ` + LoanTableName + ` ADD COLUMN IF NOT EXISTS copy_id CHAR(24);
+
+-- a member has at most one active hold on a book
+CREATE UNIQUE INDEX IF NOT EXISTS holds_tenant_active_member_idx ON ` + HoldTableName + ` (tenant, book_id, member_id)
+	WHERE status IN ('Waiting', 'Ready');
+`
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/iho/booksdb/models"
+)
+
+//nolint:gochecknoglobals
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"by": true, "for": true, "from": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "the": true, "to": true, "with": true,
+}
+
+// searchIndex is an inverted index of the searchable book fields used by
+// MemoryBookRepository. It is guarded by the repository lock.
+type searchIndex struct {
+	// postings maps a term to the weighted term frequency of every book
+	// containing it.
+	postings map[string]map[ID]float64
+	// terms remembers the terms of every book so that it can be removed.
+	terms map[ID][]string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[ID]float64),
+		terms:    make(map[ID][]string),
+	}
+}
+
+func (index *searchIndex) add(id ID, book *models.Book) {
+	frequencies := make(map[string]float64)
+
+	for _, field := range []struct {
+		text   string
+		weight float64
+	}{
+		{book.Title, titleSearchWeight},
+		{book.Author, authorSearchWeight},
+		{book.Publisher, publisherSearchWeight},
+	} {
+		tokens := tokenize(field.text)
+		for _, token := range tokens {
+			frequencies[token] += field.weight / float64(len(tokens))
+		}
+	}
+
+	terms := make([]string, 0, len(frequencies))
+
+	for term, frequency := range frequencies {
+		postings, ok := index.postings[term]
+		if !ok {
+			postings = make(map[ID]float64)
+			index.postings[term] = postings
+		}
+
+		postings[id] = frequency
+		terms = append(terms, term)
+	}
+
+	index.terms[id] = terms
+}
+
+func (index *searchIndex) remove(id ID) {
+	for _, term := range index.terms[id] {
+		delete(index.postings[term], id)
+
+		if len(index.postings[term]) == 0 {
+			delete(index.postings, term)
+		}
+	}
+
+	delete(index.terms, id)
+}
+
+// search scores every book containing one of the terms of text by the sum
+// of its weighted term frequencies times the inverse document frequency of
+// each term. Results are ordered by descending score, then by ID.
+func (index *searchIndex) search(text string, total int) []scoredID {
+	scores := make(map[ID]float64)
+
+	for _, term := range uniqueTokens(text) {
+		postings := index.postings[term]
+		idf := math.Log(1 + float64(total)/float64(len(postings)))
+
+		for id, frequency := range postings {
+			scores[id] += frequency * idf
+		}
+	}
+
+	results := make([]scoredID, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, scoredID{id: id, score: score})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+
+		return results[i].id < results[j].id
+	})
+
+	return results
+}
+
+type scoredID struct {
+	id    ID
+	score float64
+}
+
+// searchWords splits text into lower-cased words.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tokenize splits text into stemmed words without stop words.
+func tokenize(text string) []string {
+	words := searchWords(text)
+	tokens := words[:0]
+
+	for _, word := range words {
+		if !stopWords[word] {
+			tokens = append(tokens, stem(word))
+		}
+	}
+
+	return tokens
+}
+
+func uniqueTokens(text string) []string {
+	seen := make(map[string]bool)
+	tokens := make([]string, 0)
+
+	for _, token := range tokenize(text) {
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens
+}
+
+// stem strips common English inflections, so that "libraries" finds
+// "library" and "running" finds "run". It is deliberately simpler than a
+// full Porter stemmer.
+func stem(word string) string {
+	if len(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = word[:len(word)-2]
+	case strings.HasSuffix(word, "ies"):
+		word = word[:len(word)-3] + "y"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+	case strings.HasSuffix(word, "s"):
+		word = word[:len(word)-1]
+	}
+
+	for _, suffix := range []string{"ing", "ed", "ly"} {
+		base := strings.TrimSuffix(word, suffix)
+		if base == word || len(base) < 3 || !hasVowel(base) {
+			continue
+		}
+
+		// running -> runn -> run
+		if n := len(base); base[n-1] == base[n-2] && !strings.ContainsRune("lsz", rune(base[n-1])) {
+			base = base[:n-1]
+		}
+
+		return base
+	}
+
+	return word
+}
+
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
@@ -0,0 +1,42 @@
+package db
+
+import (
+	"context"
+	"regexp"
+)
+
+// DefaultTenant owns the books of callers that don't name a tenant and the
+// books stored before there were tenants.
+const DefaultTenant = "default"
+
+// tenants are named like DNS labels, so that they can be subdomains.
+var tenantPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`) //nolint:gochecknoglobals
+
+type tenantKey struct{}
+
+// WithTenant returns a context whose books belong to tenant. Every
+// BookRepository only sees the books of the tenant of its context.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns the tenant set by WithTenant or DefaultTenant.
+func TenantFromContext(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+
+	return DefaultTenant
+}
+
+// ValidTenant reports whether tenant is a valid tenant name: lower case
+// letters, digits and inner dashes, at most 63 characters.
+func ValidTenant(tenant string) bool {
+	return tenantPattern.MatchString(tenant)
+}
+
+// scopedKey identifies a value that is only unique within a tenant, like an
+// ISBN or an email, in the indexes of the memory repositories.
+func scopedKey(tenant, value string) string {
+	return tenant + "/" + value
+}
@@ -1,18 +1,49 @@
 package handlers
 
 import (
+	"time"
+
 	"github.com/go-logr/logr"
+	"github.com/iho/booksdb/auth"
 	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
 )
 
 type App struct {
-	BookRepository db.BookRepository
-	Logger         logr.Logger
+	BookRepository   db.BookRepository
+	LoanRepository   db.LoanRepository
+	MemberRepository db.MemberRepository
+	HoldRepository   db.HoldRepository
+	FineRepository   db.FineRepository
+	CopyRepository   db.CopyRepository
+	// HistoryRepository holds the revisions BookRepository records.
+	HistoryRepository db.HistoryRepository
+	FinePolicy        models.FinePolicy
+	// TrashRetention is how long deleted books can be restored.
+	TrashRetention time.Duration
+	// Authenticator checks the credentials of every request, they aren't
+	// checked if it is nil.
+	Authenticator *auth.Authenticator
+	// TenantDomain is the base domain whose subdomains name tenants,
+	// tenants aren't taken from the host if it is empty.
+	TenantDomain string
+	// StreamTimeout is how long an import or export may take, 0 for no
+	// limit. It only applies on servers using ConnContext.
+	StreamTimeout time.Duration
+	Logger        logr.Logger
 }
 
-func NewApp(bookRepository db.BookRepository, log logr.Logger) *App {
+func NewApp(repositories db.Repositories, log logr.Logger) *App {
 	return &App{
-		BookRepository: bookRepository,
-		Logger:         log,
+		BookRepository:    repositories.Books,
+		LoanRepository:    repositories.Loans,
+		MemberRepository:  repositories.Members,
+		HoldRepository:    repositories.Holds,
+		FineRepository:    repositories.Fines,
+		CopyRepository:    repositories.Copies,
+		HistoryRepository: repositories.History,
+		FinePolicy:        models.DefaultFinePolicy,
+		TrashRetention:    DefaultTrashRetention,
+		Logger:            log,
 	}
 }
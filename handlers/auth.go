@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/db"
+)
+
+// authenticate identifies the client of a request and responds with 401
+// Unauthorized if it can't. The principal goes into the request context
+// and is the actor of the changes the request makes. Without an
+// Authenticator every request is made by an admin named by ActorHeader.
+func (app *App) authenticate(c *gin.Context) {
+	principal := auth.Principal{Subject: c.GetHeader(ActorHeader), Role: auth.RoleAdmin}
+	if principal.Subject == "" {
+		principal.Subject = AnonymousActor
+	}
+
+	if app.Authenticator != nil {
+		var err error
+
+		principal, err = app.Authenticator.Authenticate(c.Request)
+		if err != nil {
+			if !errors.Is(err, auth.ErrNoCredentials) && !errors.Is(err, auth.ErrInvalidCredentials) {
+				app.abortWithError(c, err)
+				return
+			}
+
+			c.Header("WWW-Authenticate", `Bearer realm="booksdb"`)
+			app.problem(c, http.StatusUnauthorized, err.Error())
+
+			return
+		}
+	}
+
+	ctx := auth.WithPrincipal(c.Request.Context(), principal)
+	c.Request = c.Request.WithContext(db.WithActor(ctx, principal.Subject))
+	c.Next()
+}
+
+// authorize responds with 403 Forbidden unless the role of the client
+// includes required.
+func (app *App) authorize(required auth.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if app.hasRole(c, required) {
+			c.Next()
+		}
+	}
+}
+
+// hasRole reports whether the role of the client includes required and
+// responds with 403 Forbidden if it doesn't. Handlers that only require a
+// role for some requests call it instead of authorize.
+func (app *App) hasRole(c *gin.Context, required auth.Role) bool {
+	principal, _ := auth.PrincipalFromContext(c.Request.Context())
+	if !principal.Role.Allows(required) {
+		app.problem(c, http.StatusForbidden, "requires the "+string(required)+" role")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.uber.org/zap"
+)
+
+func (suite *BookHandlersTestSuite) TestAuthentication() {
+	t := suite.T()
+	t.Parallel()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	authenticator := auth.NewAuthenticator()
+	authenticator.Issuer = "https://id.example.org"
+	suite.Require().NoError(authenticator.AddAPIKey("reader-key", auth.Principal{Subject: "kiosk", Role: auth.RoleReader}))
+	suite.Require().NoError(authenticator.AddAPIKey("librarian-key", auth.Principal{Subject: "desk", Role: auth.RoleLibrarian}))
+	suite.Require().NoError(authenticator.AddAPIKey("admin-key", auth.Principal{Subject: "root", Role: auth.RoleAdmin}))
+	suite.Require().NoError(authenticator.AddJWTKey("k1", secret))
+	suite.Require().Error(authenticator.AddJWTKey("short", []byte("secret")))
+
+	repos := db.NewMemoryRepositories()
+	app := handlers.NewApp(repos, zapr.NewLogger(zap.NewNop()))
+	app.Authenticator = authenticator
+	server := httptest.NewServer(handlers.SetupRouter(app))
+	defer server.Close()
+
+	send := func(method, path, header, credentials string, body io.Reader) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, body)
+		suite.Require().NoError(err)
+		req.Header.Set("Content-Type", JSON_HTTP_HEADER)
+		if header != "" {
+			req.Header.Set(header, credentials)
+		}
+
+		return suite.do(req)
+	}
+	apiKey := func(method, path, key string, body io.Reader) int {
+		resp, _ := send(method, path, auth.APIKeyHeader, key, body)
+		return resp.StatusCode
+	}
+	bearer := func(claims auth.Claims, kid string, secret []byte) int {
+		token, err := auth.SignToken(claims, kid, secret)
+		suite.Require().NoError(err)
+		resp, _ := send(http.MethodGet, "/v1/books", "Authorization", "Bearer "+token, nil)
+
+		return resp.StatusCode
+	}
+	newBook := func() io.Reader {
+		jsonValue, _ := json.Marshal(&models.Book{Title: "Beowulf"})
+		return bytes.NewReader(jsonValue)
+	}
+
+	resp, _ := send(http.MethodGet, "/v1/books", "", "", nil)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+	suite.Assert().Contains(resp.Header.Get("WWW-Authenticate"), "Bearer")
+	suite.Assert().Equal(http.StatusUnauthorized, apiKey(http.MethodGet, "/v1/books", "guessed-key", nil))
+
+	suite.Assert().Equal(http.StatusOK, apiKey(http.MethodGet, "/v1/books", "reader-key", nil))
+	suite.Assert().Equal(http.StatusForbidden, apiKey(http.MethodPost, "/v1/books", "reader-key", newBook()))
+
+	resp, raw := send(http.MethodPost, "/v1/books", auth.APIKeyHeader, "librarian-key", newBook())
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+	book := &models.Book{}
+	suite.Require().NoError(json.Unmarshal(raw, book))
+
+	revisions, err := repos.History.BookRevisions(suite.Context, db.ID(book.ID.Hex()))
+	suite.Require().NoError(err)
+	if suite.Assert().Len(revisions, 1) {
+		suite.Assert().Equal("desk", revisions[0].Actor)
+	}
+
+	batchDelete := func(key string) int {
+		jsonValue, _ := json.Marshal(handlers.BatchDeleteRequest{Deletes: []handlers.BatchDelete{{ID: book.ID.Hex()}}})
+		return apiKey(http.MethodPost, "/v1/books:batchDelete", key, bytes.NewReader(jsonValue))
+	}
+	suite.Assert().Equal(http.StatusForbidden, batchDelete("librarian-key"))
+	suite.Assert().Equal(http.StatusOK, batchDelete("admin-key"))
+
+	now := time.Now()
+	claims := auth.Claims{
+		Subject:   "alice",
+		Role:      auth.RoleLibrarian,
+		Issuer:    "https://id.example.org",
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}
+	suite.Assert().Equal(http.StatusOK, bearer(claims, "k1", secret))
+	suite.Assert().Equal(http.StatusOK, bearer(claims, "", secret))
+	suite.Assert().Equal(http.StatusUnauthorized, bearer(claims, "k2", secret))
+	suite.Assert().Equal(http.StatusUnauthorized, bearer(claims, "k1", []byte("another secret, just as long....")))
+
+	expired := claims
+	expired.ExpiresAt = now.Add(-time.Hour).Unix()
+	suite.Assert().Equal(http.StatusUnauthorized, bearer(expired, "k1", secret))
+
+	foreign := claims
+	foreign.Issuer = "https://evil.example.org"
+	suite.Assert().Equal(http.StatusUnauthorized, bearer(foreign, "k1", secret))
+
+	roleless := claims
+	roleless.Role = "owner"
+	suite.Assert().Equal(http.StatusUnauthorized, bearer(roleless, "k1", secret))
+
+	// unsigned tokens are never accepted
+	token, err := auth.SignToken(claims, "k1", secret)
+	suite.Require().NoError(err)
+	segments := strings.Split(token, ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + segments[1] + "."
+	resp, _ = send(http.MethodGet, "/v1/books", "Authorization", "Bearer "+unsigned, nil)
+	suite.Assert().Equal(http.StatusUnauthorized, resp.StatusCode)
+
+	resp, _ = send(http.MethodPost, "/v1/books", "Authorization", "Bearer "+token, newBook())
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// Author is a contributor credited in at least one book.
+type Author struct {
+	Name     string                   `json:"name"`
+	SortName string                   `json:"sort_name"`
+	Roles    []models.ContributorRole `json:"roles"`
+	Books    int                      `json:"books"`
+}
+
+type AuthorList struct {
+	Authors    []Author `json:"authors"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// ListAuthors lists the distinct contributors of all books with the number
+// of books crediting them, ordered by sort name:
+//
+//	?role=&limit=20&cursor=
+//
+// A role only counts the books the contributors have that role in.
+func (app *App) ListAuthors(c *gin.Context) {
+	query := db.ContributorQuery{
+		Role:   models.ContributorRole(c.Query("role")),
+		Cursor: c.Query("cursor"),
+	}
+
+	if limit, err := intParam(c, "limit"); err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	} else if limit != nil {
+		query.Limit = *limit
+	}
+
+	page, err := app.BookRepository.Contributors(c.Request.Context(), query)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	list := AuthorList{
+		Authors:    make([]Author, 0, len(page.Contributors)),
+		NextCursor: page.NextCursor,
+	}
+	for _, contributor := range page.Contributors {
+		list.Authors = append(list.Authors, Author{
+			Name:     contributor.Name,
+			SortName: contributor.SortName,
+			Roles:    contributor.Roles,
+			Books:    contributor.Books,
+		})
+	}
+
+	app.render(c, http.StatusOK, list)
+}
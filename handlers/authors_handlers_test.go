@@ -0,0 +1,205 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) getAuthors(t *testing.T, server TestServer, role models.ContributorRole) map[string]handlers.Author {
+	t.Helper()
+
+	authors := make(map[string]handlers.Author)
+	cursor := ""
+
+	for {
+		resp, err := http.Get(server.TS.URL + "/v1/authors?limit=1000&role=" + string(role) + "&cursor=" + cursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+		list := &handlers.AuthorList{}
+		err = json.NewDecoder(resp.Body).Decode(list)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, author := range list.Authors {
+			authors[author.Name] = author
+		}
+
+		if list.NextCursor == "" {
+			return authors
+		}
+
+		cursor = list.NextCursor
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestContributors() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Contributors"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			prefix := primitive.NewObjectID().Hex()
+			editor, translator := prefix+" editor", prefix+" translator"
+			first, second := prefix+" first", prefix+" second"
+
+			anthology := &models.Book{
+				Title: "anthology",
+				Contributors: []models.Contributor{
+					{Name: " " + editor + " ", Role: models.RoleEditor, SortName: "Editor, " + prefix},
+					{Name: first, Role: models.RoleAuthor},
+					{Name: second, Role: models.RoleAuthor},
+					{Name: translator, Role: models.RoleTranslator},
+				},
+			}
+			jsonValue, _ := json.Marshal(anthology)
+
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+			if err != nil {
+				t.Fatal(err)
+			}
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+			anthology, err = suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			suite.Assert().Equal(first+", "+second, anthology.Author)
+			if suite.Assert().Len(anthology.Contributors, 4) {
+				suite.Assert().Equal(editor, anthology.Contributors[0].Name)
+				suite.Assert().Equal(first, anthology.Contributors[1].SortName)
+			}
+
+			// a book with just an author credits it as a contributor
+			resp, err = http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER,
+				bytes.NewBufferString(`{"title": "novel", "author": "`+first+`"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			novel, err := suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal([]models.Contributor{{Name: first, Role: models.RoleAuthor, SortName: first}},
+				novel.Contributors)
+
+			resp, err = http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER,
+				bytes.NewBufferString(`{"title": "invalid", "contributors": [{"name": "x", "role": "Narrator"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books?contributor=" + url.QueryEscape(first))
+			suite.Assert().NoError(err)
+			books, err := suite.getBooksFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Len(books.Books, 2)
+
+			authors := suite.getAuthors(t, server, "")
+			suite.Assert().Equal(handlers.Author{
+				Name:     first,
+				SortName: first,
+				Roles:    []models.ContributorRole{models.RoleAuthor},
+				Books:    2,
+			}, authors[first])
+			suite.Assert().Equal("Editor, "+prefix, authors[editor].SortName)
+
+			translators := suite.getAuthors(t, server, models.RoleTranslator)
+			suite.Assert().Contains(translators, translator)
+			suite.Assert().NotContains(translators, first)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestUpdateContributors() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("UpdateContributors"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			prefix := primitive.NewObjectID().Hex()
+			first, second := prefix+" first", prefix+" second"
+
+			// the contributors win over a different author
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBufferString(
+				`{"title": "invalid", "author": "`+second+`", "contributors": [{"name": "`+first+`", "role": "Author"}]}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			// changing just the author contradicts its contributor
+			resp = suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"author": "`+second+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType,
+				`[{"op": "replace", "path": "/author", "value": "`+second+`"}]`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			// without the contributors the author is credited again
+			resp = suite.patchBook(t, url, handlers.MergePatchMIMEType,
+				`{"author": "`+second+`", "contributors": null}`)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			patched, err := suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(second, patched.Author)
+			suite.Assert().Equal([]models.Contributor{{Name: second, Role: models.RoleAuthor, SortName: second}},
+				patched.Contributors)
+
+			// changing just the contributors derives the author from them
+			resp = suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"contributors": [
+				{"name": "`+first+`", "role": "Author"},
+				{"name": "`+second+`", "role": "Editor"}]}`)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			patched, err = suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(first, patched.Author)
+
+			update := *patched
+			update.Contributors = []models.Contributor{
+				{Name: second, Role: models.RoleAuthor},
+				{Name: first, Role: models.RoleAuthor},
+			}
+			jsonValue, _ := json.Marshal(update)
+			req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonValue))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			updated, err := suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(second+", "+first, updated.Author)
+		})
+	}
+}
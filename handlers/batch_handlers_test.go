@@ -0,0 +1,127 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) batch(url string, request interface{}) (*handlers.BatchReport, int) {
+	jsonValue, _ := json.Marshal(request)
+	resp, raw := suite.request(http.MethodPost, url, JSON_HTTP_HEADER, "", bytes.NewReader(jsonValue))
+
+	report := &handlers.BatchReport{}
+	if resp.StatusCode == http.StatusOK {
+		suite.Assert().NoError(json.Unmarshal(raw, report))
+	}
+
+	return report, resp.StatusCode
+}
+
+func (suite *BookHandlersTestSuite) TestBatchBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("BatchBooks"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			url := server.TS.URL + "/v1/books:"
+			publisher := primitive.NewObjectID().Hex()
+			isbn := common.CreateRandomISBN()
+
+			// an invalid book aborts an atomic batch before it is stored
+			report, status := suite.batch(url+"batchCreate", handlers.BatchCreateRequest{Books: []*models.Book{
+				{Title: "Beowulf", Publisher: publisher, ISBN: isbn},
+				{Publisher: publisher},
+			}})
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().Equal(db.BatchAtomic, report.Mode)
+			suite.Assert().True(report.Aborted)
+			suite.Assert().Equal(2, report.Failed)
+			if suite.Assert().Len(report.Results, 2) {
+				suite.Assert().Equal(http.StatusFailedDependency, report.Results[0].Status)
+				suite.Assert().Equal(http.StatusUnprocessableEntity, report.Results[1].Status)
+			}
+
+			report, status = suite.batch(url+"batchCreate", handlers.BatchCreateRequest{
+				Mode: db.BatchBestEffort,
+				Books: []*models.Book{
+					{Title: "Beowulf", Publisher: publisher, ISBN: isbn},
+					{Title: "Grendel", Publisher: publisher, ISBN: isbn},
+					{Title: "The Hobbit", Publisher: publisher},
+				},
+			})
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().False(report.Aborted)
+			suite.Assert().Equal(2, report.Succeeded)
+			if !suite.Assert().Len(report.Results, 3) {
+				return
+			}
+			suite.Assert().Equal(http.StatusCreated, report.Results[0].Status)
+			suite.Assert().Equal(http.StatusConflict, report.Results[1].Status)
+			suite.Assert().NotNil(report.Results[2].Book)
+			beowulf, hobbit := report.Results[0].ID, report.Results[2].ID
+
+			report, status = suite.batch(url+"batchUpdate", handlers.BatchUpdateRequest{Updates: []handlers.BatchUpdate{
+				{ID: beowulf, Version: 1, Patch: map[string]interface{}{"rating": 4}},
+				{ID: hobbit, Version: 2, Patch: map[string]interface{}{"rating": 5}},
+			}})
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().True(report.Aborted)
+			if suite.Assert().Len(report.Results, 2) {
+				suite.Assert().Equal(http.StatusFailedDependency, report.Results[0].Status)
+				suite.Assert().Equal(http.StatusPreconditionFailed, report.Results[1].Status)
+			}
+
+			report, status = suite.batch(url+"batchUpdate", handlers.BatchUpdateRequest{Updates: []handlers.BatchUpdate{
+				{ID: beowulf, Version: 1, Patch: map[string]interface{}{"rating": 4}},
+				{ID: hobbit, Book: &models.Book{Title: "The Hobbit, revised", Publisher: publisher}},
+			}})
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().Equal(2, report.Succeeded)
+			if suite.Assert().Len(report.Results, 2) && suite.Assert().NotNil(report.Results[0].Book) {
+				suite.Assert().Equal(4, report.Results[0].Book.Rating)
+				suite.Assert().EqualValues(2, report.Results[0].Book.Version)
+				suite.Assert().Equal("The Hobbit, revised", report.Results[1].Book.Title)
+			}
+
+			report, status = suite.batch(url+"batchUpdate", handlers.BatchUpdateRequest{
+				Mode:    db.BatchBestEffort,
+				Updates: []handlers.BatchUpdate{{ID: beowulf}},
+			})
+			suite.Assert().Equal(http.StatusOK, status)
+			if suite.Assert().Len(report.Results, 1) {
+				suite.Assert().Equal(http.StatusUnprocessableEntity, report.Results[0].Status)
+			}
+
+			report, status = suite.batch(url+"batchDelete", handlers.BatchDeleteRequest{Deletes: []handlers.BatchDelete{
+				{ID: beowulf, Version: 2},
+				{ID: hobbit, Version: 2},
+			}})
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().False(report.Aborted)
+			suite.Assert().Equal(2, report.Succeeded)
+
+			resp, err := http.Get(server.TS.URL + "/v1/books/" + beowulf)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+			_, status = suite.batch(url+"batchDelete", handlers.BatchDeleteRequest{Mode: "sometimes"})
+			suite.Assert().Equal(http.StatusBadRequest, status)
+
+			_, status = suite.batch(url+"batchCreate", handlers.BatchCreateRequest{
+				Books: make([]*models.Book, db.MaxBatchSize+1),
+			})
+			suite.Assert().Equal(http.StatusBadRequest, status)
+		})
+	}
+}
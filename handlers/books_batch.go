@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// BatchCreateRequest, BatchUpdateRequest and BatchDeleteRequest are the
+// bodies of the batch methods. Mode defaults to atomic.
+type BatchCreateRequest struct {
+	Mode  db.BatchMode   `json:"mode"`
+	Books []*models.Book `json:"books"`
+}
+
+type BatchUpdateRequest struct {
+	Mode    db.BatchMode  `json:"mode"`
+	Updates []BatchUpdate `json:"updates"`
+}
+
+type BatchDeleteRequest struct {
+	Mode    db.BatchMode  `json:"mode"`
+	Deletes []BatchDelete `json:"deletes"`
+}
+
+// BatchUpdate replaces a book with Book or changes it with the JSON merge
+// patch Patch. A Version other than 0 plays the role of If-Match.
+type BatchUpdate struct {
+	ID      string       `json:"id"`
+	Version int64        `json:"version"`
+	Book    *models.Book `json:"book,omitempty"`
+	Patch   interface{}  `json:"patch,omitempty"`
+}
+
+type BatchDelete struct {
+	ID      string `json:"id"`
+	Version int64  `json:"version"`
+}
+
+// BatchResult is the outcome of a single item, Status is the status code a
+// single request for it would have got. The items of a rolled back atomic
+// batch that didn't fail themselves have 424 Failed Dependency.
+type BatchResult struct {
+	Index  int                 `json:"index"`
+	ID     string              `json:"id,omitempty"`
+	Status int                 `json:"status"`
+	Book   *models.Book        `json:"book,omitempty"`
+	Error  string              `json:"error,omitempty"`
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// BatchReport lists the results in request order. Aborted is set if an
+// atomic batch wasn't applied.
+type BatchReport struct {
+	Mode      db.BatchMode  `json:"mode"`
+	Aborted   bool          `json:"aborted"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// BatchCreateBooks creates books like CreateBook.
+func (app *App) BatchCreateBooks(c *gin.Context) {
+	var request BatchCreateRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	items, ok := app.newBatchItems(c, request.Mode, len(request.Books))
+	if !ok {
+		return
+	}
+
+	books := make([]*models.Book, 0, len(request.Books))
+
+	for i, book := range request.Books {
+		if book == nil {
+			book = new(models.Book)
+		}
+
+		if err := book.Validate(); err != nil {
+			items.fail(i, err)
+			continue
+		}
+
+		book.Normalize()
+		items.accept(i)
+		books = append(books, book)
+	}
+
+	if items.rejected() {
+		app.render(c, http.StatusOK, items.report())
+		return
+	}
+
+	errs, err := app.BookRepository.AddBooks(c.Request.Context(), books, items.mode)
+	if err != nil && !errors.Is(err, db.ErrBatchAborted) {
+		app.abortWithError(c, err)
+		return
+	}
+
+	items.apply(errs, func(j int, result *BatchResult) {
+		result.ID = books[j].ID.Hex()
+		result.Status = http.StatusCreated
+		result.Book = books[j]
+	})
+
+	app.render(c, http.StatusOK, items.report())
+}
+
+// BatchUpdateBooks replaces or patches books like UpdateBook and PatchBook.
+func (app *App) BatchUpdateBooks(c *gin.Context) {
+	var request BatchUpdateRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	items, ok := app.newBatchItems(c, request.Mode, len(request.Updates))
+	if !ok {
+		return
+	}
+
+	changes := make([]db.BookChange, 0, len(request.Updates))
+
+	for i, update := range request.Updates {
+		items.results[i].ID = update.ID
+
+		change, err := bookChange(update)
+		if err != nil {
+			items.fail(i, err)
+			continue
+		}
+
+		items.accept(i)
+		changes = append(changes, change)
+	}
+
+	if items.rejected() {
+		app.render(c, http.StatusOK, items.report())
+		return
+	}
+
+	books, errs, err := app.BookRepository.UpdateBooks(c.Request.Context(), changes, items.mode)
+	if err != nil && !errors.Is(err, db.ErrBatchAborted) {
+		app.abortWithError(c, err)
+		return
+	}
+
+	items.apply(errs, func(j int, result *BatchResult) {
+		result.Status = http.StatusOK
+		result.Book = books[j]
+	})
+
+	app.render(c, http.StatusOK, items.report())
+}
+
+// bookChange checks an update before it is applied.
+func bookChange(update BatchUpdate) (db.BookChange, error) {
+	var apply func(book *models.Book) (*models.Book, error)
+
+	switch {
+	case update.Book != nil && update.Patch == nil:
+		// the repository keeps the ID, version and tenant of the stored
+		// book
+		apply = func(book *models.Book) (*models.Book, error) {
+			return replaceBook(book, update.Book)
+		}
+	case update.Patch != nil && update.Book == nil:
+		apply = func(book *models.Book) (*models.Book, error) {
+			return patchBook(book, func(doc interface{}) (interface{}, error) {
+				return mergePatch(doc, update.Patch), nil
+			})
+		}
+	default:
+		return db.BookChange{}, &models.ValidationError{Fields: []models.FieldError{{
+			Field:   "book",
+			Rule:    "required_without",
+			Message: "either book or patch is required",
+		}}}
+	}
+
+	return db.BookChange{
+		ID: db.ID(update.ID),
+		Update: func(book *models.Book) (*models.Book, error) {
+			if update.Version != 0 && book.Version != update.Version {
+				return nil, db.ErrVersionMismatch
+			}
+
+			return apply(book)
+		},
+	}, nil
+}
+
+// BatchDeleteBooks deletes books like DeleteBook, books that still have
+// copies are refused.
+func (app *App) BatchDeleteBooks(c *gin.Context) {
+	var request BatchDeleteRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	items, ok := app.newBatchItems(c, request.Mode, len(request.Deletes))
+	if !ok {
+		return
+	}
+
+	deletions := make([]db.BookDeletion, 0, len(request.Deletes))
+
+	for i, deletion := range request.Deletes {
+		items.results[i].ID = deletion.ID
+
+		copies, err := app.CopyRepository.BookCopies(c.Request.Context(), db.ID(deletion.ID))
+		if err == nil && len(copies) > 0 {
+			err = errBookHasCopies
+		}
+
+		if err != nil {
+			items.fail(i, err)
+			continue
+		}
+
+		items.accept(i)
+		deletions = append(deletions, db.BookDeletion{ID: db.ID(deletion.ID), Version: deletion.Version})
+	}
+
+	if items.rejected() {
+		app.render(c, http.StatusOK, items.report())
+		return
+	}
+
+	errs, err := app.BookRepository.DeleteBooks(c.Request.Context(), deletions, items.mode)
+	if err != nil && !errors.Is(err, db.ErrBatchAborted) {
+		app.abortWithError(c, err)
+		return
+	}
+
+	items.apply(errs, func(j int, result *BatchResult) {
+		result.Status = http.StatusNoContent
+	})
+
+	app.render(c, http.StatusOK, items.report())
+}
+
+// batchItems collects the results of a batch request. Items failing the
+// checks of the handler never reach the repository, the others are
+// pending.
+type batchItems struct {
+	app     *App
+	c       *gin.Context
+	mode    db.BatchMode
+	results []BatchResult
+	pending []int
+}
+
+// newBatchItems responds with 400 Bad Request if the mode or the size of
+// the batch is invalid.
+func (app *App) newBatchItems(c *gin.Context, mode db.BatchMode, n int) (*batchItems, bool) {
+	if mode == "" {
+		mode = db.BatchAtomic
+	}
+
+	if !mode.Valid() {
+		app.problem(c, http.StatusBadRequest,
+			fmt.Sprintf("mode must be %s or %s", db.BatchAtomic, db.BatchBestEffort))
+
+		return nil, false
+	}
+
+	if n > db.MaxBatchSize {
+		app.problem(c, http.StatusBadRequest, fmt.Sprintf("a batch has at most %d items", db.MaxBatchSize))
+		return nil, false
+	}
+
+	results := make([]BatchResult, n)
+	for i := range results {
+		results[i].Index = i
+	}
+
+	return &batchItems{app: app, c: c, mode: mode, results: results, pending: make([]int, 0, n)}, true
+}
+
+func (items *batchItems) accept(i int) {
+	items.pending = append(items.pending, i)
+}
+
+// fail records the error of item i the way abortWithError would report it.
+func (items *batchItems) fail(i int, err error) {
+	result := &items.results[i]
+
+	var validationErr *models.ValidationError
+
+	switch {
+	case errors.As(err, &validationErr):
+		result.Status = http.StatusUnprocessableEntity
+		result.Errors = validationErr.Fields
+	case errors.Is(err, db.ErrBatchAborted):
+		result.Status = http.StatusFailedDependency
+	default:
+		result.Status = errorStatus(err)
+	}
+
+	if result.Status == http.StatusInternalServerError {
+		items.app.Logger.Error(err, "batch item failed", "path", items.c.Request.URL.Path, "index", i)
+		result.Error = http.StatusText(http.StatusInternalServerError)
+
+		return
+	}
+
+	result.Error = err.Error()
+}
+
+// rejected reports whether an atomic batch failed the checks of the
+// handler, its pending items are aborted then.
+func (items *batchItems) rejected() bool {
+	if items.mode != db.BatchAtomic || len(items.pending) == len(items.results) {
+		return false
+	}
+
+	for _, i := range items.pending {
+		items.fail(i, db.ErrBatchAborted)
+	}
+
+	return true
+}
+
+// apply records the errors the repository returned for the pending items,
+// succeed fills in the result of the j-th pending item.
+func (items *batchItems) apply(errs []error, succeed func(j int, result *BatchResult)) {
+	for j, i := range items.pending {
+		if errs[j] != nil {
+			items.fail(i, errs[j])
+			continue
+		}
+
+		succeed(j, &items.results[i])
+	}
+}
+
+func (items *batchItems) report() *BatchReport {
+	report := &BatchReport{Mode: items.mode, Results: items.results}
+
+	for _, result := range items.results {
+		if result.Error != "" {
+			report.Failed++
+		} else {
+			report.Succeeded++
+		}
+	}
+
+	report.Aborted = items.mode == db.BatchAtomic && report.Failed > 0
+
+	return report
+}
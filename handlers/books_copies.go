@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// errCopyStatusChanged rejects edits of copies that lend or return them,
+// which only checkouts and checkins do.
+var errCopyStatusChanged = &models.ValidationError{Fields: []models.FieldError{
+	{Field: "status", Rule: "readonly", Message: "can only be changed by checking the copy out or in"},
+}}
+
+type CopyList struct {
+	Copies       []*models.Copy      `json:"copies"`
+	Availability models.Availability `json:"availability"`
+}
+
+// ListBookCopies lists the copies of a book ordered by barcode.
+func (app *App) ListBookCopies(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.BookRepository.GetBook(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	copies, err := app.CopyRepository.BookCopies(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, CopyList{Copies: copies, Availability: models.CountCopies(copies)})
+}
+
+// AddCopy adds a copy to a book. Copies start out available unless the
+// request says otherwise, they can't start out checked out.
+func (app *App) AddCopy(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+	item := new(models.Copy)
+
+	if err := bind(c, item); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	item.Normalize()
+
+	if err := item.Validate(); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if item.Status == models.CopyCheckedOut {
+		app.abortWithError(c, errCopyStatusChanged)
+		return
+	}
+
+	book, err := app.BookRepository.GetBook(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	item.BookID = book.ID
+
+	if _, err := app.CopyRepository.AddCopy(c.Request.Context(), item); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.touchBook(c.Request.Context(), id)
+	app.promoteHold(c.Request.Context(), id, time.Now().UTC())
+
+	c.Header("ETag", etag(item.Version))
+	app.render(c, http.StatusCreated, item)
+}
+
+func (app *App) GetCopy(c *gin.Context) {
+	item, err := app.CopyRepository.GetCopy(c.Request.Context(), db.ID(c.Param("id")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.renderCopy(c, item)
+}
+
+func (app *App) GetCopyByBarcode(c *gin.Context) {
+	item, err := app.CopyRepository.GetCopyByBarcode(c.Request.Context(), c.Param("barcode"))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.renderCopy(c, item)
+}
+
+func (app *App) renderCopy(c *gin.Context, item *models.Copy) {
+	c.Header("ETag", etag(item.Version))
+
+	if ifNoneMatch(c, item.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	app.render(c, http.StatusOK, item)
+}
+
+// UpdateCopy replaces a copy. A copy stays with the book it was added to.
+// The status may be left out to keep it, a copy is only lent and returned
+// by checkouts and checkins.
+func (app *App) UpdateCopy(c *gin.Context) {
+	update := new(models.Copy)
+
+	if err := bind(c, update); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	item, err := app.CopyRepository.UpdateCopy(c.Request.Context(), db.ID(c.Param("id")),
+		func(oldCopy *models.Copy) (*models.Copy, error) {
+			if !ifMatch(c, oldCopy.Version) {
+				return nil, db.ErrVersionMismatch
+			}
+
+			replacement := *update
+			if replacement.Status == "" {
+				replacement.Status = oldCopy.Status
+			}
+
+			if (replacement.Status == models.CopyCheckedOut) != (oldCopy.Status == models.CopyCheckedOut) {
+				return nil, errCopyStatusChanged
+			}
+
+			replacement.Normalize()
+
+			return &replacement, replacement.Validate()
+		})
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	bookID := db.ID(item.BookID.Hex())
+	app.touchBook(c.Request.Context(), bookID)
+	app.promoteHold(c.Request.Context(), bookID, time.Now().UTC())
+
+	c.Header("ETag", etag(item.Version))
+	app.render(c, http.StatusOK, item)
+}
+
+func (app *App) DeleteCopy(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	item, err := app.CopyRepository.GetCopy(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if !ifMatch(c, item.Version) {
+		app.abortWithError(c, db.ErrVersionMismatch)
+		return
+	}
+
+	// a lent copy is deleted once it's back
+	if item.Status == models.CopyCheckedOut {
+		app.abortWithError(c, models.ErrCopyCheckedOut)
+		return
+	}
+
+	if err := app.CopyRepository.DeleteCopy(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.touchBook(c.Request.Context(), db.ID(item.BookID.Hex()))
+
+	c.Status(http.StatusNoContent)
+}
+
+// touchBook bumps the version of a book after its copies changed, so that
+// the ETag of the book, which covers its availability, changes too.
+// Failures are only logged, the copy itself has been changed already.
+func (app *App) touchBook(ctx context.Context, id db.ID) {
+	for attempt := 1; attempt <= maxUpdateAttempts; attempt++ {
+		_, err := app.BookRepository.UpdateBook(ctx, id, func(book *models.Book) (*models.Book, error) {
+			return book, nil
+		})
+		if err == nil {
+			return
+		}
+
+		if attempt == maxUpdateAttempts || !errors.Is(err, db.ErrVersionMismatch) {
+			app.Logger.Error(err, "can't update the version of a book", "book", id)
+			return
+		}
+	}
+}
@@ -5,33 +5,30 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/iho/booksdb/models"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func (app *App) CreateBook(c *gin.Context) {
 	book := new(models.Book)
 
-	err := c.BindJSON(&book)
+	err := bind(c, &book)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-
+		app.bindError(c, err)
 		return
 	}
 
-	id, err := app.BookRepository.AddBook(c.Request.Context(), book)
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-
+	if err := book.Validate(); err != nil {
+		app.abortWithError(c, err)
 		return
 	}
 
-	bookID, err := primitive.ObjectIDFromHex(string(id))
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+	book.Normalize()
 
+	_, err = app.BookRepository.AddBook(c.Request.Context(), book)
+	if err != nil {
+		app.abortWithError(c, err)
 		return
 	}
 
-	book.ID = bookID
-	c.IndentedJSON(http.StatusCreated, book)
+	c.Header("ETag", etag(book.Version))
+	app.render(c, http.StatusCreated, book)
 }
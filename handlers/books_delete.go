@@ -1,20 +1,56 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/iho/booksdb/db"
 )
 
+var errBookHasCopies = errors.New("book still has copies")
+
+// DeleteBook refuses to delete books that still have copies, they have to
+// be deleted first.
 func (app *App) DeleteBook(c *gin.Context) {
 	id := c.Param("id")
 
-	err := app.BookRepository.DeleteBook(c.Request.Context(), db.ID(id))
+	copies, err := app.CopyRepository.BookCopies(c.Request.Context(), db.ID(id))
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		app.abortWithError(c, err)
 		return
 	}
 
-	c.IndentedJSON(http.StatusNoContent, gin.H{"message": "book successfully removed"})
+	if len(copies) > 0 {
+		app.abortWithError(c, errBookHasCopies)
+		return
+	}
+
+	if c.GetHeader("If-Match") == "" {
+		err = app.BookRepository.DeleteBook(c.Request.Context(), db.ID(id))
+	} else {
+		err = app.deleteBookIfMatch(c, db.ID(id))
+	}
+
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// deleteBookIfMatch checks If-Match against the current version and deletes
+// exactly that version, so a concurrent update makes the delete fail.
+func (app *App) deleteBookIfMatch(c *gin.Context, id db.ID) error {
+	book, err := app.BookRepository.GetBook(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatch(c, book.Version) {
+		return db.ErrVersionMismatch
+	}
+
+	return app.BookRepository.DeleteBookVersion(c.Request.Context(), id, book.Version)
 }
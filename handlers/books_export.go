@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/bookio"
+)
+
+// ExportBooks streams every book matching the filters and sorting of
+// ListBooks as ?format=csv (the default), ndjson or xlsx. Books are written
+// as they are read from the repository, none of them is held back. The
+// BookCountTrailer tells a complete export from one that was cut short.
+func (app *App) ExportBooks(c *gin.Context) {
+	app.streamDeadline(c)
+
+	format, err := bookio.ParseFormat(c.DefaultQuery("format", string(bookio.FormatCSV)))
+	if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query, err := parseBookQuery(c)
+	if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+
+	iterator, err := app.BookRepository.IterateBooks(ctx, query.Filter, query.Sort)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+	defer iterator.Close(ctx)
+
+	c.Header("Content-Type", format.MIMEType())
+	c.Header("Content-Disposition", `attachment; filename="books.`+string(format)+`"`)
+	c.Header("Trailer", BookCountTrailer)
+	c.Status(http.StatusOK)
+
+	// the status is sent with the first bytes, from here on a failure can
+	// only cut the export short
+	writer, err := bookio.NewWriter(c.Writer, format)
+	if err != nil {
+		app.Logger.Error(err, "can't export books")
+		return
+	}
+
+	count := 0
+
+	for iterator.Next(ctx) {
+		if err := writer.Write(iterator.Book()); err != nil {
+			app.Logger.Error(err, "can't export books")
+			return
+		}
+
+		count++
+	}
+
+	if err := iterator.Err(); err != nil {
+		// the writer isn't closed, so that an XLSX file is left unreadable
+		// rather than silently short
+		app.Logger.Error(err, "can't export books")
+		return
+	}
+
+	if err := writer.Close(); err != nil {
+		app.Logger.Error(err, "can't export books")
+		return
+	}
+
+	c.Writer.Header().Set(BookCountTrailer, strconv.Itoa(count))
+}
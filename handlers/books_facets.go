@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+)
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets counts books by genre, tag, publisher and status, the most common
+// values first.
+type Facets struct {
+	Genres     []FacetCount `json:"genres"`
+	Tags       []FacetCount `json:"tags"`
+	Publishers []FacetCount `json:"publishers"`
+	Statuses   []FacetCount `json:"statuses"`
+}
+
+// BookFacets counts the books matching the filters of ListBooks by facet.
+// Sorting and pagination parameters are ignored.
+func (app *App) BookFacets(c *gin.Context) {
+	query, err := parseBookQuery(c)
+	if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	facets, err := app.BookRepository.Facets(c.Request.Context(), query.Filter)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, Facets{
+		Genres:     facetCounts(facets.Genres),
+		Tags:       facetCounts(facets.Tags),
+		Publishers: facetCounts(facets.Publishers),
+		Statuses:   facetCounts(facets.Statuses),
+	})
+}
+
+func facetCounts(counts []db.FacetCount) []FacetCount {
+	result := make([]FacetCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, FacetCount{Value: count.Value, Count: count.Count})
+	}
+
+	return result
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
 )
 
 func (app *App) GetBook(c *gin.Context) {
@@ -12,9 +13,51 @@ func (app *App) GetBook(c *gin.Context) {
 
 	book, err := app.BookRepository.GetBook(c.Request.Context(), db.ID(id))
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		app.abortWithError(c, err)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, book)
+	app.renderBook(c, book)
+}
+
+// GetBookByISBN accepts either ISBN form, with or without hyphens.
+func (app *App) GetBookByISBN(c *gin.Context) {
+	isbn, err := models.NormalizeISBN(c.Param("isbn"))
+	if err != nil {
+		app.problem(c, http.StatusUnprocessableEntity, c.Param("isbn")+" is not a valid ISBN-10 or ISBN-13")
+		return
+	}
+
+	book, err := app.BookRepository.GetBookByISBN(c.Request.Context(), isbn)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.renderBook(c, book)
+}
+
+// BookDetails is a book together with the availability of its copies.
+type BookDetails struct {
+	*models.Book
+	Availability models.Availability `json:"availability"`
+}
+
+// renderBook responds with the book and its availability. The ETag stays the
+// book version, every change to a copy bumps it.
+func (app *App) renderBook(c *gin.Context, book *models.Book) {
+	c.Header("ETag", etag(book.Version))
+
+	if ifNoneMatch(c, book.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	copies, err := app.CopyRepository.BookCopies(c.Request.Context(), db.ID(book.ID.Hex()))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, BookDetails{Book: book, Availability: models.CountCopies(copies)})
 }
@@ -2,16 +2,23 @@ package handlers_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/go-logr/zapr"
 	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
 	"github.com/iho/booksdb/handlers"
 	"github.com/iho/booksdb/models"
 	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.uber.org/zap"
 )
 
@@ -21,6 +28,7 @@ const (
 
 type TestServer struct {
 	TS   *httptest.Server
+	App  *handlers.App
 	Name string
 }
 
@@ -38,10 +46,11 @@ func (suite *BookHandlersTestSuite) SetupSuite() {
 		zapLog, _ := zap.NewDevelopment() // ToDo: change that because don't feel right
 		log := zapr.NewLogger(zapLog)
 
-		app := handlers.NewApp(repo.Repo, log)
+		app := handlers.NewApp(repo.Repositories, log)
 
 		suite.Servers = append(suite.Servers, TestServer{
 			TS:   httptest.NewServer(handlers.SetupRouter(app)),
+			App:  app,
 			Name: repo.Name,
 		})
 	}
@@ -55,10 +64,10 @@ func (suite *BookHandlersTestSuite) getBookFromResponse(resp *http.Response) (*m
 	return book, json.NewDecoder(resp.Body).Decode(book)
 }
 
-func (suite *BookHandlersTestSuite) getBooksFromResponse(resp *http.Response) ([]*models.Book, error) {
-	books := make([]*models.Book, 0)
+func (suite *BookHandlersTestSuite) getBooksFromResponse(resp *http.Response) (*handlers.BookList, error) {
+	books := new(handlers.BookList)
 	defer resp.Body.Close()
-	return books, json.NewDecoder(resp.Body).Decode(&books)
+	return books, json.NewDecoder(resp.Body).Decode(books)
 }
 
 func (suite *BookHandlersTestSuite) TestAddBook() {
@@ -108,6 +117,39 @@ func (suite *BookHandlersTestSuite) TestAddBookFailed() {
 	}
 }
 
+func (suite *BookHandlersTestSuite) TestAddBookInvalid() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("AddBookInvalid"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			buf := bytes.NewBufferString(`{"title": "", "rating": -1, "status": "Lost"}`)
+
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusUnprocessableEntity)
+
+			problem := new(handlers.Problem)
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(problem))
+
+			rules := make(map[string]string)
+			for _, field := range problem.Errors {
+				rules[field.Field] = field.Rule
+			}
+			suite.Assert().Equal(map[string]string{
+				"title":  "required",
+				"rating": "min",
+				"status": "oneof",
+			}, rules)
+		})
+	}
+}
+
 func (suite *BookHandlersTestSuite) TestDeleteBook() {
 	t := suite.T()
 	t.Parallel()
@@ -218,7 +260,19 @@ func (suite *BookHandlersTestSuite) TestGetBookFailed() {
 			t.Parallel()
 			resp, err := http.Get(server.TS.URL + "/v1/books/wrong_id")
 			suite.Assert().NoError(err)
-			suite.Assert().NotEqual(resp.StatusCode, http.StatusOK)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusUnprocessableEntity)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/" + primitive.NewObjectID().Hex())
+			suite.Assert().NoError(err)
+			defer resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusNotFound)
+			suite.Assert().Equal(handlers.ProblemMIMEType, resp.Header.Get("Content-Type"))
+
+			problem := new(handlers.Problem)
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(problem))
+			suite.Assert().Equal(http.StatusNotFound, problem.Status)
+			suite.Assert().NotEmpty(problem.Detail)
 		})
 	}
 }
@@ -254,7 +308,68 @@ func (suite *BookHandlersTestSuite) TestListBooks() {
 
 			books, err := suite.getBooksFromResponse(resp)
 			suite.Assert().NoError(err)
-			suite.Assert().NotEmpty(books)
+			suite.Assert().NotEmpty(books.Books)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestListBooksFiltered() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("ListBooksFiltered"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			for i := 0; i < 3; i++ {
+				book := common.CreateRandomBook()
+				book.Publisher = publisher
+				jsonValue, _ := json.Marshal(book)
+
+				resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+				suite.Assert().Equal(resp.StatusCode, http.StatusCreated)
+			}
+
+			resp, err := http.Get(server.TS.URL + "/v1/books?sort=-rating,title&limit=2&publisher=" + publisher)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+			books, err := suite.getBooksFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Len(books.Books, 2)
+			suite.Assert().GreaterOrEqual(books.Books[0].Rating, books.Books[1].Rating)
+			suite.Assert().NotEmpty(books.NextCursor)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books?sort=-rating,title&limit=2&publisher=" + publisher +
+				"&cursor=" + books.NextCursor)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+			books, err = suite.getBooksFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Len(books.Books, 1)
+			suite.Assert().Empty(books.NextCursor)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestListBooksBadQuery() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("ListBooksBadQuery"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := http.Get(server.TS.URL + "/v1/books?sort=isbn")
+			suite.Assert().NoError(err)
+			defer resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusBadRequest)
 		})
 	}
 }
@@ -307,6 +422,594 @@ func (suite *BookHandlersTestSuite) TestPutBook() {
 	}
 }
 
+func (suite *BookHandlersTestSuite) createBook(t *testing.T, server TestServer) *models.Book {
+	t.Helper()
+
+	jsonValue, _ := json.Marshal(common.CreateRandomBook())
+
+	resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Assert().Equal(resp.StatusCode, http.StatusCreated)
+
+	book, err := suite.getBookFromResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+func (suite *BookHandlersTestSuite) TestGetBookByISBN() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("GetBookByISBN"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			isbn13 := common.CreateRandomISBN()
+			isbn10, err := models.ISBN13To10(isbn13)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			book := common.CreateRandomBook()
+			book.ISBN = isbn10[:1] + "-" + isbn10[1:4] + "-" + isbn10[4:9] + "-" + isbn10[9:]
+			jsonValue, _ := json.Marshal(book)
+
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			created, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(isbn13, created.ISBN)
+
+			for _, lookup := range []string{isbn13, isbn10, book.ISBN} {
+				resp, err = http.Get(server.TS.URL + "/v1/books/isbn/" + lookup)
+				suite.Assert().NoError(err)
+				suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+				found, err := suite.getBookFromResponse(resp)
+				suite.Assert().NoError(err)
+				suite.Assert().Equal(created.ID, found.ID)
+			}
+
+			resp, err = http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/isbn/0000000001")
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			book.ISBN = isbn13[:12] + string('0'+(isbn13[12]-'0'+1)%10)
+			jsonValue, _ = json.Marshal(book)
+			resp, err = http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestSearchBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("SearchBooks"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			word := "w" + primitive.NewObjectID().Hex()
+
+			byAuthor := common.CreateRandomBook()
+			byAuthor.Author = word
+			byTitle := common.CreateRandomBook()
+			byTitle.Title = word
+
+			for _, book := range []*models.Book{byAuthor, byTitle} {
+				jsonValue, _ := json.Marshal(book)
+				resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+				suite.Assert().NoError(err)
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			}
+
+			resp, err := http.Get(server.TS.URL + "/v1/books/search?q=" + word)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+			results := &handlers.SearchResults{}
+			err = json.NewDecoder(resp.Body).Decode(results)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(results.Results, 2) {
+				suite.Assert().Equal(word, results.Results[0].Book.Title)
+				suite.Assert().Equal(word, results.Results[1].Book.Author)
+			}
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/search")
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) checkout(t *testing.T, server TestServer, id, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/books/"+id+"/checkout", JSON_HTTP_HEADER, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func (suite *BookHandlersTestSuite) TestCheckoutCheckin() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("CheckoutCheckin"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			if book.Status == models.CheckedOut {
+				resp, err := http.Post(server.TS.URL+"/v1/books/"+book.ID.Hex()+"/checkin", JSON_HTTP_HEADER, nil)
+				suite.Assert().NoError(err)
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusNoContent, resp.StatusCode)
+			}
+
+			member := suite.createMember(t, server)
+			resp := suite.checkout(t, server, book.ID.Hex(), `{"member_id": "`+member.ID.Hex()+`"}`)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			loan := &models.Loan{}
+			err := json.NewDecoder(resp.Body).Decode(loan)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(book.ID, loan.BookID)
+			suite.Assert().Equal(member.ID, loan.MemberID)
+			suite.Assert().Equal(1, suite.getMember(t, server, member.ID.Hex()).Loans)
+			suite.Assert().WithinDuration(loan.CheckedOutAt.Add(models.DefaultLoanPeriod), loan.DueAt, time.Second)
+
+			other := suite.createMember(t, server)
+			resp = suite.checkout(t, server, book.ID.Hex(), `{"member_id": "`+other.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/" + book.ID.Hex())
+			suite.Assert().NoError(err)
+			checkedOut, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(models.CheckedOut, checkedOut.Status)
+
+			resp, err = http.Post(server.TS.URL+"/v1/books/"+book.ID.Hex()+"/checkin", JSON_HTTP_HEADER, nil)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			returned := &models.Loan{}
+			err = json.NewDecoder(resp.Body).Decode(returned)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(loan.ID, returned.ID)
+			suite.Assert().NotNil(returned.ReturnedAt)
+			suite.Assert().Equal(0, suite.getMember(t, server, member.ID.Hex()).Loans)
+			suite.Assert().Equal(0, suite.getMember(t, server, other.ID.Hex()).Loans)
+
+			resp, err = http.Post(server.TS.URL+"/v1/books/"+book.ID.Hex()+"/checkin", JSON_HTTP_HEADER, nil)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/" + book.ID.Hex() + "/loans")
+			suite.Assert().NoError(err)
+			loans := &handlers.LoanList{}
+			err = json.NewDecoder(resp.Body).Decode(loans)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Len(loans.Loans, 1)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestCheckoutInvalid() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("CheckoutInvalid"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+
+			resp := suite.checkout(t, server, book.ID.Hex(), `{}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			member := suite.createMember(t, server)
+			resp = suite.checkout(t, server, book.ID.Hex(), `{"member_id": "`+member.ID.Hex()+`", "due_at": "2000-01-01T00:00:00Z"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.checkout(t, server, book.ID.Hex(), `{"member_id": "`+primitive.NewObjectID().Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.checkout(t, server, primitive.NewObjectID().Hex(), `{"member_id": "`+member.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+			suite.Assert().Equal(0, suite.getMember(t, server, member.ID.Hex()).Loans)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestCheckoutConcurrent() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("CheckoutConcurrent"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := common.CreateRandomBook()
+			book.Status = models.CheckedIn
+			jsonValue, _ := json.Marshal(book)
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+			suite.Assert().NoError(err)
+			created, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+
+			const borrowers = 8
+			statuses := make(chan int, borrowers)
+
+			members := make([]*models.Member, borrowers)
+			for i := range members {
+				members[i] = suite.createMember(t, server)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < borrowers; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					resp := suite.checkout(t, server, created.ID.Hex(), fmt.Sprintf(`{"member_id": "%s"}`, members[i].ID.Hex()))
+					resp.Body.Close()
+					statuses <- resp.StatusCode
+				}(i)
+			}
+			wg.Wait()
+			close(statuses)
+
+			counts := make(map[int]int)
+			for status := range statuses {
+				counts[status]++
+			}
+			suite.Assert().Equal(1, counts[http.StatusCreated])
+			suite.Assert().Equal(borrowers-1, counts[http.StatusConflict])
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestGetBookETag() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("GetBookETag"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+
+			resp, err := http.Get(server.TS.URL + "/v1/books/" + book.ID.Hex())
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+			suite.Assert().Equal(`"1"`, resp.Header.Get("ETag"))
+
+			req, err := http.NewRequest("GET", server.TS.URL+"/v1/books/"+book.ID.Hex(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-None-Match", `"1"`)
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusNotModified)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestPutBookIfMatch() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("PutBookIfMatch"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			put := func(etag string) *http.Response {
+				jsonValue, _ := json.Marshal(book)
+				req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonValue))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("If-Match", etag)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				return resp
+			}
+
+			resp := put(`"1"`)
+			suite.Assert().Equal(resp.StatusCode, http.StatusCreated)
+			suite.Assert().Equal(`"2"`, resp.Header.Get("ETag"))
+
+			// the second librarian still holds version 1
+			resp = put(`"1"`)
+			suite.Assert().Equal(resp.StatusCode, http.StatusPreconditionFailed)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestUpdateBookStatus() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("UpdateBookStatus"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+			other := models.CheckedOut
+			if book.Status == models.CheckedOut {
+				other = models.CheckedIn
+			}
+
+			put := func(update models.Book) *http.Response {
+				jsonValue, _ := json.Marshal(update)
+				req, err := http.NewRequest("PUT", url, bytes.NewBuffer(jsonValue))
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return resp
+			}
+
+			// only checkouts and checkins change the status
+			update := *book
+			update.Status = other
+			resp := put(update)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"status": "`+string(other)+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType,
+				`[{"op": "replace", "path": "/status", "value": "`+string(other)+`"}]`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			// leaving it out keeps the stored one
+			update.Status = ""
+			update.Title = "new title"
+			resp = put(update)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			updated, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(book.Status, updated.Status)
+
+			resp = suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"status": null, "title": "patched"}`)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			patched, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(book.Status, patched.Status)
+			suite.Assert().Equal("patched", patched.Title)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestDeleteBookIfMatch() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("DeleteBookIfMatch"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			del := func(etag string) *http.Response {
+				req, err := http.NewRequest("DELETE", url, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("If-Match", etag)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+
+				return resp
+			}
+
+			suite.Assert().Equal(del(`"7"`).StatusCode, http.StatusPreconditionFailed)
+			suite.Assert().Equal(del(`"1"`).StatusCode, http.StatusNoContent)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) patchBook(t *testing.T, url, contentType, patch string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest("PATCH", url, bytes.NewBufferString(patch))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func (suite *BookHandlersTestSuite) TestPatchBookMergePatch() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("PatchBookMergePatch"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			resp := suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"title": "patched", "publisher": null}`)
+			suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+			patched, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal("patched", patched.Title)
+			suite.Assert().Empty(patched.Publisher)
+			suite.Assert().Equal(book.Author, patched.Author)
+			suite.Assert().Equal(book.Rating, patched.Rating)
+			suite.Assert().Equal(book.Version+1, patched.Version)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestBodyTooLarge() {
+	t := suite.T()
+	t.Parallel()
+
+	// a title longer than the limit of 8 MiB
+	title := strings.Repeat("a", 8<<20)
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("BodyTooLarge"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			resp := suite.patchBook(t, url, handlers.MergePatchMIMEType, `{"title": "`+title+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+			resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER,
+				strings.NewReader(`{"title": "`+title+`"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusRequestEntityTooLarge, resp.StatusCode)
+
+			resp, err = http.Get(url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stored, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(book.Title, stored.Title)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestPatchBookJSONPatch() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("PatchBookJSONPatch"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			resp := suite.patchBook(t, url, handlers.JSONPatchMIMEType, `[
+				{"op": "test", "path": "/title", "value": "`+book.Title+`"},
+				{"op": "replace", "path": "/rating", "value": 3},
+				{"op": "copy", "from": "/author", "path": "/publisher"}
+			]`)
+			suite.Assert().Equal(resp.StatusCode, http.StatusOK)
+
+			patched, err := suite.getBookFromResponse(resp)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(book.Title, patched.Title)
+			suite.Assert().Equal(3, patched.Rating)
+			suite.Assert().Equal(book.Author, patched.Publisher)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType,
+				`[{"op": "test", "path": "/title", "value": "something else"}]`)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusConflict)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType, `[{"op": "remove", "path": "/nope"}]`)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusUnprocessableEntity)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType, `{"op": "remove"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusBadRequest)
+
+			resp = suite.patchBook(t, url, handlers.JSONPatchMIMEType, `[{"op": "replace", "path": "/rating", "value": 9}]`)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusUnprocessableEntity)
+
+			resp = suite.patchBook(t, url, JSON_HTTP_HEADER, `{"title": "plain json"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(resp.StatusCode, http.StatusUnsupportedMediaType)
+		})
+	}
+}
+
+// unavailableRepository fails every call the way a repository does when
+// the database is down.
+type unavailableRepository struct {
+	db.BookRepository
+}
+
+func (unavailableRepository) GetBook(ctx context.Context, id db.ID) (*models.Book, error) {
+	return nil, fmt.Errorf("some database error has occurred: %w", db.ErrUnavailable)
+}
+
+func (suite *BookHandlersTestSuite) TestGetBookUnavailable() {
+	t := suite.T()
+	t.Parallel()
+
+	app := handlers.NewApp(db.Repositories{Books: unavailableRepository{}}, zapr.NewLogger(zap.NewNop()))
+	server := httptest.NewServer(handlers.SetupRouter(app))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/v1/books/" + primitive.NewObjectID().Hex())
+	suite.Assert().NoError(err)
+	defer resp.Body.Close()
+	suite.Assert().Equal(resp.StatusCode, http.StatusServiceUnavailable)
+	suite.Assert().Equal(handlers.ProblemMIMEType, resp.Header.Get("Content-Type"))
+}
+
 func (suite *BookHandlersTestSuite) TeardDownTest() {
 	suite.Suite.TeardDown()
 }
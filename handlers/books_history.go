@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// ActorHeader names whoever makes a request while authentication is
+// disabled, AnonymousActor stands in if it is missing. Otherwise the
+// authenticated principal is the actor.
+const (
+	ActorHeader    = "X-Actor"
+	AnonymousActor = "anonymous"
+)
+
+var errNoHistory = fmt.Errorf("book history %w", db.ErrNotFound)
+
+type RevisionList struct {
+	Revisions []*models.Revision `json:"revisions"`
+}
+
+type RevertRequest struct {
+	Version int64 `json:"version"`
+}
+
+// ListBookHistory lists the revisions of a book, the latest first. The
+// history outlives the book, it stays available after a purge.
+func (app *App) ListBookHistory(c *gin.Context) {
+	revisions, err := app.HistoryRepository.BookRevisions(c.Request.Context(), db.ID(c.Param("id")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if len(revisions) == 0 {
+		app.abortWithError(c, errNoHistory)
+		return
+	}
+
+	app.render(c, http.StatusOK, RevisionList{Revisions: revisions})
+}
+
+// RevertBook puts a book back into the state of one of its revisions. The
+// revert is an update of its own, the revisions in between are kept.
+func (app *App) RevertBook(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	var request RevertRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	if request.Version <= 0 {
+		app.abortWithError(c, &models.ValidationError{Fields: []models.FieldError{
+			{Field: "version", Rule: "min", Message: "must be a version of the book"},
+		}})
+
+		return
+	}
+
+	revision, err := app.HistoryRepository.BookRevision(c.Request.Context(), id, request.Version)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	ctx := db.WithRevert(c.Request.Context(), request.Version)
+
+	book, err := app.BookRepository.UpdateBook(ctx, id, func(oldBook *models.Book) (*models.Book, error) {
+		if !ifMatch(c, oldBook.Version) {
+			return nil, db.ErrVersionMismatch
+		}
+
+		// a copy, the update can run more than once
+		replacement := *revision.Book
+
+		return &replacement, nil
+	})
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(book.Version))
+	app.render(c, http.StatusOK, book)
+}
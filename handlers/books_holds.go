@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var errHoldNotFound = fmt.Errorf("hold %w", db.ErrNotFound)
+
+type HoldList struct {
+	Holds []*models.Hold `json:"holds"`
+}
+
+type HoldRequest struct {
+	MemberID string `json:"member_id"`
+}
+
+// PlaceHold puts a member at the end of the queue for a book. A hold is
+// ready for pickup right away if a copy is on the shelf for it.
+func (app *App) PlaceHold(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+	ctx := c.Request.Context()
+
+	var request HoldRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	hold := &models.Hold{
+		Status:   models.HoldWaiting,
+		PlacedAt: now,
+	}
+
+	// an invalid ID is reported as a missing one by Validate
+	hold.MemberID, _ = primitive.ObjectIDFromHex(request.MemberID)
+
+	if err := hold.Validate(); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	book, err := app.BookRepository.GetBook(ctx, id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	hold.BookID = book.ID
+
+	member, err := app.MemberRepository.GetMember(ctx, db.ID(hold.MemberID.Hex()))
+	if errors.Is(err, db.ErrNotFound) {
+		err = errNotAMember
+	}
+
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if member.Expired(now) {
+		app.abortWithError(c, models.ErrMembershipExpired)
+		return
+	}
+
+	queue, err := app.HoldRepository.BookHolds(ctx, id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	for _, queued := range queue {
+		if queued.MemberID == member.ID {
+			app.abortWithError(c, models.ErrAlreadyOnHold)
+			return
+		}
+	}
+
+	// the repository catches a hold placed since the queue was read
+	holdID, err := app.HoldRepository.AddHold(ctx, hold)
+	if errors.Is(err, db.ErrConflict) {
+		err = models.ErrAlreadyOnHold
+	}
+
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.promoteHold(ctx, id, now)
+
+	if hold, err = app.HoldRepository.GetHold(ctx, holdID); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusCreated, hold)
+}
+
+// ListBookHolds lists the queue of a book, the next in line first.
+func (app *App) ListBookHolds(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.BookRepository.GetBook(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	holds, err := app.HoldRepository.BookHolds(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, HoldList{Holds: holds})
+}
+
+// CancelHold takes a hold out of the queue of its book. Cancelling a ready
+// hold passes the book on to the next member in line.
+func (app *App) CancelHold(c *gin.Context) {
+	hold, err := app.HoldRepository.GetHold(c.Request.Context(), db.ID(c.Param("hold")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if hold.BookID.Hex() != c.Param("id") {
+		app.abortWithError(c, errHoldNotFound)
+		return
+	}
+
+	if err := app.cancelHold(c.Request.Context(), hold, time.Now().UTC()); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// cancelHold cancels an active hold and promotes the next one if the
+// cancelled hold was holding the book.
+func (app *App) cancelHold(ctx context.Context, hold *models.Hold, now time.Time) error {
+	var wasReady bool
+
+	cancelled, err := app.updateHold(ctx, db.ID(hold.ID.Hex()), func(hold *models.Hold) error {
+		wasReady = hold.Status == models.HoldReady
+
+		return hold.Cancel(now)
+	})
+	if err != nil {
+		return err
+	}
+
+	if wasReady {
+		app.promoteHold(ctx, db.ID(cancelled.BookID.Hex()), now)
+	}
+
+	return nil
+}
+
+// ListMemberHolds lists the holds of a member, the latest first.
+func (app *App) ListMemberHolds(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.MemberRepository.GetMember(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	holds, err := app.HoldRepository.MemberHolds(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, HoldList{Holds: holds})
+}
+
+// promoteHold makes waiting holds on a book ready for pickup in queue
+// order, as long as there are more copies on the shelf than ready holds
+// keeping them. A book without copies counts as one copy that is on the
+// shelf unless the book is checked out. Failures are only logged, the
+// holds are promoted by the next checkin or expiry instead.
+func (app *App) promoteHold(ctx context.Context, bookID db.ID, now time.Time) {
+	shelved, err := app.shelvedCopies(ctx, bookID)
+	if err != nil {
+		app.Logger.Error(err, "can't promote a hold", "book", bookID)
+		return
+	}
+
+	queue, err := app.HoldRepository.BookHolds(ctx, bookID)
+	if err != nil {
+		app.Logger.Error(err, "can't promote a hold", "book", bookID)
+		return
+	}
+
+	for _, hold := range queue {
+		if hold.Status == models.HoldReady {
+			shelved--
+		}
+	}
+
+	for _, hold := range queue {
+		if shelved <= 0 {
+			return
+		}
+
+		if hold.Status != models.HoldWaiting {
+			continue
+		}
+
+		// a lost race means someone else changed the queue and promoted it
+		_, err = app.HoldRepository.UpdateHold(ctx, db.ID(hold.ID.Hex()), func(hold *models.Hold) (*models.Hold, error) {
+			return hold, hold.MakeReady(now, models.DefaultHoldPickupPeriod)
+		})
+		if errors.Is(err, db.ErrConflict) || errors.Is(err, models.ErrHoldNotWaiting) {
+			return
+		} else if err != nil {
+			app.Logger.Error(err, "can't promote a hold", "book", bookID)
+			return
+		}
+
+		shelved--
+	}
+}
+
+// shelvedCopies counts the copies of a book that are on the shelf.
+func (app *App) shelvedCopies(ctx context.Context, bookID db.ID) (int, error) {
+	copies, err := app.CopyRepository.BookCopies(ctx, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(copies) > 0 {
+		return models.CountCopies(copies).Available, nil
+	}
+
+	book, err := app.BookRepository.GetBook(ctx, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	if book.Status == models.CheckedOut {
+		return 0, nil
+	}
+
+	return 1, nil
+}
+
+// readyHolds returns the ready holds that keep copies of a book for their
+// members. Holds that weren't collected in time don't keep a copy any more,
+// even before ExpireHolds closes them.
+func (app *App) readyHolds(ctx context.Context, bookID db.ID, now time.Time) ([]*models.Hold, error) {
+	queue, err := app.HoldRepository.BookHolds(ctx, bookID)
+	if err != nil {
+		return nil, err
+	}
+
+	var ready []*models.Hold
+
+	for _, hold := range queue {
+		if hold.Status == models.HoldReady && !hold.Overdue(now) {
+			ready = append(ready, hold)
+		}
+	}
+
+	return ready, nil
+}
+
+// updateHold applies change with UpdateHold, retrying lost compare-and-swap
+// races.
+func (app *App) updateHold(ctx context.Context, id db.ID, change func(hold *models.Hold) error) (*models.Hold, error) {
+	for attempt := 1; ; attempt++ {
+		hold, err := app.HoldRepository.UpdateHold(ctx, id, func(hold *models.Hold) (*models.Hold, error) {
+			return hold, change(hold)
+		})
+		if errors.Is(err, db.ErrVersionMismatch) && attempt < maxUpdateAttempts {
+			continue
+		}
+
+		return hold, err
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/bookio"
+)
+
+// BookAction dispatches the custom methods of the books collection, e.g.
+// POST /v1/books:import. gin routes a colon inside a path segment as a
+// parameter, so they all share a single route.
+func (app *App) BookAction(c *gin.Context) {
+	// the parameter starts at the colon
+	switch strings.TrimPrefix(c.Param("action"), ":") {
+	case "import":
+		app.ImportBooks(c)
+	case "batchCreate":
+		app.BatchCreateBooks(c)
+	case "batchUpdate":
+		app.BatchUpdateBooks(c)
+	case "batchDelete":
+		// bulk deletes are for admins only
+		if app.hasRole(c, auth.RoleAdmin) {
+			app.BatchDeleteBooks(c)
+		}
+	default:
+		app.problem(c, http.StatusNotFound, "unknown action "+c.Param("action"))
+	}
+}
+
+// ImportBooks creates the books streamed in the request body as CSV or
+// NDJSON, picked by Content-Type or ?format=csv|ndjson. Invalid rows don't
+// stop the import, the response reports the outcome of every row.
+func (app *App) ImportBooks(c *gin.Context) {
+	app.streamDeadline(c)
+
+	format, err := requestFormat(c)
+	if err != nil {
+		app.problem(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	reader, err := bookio.NewReader(c.Request.Body, format)
+	if errors.Is(err, bookio.ErrUnknownFormat) {
+		app.problem(c, http.StatusUnsupportedMediaType, "books can't be imported from "+string(format))
+		return
+	} else if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	report, err := bookio.Import(c.Request.Context(), app.BookRepository, reader, bookio.DefaultBatchSize)
+	if err != nil {
+		// the books of earlier batches stay imported
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, report)
+}
+
+func requestFormat(c *gin.Context) (bookio.Format, error) {
+	if name := c.Query("format"); name != "" {
+		return bookio.ParseFormat(name)
+	}
+
+	return bookio.FormatFromMIMEType(c.ContentType())
+}
@@ -1,17 +1,126 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
 )
 
+type BookList struct {
+	Books      []*models.Book `json:"books"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
 func (app *App) ListBooks(c *gin.Context) {
-	books, err := app.BookRepository.AllBooks(c.Request.Context())
+	query, err := parseBookQuery(c)
+	if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := app.BookRepository.QueryBooks(c.Request.Context(), query)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		app.abortWithError(c, err)
 		return
 	}
 
-	c.IndentedJSON(http.StatusOK, books)
+	app.render(c, http.StatusOK, BookList{
+		Books:      page.Books,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// parseBookQuery reads filters, sorting and pagination from the query string:
+//
+//	?author=&contributor=&role=&genre=&tag=&publisher=&status=
+//	&min_rating=&max_rating=
+//	&created_after=&created_before=&updated_after=&updated_before=
+//	&sort=-rating,title&limit=20&cursor=
+//
+// Dates are RFC 3339 timestamps. Tags are matched case-insensitively.
+func parseBookQuery(c *gin.Context) (db.BookQuery, error) {
+	query := db.BookQuery{
+		Filter: db.BookFilter{
+			Author:      c.Query("author"),
+			Contributor: c.Query("contributor"),
+			Role:        models.ContributorRole(c.Query("role")),
+			Genre:       c.Query("genre"),
+			Tag:         strings.ToLower(strings.TrimSpace(c.Query("tag"))),
+			Publisher:   c.Query("publisher"),
+			Status:      models.BookStatusType(c.Query("status")),
+		},
+		Cursor: c.Query("cursor"),
+	}
+
+	var err error
+
+	if query.Filter.MinRating, err = intParam(c, "min_rating"); err != nil {
+		return query, err
+	}
+
+	if query.Filter.MaxRating, err = intParam(c, "max_rating"); err != nil {
+		return query, err
+	}
+
+	if query.Filter.CreatedAfter, err = timeParam(c, "created_after"); err != nil {
+		return query, err
+	}
+
+	if query.Filter.CreatedBefore, err = timeParam(c, "created_before"); err != nil {
+		return query, err
+	}
+
+	if query.Filter.UpdatedAfter, err = timeParam(c, "updated_after"); err != nil {
+		return query, err
+	}
+
+	if query.Filter.UpdatedBefore, err = timeParam(c, "updated_before"); err != nil {
+		return query, err
+	}
+
+	if query.Sort, err = db.ParseSort(c.Query("sort")); err != nil {
+		return query, err
+	}
+
+	if limit, err := intParam(c, "limit"); err != nil {
+		return query, err
+	} else if limit != nil {
+		query.Limit = *limit
+	}
+
+	return query, nil
+}
+
+func intParam(c *gin.Context, name string) (*int, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+
+	return &number, nil
+}
+
+func timeParam(c *gin.Context, name string) (*time.Time, error) {
+	value, ok := c.GetQuery(name)
+	if !ok || value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+
+	return &parsed, nil
 }
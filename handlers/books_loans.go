@@ -0,0 +1,458 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxUpdateAttempts bounds the retries of a lost compare-and-swap race.
+const maxUpdateAttempts = 5
+
+var errNotAMember = &models.ValidationError{Fields: []models.FieldError{
+	{Field: "member_id", Rule: "exists", Message: "is not a member"},
+}}
+
+var errNotACopy = &models.ValidationError{Fields: []models.FieldError{
+	{Field: "barcode", Rule: "exists", Message: "is not a copy of the book"},
+}}
+
+// errCopyRequired rejects checkins that don't tell which of several lent
+// copies came back.
+var errCopyRequired = &models.ValidationError{Fields: []models.FieldError{
+	{Field: "barcode", Rule: "required", Message: "is required when several copies are checked out"},
+}}
+
+type LoanList struct {
+	Loans []*models.Loan `json:"loans"`
+}
+
+type CheckoutRequest struct {
+	MemberID string `json:"member_id"`
+	// DueAt defaults to models.DefaultLoanPeriod from now.
+	DueAt *time.Time `json:"due_at"`
+	// Barcode picks the copy to lend, any available copy is lent without it.
+	// Books without copies are lent as a whole.
+	Barcode string `json:"barcode"`
+}
+
+type CheckinRequest struct {
+	// Barcode is the returned copy. It may be left out if only one copy of
+	// the book is checked out.
+	Barcode string `json:"barcode"`
+}
+
+// CheckoutBook lends a copy of the book to a member, or the book itself if
+// it has no copies. Every ready hold keeps a copy for the member who placed
+// it, checking that copy out fulfils the hold. The checkout takes up one of
+// the member's loans, flips the copy or the book to CheckedOut and records a
+// loan, undoing the earlier steps if a later one fails. Both changes are
+// compare-and-swaps, so of two concurrent checkouts of the last copy
+// exactly one succeeds and the other gets 409 Conflict, and a member can't
+// go over the borrowing limit.
+func (app *App) CheckoutBook(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+	ctx := c.Request.Context()
+
+	var request CheckoutRequest
+	if err := bind(c, &request); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	loan := &models.Loan{
+		CheckedOutAt: now,
+		DueAt:        now.Add(models.DefaultLoanPeriod),
+	}
+
+	// an invalid ID is reported as a missing one by Validate
+	loan.MemberID, _ = primitive.ObjectIDFromHex(request.MemberID)
+
+	if request.DueAt != nil {
+		loan.DueAt = request.DueAt.UTC()
+	}
+
+	if err := loan.Validate(); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	copies, err := app.CopyRepository.BookCopies(ctx, id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if request.Barcode != "" && findCopy(copies, request.Barcode) == nil {
+		app.abortWithError(c, errNotACopy)
+		return
+	}
+
+	if len(copies) > 0 {
+		if err := app.matchBook(c, id); err != nil {
+			app.abortWithError(c, err)
+			return
+		}
+	}
+
+	holds, err := app.readyHolds(ctx, id, now)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	var hold *models.Hold
+
+	for _, ready := range holds {
+		if ready.MemberID == loan.MemberID {
+			hold = ready
+		}
+	}
+
+	// the copies kept for the holds of others aren't lent
+	if available := models.CountCopies(copies).Available; hold == nil && len(holds) > 0 && available <= len(holds) {
+		if available == 0 && len(copies) > 0 {
+			app.abortWithError(c, models.ErrNoCopyAvailable)
+		} else {
+			app.abortWithError(c, models.ErrOnHold)
+		}
+
+		return
+	}
+
+	memberID := db.ID(loan.MemberID.Hex())
+
+	err = app.updateMember(c, memberID, func(member *models.Member) error {
+		return member.Borrow(now)
+	})
+	if errors.Is(err, db.ErrNotFound) {
+		err = errNotAMember
+	}
+
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if len(copies) == 0 {
+		book, err := app.changeBookStatus(c, id, (*models.Book).CheckOut)
+		if err != nil {
+			app.undoBorrow(c, memberID)
+			app.abortWithError(c, err)
+
+			return
+		}
+
+		loan.BookID = book.ID
+	} else {
+		item, err := app.checkOutCopy(c, copies, request.Barcode)
+		if err != nil {
+			app.undoBorrow(c, memberID)
+			app.abortWithError(c, err)
+
+			return
+		}
+
+		loan.BookID = item.BookID
+		loan.CopyID = item.ID
+	}
+
+	if _, err := app.LoanRepository.AddLoan(ctx, loan); err != nil {
+		app.undoCheckout(c, loan)
+		app.undoBorrow(c, memberID)
+		app.abortWithError(c, err)
+
+		return
+	}
+
+	if hold != nil {
+		_, err := app.updateHold(ctx, db.ID(hold.ID.Hex()), func(hold *models.Hold) error {
+			return hold.Fulfil(now)
+		})
+		if err != nil {
+			app.Logger.Error(err, "can't fulfil a hold", "hold", hold.ID.Hex())
+		}
+	}
+
+	if !loan.CopyID.IsZero() {
+		app.touchBook(ctx, id)
+	}
+
+	app.render(c, http.StatusCreated, loan)
+}
+
+// checkOutCopy flips the copy with the barcode to CheckedOut, or the first
+// available one of copies if barcode is empty. A copy lent by a concurrent
+// checkout is skipped in favour of the next one.
+func (app *App) checkOutCopy(c *gin.Context, copies []*models.Copy, barcode string) (*models.Copy, error) {
+	if barcode != "" {
+		item := findCopy(copies, barcode)
+		if item == nil {
+			return nil, errNotACopy
+		}
+
+		return app.changeCopyStatus(c, db.ID(item.ID.Hex()), (*models.Copy).CheckOut)
+	}
+
+	for _, item := range copies {
+		if item.Status != models.CopyAvailable {
+			continue
+		}
+
+		lent, err := app.changeCopyStatus(c, db.ID(item.ID.Hex()), (*models.Copy).CheckOut)
+		if errors.Is(err, models.ErrCopyCheckedOut) || errors.Is(err, models.ErrCopyNotAvailable) ||
+			errors.Is(err, db.ErrNotFound) {
+			continue
+		}
+
+		return lent, err
+	}
+
+	return nil, models.ErrNoCopyAvailable
+}
+
+// findCopy returns the copy with the barcode, or nil.
+func findCopy(copies []*models.Copy, barcode string) *models.Copy {
+	for _, item := range copies {
+		if item.Barcode == barcode {
+			return item
+		}
+	}
+
+	return nil
+}
+
+// undoCheckout returns the copy or the book of a loan that couldn't be
+// recorded to the shelf.
+func (app *App) undoCheckout(c *gin.Context, loan *models.Loan) {
+	if !loan.CopyID.IsZero() {
+		id := db.ID(loan.CopyID.Hex())
+
+		if _, err := app.changeCopyStatus(c, id, (*models.Copy).CheckIn); err != nil {
+			app.Logger.Error(err, "can't undo a checkout", "copy", id)
+		}
+
+		return
+	}
+
+	id := db.ID(loan.BookID.Hex())
+
+	_, err := app.BookRepository.UpdateBook(c.Request.Context(), id, func(book *models.Book) (*models.Book, error) {
+		return book, book.CheckIn()
+	})
+	if err != nil {
+		app.Logger.Error(err, "can't undo a checkout", "book", id)
+	}
+}
+
+// undoBorrow gives a member back a loan.
+func (app *App) undoBorrow(c *gin.Context, id db.ID) {
+	err := app.updateMember(c, id, func(member *models.Member) error {
+		member.Return()
+		return nil
+	})
+	if err != nil {
+		app.Logger.Error(err, "can't give a loan back to a member", "member", id)
+	}
+}
+
+// CheckinBook puts the returned copy, or the book if it has no copies, back
+// on the shelf, closes its loan, gives the member the loan back, fines a
+// late return and keeps the copy for the next hold in line. It responds
+// with the closed loan, or with 204 No Content for books that were checked
+// out without one.
+func (app *App) CheckinBook(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+	ctx := c.Request.Context()
+
+	var request CheckinRequest
+	if c.Request.ContentLength != 0 {
+		if err := bind(c, &request); err != nil {
+			app.bindError(c, err)
+			return
+		}
+	}
+
+	copies, err := app.CopyRepository.BookCopies(ctx, id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	item, err := returnedCopy(copies, request.Barcode)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	var copyID db.ID
+
+	if item == nil {
+		if _, err := app.changeBookStatus(c, id, (*models.Book).CheckIn); err != nil {
+			app.abortWithError(c, err)
+			return
+		}
+	} else {
+		if err := app.matchBook(c, id); err != nil {
+			app.abortWithError(c, err)
+			return
+		}
+
+		copyID = db.ID(item.ID.Hex())
+
+		if _, err := app.changeCopyStatus(c, copyID, (*models.Copy).CheckIn); err != nil {
+			app.abortWithError(c, err)
+			return
+		}
+
+		app.touchBook(ctx, id)
+	}
+
+	now := time.Now().UTC()
+
+	loan, err := app.LoanRepository.ReturnLoan(ctx, id, copyID, now)
+	if errors.Is(err, db.ErrNotFound) {
+		app.promoteHold(ctx, id, now)
+		c.Status(http.StatusNoContent)
+
+		return
+	} else if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if !loan.MemberID.IsZero() {
+		app.undoBorrow(c, db.ID(loan.MemberID.Hex()))
+	}
+
+	if _, err := app.assessFine(ctx, loan, now); err != nil {
+		app.Logger.Error(err, "can't fine a late return", "loan", loan.ID.Hex())
+	}
+
+	app.promoteHold(ctx, id, now)
+
+	app.render(c, http.StatusOK, loan)
+}
+
+// returnedCopy picks the copy a checkin returns: the one with the barcode,
+// or the only lent copy if barcode is empty. It returns nil for books that
+// have no copy checked out, which are returned as a whole.
+func returnedCopy(copies []*models.Copy, barcode string) (*models.Copy, error) {
+	if barcode != "" {
+		if item := findCopy(copies, barcode); item != nil {
+			return item, nil
+		}
+
+		return nil, errNotACopy
+	}
+
+	var lent *models.Copy
+
+	for _, item := range copies {
+		if item.Status != models.CopyCheckedOut {
+			continue
+		}
+
+		if lent != nil {
+			return nil, errCopyRequired
+		}
+
+		lent = item
+	}
+
+	return lent, nil
+}
+
+// changeBookStatus applies a status transition with UpdateBook. A lost
+// compare-and-swap race is retried against the new state of the book, unless
+// the client pinned a version with If-Match.
+func (app *App) changeBookStatus(c *gin.Context, id db.ID, transition func(book *models.Book) error) (*models.Book, error) {
+	for attempt := 1; ; attempt++ {
+		book, err := app.BookRepository.UpdateBook(c.Request.Context(), id, func(book *models.Book) (*models.Book, error) {
+			if !ifMatch(c, book.Version) {
+				return nil, db.ErrVersionMismatch
+			}
+
+			return book, transition(book)
+		})
+		if errors.Is(err, db.ErrVersionMismatch) && c.GetHeader("If-Match") == "" && attempt < maxUpdateAttempts {
+			continue
+		}
+
+		return book, err
+	}
+}
+
+// matchBook checks If-Match against the version of a book whose copies
+// are lent or returned, which changeBookStatus does for books without
+// copies.
+func (app *App) matchBook(c *gin.Context, id db.ID) error {
+	if c.GetHeader("If-Match") == "" {
+		return nil
+	}
+
+	book, err := app.BookRepository.GetBook(c.Request.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	if !ifMatch(c, book.Version) {
+		return db.ErrVersionMismatch
+	}
+
+	return nil
+}
+
+// changeCopyStatus applies a status transition with UpdateCopy, retrying
+// lost compare-and-swap races like changeBookStatus.
+func (app *App) changeCopyStatus(c *gin.Context, id db.ID, transition func(item *models.Copy) error) (*models.Copy, error) {
+	for attempt := 1; ; attempt++ {
+		item, err := app.CopyRepository.UpdateCopy(c.Request.Context(), id, func(item *models.Copy) (*models.Copy, error) {
+			return item, transition(item)
+		})
+		if errors.Is(err, db.ErrVersionMismatch) && attempt < maxUpdateAttempts {
+			continue
+		}
+
+		return item, err
+	}
+}
+
+// updateMember applies change with UpdateMember, retrying lost
+// compare-and-swap races.
+func (app *App) updateMember(c *gin.Context, id db.ID, change func(member *models.Member) error) error {
+	for attempt := 1; ; attempt++ {
+		_, err := app.MemberRepository.UpdateMember(c.Request.Context(), id, func(member *models.Member) (*models.Member, error) {
+			return member, change(member)
+		})
+		if errors.Is(err, db.ErrVersionMismatch) && attempt < maxUpdateAttempts {
+			continue
+		}
+
+		return err
+	}
+}
+
+// ListBookLoans lists the loans of a book, the latest first.
+func (app *App) ListBookLoans(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.BookRepository.GetBook(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	loans, err := app.LoanRepository.BookLoans(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, LoanList{Loans: loans})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// PatchBook changes only the fields named by the patch. Both patch formats
+// are applied to the JSON form of the stored book inside UpdateBook, so the
+// patch always sees the current version.
+func (app *App) PatchBook(c *gin.Context) {
+	id := c.Param("id")
+
+	limitBody(c)
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if isBodyTooLarge(err) {
+		app.bodyTooLarge(c)
+		return
+	} else if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var apply func(doc interface{}) (interface{}, error)
+
+	switch c.ContentType() {
+	case MergePatchMIMEType:
+		var patch interface{}
+		if err := json.Unmarshal(body, &patch); err != nil {
+			app.problem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apply = func(doc interface{}) (interface{}, error) {
+			return mergePatch(doc, patch), nil
+		}
+	case JSONPatchMIMEType:
+		operations, err := parseJSONPatch(body)
+		if err != nil {
+			app.problem(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		apply = func(doc interface{}) (interface{}, error) {
+			return applyJSONPatch(doc, operations)
+		}
+	default:
+		app.problem(c, http.StatusUnsupportedMediaType,
+			fmt.Sprintf("use %s or %s", MergePatchMIMEType, JSONPatchMIMEType))
+
+		return
+	}
+
+	book, err := app.BookRepository.UpdateBook(c.Request.Context(), db.ID(id), func(oldBook *models.Book) (*models.Book, error) {
+		if !ifMatch(c, oldBook.Version) {
+			return nil, db.ErrVersionMismatch
+		}
+
+		return patchBook(oldBook, apply)
+	})
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(book.Version))
+	app.render(c, http.StatusOK, book)
+}
+
+func patchBook(book *models.Book, apply func(doc interface{}) (interface{}, error)) (*models.Book, error) {
+	raw, err := json.Marshal(book)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode a book: %w", err)
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("can't decode a book: %w", err)
+	}
+
+	doc, err = apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err = json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode a book: %w", err)
+	}
+
+	patched := new(models.Book)
+	if err := json.Unmarshal(raw, patched); err != nil {
+		return nil, fmt.Errorf("%w: %s", errPatchResult, err.Error())
+	}
+
+	return replaceBook(book, patched)
+}
@@ -6,33 +6,71 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/iho/booksdb/db"
 	"github.com/iho/booksdb/models"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// errStatusChanged rejects updates that change the status of a book, which
+// only checkouts and checkins do.
+var errStatusChanged = &models.ValidationError{Fields: []models.FieldError{
+	{Field: "status", Rule: "readonly", Message: "can only be changed by checking the book out or in"},
+}}
+
+// UpdateBook replaces the stored book, see replaceBook.
 func (app *App) UpdateBook(c *gin.Context) {
 	id := c.Param("id")
 	book := new(models.Book)
 
-	err := c.BindJSON(&book)
+	err := bind(c, &book)
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		app.bindError(c, err)
 		return
 	}
 
 	book, err = app.BookRepository.UpdateBook(c.Request.Context(), db.ID(id), func(oldBook *models.Book) (*models.Book, error) {
-		return book, nil
+		if !ifMatch(c, oldBook.Version) {
+			return nil, db.ErrVersionMismatch
+		}
+
+		return replaceBook(oldBook, book)
 	})
 	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+		app.abortWithError(c, err)
 		return
 	}
 
-	bookID, err := primitive.ObjectIDFromHex(string(id))
-	if err != nil {
-		c.IndentedJSON(http.StatusBadRequest, gin.H{"message": err.Error()})
-		return
+	c.Header("ETag", etag(book.Version))
+	app.render(c, http.StatusCreated, book)
+}
+
+// replaceBook checks update as the replacement of stored and returns the
+// book to store. The status may be left out, it is kept either way, and an
+// author left as stored follows the contributors, see
+// models.Book.DeriveAuthor. update itself isn't changed, the repository may
+// call its update function more than once.
+func replaceBook(stored, update *models.Book) (*models.Book, error) {
+	replacement := *update
+	replacement.DeriveAuthor(stored)
+
+	if err := replacement.Validate(); err != nil {
+		return nil, err
 	}
 
-	book.ID = bookID
-	c.IndentedJSON(http.StatusCreated, book)
+	replacement.Normalize()
+
+	if err := keepStatus(stored, &replacement); err != nil {
+		return nil, err
+	}
+
+	return &replacement, nil
+}
+
+// keepStatus gives the update of a book the status of the stored book. An
+// update asking for another status fails.
+func keepStatus(stored, update *models.Book) error {
+	if update.Status != "" && update.Status != stored.Status {
+		return errStatusChanged
+	}
+
+	update.Status = stored.Status
+
+	return nil
 }
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+type SearchHit struct {
+	Score float64      `json:"score"`
+	Book  *models.Book `json:"book"`
+}
+
+type SearchResults struct {
+	Results    []SearchHit `json:"results"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// SearchBooks runs a full-text search over titles, authors and publishers:
+//
+//	?q=&limit=20&cursor=
+//
+// Results are ordered by relevance.
+func (app *App) SearchBooks(c *gin.Context) {
+	search := db.BookSearch{
+		Text:   c.Query("q"),
+		Cursor: c.Query("cursor"),
+	}
+
+	if search.Text == "" {
+		app.problem(c, http.StatusBadRequest, "q is required")
+		return
+	}
+
+	if limit, err := intParam(c, "limit"); err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	} else if limit != nil {
+		search.Limit = *limit
+	}
+
+	page, err := app.BookRepository.SearchBooks(c.Request.Context(), search)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	results := SearchResults{
+		Results:    make([]SearchHit, 0, len(page.Results)),
+		NextCursor: page.NextCursor,
+	}
+	for _, result := range page.Results {
+		results.Results = append(results.Results, SearchHit{Score: result.Score, Book: result.Book})
+	}
+
+	app.render(c, http.StatusOK, results)
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+)
+
+// DefaultTrashRetention is how long deleted books stay in the trash unless
+// App.TrashRetention says otherwise.
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// ListTrash lists the deleted books like ListBooks, the most recently
+// deleted first unless sorted otherwise.
+func (app *App) ListTrash(c *gin.Context) {
+	query, err := parseBookQuery(c)
+	if err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query.Filter.Deleted = true
+
+	if len(query.Sort) == 0 {
+		query.Sort = []db.SortField{{Field: db.SortByDeletedAt, Descending: true}}
+	}
+
+	page, err := app.BookRepository.QueryBooks(c.Request.Context(), query)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, BookList{
+		Books:      page.Books,
+		NextCursor: page.NextCursor,
+	})
+}
+
+// RestoreBook takes a book out of the trash.
+func (app *App) RestoreBook(c *gin.Context) {
+	book, err := app.BookRepository.RestoreBook(c.Request.Context(), db.ID(c.Param("id")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(book.Version))
+	app.render(c, http.StatusOK, book)
+}
+
+// PurgeTrash removes the books that have been in the trash for longer than
+// TrashRetention for good. It returns the number of purged books.
+func (app *App) PurgeTrash(ctx context.Context, now time.Time) (int, error) {
+	return app.BookRepository.PurgeBooks(ctx, now.Add(-app.TrashRetention))
+}
@@ -0,0 +1,300 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+)
+
+func (suite *BookHandlersTestSuite) addCopy(t *testing.T, server TestServer, bookID, body string) (*models.Copy, int) {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/books/"+bookID+"/copies", JSON_HTTP_HEADER, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, resp.StatusCode
+	}
+
+	item := new(models.Copy)
+	if err := json.NewDecoder(resp.Body).Decode(item); err != nil {
+		t.Fatal(err)
+	}
+
+	return item, resp.StatusCode
+}
+
+func (suite *BookHandlersTestSuite) getBookDetails(t *testing.T, server TestServer, bookID string) (*handlers.BookDetails, string) {
+	t.Helper()
+
+	resp, err := http.Get(server.TS.URL + "/v1/books/" + bookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	details := &handlers.BookDetails{Book: new(models.Book)}
+	if err := json.NewDecoder(resp.Body).Decode(details); err != nil {
+		t.Fatal(err)
+	}
+
+	return details, resp.Header.Get("ETag")
+}
+
+func (suite *BookHandlersTestSuite) TestCopies() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Copies"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			bookID := book.ID.Hex()
+			prefix := bookID
+
+			details, etag := suite.getBookDetails(t, server, bookID)
+			suite.Assert().Equal(models.Availability{}, details.Availability)
+			suite.Assert().Equal(book.Title, details.Title)
+
+			first, status := suite.addCopy(t, server, bookID,
+				`{"barcode": " `+prefix+`-1 ", "location": "A-12", "condition": "New"}`)
+			suite.Assert().Equal(http.StatusCreated, status)
+			if suite.Assert().NotNil(first) {
+				suite.Assert().Equal(prefix+"-1", first.Barcode)
+				suite.Assert().Equal(models.CopyAvailable, first.Status)
+				suite.Assert().Equal(book.ID, first.BookID)
+			}
+
+			_, status = suite.addCopy(t, server, bookID, `{"barcode": "`+prefix+`-1"}`)
+			suite.Assert().Equal(http.StatusConflict, status)
+
+			_, status = suite.addCopy(t, server, bookID, `{"barcode": "`+prefix+`-x", "condition": "Shiny"}`)
+			suite.Assert().Equal(http.StatusUnprocessableEntity, status)
+
+			_, status = suite.addCopy(t, server, bookID, `{"barcode": "`+prefix+`-2", "status": "InRepair"}`)
+			suite.Assert().Equal(http.StatusCreated, status)
+
+			_, status = suite.addCopy(t, server, bookID, `{"barcode": "`+prefix+`-3", "status": "Withdrawn"}`)
+			suite.Assert().Equal(http.StatusCreated, status)
+
+			details, newETag := suite.getBookDetails(t, server, bookID)
+			suite.Assert().NotEqual(etag, newETag)
+			suite.Assert().Equal(models.Availability{Total: 2, Available: 1, Unavailable: 1}, details.Availability)
+
+			resp, err := http.Get(server.TS.URL + "/v1/copies/barcode/" + prefix + "-1")
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(`"1"`, resp.Header.Get("ETag"))
+
+			first.Status = models.CopyCheckedOut
+			jsonValue, _ := json.Marshal(first)
+			req, err := http.NewRequest("PUT", server.TS.URL+"/v1/copies/"+first.ID.Hex(), bytes.NewBuffer(jsonValue))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			_, status = suite.addCopy(t, server, bookID, `{"barcode": "`+prefix+`-4", "status": "CheckedOut"}`)
+			suite.Assert().Equal(http.StatusUnprocessableEntity, status)
+
+			req, err = http.NewRequest("PUT", server.TS.URL+"/v1/copies/"+first.ID.Hex(),
+				bytes.NewBufferString(`{"barcode": "`+prefix+`-1", "location": "B-3"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-Match", `"1"`)
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			updated := new(models.Copy)
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(updated))
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(`"2"`, resp.Header.Get("ETag"))
+			suite.Assert().Equal(models.CopyAvailable, updated.Status)
+			suite.Assert().Equal("B-3", updated.Location)
+
+			member := suite.createMember(t, server)
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+member.ID.Hex()+`", "barcode": "`+prefix+`-1"}`)
+			loan := new(models.Loan)
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(loan))
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			suite.Assert().Equal(first.ID, loan.CopyID)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/" + bookID + "/copies")
+			suite.Assert().NoError(err)
+			list := &handlers.CopyList{}
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(list))
+			resp.Body.Close()
+			suite.Assert().Len(list.Copies, 3)
+			suite.Assert().Equal(models.Availability{Total: 2, CheckedOut: 1, Unavailable: 1}, list.Availability)
+
+			req, err = http.NewRequest("DELETE", server.TS.URL+"/v1/books/"+bookID, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			req, err = http.NewRequest("DELETE", server.TS.URL+"/v1/copies/"+first.ID.Hex(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err = http.Post(server.TS.URL+"/v1/books/"+bookID+"/checkin", JSON_HTTP_HEADER, nil)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+			for _, item := range list.Copies {
+				req, err := http.NewRequest("DELETE", server.TS.URL+"/v1/copies/"+item.ID.Hex(), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				suite.Assert().NoError(err)
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusNoContent, resp.StatusCode)
+			}
+
+			details, _ = suite.getBookDetails(t, server, bookID)
+			suite.Assert().Equal(models.Availability{}, details.Availability)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) checkin(t *testing.T, server TestServer, id, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/books/"+id+"/checkin", JSON_HTTP_HEADER, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func (suite *BookHandlersTestSuite) decodeLoan(t *testing.T, resp *http.Response) *models.Loan {
+	t.Helper()
+	defer resp.Body.Close()
+
+	loan := new(models.Loan)
+	if err := json.NewDecoder(resp.Body).Decode(loan); err != nil {
+		t.Fatal(err)
+	}
+
+	return loan
+}
+
+func (suite *BookHandlersTestSuite) TestCopyCirculation() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("CopyCirculation"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			bookID := suite.createBook(t, server).ID.Hex()
+			first, _ := suite.addCopy(t, server, bookID, `{"barcode": "`+bookID+`-1"}`)
+			second, _ := suite.addCopy(t, server, bookID, `{"barcode": "`+bookID+`-2"}`)
+			suite.Require().NotNil(first)
+			suite.Require().NotNil(second)
+
+			members := make([]string, 4)
+			for i := range members {
+				members[i] = suite.createMember(t, server).ID.Hex()
+			}
+
+			resp := suite.checkout(t, server, bookID, `{"member_id": "`+members[0]+`", "barcode": "`+bookID+`-9"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[0]+`"}`)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			suite.Assert().Equal(first.ID, suite.decodeLoan(t, resp).CopyID)
+
+			details, _ := suite.getBookDetails(t, server, bookID)
+			suite.Assert().Equal(models.Availability{Total: 2, Available: 1, CheckedOut: 1}, details.Availability)
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[1]+`", "barcode": "`+bookID+`-1"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[1]+`"}`)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			suite.Assert().Equal(second.ID, suite.decodeLoan(t, resp).CopyID)
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[2]+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+			suite.Assert().Equal(0, suite.getMember(t, server, members[2]).Loans)
+
+			details, _ = suite.getBookDetails(t, server, bookID)
+			suite.Assert().Equal(models.Availability{Total: 2, CheckedOut: 2}, details.Availability)
+
+			// two copies are lent, the checkin has to tell which one is back
+			resp = suite.checkin(t, server, bookID, "")
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp = suite.checkin(t, server, bookID, `{"barcode": "`+bookID+`-2"}`)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			loan := suite.decodeLoan(t, resp)
+			suite.Assert().Equal(second.ID, loan.CopyID)
+			suite.Assert().False(loan.Open())
+
+			resp = suite.checkin(t, server, bookID, `{"barcode": "`+bookID+`-2"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			// the returned copy is kept for the hold of the third member
+			hold, status := suite.placeHold(t, server, bookID, suite.getMember(t, server, members[2]))
+			suite.Assert().Equal(http.StatusCreated, status)
+			if suite.Assert().NotNil(hold) {
+				suite.Assert().Equal(models.HoldReady, hold.Status)
+			}
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[3]+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp = suite.checkout(t, server, bookID, `{"member_id": "`+members[2]+`"}`)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			suite.Assert().Equal(second.ID, suite.decodeLoan(t, resp).CopyID)
+
+			resp = suite.checkin(t, server, bookID, `{"barcode": "`+bookID+`-1"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+			resp = suite.checkin(t, server, bookID, "")
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(second.ID, suite.decodeLoan(t, resp).CopyID)
+
+			details, _ = suite.getBookDetails(t, server, bookID)
+			suite.Assert().Equal(models.Availability{Total: 2, Available: 2}, details.Availability)
+
+			for _, member := range members {
+				suite.Assert().Equal(0, suite.getMember(t, server, member).Loans)
+			}
+		})
+	}
+}
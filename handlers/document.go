@@ -0,0 +1,525 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Responses in formats other than JSON are rendered from the JSON encoding
+// of a value, so that every format has the same field names and values.
+// The JSON is decoded into a document of objects keeping their members in
+// order, []interface{}, json.Number, string, bool and nil.
+
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func toDocument(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode a response: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	return readDocument(decoder)
+}
+
+func readDocument(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("can't decode a response: %w", err)
+	}
+
+	switch token {
+	case json.Delim('{'):
+		doc := object{}
+
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, fmt.Errorf("can't decode a response: %w", err)
+			}
+
+			value, err := readDocument(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = append(doc, member{key: key.(string), value: value}) //nolint:forcetypeassert
+		}
+
+		_, err = decoder.Token()
+
+		return doc, err
+	case json.Delim('['):
+		doc := make([]interface{}, 0)
+
+		for decoder.More() {
+			value, err := readDocument(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = append(doc, value)
+		}
+
+		_, err = decoder.Token()
+
+		return doc, err
+	}
+
+	return token, nil
+}
+
+// number turns a JSON number into an int64 if it is an integer.
+func number(value json.Number) interface{} {
+	if integer, err := value.Int64(); err == nil {
+		return integer
+	}
+
+	float, _ := value.Float64()
+
+	return float
+}
+
+// yamlValue converts a document into values yaml.v2 keeps in order.
+func yamlValue(doc interface{}) interface{} {
+	switch doc := doc.(type) {
+	case object:
+		result := make(yaml.MapSlice, 0, len(doc))
+		for _, member := range doc {
+			result = append(result, yaml.MapItem{Key: member.key, Value: yamlValue(member.value)})
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(doc))
+		for i, item := range doc {
+			result[i] = yamlValue(item)
+		}
+
+		return result
+	case json.Number:
+		return number(doc)
+	}
+
+	return doc
+}
+
+// msgpackMap is encoded by codec as a map of alternating keys and values.
+type msgpackMap []interface{}
+
+func (msgpackMap) MapBySlice() {}
+
+func msgpackValue(doc interface{}) interface{} {
+	switch doc := doc.(type) {
+	case object:
+		result := make(msgpackMap, 0, 2*len(doc))
+		for _, member := range doc {
+			result = append(result, member.key, msgpackValue(member.value))
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(doc))
+		for i, item := range doc {
+			result[i] = msgpackValue(item)
+		}
+
+		return result
+	case json.Number:
+		return number(doc)
+	}
+
+	return doc
+}
+
+// XML has no arrays, list items are wrapped in <item> elements. Members
+// whose keys aren't valid element names are written as <entry key="...">.
+const (
+	xmlItem  = "item"
+	xmlEntry = "entry"
+)
+
+func writeXML(w io.Writer, root string, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("can't write a response: %w", err)
+	}
+
+	encoder := xml.NewEncoder(w)
+	if err := encodeXML(encoder, root, doc); err != nil {
+		return fmt.Errorf("can't write a response: %w", err)
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return fmt.Errorf("can't write a response: %w", err)
+	}
+
+	return nil
+}
+
+func encodeXML(encoder *xml.Encoder, name string, doc interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: xmlEntry},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	if err := encoder.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch doc := doc.(type) {
+	case object:
+		for _, member := range doc {
+			if err := encodeXML(encoder, member.key, member.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range doc {
+			if err := encodeXML(encoder, xmlItem, item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(doc))); err != nil {
+			return err
+		}
+	}
+
+	return encoder.EncodeToken(start.End())
+}
+
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+
+	for i, r := range name {
+		if !unicode.IsLetter(r) && r != '_' && (i == 0 || !unicode.IsDigit(r) && r != '-' && r != '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// xmlRootName names the root element after the type of the value, e.g.
+// <book_list> for a BookList.
+func xmlRootName(value interface{}) string {
+	t := reflect.TypeOf(value)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Name() == "" {
+		return "response"
+	}
+
+	var name strings.Builder
+
+	for i, r := range t.Name() {
+		if unicode.IsUpper(r) && i > 0 {
+			name.WriteByte('_')
+		}
+
+		name.WriteRune(unicode.ToLower(r))
+	}
+
+	return name.String()
+}
+
+// readXML decodes the root element of an XML document into the values
+// yaml.v2 and codec produce: elements with children become maps, repeated
+// children lists, and elements with text only strings.
+func readXML(r io.Reader) (interface{}, error) {
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := token.(xml.StartElement); ok {
+			return readXMLElement(decoder)
+		}
+	}
+}
+
+func readXMLElement(decoder *xml.Decoder) (interface{}, error) {
+	var (
+		text     strings.Builder
+		children map[string]interface{}
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+		case xml.StartElement:
+			child, err := readXMLElement(decoder)
+			if err != nil {
+				return nil, err
+			}
+
+			if children == nil {
+				children = make(map[string]interface{})
+			}
+
+			key := token.Name.Local
+			if key == xmlEntry {
+				for _, attr := range token.Attr {
+					if attr.Name.Local == "key" {
+						key = attr.Value
+					}
+				}
+			}
+
+			// elements only decode to lists when they are repeated
+			switch existing := children[key].(type) {
+			case nil:
+				children[key] = child
+			case []interface{}:
+				children[key] = append(existing, child)
+			default:
+				children[key] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			if children == nil {
+				return text.String(), nil
+			}
+
+			return children, nil
+		}
+	}
+}
+
+//nolint:gochecknoglobals
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// coerce adapts a decoded request body to the JSON types of the value it is
+// bound to: XML only has strings, and YAML reads an ISBN as a number.
+// Values of types that decode JSON themselves, like times and ObjectIDs,
+// are passed on as they are.
+func coerce(value interface{}, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return value, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return coerceStruct(value, t)
+	case reflect.Slice, reflect.Array:
+		return coerceList(value, t)
+	case reflect.Map:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return value, nil
+		}
+
+		for key, field := range fields {
+			var err error
+			if fields[key], err = coerce(field, t.Elem()); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.String:
+		switch value.(type) {
+		case string, nil, map[string]interface{}, []interface{}:
+			return value, nil
+		}
+
+		return fmt.Sprint(value), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if text, ok := value.(string); ok {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return nil, nil
+			}
+
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, fmt.Errorf("%q is not a number", text)
+			}
+
+			return json.Number(text), nil
+		}
+	case reflect.Bool:
+		if text, ok := value.(string); ok {
+			text = strings.TrimSpace(text)
+			if text == "" {
+				return nil, nil
+			}
+
+			flag, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a boolean", text)
+			}
+
+			return flag, nil
+		}
+	}
+
+	return value, nil
+}
+
+func coerceStruct(value interface{}, t reflect.Type) (interface{}, error) {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		if text, ok := value.(string); ok && strings.TrimSpace(text) == "" {
+			// an empty XML element
+			return map[string]interface{}{}, nil
+		}
+
+		return value, nil
+	}
+
+	types := jsonFields(t)
+
+	for key, field := range fields {
+		fieldType, ok := types[key]
+		if !ok {
+			continue
+		}
+
+		var err error
+		if fields[key], err = coerce(field, fieldType); err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return fields, nil
+}
+
+func coerceList(value interface{}, t reflect.Type) (interface{}, error) {
+	var items []interface{}
+
+	switch value := value.(type) {
+	case []interface{}:
+		items = value
+	case map[string]interface{}:
+		// <tags><item>a</item><item>b</item></tags>
+		switch item := value[xmlItem].(type) {
+		case []interface{}:
+			items = item
+		case nil:
+		default:
+			items = []interface{}{item}
+		}
+	case string:
+		if strings.TrimSpace(value) != "" {
+			return value, nil
+		}
+	default:
+		return value, nil
+	}
+
+	result := make([]interface{}, len(items))
+
+	for i, item := range items {
+		var err error
+		if result[i], err = coerce(item, t.Elem()); err != nil {
+			return nil, fmt.Errorf("%d: %w", i, err)
+		}
+	}
+
+	return result, nil
+}
+
+// jsonFields maps the JSON names of the fields of a struct onto their
+// types, including the fields of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if name == "-" {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				for key, fieldType := range jsonFields(embedded) {
+					fields[key] = fieldType
+				}
+
+				continue
+			}
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fields[name] = field.Type
+	}
+
+	return fields
+}
+
+// plainValue makes the maps decoded by yaml.v2 and codec encodable as JSON.
+func plainValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[fmt.Sprint(key)] = plainValue(item)
+		}
+
+		return result
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = plainValue(item)
+		}
+
+		return value
+	case []interface{}:
+		for i, item := range value {
+			value[i] = plainValue(item)
+		}
+
+		return value
+	}
+
+	return value
+}
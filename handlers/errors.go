@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+const ProblemMIMEType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Errors lists the offending fields of an invalid request.
+	Errors []models.FieldError `json:"errors,omitempty"`
+}
+
+// problem responds with a problem details body and aborts the request.
+func (app *App) problem(c *gin.Context, status int, detail string) {
+	app.renderProblem(c, Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	})
+}
+
+// renderProblem renders the problem in the representation asked for, JSON
+// if the client accepts none of them.
+func (app *App) renderProblem(c *gin.Context, problem Problem) {
+	problem.Instance = c.Request.URL.Path
+
+	format, _ := negotiate(c.GetHeader("Accept"))
+
+	contentType := ""
+	switch format {
+	case representationJSON, representationCompactJSON:
+		contentType = ProblemMIMEType
+	case representationXML:
+		contentType = ProblemXMLMIMEType
+	}
+
+	c.Header("Vary", "Accept")
+	c.Abort()
+	app.encode(c, problem.Status, format, problem, contentType)
+}
+
+// abortWithError maps an error returned by a repository or by request
+// processing to the matching status code.
+func (app *App) abortWithError(c *gin.Context, err error) {
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		app.renderProblem(c, Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(http.StatusUnprocessableEntity),
+			Status: http.StatusUnprocessableEntity,
+			Detail: validationErr.Error(),
+			Errors: validationErr.Fields,
+		})
+
+		return
+	}
+
+	status := errorStatus(err)
+	if status == http.StatusInternalServerError {
+		app.Logger.Error(err, "request failed", "path", c.Request.URL.Path)
+		app.problem(c, status, "")
+
+		return
+	}
+
+	app.problem(c, status, err.Error())
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, db.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrInvalidID), errors.Is(err, errPatchPath), errors.Is(err, errPatchResult):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, db.ErrConflict), errors.Is(err, errPatchTestFailed),
+		errors.Is(err, models.ErrAlreadyCheckedOut), errors.Is(err, models.ErrNotCheckedOut),
+		errors.Is(err, models.ErrMembershipExpired), errors.Is(err, models.ErrBorrowingLimitReached),
+		errors.Is(err, errMemberHasLoans), errors.Is(err, models.ErrOnHold),
+		errors.Is(err, models.ErrAlreadyOnHold), errors.Is(err, models.ErrHoldClosed),
+		errors.Is(err, errMemberHasFines), errors.Is(err, models.ErrFineSettled),
+		errors.Is(err, errBookHasCopies), errors.Is(err, models.ErrCopyCheckedOut),
+		errors.Is(err, models.ErrCopyNotAvailable), errors.Is(err, models.ErrCopyNotCheckedOut),
+		errors.Is(err, models.ErrNoCopyAvailable):
+		return http.StatusConflict
+	case errors.Is(err, db.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, db.ErrInvalidCursor):
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Books are tagged with their version, e.g. `"3"`.
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch reports whether a book at the given version satisfies the
+// If-Match header of the request. A missing header always matches. Weak
+// tags never match as If-Match requires strong comparison.
+func ifMatch(c *gin.Context, version int64) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		return true
+	}
+
+	return matchesETag(header, version)
+}
+
+// ifNoneMatch reports whether the If-None-Match header of the request lists
+// the given version, using weak comparison.
+func ifNoneMatch(c *gin.Context, version int64) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+
+	return matchesETag(strings.ReplaceAll(header, "W/", ""), version)
+}
+
+func matchesETag(header string, version int64) bool {
+	current := etag(version)
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,113 @@
+package handlers_test
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iho/booksdb/bookio"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) exportBooks(url string) ([]byte, *http.Response) {
+	resp, err := http.Get(url)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+
+	return body, resp
+}
+
+func (suite *BookHandlersTestSuite) TestExportBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("ExportBooks"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+
+			for _, book := range []*models.Book{
+				{Title: "Beowulf", Publisher: publisher, Rating: 3, Tags: []string{"epic", "dragons"}},
+				{Title: "The Hobbit <&>", Publisher: publisher, Rating: 5, Contributors: []models.Contributor{
+					{Name: "J. R. R. Tolkien", Role: models.RoleAuthor},
+					{Name: "Alan Lee", Role: models.RoleIllustrator},
+				}},
+			} {
+				jsonValue, _ := json.Marshal(book)
+				resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+				suite.Assert().NoError(err)
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			}
+
+			url := server.TS.URL + "/v1/books/export?publisher=" + publisher + "&sort=-rating"
+
+			body, resp := suite.exportBooks(url)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(bookio.CSVMIMEType, resp.Header.Get("Content-Type"))
+			suite.Assert().Contains(resp.Header.Get("Content-Disposition"), "books.csv")
+			suite.Assert().Equal("2", resp.Trailer.Get(handlers.BookCountTrailer))
+			records, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(records, 3) {
+				suite.Assert().Equal(bookio.ExportColumns, records[0])
+				suite.Assert().Equal("The Hobbit <&>", records[1][2])
+				suite.Assert().Equal("J. R. R. Tolkien (Author); Alan Lee (Illustrator)", records[1][4])
+				suite.Assert().Equal("epic; dragons", records[2][7])
+			}
+
+			// an export can be imported again
+			report, status := suite.importBooks(server.TS.URL+"/v1/books:import", bookio.CSVMIMEType, string(body))
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().Equal(2, report.Imported)
+
+			body, resp = suite.exportBooks(url + "&format=ndjson")
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(bookio.NDJSONMIMEType, resp.Header.Get("Content-Type"))
+			var titles []string
+			scanner := bufio.NewScanner(bytes.NewReader(body))
+			for scanner.Scan() {
+				book := &models.Book{}
+				suite.Assert().NoError(json.Unmarshal(scanner.Bytes(), book))
+				titles = append(titles, book.Title)
+			}
+			suite.Assert().Equal([]string{"The Hobbit <&>", "The Hobbit <&>", "Beowulf", "Beowulf"}, titles)
+
+			body, resp = suite.exportBooks(url + "&format=xlsx")
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(bookio.XLSXMIMEType, resp.Header.Get("Content-Type"))
+			archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+			if suite.Assert().NoError(err) && suite.Assert().Len(archive.File, 5) {
+				sheet, err := archive.File[4].Open()
+				suite.Assert().NoError(err)
+				content, err := ioutil.ReadAll(sheet)
+				suite.Assert().NoError(err)
+				sheet.Close()
+				suite.Assert().Equal("xl/worksheets/sheet1.xml", archive.File[4].Name)
+				suite.Assert().Equal(5, strings.Count(string(content), "<row "))
+				suite.Assert().Contains(string(content), "The Hobbit &lt;&amp;&gt;")
+				suite.Assert().Contains(string(content), `<c r="K2"><v>5</v></c>`)
+			}
+
+			_, resp = suite.exportBooks(url + "&format=pdf")
+			suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+
+			_, status = suite.importBooks(server.TS.URL+"/v1/books:import?format=xlsx", bookio.XLSXMIMEType, "")
+			suite.Assert().Equal(http.StatusUnsupportedMediaType, status)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) TestClassification() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Classification"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+
+			post := func(book *models.Book) *http.Response {
+				jsonValue, _ := json.Marshal(book)
+				resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return resp
+			}
+
+			resp := post(&models.Book{
+				Title:     "The Hobbit",
+				Publisher: publisher,
+				Genres:    []string{" Fantasy", "Fantasy "},
+				Tags:      []string{"Dragons", " dragons", "Quest"},
+				Dewey:     "823.912",
+				LCC:       "pr6039.o32",
+			})
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			book, err := suite.getBookFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			suite.Assert().Equal([]string{"Fantasy"}, book.Genres)
+			suite.Assert().Equal([]string{"dragons", "quest"}, book.Tags)
+			suite.Assert().Equal("PR6039.O32", book.LCC)
+
+			resp = post(&models.Book{Title: "Beowulf", Publisher: publisher, Genres: []string{"Epic"}, Tags: []string{"dragons"}})
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+			resp = post(&models.Book{Title: "invalid", Dewey: "82", LCC: "6039"})
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books/facets?publisher=" + publisher + "&tag=Dragons")
+			if err != nil {
+				t.Fatal(err)
+			}
+			facets := &handlers.Facets{}
+			suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(facets))
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal([]handlers.FacetCount{{Value: "Epic", Count: 1}, {Value: "Fantasy", Count: 1}}, facets.Genres)
+			suite.Assert().Equal([]handlers.FacetCount{{Value: "dragons", Count: 2}, {Value: "quest", Count: 1}}, facets.Tags)
+			suite.Assert().Equal([]handlers.FacetCount{{Value: publisher, Count: 2}}, facets.Publishers)
+
+			resp, err = http.Get(server.TS.URL + "/v1/books?publisher=" + publisher + "&genre=Epic")
+			suite.Assert().NoError(err)
+			books, err := suite.getBooksFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(books.Books, 1) {
+				suite.Assert().Equal("Beowulf", books.Books[0].Title)
+			}
+		})
+	}
+}
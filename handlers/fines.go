@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+type FineList struct {
+	Fines []*models.Fine `json:"fines"`
+	// Outstanding is the total the member still owes.
+	Outstanding int64 `json:"outstanding"`
+}
+
+// AssessFines brings the fines of all overdue loans of every tenant up to
+// date with FinePolicy. It returns the number of loans that carry a fine.
+func (app *App) AssessFines(ctx context.Context, now time.Time) (int, error) {
+	loans, err := app.LoanRepository.OverdueLoans(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	fined := 0
+
+	for _, loan := range loans {
+		fine, err := app.assessFine(db.WithTenant(ctx, loan.Tenant), loan, now)
+		if err != nil {
+			return fined, err
+		}
+
+		if fine != nil {
+			fined++
+		}
+	}
+
+	return fined, nil
+}
+
+// assessFine records the fine of a loan, or returns nil if the loan isn't
+// late enough to be fined.
+func (app *App) assessFine(ctx context.Context, loan *models.Loan, now time.Time) (*models.Fine, error) {
+	amount := app.FinePolicy.Assess(loan, now)
+	if amount == 0 {
+		return nil, nil
+	}
+
+	loanID := db.ID(loan.ID.Hex())
+
+	fine, err := app.FineRepository.LoanFine(ctx, loanID)
+	if errors.Is(err, db.ErrNotFound) {
+		fine = &models.Fine{
+			LoanID:   loan.ID,
+			MemberID: loan.MemberID,
+			BookID:   loan.BookID,
+			Status:   models.FineOutstanding,
+		}
+		fine.Assess(amount, now)
+
+		_, err = app.FineRepository.AddFine(ctx, fine)
+		if !errors.Is(err, db.ErrConflict) {
+			return fine, err
+		}
+
+		// the fine was added concurrently, update it instead
+		fine, err = app.FineRepository.LoanFine(ctx, loanID)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// a fine that stays the same keeps its version, so that the ETags
+	// held by the desk stay valid
+	if assessed := *fine; !assessed.Assess(amount, now) {
+		return fine, nil
+	}
+
+	return app.updateFine(ctx, db.ID(fine.ID.Hex()), func(fine *models.Fine) error {
+		fine.Assess(amount, now)
+		return nil
+	})
+}
+
+// updateFine applies change with UpdateFine, retrying lost compare-and-swap
+// races.
+func (app *App) updateFine(ctx context.Context, id db.ID, change func(fine *models.Fine) error) (*models.Fine, error) {
+	for attempt := 1; ; attempt++ {
+		fine, err := app.FineRepository.UpdateFine(ctx, id, func(fine *models.Fine) (*models.Fine, error) {
+			return fine, change(fine)
+		})
+		if errors.Is(err, db.ErrVersionMismatch) && attempt < maxUpdateAttempts {
+			continue
+		}
+
+		return fine, err
+	}
+}
+
+func (app *App) GetFine(c *gin.Context) {
+	fine, err := app.FineRepository.GetFine(c.Request.Context(), db.ID(c.Param("id")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, fine)
+}
+
+// ListMemberFines lists the fines of a member, the latest first, together
+// with the outstanding total.
+func (app *App) ListMemberFines(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.MemberRepository.GetMember(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	fines, err := app.FineRepository.MemberFines(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	list := FineList{Fines: fines}
+	for _, fine := range fines {
+		list.Outstanding += fine.Outstanding()
+	}
+
+	app.render(c, http.StatusOK, list)
+}
+
+// PayFine takes a payment of at most the outstanding amount of a fine.
+func (app *App) PayFine(c *gin.Context) {
+	var payment models.Payment
+	if err := bind(c, &payment); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	app.settleFine(c, func(fine *models.Fine, now time.Time) error {
+		return fine.Pay(payment, now)
+	})
+}
+
+// WaiveFine lets the member off the rest of a fine.
+func (app *App) WaiveFine(c *gin.Context) {
+	var waiver models.Waiver
+	if err := bind(c, &waiver); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	app.settleFine(c, func(fine *models.Fine, now time.Time) error {
+		return fine.Waive(waiver, now)
+	})
+}
+
+func (app *App) settleFine(c *gin.Context, settle func(fine *models.Fine, now time.Time) error) {
+	now := time.Now().UTC()
+
+	fine, err := app.updateFine(c.Request.Context(), db.ID(c.Param("id")), func(fine *models.Fine) error {
+		if !ifMatch(c, fine.Version) {
+			return db.ErrVersionMismatch
+		}
+
+		return settle(fine, now)
+	})
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, fine)
+}
@@ -0,0 +1,218 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+)
+
+func (suite *BookHandlersTestSuite) getFines(t *testing.T, server TestServer, member *models.Member) *handlers.FineList {
+	t.Helper()
+
+	resp, err := http.Get(server.TS.URL + "/v1/members/" + member.ID.Hex() + "/fines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	fines := &handlers.FineList{}
+	if err := json.NewDecoder(resp.Body).Decode(fines); err != nil {
+		t.Fatal(err)
+	}
+
+	return fines
+}
+
+func (suite *BookHandlersTestSuite) settleFine(t *testing.T, server TestServer, fine *models.Fine, action, body string) (*models.Fine, int) {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/fines/"+fine.ID.Hex()+"/"+action, JSON_HTTP_HEADER, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode
+	}
+
+	settled := new(models.Fine)
+	if err := json.NewDecoder(resp.Body).Decode(settled); err != nil {
+		t.Fatal(err)
+	}
+
+	return settled, resp.StatusCode
+}
+
+// addOverdueLoan records a loan of a checked out book that was due overdue
+// ago, bypassing the checkout which only accepts due dates in the future.
+func (suite *BookHandlersTestSuite) addOverdueLoan(t *testing.T, server TestServer, member *models.Member, overdue time.Duration) *models.Book {
+	t.Helper()
+
+	book := common.CreateRandomBook()
+	book.Status = models.CheckedOut
+	jsonValue, _ := json.Marshal(book)
+
+	resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	book, err = suite.getBookFromResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dueAt := time.Now().UTC().Add(-overdue)
+	_, err = server.App.LoanRepository.AddLoan(context.Background(), &models.Loan{
+		BookID:       book.ID,
+		MemberID:     member.ID,
+		CheckedOutAt: dueAt.Add(-models.DefaultLoanPeriod),
+		DueAt:        dueAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+func (suite *BookHandlersTestSuite) TestFines() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Fines"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			member := suite.createMember(t, server)
+			policy := models.DefaultFinePolicy
+
+			// six started days, well past the grace period
+			book := suite.addOverdueLoan(t, server, member, 5*24*time.Hour+12*time.Hour)
+
+			_, err := server.App.AssessFines(context.Background(), time.Now().UTC())
+			suite.Assert().NoError(err)
+
+			fines := suite.getFines(t, server, member)
+			if !suite.Assert().Len(fines.Fines, 1) {
+				return
+			}
+			fine := fines.Fines[0]
+			suite.Assert().Equal(6*policy.DailyRate, fine.Amount)
+			suite.Assert().Equal(models.FineOutstanding, fine.Status)
+			suite.Assert().Equal(fine.Amount, fines.Outstanding)
+
+			req, err := http.NewRequest(http.MethodDelete, server.TS.URL+"/v1/members/"+member.ID.Hex(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			// the late return keeps the fine that was assessed before
+			resp, err = http.Post(server.TS.URL+"/v1/books/"+book.ID.Hex()+"/checkin", JSON_HTTP_HEADER, nil)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(fine.Amount, suite.getFines(t, server, member).Outstanding)
+
+			for _, body := range []string{`{"amount": 0}`, `{"amount": 100000}`} {
+				_, status := suite.settleFine(t, server, fine, "payments", body)
+				suite.Assert().Equal(http.StatusUnprocessableEntity, status, body)
+			}
+
+			paid, status := suite.settleFine(t, server, fine, "payments", `{"amount": 100}`)
+			suite.Assert().Equal(http.StatusOK, status)
+			if suite.Assert().NotNil(paid) {
+				suite.Assert().Equal(int64(100), paid.Paid)
+				suite.Assert().Equal(models.FineOutstanding, paid.Status)
+			}
+			suite.Assert().Equal(fine.Amount-100, suite.getFines(t, server, member).Outstanding)
+
+			paid, status = suite.settleFine(t, server, fine, "payments", `{"amount": 50}`)
+			suite.Assert().Equal(http.StatusOK, status)
+			if suite.Assert().NotNil(paid) {
+				suite.Assert().Equal(models.FinePaid, paid.Status)
+				suite.Assert().NotNil(paid.SettledAt)
+			}
+
+			_, status = suite.settleFine(t, server, fine, "payments", `{"amount": 1}`)
+			suite.Assert().Equal(http.StatusConflict, status)
+
+			// a long overdue loan is capped
+			suite.addOverdueLoan(t, server, member, 100*24*time.Hour)
+
+			_, err = server.App.AssessFines(context.Background(), time.Now().UTC())
+			suite.Assert().NoError(err)
+
+			fines = suite.getFines(t, server, member)
+			if !suite.Assert().Len(fines.Fines, 2) {
+				return
+			}
+			capped := fines.Fines[0]
+			suite.Assert().Equal(policy.Cap, capped.Amount)
+			suite.Assert().Equal(policy.Cap, fines.Outstanding)
+
+			_, status = suite.settleFine(t, server, capped, "waiver", `{}`)
+			suite.Assert().Equal(http.StatusUnprocessableEntity, status)
+
+			waived, status := suite.settleFine(t, server, capped, "waiver", `{"reason": "book was damaged before"}`)
+			suite.Assert().Equal(http.StatusOK, status)
+			if suite.Assert().NotNil(waived) {
+				suite.Assert().Equal(models.FineWaived, waived.Status)
+			}
+			suite.Assert().Equal(int64(0), suite.getFines(t, server, member).Outstanding)
+
+			// reassessing the still open loan leaves the waiver alone
+			_, err = server.App.AssessFines(context.Background(), time.Now().UTC())
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(int64(0), suite.getFines(t, server, member).Outstanding)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestAssessFinesUnchanged() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("AssessFinesUnchanged"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			member := suite.createMember(t, server)
+			suite.addOverdueLoan(t, server, member, 5*24*time.Hour+12*time.Hour)
+			now := time.Now().UTC()
+
+			_, err := server.App.AssessFines(context.Background(), now)
+			suite.Assert().NoError(err)
+			fines := suite.getFines(t, server, member)
+			if !suite.Assert().Len(fines.Fines, 1) {
+				return
+			}
+			fine := fines.Fines[0]
+
+			// another run at the same time changes nothing
+			_, err = server.App.AssessFines(context.Background(), now)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(fine, suite.getFines(t, server, member).Fines[0])
+
+			// a day later the fine grows
+			_, err = server.App.AssessFines(context.Background(), now.Add(24*time.Hour))
+			suite.Assert().NoError(err)
+			grown := suite.getFines(t, server, member).Fines[0]
+			suite.Assert().Equal(fine.Version+1, grown.Version)
+			suite.Assert().Equal(fine.Amount+models.DefaultFinePolicy.DailyRate, grown.Amount)
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) TestBookHistory() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("BookHistory"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createBook(t, server)
+			url := server.TS.URL + "/v1/books/" + book.ID.Hex()
+
+			req, err := http.NewRequest(http.MethodPatch, url, strings.NewReader(`{"title": "Grendel", "rating": 5}`))
+			suite.Require().NoError(err)
+			req.Header.Set("Content-Type", handlers.MergePatchMIMEType)
+			req.Header.Set(handlers.ActorHeader, "alice")
+			resp, err := http.DefaultClient.Do(req)
+			suite.Require().NoError(err)
+			resp.Body.Close()
+			suite.Require().Equal(http.StatusOK, resp.StatusCode)
+
+			var history handlers.RevisionList
+			resp, raw := suite.request(http.MethodGet, url+"/history", "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().NoError(json.Unmarshal(raw, &history))
+			if suite.Assert().Len(history.Revisions, 2) {
+				suite.Assert().Equal(models.RevisionUpdate, history.Revisions[0].Action)
+				suite.Assert().Equal("alice", history.Revisions[0].Actor)
+				suite.Assert().Equal(handlers.AnonymousActor, history.Revisions[1].Actor)
+				suite.Assert().Contains(string(raw), `"field": "title"`)
+			}
+
+			revert := func(version int64, ifMatch string) (*http.Response, []byte) {
+				jsonValue, _ := json.Marshal(handlers.RevertRequest{Version: version})
+				req, err := http.NewRequest(http.MethodPost, url+"/revert", bytes.NewReader(jsonValue))
+				suite.Require().NoError(err)
+				req.Header.Set("Content-Type", JSON_HTTP_HEADER)
+				if ifMatch != "" {
+					req.Header.Set("If-Match", ifMatch)
+				}
+
+				return suite.do(req)
+			}
+
+			resp, _ = revert(1, `"1"`)
+			suite.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+
+			resp, raw = revert(1, `"2"`)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(`"3"`, resp.Header.Get("ETag"))
+			reverted := &models.Book{}
+			suite.Assert().NoError(json.Unmarshal(raw, reverted))
+			suite.Assert().Equal(book.Title, reverted.Title)
+			suite.Assert().Equal(book.Rating, reverted.Rating)
+
+			resp, raw = suite.request(http.MethodGet, url+"/history", "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().NoError(json.Unmarshal(raw, &history))
+			if suite.Assert().Len(history.Revisions, 3) {
+				suite.Assert().Equal(models.RevisionRevert, history.Revisions[0].Action)
+				suite.Assert().EqualValues(1, history.Revisions[0].RevertedTo)
+			}
+
+			resp, _ = revert(7, "")
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+			resp, _ = revert(0, "")
+			suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode)
+
+			resp, _ = suite.request(http.MethodGet, server.TS.URL+"/v1/books/"+primitive.NewObjectID().Hex()+"/history", "", "", nil)
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// ExpireHolds closes the ready holds that weren't collected in time and
+// keeps their books for the next member in line. It covers every tenant and
+// returns the number of expired holds.
+func (app *App) ExpireHolds(ctx context.Context, now time.Time) (int, error) {
+	holds, err := app.HoldRepository.OverdueHolds(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	expired := 0
+
+	for _, hold := range holds {
+		// the hold, its book and the next hold belong to the same tenant
+		ctx := db.WithTenant(ctx, hold.Tenant)
+
+		_, err := app.updateHold(ctx, db.ID(hold.ID.Hex()), func(hold *models.Hold) error {
+			return hold.Expire(now)
+		})
+		// the hold was collected or cancelled in the meantime
+		if errors.Is(err, models.ErrHoldNotOverdue) {
+			continue
+		} else if err != nil {
+			return expired, err
+		}
+
+		expired++
+
+		app.promoteHold(ctx, db.ID(hold.BookID.Hex()), now)
+	}
+
+	return expired, nil
+}
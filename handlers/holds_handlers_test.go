@@ -0,0 +1,260 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+)
+
+func (suite *BookHandlersTestSuite) createCheckedInBook(t *testing.T, server TestServer) *models.Book {
+	t.Helper()
+
+	book := common.CreateRandomBook()
+	book.Status = models.CheckedIn
+	jsonValue, _ := json.Marshal(book)
+
+	resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+	book, err = suite.getBookFromResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+func (suite *BookHandlersTestSuite) placeHold(t *testing.T, server TestServer, bookID string, member *models.Member) (*models.Hold, int) {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/books/"+bookID+"/holds", JSON_HTTP_HEADER,
+		bytes.NewBufferString(`{"member_id": "`+member.ID.Hex()+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, resp.StatusCode
+	}
+
+	hold := new(models.Hold)
+	if err := json.NewDecoder(resp.Body).Decode(hold); err != nil {
+		t.Fatal(err)
+	}
+
+	return hold, resp.StatusCode
+}
+
+func (suite *BookHandlersTestSuite) getHolds(t *testing.T, url string) []*models.Hold {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	holds := &handlers.HoldList{}
+	if err := json.NewDecoder(resp.Body).Decode(holds); err != nil {
+		t.Fatal(err)
+	}
+
+	return holds.Holds
+}
+
+func (suite *BookHandlersTestSuite) cancelHold(t *testing.T, server TestServer, bookID string, hold *models.Hold) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodDelete, server.TS.URL+"/v1/books/"+bookID+"/holds/"+hold.ID.Hex(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func (suite *BookHandlersTestSuite) TestHoldQueue() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("HoldQueue"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createCheckedInBook(t, server)
+			id := book.ID.Hex()
+			borrower := suite.createMember(t, server)
+			first := suite.createMember(t, server)
+			second := suite.createMember(t, server)
+
+			resp := suite.checkout(t, server, id, `{"member_id": "`+borrower.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+			firstHold, status := suite.placeHold(t, server, id, first)
+			suite.Assert().Equal(http.StatusCreated, status)
+			suite.Assert().Equal(models.HoldWaiting, firstHold.Status)
+
+			secondHold, status := suite.placeHold(t, server, id, second)
+			suite.Assert().Equal(http.StatusCreated, status)
+
+			_, status = suite.placeHold(t, server, id, first)
+			suite.Assert().Equal(http.StatusConflict, status)
+
+			queue := suite.getHolds(t, server.TS.URL+"/v1/books/"+id+"/holds")
+			if suite.Assert().Len(queue, 2) {
+				suite.Assert().Equal(firstHold.ID, queue[0].ID)
+				suite.Assert().Equal(secondHold.ID, queue[1].ID)
+			}
+
+			resp, err := http.Post(server.TS.URL+"/v1/books/"+id+"/checkin", JSON_HTTP_HEADER, nil)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+			queue = suite.getHolds(t, server.TS.URL+"/v1/books/"+id+"/holds")
+			if suite.Assert().Len(queue, 2) {
+				suite.Assert().Equal(models.HoldReady, queue[0].Status)
+				suite.Assert().NotNil(queue[0].PickupBy)
+				suite.Assert().Equal(models.HoldWaiting, queue[1].Status)
+			}
+
+			// the book is kept for the first member in line
+			resp = suite.checkout(t, server, id, `{"member_id": "`+second.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp = suite.checkout(t, server, id, `{"member_id": "`+first.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+			holds := suite.getHolds(t, server.TS.URL+"/v1/members/"+first.ID.Hex()+"/holds")
+			if suite.Assert().Len(holds, 1) {
+				suite.Assert().Equal(models.HoldFulfilled, holds[0].Status)
+			}
+
+			suite.Assert().Equal(http.StatusNoContent, suite.cancelHold(t, server, id, secondHold))
+			suite.Assert().Equal(http.StatusConflict, suite.cancelHold(t, server, id, secondHold))
+			suite.Assert().Empty(suite.getHolds(t, server.TS.URL+"/v1/books/"+id+"/holds"))
+
+			// a hold has to be cancelled through its own book
+			other := suite.createCheckedInBook(t, server)
+			suite.Assert().Equal(http.StatusNotFound, suite.cancelHold(t, server, other.ID.Hex(), firstHold))
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestPlaceHoldConcurrent() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("PlaceHoldConcurrent"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createCheckedInBook(t, server)
+			member := suite.createMember(t, server)
+
+			const attempts = 8
+			statuses := make(chan int, attempts)
+
+			var wg sync.WaitGroup
+			for i := 0; i < attempts; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, status := suite.placeHold(t, server, book.ID.Hex(), member)
+					statuses <- status
+				}()
+			}
+			wg.Wait()
+			close(statuses)
+
+			counts := make(map[int]int)
+			for status := range statuses {
+				counts[status]++
+			}
+			suite.Assert().Equal(1, counts[http.StatusCreated])
+			suite.Assert().Equal(attempts-1, counts[http.StatusConflict])
+			suite.Assert().Len(suite.getHolds(t, server.TS.URL+"/v1/books/"+book.ID.Hex()+"/holds"), 1)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestHoldExpiry() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("HoldExpiry"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			book := suite.createCheckedInBook(t, server)
+			id := book.ID.Hex()
+			first := suite.createMember(t, server)
+			second := suite.createMember(t, server)
+
+			// a book on the shelf is ready for pickup right away
+			firstHold, status := suite.placeHold(t, server, id, first)
+			suite.Assert().Equal(http.StatusCreated, status)
+			suite.Assert().Equal(models.HoldReady, firstHold.Status)
+
+			_, status = suite.placeHold(t, server, id, second)
+			suite.Assert().Equal(http.StatusCreated, status)
+
+			expired, err := server.App.ExpireHolds(context.Background(), time.Now().UTC())
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(0, expired)
+
+			// move the pickup deadline into the past rather than expiring at a
+			// later time, which would touch the holds of the other tests
+			_, err = server.App.HoldRepository.UpdateHold(context.Background(), db.ID(firstHold.ID.Hex()),
+				func(hold *models.Hold) (*models.Hold, error) {
+					pickupBy := time.Now().UTC().Add(-time.Minute)
+					hold.PickupBy = &pickupBy
+
+					return hold, nil
+				})
+			suite.Assert().NoError(err)
+
+			expired, err = server.App.ExpireHolds(context.Background(), time.Now().UTC())
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(1, expired)
+
+			holds := suite.getHolds(t, server.TS.URL+"/v1/members/"+first.ID.Hex()+"/holds")
+			if suite.Assert().Len(holds, 1) {
+				suite.Assert().Equal(models.HoldExpired, holds[0].Status)
+			}
+
+			queue := suite.getHolds(t, server.TS.URL+"/v1/books/"+id+"/holds")
+			if suite.Assert().Len(queue, 1) {
+				suite.Assert().Equal(second.ID, queue[0].MemberID)
+				suite.Assert().Equal(models.HoldReady, queue[0].Status)
+			}
+
+			resp := suite.checkout(t, server, id, `{"member_id": "`+first.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/iho/booksdb/bookio"
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/handlers"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.uber.org/zap"
+)
+
+func (suite *BookHandlersTestSuite) importBooks(url, contentType, body string) (*bookio.ImportReport, int) {
+	resp, err := http.Post(url, contentType, strings.NewReader(body))
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	report := &bookio.ImportReport{}
+	if resp.StatusCode == http.StatusOK {
+		suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(report))
+	}
+
+	return report, resp.StatusCode
+}
+
+func (suite *BookHandlersTestSuite) TestImportBooks() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("ImportBooks"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			isbn := common.CreateRandomISBN()
+
+			csv := "isbn,title,publisher,contributors,tags\n" +
+				isbn + ",The Hobbit," + publisher + ",J. R. R. Tolkien;Alan Lee (Illustrator),Dragons;quest\n" +
+				",," + publisher + ",,\n" +
+				isbn + ",Duplicate," + publisher + ",,\n" +
+				",\"Two\nLines\"," + publisher + ",,\n"
+
+			report, status := suite.importBooks(server.TS.URL+"/v1/books:import", bookio.CSVMIMEType, csv)
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().Equal(2, report.Imported)
+			suite.Assert().Equal(2, report.Failed)
+			if suite.Assert().Len(report.Rows, 4) {
+				suite.Assert().Equal(2, report.Rows[0].Line)
+				suite.Assert().NotEmpty(report.Rows[0].ID)
+				suite.Assert().Equal(3, report.Rows[1].Line)
+				if suite.Assert().Len(report.Rows[1].Errors, 1) {
+					suite.Assert().Equal("title", report.Rows[1].Errors[0].Field)
+				}
+				suite.Assert().Equal(4, report.Rows[2].Line)
+				suite.Assert().Contains(report.Rows[2].Error, "already exists")
+				suite.Assert().Equal(5, report.Rows[3].Line)
+				suite.Assert().NotEmpty(report.Rows[3].ID)
+			}
+
+			resp, err := http.Get(server.TS.URL + "/v1/books?publisher=" + publisher + "&sort=title")
+			suite.Assert().NoError(err)
+			books, err := suite.getBooksFromResponse(resp)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(books.Books, 2) {
+				suite.Assert().Equal("The Hobbit", books.Books[0].Title)
+				suite.Assert().Equal("J. R. R. Tolkien", books.Books[0].Author)
+				suite.Assert().Equal([]string{"dragons", "quest"}, books.Books[0].Tags)
+				suite.Assert().Equal("Two\nLines", books.Books[1].Title)
+			}
+
+			ndjson := `{"title": "Beowulf", "publisher": "` + publisher + `"}` + "\n" +
+				"\n" +
+				`{"title": ` + "\n" +
+				`{"title": "Grendel", "rating": 9}`
+
+			report, status = suite.importBooks(server.TS.URL+"/v1/books:import?format=ndjson", "text/plain", ndjson)
+			suite.Assert().Equal(http.StatusOK, status)
+			suite.Assert().Equal(1, report.Imported)
+			suite.Assert().Equal(2, report.Failed)
+			if suite.Assert().Len(report.Rows, 3) {
+				suite.Assert().Equal(1, report.Rows[0].Line)
+				suite.Assert().Equal(3, report.Rows[1].Line)
+				suite.Assert().NotEmpty(report.Rows[1].Error)
+				suite.Assert().Equal(4, report.Rows[2].Line)
+				suite.Assert().NotEmpty(report.Rows[2].Errors)
+			}
+
+			_, status = suite.importBooks(server.TS.URL+"/v1/books:import", "application/pdf", "")
+			suite.Assert().Equal(http.StatusUnsupportedMediaType, status)
+
+			_, status = suite.importBooks(server.TS.URL+"/v1/books:import", bookio.CSVMIMEType, "title,pages\nx,1\n")
+			suite.Assert().Equal(http.StatusBadRequest, status)
+
+			_, status = suite.importBooks(server.TS.URL+"/v1/books:export", JSON_HTTP_HEADER, "")
+			suite.Assert().Equal(http.StatusNotFound, status)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestStreamTimeouts() {
+	t := suite.T()
+	t.Parallel()
+
+	app := handlers.NewApp(db.NewMemoryRepositories(), zapr.NewLogger(zap.NewNop()))
+	app.StreamTimeout = 0
+	server := httptest.NewUnstartedServer(handlers.SetupRouter(app))
+	server.Config.ReadTimeout = 100 * time.Millisecond
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Config.ConnContext = handlers.ConnContext
+	server.Start()
+	defer server.Close()
+
+	// slowPost sends the body in two parts that are further apart than the
+	// timeouts of the server
+	slowPost := func(url, contentType, first, second string) (*http.Response, error) {
+		body, writer := io.Pipe()
+		go func() {
+			writer.Write([]byte(first)) //nolint:errcheck
+			time.Sleep(300 * time.Millisecond)
+			writer.Write([]byte(second)) //nolint:errcheck
+			writer.Close()
+		}()
+
+		return http.Post(url, contentType, body)
+	}
+
+	resp, err := slowPost(server.URL+"/v1/books:import", bookio.CSVMIMEType, "title\nBeowulf\n", "Grendel\n")
+	suite.Require().NoError(err)
+	report := &bookio.ImportReport{}
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	suite.Assert().NoError(json.NewDecoder(resp.Body).Decode(report))
+	resp.Body.Close()
+	suite.Assert().Equal(2, report.Imported)
+
+	// other requests keep the timeouts
+	resp, err = slowPost(server.URL+"/v1/books", JSON_HTTP_HEADER, `{"title": `, `"Grendel"}`)
+	if err == nil {
+		resp.Body.Close()
+		suite.Assert().NotEqual(http.StatusCreated, resp.StatusCode)
+	}
+
+	resp, err = http.Get(server.URL + "/v1/books/export?format=ndjson")
+	suite.Require().NoError(err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	suite.Assert().NoError(err)
+	suite.Assert().Equal(2, strings.Count(string(body), "\n"))
+	suite.Assert().Equal("2", resp.Trailer.Get(handlers.BookCountTrailer))
+}
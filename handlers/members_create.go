@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/models"
+)
+
+// CreateMember registers a member. The membership runs for
+// models.DefaultMembershipPeriod and allows models.DefaultBorrowingLimit
+// loans unless the request says otherwise.
+func (app *App) CreateMember(c *gin.Context) {
+	member := new(models.Member)
+
+	if err := bind(c, member); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	member.Normalize(time.Now())
+	member.Loans = 0
+
+	if err := member.Validate(); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if _, err := app.MemberRepository.AddMember(c.Request.Context(), member); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(member.Version))
+	app.render(c, http.StatusCreated, member)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+)
+
+var (
+	errMemberHasLoans = errors.New("member still has books checked out")
+	errMemberHasFines = errors.New("member still has outstanding fines")
+)
+
+// DeleteMember refuses to delete members who haven't returned their books
+// or paid their fines and cancels the holds of the deleted member.
+func (app *App) DeleteMember(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	member, err := app.MemberRepository.GetMember(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	if !ifMatch(c, member.Version) {
+		app.abortWithError(c, db.ErrVersionMismatch)
+		return
+	}
+
+	if member.Loans > 0 {
+		app.abortWithError(c, errMemberHasLoans)
+		return
+	}
+
+	fines, err := app.FineRepository.MemberFines(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	for _, fine := range fines {
+		if fine.Outstanding() > 0 {
+			app.abortWithError(c, errMemberHasFines)
+			return
+		}
+	}
+
+	if err := app.MemberRepository.DeleteMember(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	holds, err := app.HoldRepository.MemberHolds(c.Request.Context(), id)
+	if err != nil {
+		app.Logger.Error(err, "can't cancel the holds of a deleted member", "member", id)
+	}
+
+	now := time.Now().UTC()
+
+	for _, hold := range holds {
+		if !hold.Active() {
+			continue
+		}
+
+		if err := app.cancelHold(c.Request.Context(), hold, now); err != nil {
+			app.Logger.Error(err, "can't cancel the hold of a deleted member", "hold", hold.ID.Hex())
+		}
+	}
+
+	c.Status(http.StatusNoContent)
+}
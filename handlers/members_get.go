@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+)
+
+func (app *App) GetMember(c *gin.Context) {
+	member, err := app.MemberRepository.GetMember(c.Request.Context(), db.ID(c.Param("id")))
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(member.Version))
+
+	if ifNoneMatch(c, member.Version) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	app.render(c, http.StatusOK, member)
+}
@@ -0,0 +1,280 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) postMember(t *testing.T, server TestServer, body string) *http.Response {
+	t.Helper()
+
+	resp, err := http.Post(server.TS.URL+"/v1/members", JSON_HTTP_HEADER, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return resp
+}
+
+func (suite *BookHandlersTestSuite) decodeMember(t *testing.T, resp *http.Response) *models.Member {
+	t.Helper()
+
+	defer resp.Body.Close()
+
+	member := new(models.Member)
+	if err := json.NewDecoder(resp.Body).Decode(member); err != nil {
+		t.Fatal(err)
+	}
+
+	return member
+}
+
+func (suite *BookHandlersTestSuite) createMember(t *testing.T, server TestServer) *models.Member {
+	t.Helper()
+
+	resp := suite.postMember(t, server, fmt.Sprintf(`{"name": "Reader", "email": "%s@example.com"}`,
+		primitive.NewObjectID().Hex()))
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+	return suite.decodeMember(t, resp)
+}
+
+func (suite *BookHandlersTestSuite) getMember(t *testing.T, server TestServer, id string) *models.Member {
+	t.Helper()
+
+	resp, err := http.Get(server.TS.URL + "/v1/members/" + id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+	return suite.decodeMember(t, resp)
+}
+
+func (suite *BookHandlersTestSuite) TestMembers() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Members"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			email := primitive.NewObjectID().Hex() + "@Example.com"
+
+			resp := suite.postMember(t, server, `{"name": "Reader", "email": "`+email+`", "loans": 3}`)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+			suite.Assert().Equal(`"1"`, resp.Header.Get("ETag"))
+			member := suite.decodeMember(t, resp)
+			suite.Assert().Equal(strings.ToLower(email), member.Email)
+			suite.Assert().Equal(models.DefaultBorrowingLimit, member.BorrowingLimit)
+			suite.Assert().Equal(0, member.Loans)
+			suite.Assert().WithinDuration(time.Now().Add(models.DefaultMembershipPeriod), member.ExpiresAt, time.Minute)
+
+			resp = suite.postMember(t, server, `{"name": "Someone else", "email": "`+email+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err := http.Get(server.TS.URL + "/v1/members?limit=1000")
+			suite.Assert().NoError(err)
+			members := &handlers.MemberList{}
+			err = json.NewDecoder(resp.Body).Decode(members)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			found := false
+			for _, listed := range members.Members {
+				found = found || listed.ID == member.ID
+			}
+			suite.Assert().True(found)
+
+			req, err := http.NewRequest(http.MethodPut, server.TS.URL+"/v1/members/"+member.ID.Hex(),
+				bytes.NewBufferString(`{"name": "Renamed", "email": "`+email+`", "borrowing_limit": 2}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("If-Match", `"1"`)
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			updated := suite.decodeMember(t, resp)
+			suite.Assert().Equal("Renamed", updated.Name)
+			suite.Assert().Equal(2, updated.BorrowingLimit)
+
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusPreconditionFailed, resp.StatusCode)
+
+			req, err = http.NewRequest(http.MethodDelete, server.TS.URL+"/v1/members/"+member.ID.Hex(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusNoContent, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/members/" + member.ID.Hex())
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestListMembersPages() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("ListMembersPages"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			tenant := "members-" + primitive.NewObjectID().Hex()
+			send := func(method, path, body string) *http.Response {
+				req, err := http.NewRequest(method, server.TS.URL+path, bytes.NewBufferString(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Content-Type", JSON_HTTP_HEADER)
+				req.Header.Set(handlers.TenantHeader, tenant)
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return resp
+			}
+			list := func(path string) *handlers.MemberList {
+				resp := send(http.MethodGet, path, "")
+				defer resp.Body.Close()
+				suite.Require().Equal(http.StatusOK, resp.StatusCode)
+				members := &handlers.MemberList{}
+				suite.Require().NoError(json.NewDecoder(resp.Body).Decode(members))
+
+				return members
+			}
+
+			var ids []primitive.ObjectID
+			for i := 0; i < 3; i++ {
+				resp := send(http.MethodPost, "/v1/members",
+					`{"name": "Reader", "email": "`+primitive.NewObjectID().Hex()+`@example.com"}`)
+				suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+				ids = append(ids, suite.decodeMember(t, resp).ID)
+			}
+
+			members := list("/v1/members?limit=2")
+			if suite.Assert().Len(members.Members, 2) {
+				suite.Assert().Equal(ids[0], members.Members[0].ID)
+				suite.Assert().Equal(ids[1], members.Members[1].ID)
+			}
+			suite.Assert().NotEmpty(members.NextCursor)
+
+			members = list("/v1/members?limit=2&cursor=" + members.NextCursor)
+			if suite.Assert().Len(members.Members, 1) {
+				suite.Assert().Equal(ids[2], members.Members[0].ID)
+			}
+			suite.Assert().Empty(members.NextCursor)
+
+			for _, path := range []string{"/v1/members?limit=many", "/v1/members?cursor=nope"} {
+				resp := send(http.MethodGet, path, "")
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode, path)
+			}
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestCreateMemberInvalid() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("CreateMemberInvalid"+server.Name, func(t *testing.T) {
+			t.Parallel()
+
+			for _, body := range []string{
+				`{"name": "Reader"}`,
+				`{"name": "Reader", "email": "not an email"}`,
+				`{"email": "reader@example.com"}`,
+				`{"name": "Reader", "email": "reader@example.com", "borrowing_limit": -1}`,
+			} {
+				resp := suite.postMember(t, server, body)
+				resp.Body.Close()
+				suite.Assert().Equal(http.StatusUnprocessableEntity, resp.StatusCode, body)
+			}
+		})
+	}
+}
+
+func (suite *BookHandlersTestSuite) TestMemberBorrowing() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("MemberBorrowing"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			books := make([]*models.Book, 2)
+			for i := range books {
+				book := common.CreateRandomBook()
+				book.Status = models.CheckedIn
+				jsonValue, _ := json.Marshal(book)
+				resp, err := http.Post(server.TS.URL+"/v1/books", JSON_HTTP_HEADER, bytes.NewBuffer(jsonValue))
+				suite.Assert().NoError(err)
+				books[i], err = suite.getBookFromResponse(resp)
+				suite.Assert().NoError(err)
+			}
+
+			resp := suite.postMember(t, server, fmt.Sprintf(`{"name": "Reader", "email": "%s@example.com", "borrowing_limit": 1}`,
+				primitive.NewObjectID().Hex()))
+			member := suite.decodeMember(t, resp)
+			body := `{"member_id": "` + member.ID.Hex() + `"}`
+
+			resp = suite.checkout(t, server, books[0].ID.Hex(), body)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+
+			resp = suite.checkout(t, server, books[1].ID.Hex(), body)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			req, err := http.NewRequest(http.MethodDelete, server.TS.URL+"/v1/members/"+member.ID.Hex(), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err = http.DefaultClient.Do(req)
+			suite.Assert().NoError(err)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+
+			resp, err = http.Get(server.TS.URL + "/v1/members/" + member.ID.Hex() + "/loans")
+			suite.Assert().NoError(err)
+			loans := &handlers.LoanList{}
+			err = json.NewDecoder(resp.Body).Decode(loans)
+			resp.Body.Close()
+			suite.Assert().NoError(err)
+			if suite.Assert().Len(loans.Loans, 1) {
+				suite.Assert().Equal(books[0].ID, loans.Loans[0].BookID)
+			}
+
+			resp = suite.postMember(t, server, fmt.Sprintf(`{"name": "Former reader", "email": "%s@example.com", "expires_at": "2000-01-01T00:00:00Z"}`,
+				primitive.NewObjectID().Hex()))
+			expired := suite.decodeMember(t, resp)
+
+			resp = suite.checkout(t, server, books[1].ID.Hex(), `{"member_id": "`+expired.ID.Hex()+`"}`)
+			resp.Body.Close()
+			suite.Assert().Equal(http.StatusConflict, resp.StatusCode)
+		})
+	}
+}
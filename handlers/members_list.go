@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+type MemberList struct {
+	Members    []*models.Member `json:"members"`
+	NextCursor string           `json:"next_cursor,omitempty"`
+}
+
+// ListMembers lists the members in the order they were added:
+//
+//	?limit=20&cursor=
+func (app *App) ListMembers(c *gin.Context) {
+	query := db.MemberQuery{Cursor: c.Query("cursor")}
+
+	if limit, err := intParam(c, "limit"); err != nil {
+		app.problem(c, http.StatusBadRequest, err.Error())
+		return
+	} else if limit != nil {
+		query.Limit = *limit
+	}
+
+	page, err := app.MemberRepository.QueryMembers(c.Request.Context(), query)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, MemberList{Members: page.Members, NextCursor: page.NextCursor})
+}
+
+// ListMemberLoans lists the loans of a member, the latest first.
+func (app *App) ListMemberLoans(c *gin.Context) {
+	id := db.ID(c.Param("id"))
+
+	if _, err := app.MemberRepository.GetMember(c.Request.Context(), id); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	loans, err := app.LoanRepository.MemberLoans(c.Request.Context(), id)
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	app.render(c, http.StatusOK, LoanList{Loans: loans})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/models"
+)
+
+// UpdateMember replaces a member. The number of current loans is kept, it
+// only changes through checkouts and checkins.
+func (app *App) UpdateMember(c *gin.Context) {
+	member := new(models.Member)
+
+	if err := bind(c, member); err != nil {
+		app.bindError(c, err)
+		return
+	}
+
+	member.Normalize(time.Now())
+
+	if err := member.Validate(); err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	member, err := app.MemberRepository.UpdateMember(c.Request.Context(), db.ID(c.Param("id")),
+		func(oldMember *models.Member) (*models.Member, error) {
+			if !ifMatch(c, oldMember.Version) {
+				return nil, db.ErrVersionMismatch
+			}
+
+			member.Loans = oldMember.Loans
+
+			return member, nil
+		})
+	if err != nil {
+		app.abortWithError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(member.Version))
+	app.render(c, http.StatusOK, member)
+}
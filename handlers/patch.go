@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	MergePatchMIMEType = "application/merge-patch+json"
+	JSONPatchMIMEType  = "application/json-patch+json"
+)
+
+var (
+	errPatchTestFailed = errors.New("test operation failed")
+	errPatchPath       = errors.New("path can't be resolved")
+	errPatchResult     = errors.New("patched document is not a valid book")
+)
+
+// mergePatch applies an RFC 7396 JSON Merge Patch to a decoded JSON
+// document: objects are merged recursively, null removes a member and
+// anything else replaces the target.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+		} else {
+			targetObject[key] = mergePatch(targetObject[key], value)
+		}
+	}
+
+	return targetObject
+}
+
+type patchOperation struct {
+	Op    string
+	Path  []string
+	From  []string
+	Value interface{}
+}
+
+// parseJSONPatch decodes and validates an RFC 6902 JSON Patch document.
+func parseJSONPatch(body []byte) ([]patchOperation, error) {
+	var raw []map[string]json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("patch must be an array of operations: %w", err)
+	}
+
+	operations := make([]patchOperation, 0, len(raw))
+
+	for i, fields := range raw {
+		operation, err := parsePatchOperation(fields)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+
+		operations = append(operations, operation)
+	}
+
+	return operations, nil
+}
+
+func parsePatchOperation(fields map[string]json.RawMessage) (patchOperation, error) {
+	var (
+		operation patchOperation
+		path      string
+	)
+
+	if err := json.Unmarshal(fields["op"], &operation.Op); err != nil {
+		return operation, errors.New(`"op" must be a string`)
+	}
+
+	if err := json.Unmarshal(fields["path"], &path); err != nil {
+		return operation, errors.New(`"path" must be a string`)
+	}
+
+	var err error
+	if operation.Path, err = parseJSONPointer(path); err != nil {
+		return operation, err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		value, ok := fields["value"]
+		if !ok {
+			return operation, fmt.Errorf(`%q requires "value"`, operation.Op)
+		}
+
+		if err := json.Unmarshal(value, &operation.Value); err != nil {
+			return operation, fmt.Errorf(`"value" is not valid JSON: %w`, err)
+		}
+	case "move", "copy":
+		var from string
+		if err := json.Unmarshal(fields["from"], &from); err != nil {
+			return operation, fmt.Errorf(`%q requires "from"`, operation.Op)
+		}
+
+		if operation.From, err = parseJSONPointer(from); err != nil {
+			return operation, err
+		}
+	case "remove":
+	default:
+		return operation, fmt.Errorf("unknown operation %q", operation.Op)
+	}
+
+	return operation, nil
+}
+
+// parseJSONPointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parseJSONPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("JSON pointer %q must start with /", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// applyJSONPatch applies the operations in order and returns the patched
+// document. The patch is atomic: any failing operation fails the whole
+// patch.
+func applyJSONPatch(doc interface{}, operations []patchOperation) (interface{}, error) {
+	var err error
+
+	for i, operation := range operations {
+		doc, err = applyPatchOperation(doc, operation)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s): %w", i, operation.Op, err)
+		}
+	}
+
+	return doc, nil
+}
+
+func applyPatchOperation(doc interface{}, operation patchOperation) (interface{}, error) {
+	switch operation.Op {
+	case "add":
+		return addValue(doc, operation.Path, operation.Value)
+	case "remove":
+		doc, _, err := removeValue(doc, operation.Path)
+
+		return doc, err
+	case "replace":
+		doc, _, err := removeValue(doc, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, operation.Path, operation.Value)
+	case "move":
+		if isPrefix(operation.From, operation.Path) && len(operation.From) < len(operation.Path) {
+			return nil, errors.New("can't move a value into one of its children")
+		}
+
+		doc, value, err := removeValue(doc, operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, operation.Path, value)
+	case "copy":
+		value, err := getValue(doc, operation.From)
+		if err != nil {
+			return nil, err
+		}
+
+		return addValue(doc, operation.Path, deepCopy(value))
+	case "test":
+		value, err := getValue(doc, operation.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !reflect.DeepEqual(value, operation.Value) {
+			return nil, errPatchTestFailed
+		}
+
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", operation.Op)
+}
+
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch container := doc.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: no member %q", errPatchPath, token)
+			}
+
+			doc = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, err
+			}
+
+			doc = container[index]
+		default:
+			return nil, fmt.Errorf("%w: %q is not inside a container", errPatchPath, token)
+		}
+	}
+
+	return doc, nil
+}
+
+// addValue returns doc with value added at path. Arrays are copied on
+// write because inserting may reallocate them.
+func addValue(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			container[token] = value
+
+			return container, nil
+		}
+
+		child, ok := container[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: no member %q", errPatchPath, token)
+		}
+
+		child, err := addValue(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+
+		container[token] = child
+
+		return container, nil
+	case []interface{}:
+		if len(rest) == 0 {
+			index := len(container)
+			if token != "-" {
+				var err error
+				if index, err = arrayIndex(token, len(container)); err != nil {
+					return nil, err
+				}
+			}
+
+			result := make([]interface{}, 0, len(container)+1)
+			result = append(result, container[:index]...)
+			result = append(result, value)
+
+			return append(result, container[index:]...), nil
+		}
+
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, err
+		}
+
+		child, err := addValue(container[index], rest, value)
+		if err != nil {
+			return nil, err
+		}
+
+		container[index] = child
+
+		return container, nil
+	}
+
+	return nil, fmt.Errorf("%w: %q is not inside a container", errPatchPath, token)
+}
+
+// removeValue returns doc without the value at path and the removed value.
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch container := doc.(type) {
+	case map[string]interface{}:
+		child, ok := container[token]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: no member %q", errPatchPath, token)
+		}
+
+		if len(rest) == 0 {
+			delete(container, token)
+
+			return container, child, nil
+		}
+
+		child, removed, err := removeValue(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		container[token] = child
+
+		return container, removed, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(rest) == 0 {
+			removed := container[index]
+			result := make([]interface{}, 0, len(container)-1)
+			result = append(result, container[:index]...)
+
+			return append(result, container[index+1:]...), removed, nil
+		}
+
+		child, removed, err := removeValue(container[index], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		container[index] = child
+
+		return container, removed, nil
+	}
+
+	return nil, nil, fmt.Errorf("%w: %q is not inside a container", errPatchPath, token)
+}
+
+func arrayIndex(token string, last int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("%w: %q is not an array index", errPatchPath, token)
+	}
+
+	if index > last {
+		return 0, fmt.Errorf("%w: index %d is out of bounds", errPatchPath, index)
+	}
+
+	return index, nil
+}
+
+func isPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func deepCopy(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[key] = deepCopy(item)
+		}
+
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, item := range value {
+			result[i] = deepCopy(item)
+		}
+
+		return result
+	}
+
+	return value
+}
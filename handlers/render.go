@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v2"
+)
+
+// Media types of the representations besides JSON. Their aliases are
+// accepted as well.
+const (
+	XMLMIMEType     = "application/xml"
+	YAMLMIMEType    = "application/yaml"
+	MsgPackMIMEType = "application/msgpack"
+
+	ProblemXMLMIMEType = "application/problem+xml"
+)
+
+var errUnsupportedMediaType = errors.New("unsupported media type")
+
+// maxBodySize limits the bodies of requests that are read as a whole, a
+// full batch of books fits easily. Imports are streamed and not limited.
+const maxBodySize = 8 << 20
+
+type representation int
+
+const (
+	representationJSON representation = iota
+	// compact JSON is asked for with application/json;compact=true
+	representationCompactJSON
+	representationXML
+	representationYAML
+	representationMsgPack
+)
+
+//nolint:gochecknoglobals
+var representations = map[string]representation{
+	binding.MIMEJSON:          representationJSON,
+	XMLMIMEType:               representationXML,
+	"text/xml":                representationXML,
+	YAMLMIMEType:              representationYAML,
+	"application/x-yaml":      representationYAML,
+	"text/yaml":               representationYAML,
+	"text/x-yaml":             representationYAML,
+	MsgPackMIMEType:           representationMsgPack,
+	"application/x-msgpack":   representationMsgPack,
+	"application/vnd.msgpack": representationMsgPack,
+	"application/*":           representationJSON,
+	"*/*":                     representationJSON,
+	ProblemMIMEType:           representationJSON,
+	ProblemXMLMIMEType:        representationXML,
+}
+
+// msgpackHandle writes strings and binary data in the current MessagePack
+// spec and reads maps with string keys.
+//
+//nolint:gochecknoglobals
+var msgpackHandle = func() *codec.MsgpackHandle {
+	handle := new(codec.MsgpackHandle)
+	handle.WriteExt = true
+	handle.RawToString = true
+	handle.MapType = reflect.TypeOf(map[string]interface{}(nil))
+
+	return handle
+}()
+
+// negotiate picks the representation with the highest quality in an Accept
+// header, the first one listed if there is a tie. Indented JSON is the
+// default if the header is missing.
+func negotiate(accept string) (representation, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return representationJSON, true
+	}
+
+	var (
+		best    representation
+		quality float64
+		found   bool
+	)
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		format, ok := representations[mediaType]
+		if !ok || q <= quality {
+			continue
+		}
+
+		if format == representationJSON && params["compact"] == "true" {
+			format = representationCompactJSON
+		}
+
+		best, quality, found = format, q, true
+	}
+
+	return best, found
+}
+
+// render responds with value in the representation asked for by the Accept
+// header, or with 406 Not Acceptable if none of them can be produced.
+func (app *App) render(c *gin.Context, status int, value interface{}) {
+	format, ok := negotiate(c.GetHeader("Accept"))
+	if !ok {
+		app.problem(c, http.StatusNotAcceptable, "responses can be JSON, XML, YAML or MessagePack")
+		return
+	}
+
+	c.Header("Vary", "Accept")
+	app.encode(c, status, format, value, "")
+}
+
+// encode writes value as format. contentType overrides the media type of
+// the format.
+func (app *App) encode(c *gin.Context, status int, format representation, value interface{}, contentType string) {
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+
+	switch format {
+	case representationJSON:
+		c.IndentedJSON(status, value)
+		return
+	case representationCompactJSON:
+		c.JSON(status, value)
+		return
+	}
+
+	doc, err := toDocument(value)
+	if err != nil {
+		app.Logger.Error(err, "can't render a response", "path", c.Request.URL.Path)
+		c.AbortWithStatus(http.StatusInternalServerError)
+
+		return
+	}
+
+	var output documentRender
+
+	switch format {
+	case representationXML:
+		root := xmlRootName(value)
+		output = documentRender{mimeType: XMLMIMEType + "; charset=utf-8", write: func(w io.Writer) error {
+			return writeXML(w, root, doc)
+		}}
+	case representationYAML:
+		output = documentRender{mimeType: YAMLMIMEType + "; charset=utf-8", write: func(w io.Writer) error {
+			raw, err := yaml.Marshal(yamlValue(doc))
+			if err != nil {
+				return fmt.Errorf("can't write a response: %w", err)
+			}
+
+			_, err = w.Write(raw)
+
+			return err
+		}}
+	default:
+		output = documentRender{mimeType: MsgPackMIMEType, write: func(w io.Writer) error {
+			return codec.NewEncoder(w, msgpackHandle).Encode(msgpackValue(doc))
+		}}
+	}
+
+	c.Render(status, output)
+}
+
+// documentRender is a gin renderer. It keeps a Content-Type already set.
+type documentRender struct {
+	mimeType string
+	write    func(w io.Writer) error
+}
+
+func (r documentRender) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+
+	return r.write(w)
+}
+
+func (r documentRender) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", r.mimeType)
+	}
+}
+
+// bind decodes the request body into obj according to its Content-Type,
+// JSON if there is none. Other representations are converted into JSON
+// first, so every one of them is bound the same way.
+func bind(c *gin.Context, obj interface{}) error {
+	limitBody(c)
+
+	contentType := c.ContentType()
+	if contentType == "" {
+		return c.ShouldBindJSON(obj)
+	}
+
+	format, ok := representations[contentType]
+	if !ok || strings.Contains(contentType, "*") {
+		return fmt.Errorf("%w %q", errUnsupportedMediaType, contentType)
+	}
+
+	var (
+		value interface{}
+		err   error
+	)
+
+	switch format {
+	case representationJSON, representationCompactJSON:
+		return c.ShouldBindJSON(obj)
+	case representationXML:
+		value, err = readXML(c.Request.Body)
+	case representationYAML:
+		var raw []byte
+		if raw, err = ioutil.ReadAll(c.Request.Body); err == nil {
+			err = yaml.Unmarshal(raw, &value)
+		}
+	case representationMsgPack:
+		err = codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(&value)
+	}
+
+	if err != nil {
+		return fmt.Errorf("can't decode the request body: %w", err)
+	}
+
+	if value, err = coerce(plainValue(value), reflect.TypeOf(obj)); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("can't decode the request body: %w", err)
+	}
+
+	return binding.JSON.BindBody(raw, obj)
+}
+
+// bindError responds to a request whose body can't be bound.
+func (app *App) bindError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+
+	switch {
+	case errors.Is(err, errUnsupportedMediaType):
+		status = http.StatusUnsupportedMediaType
+	case isBodyTooLarge(err):
+		app.bodyTooLarge(c)
+		return
+	}
+
+	app.problem(c, status, err.Error())
+}
+
+// limitBody makes reading more than maxBodySize of the request body fail,
+// see isBodyTooLarge.
+func limitBody(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBodySize)
+}
+
+// isBodyTooLarge reports whether err comes from reading past the limit set
+// by limitBody. The error of http.MaxBytesReader can only be told apart by
+// its text before Go 1.19.
+func isBodyTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "http: request body too large")
+}
+
+func (app *App) bodyTooLarge(c *gin.Context) {
+	app.problem(c, http.StatusRequestEntityTooLarge,
+		"the request body is larger than "+strconv.Itoa(maxBodySize)+" bytes")
+}
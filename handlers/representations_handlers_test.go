@@ -0,0 +1,145 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/iho/booksdb/common"
+	"github.com/iho/booksdb/handlers"
+	"github.com/ugorji/go/codec"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v2"
+)
+
+func (suite *BookHandlersTestSuite) request(method, url, contentType, accept string, body io.Reader) (*http.Response, []byte) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	return suite.do(req)
+}
+
+// do sends req and reads the whole response.
+func (suite *BookHandlersTestSuite) do(req *http.Request) (*http.Response, []byte) {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		suite.T().Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	suite.Assert().NoError(err)
+
+	return resp, raw
+}
+
+func (suite *BookHandlersTestSuite) TestRepresentations() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Representations"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			books := server.TS.URL + "/v1/books"
+
+			xmlBody := `<book><title>The Hobbit &amp; more</title><publisher>` + publisher + `</publisher>` +
+				`<rating>4</rating><tags><item>Dragons</item></tags>` +
+				`<contributors><item><name>J. R. R. Tolkien</name><role>Author</role></item></contributors></book>`
+			resp, raw := suite.request(http.MethodPost, books, handlers.XMLMIMEType, handlers.XMLMIMEType, strings.NewReader(xmlBody))
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode, string(raw))
+			suite.Assert().Equal("application/xml; charset=utf-8", resp.Header.Get("Content-Type"))
+			suite.Assert().Contains(resp.Header.Get("Vary"), "Accept")
+
+			var created struct {
+				XMLName xml.Name `xml:"book"`
+				ID      string   `xml:"id"`
+				Title   string   `xml:"title"`
+				Author  string   `xml:"author"`
+				Rating  int      `xml:"rating"`
+				Tags    []string `xml:"tags>item"`
+			}
+			suite.Assert().NoError(xml.Unmarshal(raw, &created))
+			suite.Assert().Len(created.ID, 24)
+			suite.Assert().Equal("The Hobbit & more", created.Title)
+			suite.Assert().Equal("J. R. R. Tolkien", created.Author)
+			suite.Assert().Equal(4, created.Rating)
+			suite.Assert().Equal([]string{"dragons"}, created.Tags)
+
+			// YAML reads an unquoted ISBN as a number
+			isbn := common.CreateRandomISBN()
+			yamlBody := "title: Beowulf\npublisher: " + publisher + "\nisbn: " + isbn + "\ngenres: [Epic]\n"
+			resp, raw = suite.request(http.MethodPost, books, "application/x-yaml", handlers.YAMLMIMEType, strings.NewReader(yamlBody))
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode, string(raw))
+			var document yaml.MapSlice
+			suite.Assert().NoError(yaml.Unmarshal(raw, &document))
+			if suite.Assert().NotEmpty(document) {
+				suite.Assert().Equal("id", document[0].Key)
+				suite.Assert().Len(document[0].Value, 24)
+			}
+			var beowulf map[string]interface{}
+			suite.Assert().NoError(yaml.Unmarshal(raw, &beowulf))
+			suite.Assert().Equal(isbn, beowulf["isbn"])
+			suite.Assert().Equal([]interface{}{"Epic"}, beowulf["genres"])
+
+			var msgpack bytes.Buffer
+			handle := new(codec.MsgpackHandle)
+			handle.WriteExt = true
+			suite.Assert().NoError(codec.NewEncoder(&msgpack, handle).Encode(map[string]interface{}{
+				"title": "Grendel", "publisher": publisher, "rating": 3,
+			}))
+			resp, raw = suite.request(http.MethodPost, books, handlers.MsgPackMIMEType, handlers.MsgPackMIMEType, &msgpack)
+			suite.Assert().Equal(http.StatusCreated, resp.StatusCode, string(raw))
+			suite.Assert().Equal(handlers.MsgPackMIMEType, resp.Header.Get("Content-Type"))
+			handle.RawToString = true
+			var grendel map[string]interface{}
+			suite.Assert().NoError(codec.NewDecoderBytes(raw, handle).Decode(&grendel))
+			suite.Assert().Equal("Grendel", grendel["title"])
+			suite.Assert().EqualValues(3, grendel["rating"])
+
+			list := books + "?publisher=" + publisher
+			resp, raw = suite.request(http.MethodGet, list, "", "application/json; compact=true", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().NotContains(string(raw), "\n")
+
+			resp, raw = suite.request(http.MethodGet, list, "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Contains(string(raw), "\n    ")
+
+			resp, raw = suite.request(http.MethodGet, list, "", "application/xml;q=0.5, application/yaml, */*;q=0.1", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal("application/yaml; charset=utf-8", resp.Header.Get("Content-Type"))
+			suite.Assert().True(strings.HasPrefix(string(raw), "books:\n"))
+
+			resp, raw = suite.request(http.MethodGet, list, "", "text/html", nil)
+			suite.Assert().Equal(http.StatusNotAcceptable, resp.StatusCode)
+			suite.Assert().Equal(handlers.ProblemMIMEType, resp.Header.Get("Content-Type"))
+
+			resp, raw = suite.request(http.MethodGet, books+"/"+primitive.NewObjectID().Hex(), "", "text/xml", nil)
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+			suite.Assert().Equal(handlers.ProblemXMLMIMEType, resp.Header.Get("Content-Type"))
+			suite.Assert().Contains(string(raw), "<problem><type>about:blank</type>")
+
+			resp, _ = suite.request(http.MethodPost, books, "text/plain", "", strings.NewReader("The Hobbit"))
+			suite.Assert().Equal(http.StatusUnsupportedMediaType, resp.StatusCode)
+
+			resp, _ = suite.request(http.MethodPost, books, handlers.XMLMIMEType, "", strings.NewReader("<book><rating>many</rating></book>"))
+			suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+		})
+	}
+}
@@ -2,17 +2,57 @@ package handlers
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/auth"
 )
 
 func SetupRouter(app *App) *gin.Engine {
+	// readers may read everything, librarians may also change it and
+	// admins may delete books in bulk, see BookAction
+	read := app.authorize(auth.RoleReader)
+	write := app.authorize(auth.RoleLibrarian)
+
 	r := gin.Default()
-	v1 := r.Group("/v1")
+	v1 := r.Group("/v1", app.authenticate, app.resolveTenant)
 	{
-		v1.GET("books", app.ListBooks)
-		v1.POST("books", app.CreateBook)
-		v1.GET("books/:id", app.GetBook)
-		v1.PUT("books/:id", app.UpdateBook)
-		v1.DELETE("books/:id", app.DeleteBook)
+		v1.GET("books", read, app.ListBooks)
+		v1.POST("books", write, app.CreateBook)
+		v1.POST("books:action", write, app.BookAction)
+		v1.GET("books/search", read, app.SearchBooks)
+		v1.GET("books/facets", read, app.BookFacets)
+		v1.GET("books/export", read, app.ExportBooks)
+		v1.GET("books/trash", read, app.ListTrash)
+		v1.GET("books/isbn/:isbn", read, app.GetBookByISBN)
+		v1.GET("books/:id", read, app.GetBook)
+		v1.PUT("books/:id", write, app.UpdateBook)
+		v1.PATCH("books/:id", write, app.PatchBook)
+		v1.DELETE("books/:id", write, app.DeleteBook)
+		v1.POST("books/:id/restore", write, app.RestoreBook)
+		v1.GET("books/:id/history", read, app.ListBookHistory)
+		v1.POST("books/:id/revert", write, app.RevertBook)
+		v1.POST("books/:id/checkout", write, app.CheckoutBook)
+		v1.POST("books/:id/checkin", write, app.CheckinBook)
+		v1.GET("books/:id/loans", read, app.ListBookLoans)
+		v1.GET("books/:id/holds", read, app.ListBookHolds)
+		v1.POST("books/:id/holds", write, app.PlaceHold)
+		v1.DELETE("books/:id/holds/:hold", write, app.CancelHold)
+		v1.GET("books/:id/copies", read, app.ListBookCopies)
+		v1.POST("books/:id/copies", write, app.AddCopy)
+		v1.GET("copies/barcode/:barcode", read, app.GetCopyByBarcode)
+		v1.GET("copies/:id", read, app.GetCopy)
+		v1.PUT("copies/:id", write, app.UpdateCopy)
+		v1.DELETE("copies/:id", write, app.DeleteCopy)
+		v1.GET("authors", read, app.ListAuthors)
+		v1.GET("members", read, app.ListMembers)
+		v1.POST("members", write, app.CreateMember)
+		v1.GET("members/:id", read, app.GetMember)
+		v1.PUT("members/:id", write, app.UpdateMember)
+		v1.DELETE("members/:id", write, app.DeleteMember)
+		v1.GET("members/:id/loans", read, app.ListMemberLoans)
+		v1.GET("members/:id/holds", read, app.ListMemberHolds)
+		v1.GET("members/:id/fines", read, app.ListMemberFines)
+		v1.GET("fines/:id", read, app.GetFine)
+		v1.POST("fines/:id/payments", write, app.PayFine)
+		v1.POST("fines/:id/waiver", write, app.WaiveFine)
 	}
 	return r
 }
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BookCountTrailer is the trailer of an export, it carries the number of
+// books exported and is left out if the export was cut short.
+const BookCountTrailer = "X-Book-Count"
+
+type connKey struct{}
+
+// ConnContext keeps the connection of a request in its context for
+// streamDeadline, it is meant for http.Server.ConnContext.
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connKey{}, conn)
+}
+
+// streamDeadline replaces the read and write timeouts of the server with
+// StreamTimeout for the rest of the request, they would cut off the import
+// and export of a large catalog. Nothing changes on servers without
+// ConnContext.
+func (app *App) streamDeadline(c *gin.Context) {
+	conn, ok := c.Request.Context().Value(connKey{}).(net.Conn)
+	if !ok {
+		return
+	}
+
+	var deadline time.Time
+	if app.StreamTimeout > 0 {
+		deadline = time.Now().Add(app.StreamTimeout)
+	}
+
+	// a closed connection fails the request anyway
+	conn.SetDeadline(deadline) //nolint:errcheck
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/db"
+)
+
+// TenantHeader names the tenant of a request.
+const TenantHeader = "X-Tenant"
+
+// resolveTenant puts the tenant of a request into its context, every
+// repository call of the request is limited to it. A client bound to a
+// tenant always gets its own. Admins name one in TenantHeader or as the
+// subdomain of TenantDomain, the other clients may only name
+// db.DefaultTenant. Without a name they all get db.DefaultTenant.
+func (app *App) resolveTenant(c *gin.Context) {
+	requested := c.GetHeader(TenantHeader)
+	if requested == "" {
+		requested = app.hostTenant(c.Request.Host)
+	}
+
+	if requested != "" && !db.ValidTenant(requested) {
+		app.problem(c, http.StatusBadRequest, "invalid tenant "+requested)
+		return
+	}
+
+	tenant := requested
+
+	principal, _ := auth.PrincipalFromContext(c.Request.Context())
+
+	switch {
+	case principal.Tenant != "":
+		if requested != "" && requested != principal.Tenant {
+			app.problem(c, http.StatusForbidden, "no access to tenant "+requested)
+			return
+		}
+
+		tenant = principal.Tenant
+	case requested != "" && requested != db.DefaultTenant && !principal.Role.Allows(auth.RoleAdmin):
+		app.problem(c, http.StatusForbidden, "only admins may pick the tenant "+requested)
+		return
+	}
+
+	if tenant == "" {
+		tenant = db.DefaultTenant
+	}
+
+	c.Request = c.Request.WithContext(db.WithTenant(c.Request.Context(), tenant))
+	c.Next()
+}
+
+// hostTenant returns the subdomain of TenantDomain host is in, or "" if it
+// isn't in one.
+func (app *App) hostTenant(host string) string {
+	if app.TenantDomain == "" {
+		return ""
+	}
+
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+
+	suffix := "." + strings.ToLower(strings.TrimSuffix(app.TenantDomain, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+
+	return strings.TrimSuffix(host, suffix)
+}
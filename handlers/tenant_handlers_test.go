@@ -0,0 +1,225 @@
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/iho/booksdb/auth"
+	"github.com/iho/booksdb/db"
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.uber.org/zap"
+)
+
+func (suite *BookHandlersTestSuite) TestTenants() {
+	t := suite.T()
+	t.Parallel()
+
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	authenticator := auth.NewAuthenticator()
+	suite.Require().NoError(authenticator.AddAPIKey("staff-key", auth.Principal{Subject: "staff", Role: auth.RoleAdmin}))
+	suite.Require().NoError(authenticator.AddAPIKey("desk-key", auth.Principal{Subject: "desk", Role: auth.RoleLibrarian}))
+	suite.Require().NoError(authenticator.AddAPIKey("north-key",
+		auth.Principal{Subject: "north", Role: auth.RoleLibrarian, Tenant: "north"}))
+	suite.Require().NoError(authenticator.AddJWTKey("k1", secret))
+
+	app := handlers.NewApp(db.NewMemoryRepositories(), zapr.NewLogger(zap.NewNop()))
+	app.Authenticator = authenticator
+	app.TenantDomain = "books.example.com"
+	server := httptest.NewServer(handlers.SetupRouter(app))
+	defer server.Close()
+
+	send := func(method, path string, headers map[string]string, document interface{}) (*http.Response, []byte) {
+		var body []byte
+		if document != nil {
+			body, _ = json.Marshal(document)
+		}
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Content-Type", JSON_HTTP_HEADER)
+		for name, value := range headers {
+			if name == "Host" {
+				req.Host = value
+				continue
+			}
+			req.Header.Set(name, value)
+		}
+
+		return suite.do(req)
+	}
+	staff := func(tenant string) map[string]string {
+		return map[string]string{auth.APIKeyHeader: "staff-key", handlers.TenantHeader: tenant}
+	}
+
+	resp, raw := send(http.MethodPost, "/v1/books", staff("south"), &models.Book{Title: "Grendel"})
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+	grendel := &models.Book{}
+	suite.Require().NoError(json.Unmarshal(raw, grendel))
+
+	// a key bound to a tenant works in it without naming it
+	resp, raw = send(http.MethodPost, "/v1/books", map[string]string{auth.APIKeyHeader: "north-key"},
+		&models.Book{Title: "Beowulf"})
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+	beowulf := &models.Book{}
+	suite.Require().NoError(json.Unmarshal(raw, beowulf))
+
+	resp, _ = send(http.MethodGet, "/v1/books/"+beowulf.ID.Hex(), staff("north"), nil)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/books/"+beowulf.ID.Hex(), staff("south"), nil)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/books/"+beowulf.ID.Hex(), staff(""), nil)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+	resp, _ = send(http.MethodDelete, "/v1/books/"+beowulf.ID.Hex(), staff("south"), nil)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+	var list handlers.BookList
+	resp, raw = send(http.MethodGet, "/v1/books", staff("south"), nil)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().NoError(json.Unmarshal(raw, &list))
+	if suite.Assert().Len(list.Books, 1) {
+		suite.Assert().Equal(grendel.ID, list.Books[0].ID)
+	}
+
+	// the subdomain names the tenant unless the header does
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(),
+		map[string]string{auth.APIKeyHeader: "staff-key", "Host": "south.books.example.com"}, nil)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(),
+		map[string]string{auth.APIKeyHeader: "staff-key", "Host": "north.books.example.com:8080"}, nil)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+	resp, _ = send(http.MethodGet, "/v1/books",
+		map[string]string{auth.APIKeyHeader: "north-key", handlers.TenantHeader: "south"}, nil)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/books", staff("Not a tenant"), nil)
+	suite.Assert().Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// only admins pick a tenant, other unbound keys get the default one
+	desk := map[string]string{auth.APIKeyHeader: "desk-key"}
+	resp, _ = send(http.MethodGet, "/v1/books", desk, nil)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	desk[handlers.TenantHeader] = db.DefaultTenant
+	resp, _ = send(http.MethodGet, "/v1/books", desk, nil)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	desk[handlers.TenantHeader] = "south"
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(), desk, nil)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp, _ = send(http.MethodPost, "/v1/books", desk, &models.Book{Title: "Heorot"})
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(),
+		map[string]string{auth.APIKeyHeader: "desk-key", "Host": "south.books.example.com"}, nil)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+
+	// members are kept apart like books, their emails too
+	member := &models.Member{Name: "Alice", Email: "alice@example.com"}
+	resp, raw = send(http.MethodPost, "/v1/members", staff("north"), member)
+	suite.Require().Equal(http.StatusCreated, resp.StatusCode)
+	suite.Require().NoError(json.Unmarshal(raw, member))
+	resp, _ = send(http.MethodPost, "/v1/members", staff("south"), &models.Member{Name: "Alice", Email: "alice@example.com"})
+	suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+	resp, _ = send(http.MethodGet, "/v1/members/"+member.ID.Hex(), staff("south"), nil)
+	suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+	var members handlers.MemberList
+	resp, raw = send(http.MethodGet, "/v1/members", staff("south"), nil)
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	suite.Require().NoError(json.Unmarshal(raw, &members))
+	if suite.Assert().Len(members.Members, 1) {
+		suite.Assert().NotEqual(member.ID, members.Members[0].ID)
+	}
+
+	token, err := auth.SignToken(auth.Claims{
+		Subject:   "alice",
+		Role:      auth.RoleReader,
+		Tenant:    "south",
+		ExpiresAt: time.Now().Add(time.Hour).Unix(),
+	}, "k1", secret)
+	suite.Require().NoError(err)
+	bearer := map[string]string{"Authorization": "Bearer " + token}
+
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(), bearer, nil)
+	suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+	bearer["Host"] = "north.books.example.com"
+	resp, _ = send(http.MethodGet, "/v1/books/"+grendel.ID.Hex(), bearer, nil)
+	suite.Assert().Equal(http.StatusForbidden, resp.StatusCode)
+}
+
+func (suite *BookHandlersTestSuite) TestTenantJobs() {
+	t := suite.T()
+	t.Parallel()
+
+	app := handlers.NewApp(db.NewMemoryRepositories(), zapr.NewLogger(zap.NewNop()))
+	server := httptest.NewServer(handlers.SetupRouter(app))
+	defer server.Close()
+
+	north := db.WithTenant(context.Background(), "north")
+	send := func(method, path string, document interface{}, target interface{}) int {
+		body, _ := json.Marshal(document)
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		suite.Require().NoError(err)
+		req.Header.Set("Content-Type", JSON_HTTP_HEADER)
+		req.Header.Set(handlers.TenantHeader, "north")
+
+		resp, raw := suite.do(req)
+		if target != nil {
+			suite.Require().NoError(json.Unmarshal(raw, target))
+		}
+
+		return resp.StatusCode
+	}
+
+	book := &models.Book{Title: "Beowulf", Status: models.CheckedIn}
+	suite.Require().Equal(http.StatusCreated, send(http.MethodPost, "/v1/books", book, book))
+	first := &models.Member{Name: "Alice", Email: "alice@example.com"}
+	suite.Require().Equal(http.StatusCreated, send(http.MethodPost, "/v1/members", first, first))
+	second := &models.Member{Name: "Bob", Email: "bob@example.com"}
+	suite.Require().Equal(http.StatusCreated, send(http.MethodPost, "/v1/members", second, second))
+
+	hold := &models.Hold{}
+	suite.Require().Equal(http.StatusCreated,
+		send(http.MethodPost, "/v1/books/"+book.ID.Hex()+"/holds", map[string]string{"member_id": first.ID.Hex()}, hold))
+	suite.Require().Equal(http.StatusCreated,
+		send(http.MethodPost, "/v1/books/"+book.ID.Hex()+"/holds", map[string]string{"member_id": second.ID.Hex()}, nil))
+
+	_, err := app.HoldRepository.UpdateHold(north, db.ID(hold.ID.Hex()), func(hold *models.Hold) (*models.Hold, error) {
+		pickupBy := time.Now().UTC().Add(-time.Minute)
+		hold.PickupBy = &pickupBy
+
+		return hold, nil
+	})
+	suite.Require().NoError(err)
+
+	// the jobs run without a tenant and work in the one of each record
+	expired, err := app.ExpireHolds(context.Background(), time.Now().UTC())
+	suite.Require().NoError(err)
+	suite.Assert().Equal(1, expired)
+
+	var queue handlers.HoldList
+	suite.Require().Equal(http.StatusOK, send(http.MethodGet, "/v1/books/"+book.ID.Hex()+"/holds", nil, &queue))
+	if suite.Assert().Len(queue.Holds, 1) {
+		suite.Assert().Equal(second.ID, queue.Holds[0].MemberID)
+		suite.Assert().Equal(models.HoldReady, queue.Holds[0].Status)
+	}
+
+	now := time.Now().UTC()
+	_, err = app.LoanRepository.AddLoan(north, &models.Loan{
+		BookID:       book.ID,
+		MemberID:     second.ID,
+		CheckedOutAt: now.Add(-2 * models.DefaultLoanPeriod),
+		DueAt:        now.Add(-models.DefaultLoanPeriod),
+	})
+	suite.Require().NoError(err)
+
+	fined, err := app.AssessFines(context.Background(), now)
+	suite.Require().NoError(err)
+	suite.Assert().Equal(1, fined)
+
+	var fines handlers.FineList
+	suite.Require().Equal(http.StatusOK, send(http.MethodGet, "/v1/members/"+second.ID.Hex()+"/fines", nil, &fines))
+	suite.Assert().Len(fines.Fines, 1)
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iho/booksdb/handlers"
+	"github.com/iho/booksdb/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (suite *BookHandlersTestSuite) TestTrash() {
+	t := suite.T()
+	t.Parallel()
+
+	for _, server := range suite.Servers {
+		server := server
+		t.Run("Trash"+server.Name, func(t *testing.T) {
+			t.Parallel()
+			publisher := primitive.NewObjectID().Hex()
+			books := server.TS.URL + "/v1/books"
+
+			var ids []string
+			for _, title := range []string{"Beowulf", "Grendel"} {
+				jsonValue, _ := json.Marshal(&models.Book{Title: title, Publisher: publisher})
+				resp, raw := suite.request(http.MethodPost, books, JSON_HTTP_HEADER, "", bytes.NewReader(jsonValue))
+				suite.Assert().Equal(http.StatusCreated, resp.StatusCode)
+				book := &models.Book{}
+				suite.Assert().NoError(json.Unmarshal(raw, book))
+				ids = append(ids, book.ID.Hex())
+
+				resp, _ = suite.request(http.MethodDelete, books+"/"+book.ID.Hex(), "", "", nil)
+				suite.Assert().Equal(http.StatusNoContent, resp.StatusCode)
+			}
+
+			resp, _ := suite.request(http.MethodGet, books+"/"+ids[0], "", "", nil)
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+			var list handlers.BookList
+			resp, raw := suite.request(http.MethodGet, books+"?publisher="+publisher, "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().NoError(json.Unmarshal(raw, &list))
+			suite.Assert().Empty(list.Books)
+
+			resp, raw = suite.request(http.MethodGet, books+"/trash?publisher="+publisher+"&sort=title", "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().NoError(json.Unmarshal(raw, &list))
+			if suite.Assert().Len(list.Books, 2) {
+				suite.Assert().Equal("Beowulf", list.Books[0].Title)
+				suite.Assert().NotNil(list.Books[0].DeletedAt)
+				suite.Assert().EqualValues(2, list.Books[1].Version)
+			}
+
+			resp, raw = suite.request(http.MethodPost, books+"/"+ids[0]+"/restore", "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+			suite.Assert().Equal(`"3"`, resp.Header.Get("ETag"))
+			restored := &models.Book{}
+			suite.Assert().NoError(json.Unmarshal(raw, restored))
+			suite.Assert().Nil(restored.DeletedAt)
+
+			resp, _ = suite.request(http.MethodPost, books+"/"+ids[0]+"/restore", "", "", nil)
+			suite.Assert().Equal(http.StatusNotFound, resp.StatusCode)
+
+			resp, _ = suite.request(http.MethodGet, books+"/"+ids[0], "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+
+			// books deleted within the retention period are kept
+			_, err := server.App.PurgeTrash(suite.Context, time.Now())
+			suite.Assert().NoError(err)
+			resp, _ = suite.request(http.MethodPost, books+"/"+ids[1]+"/restore", "", "", nil)
+			suite.Assert().Equal(http.StatusOK, resp.StatusCode)
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,13 +14,80 @@ const (
 	CheckedIn  BookStatusType = "CheckedIn"
 )
 
+var (
+	ErrAlreadyCheckedOut = errors.New("book is already checked out")
+	ErrNotCheckedOut     = errors.New("book is not checked out")
+)
+
+// Book is a bibliographic record. Author is derived from Contributors by
+// Normalize and kept for filtering, sorting and searching by author. A book
+// whose contributors credit authors can't have another author. Status
+// tells whether a book without copies is lent, books with copies are lent
+// copy by copy, see Copy.
 type Book struct {
-	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Title     string             `json:"title" bson:"title,omitempty"`
-	Author    string             `json:"author" bson:"author,omitempty"`
-	Publisher string             `json:"publisher" bson:"publisher,omitempty"`
-	Rating    int                `json:"rating" bson:"rating,omitempty"`
-	Status    BookStatusType     `json:"status" bson:"status,omitempty"`
-	CreatedAt time.Time          `json:"-" bson:"created_at"`
-	UpdatedAt time.Time          `json:"-" bson:"updated_at"`
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ISBN         string             `json:"isbn,omitempty" bson:"isbn,omitempty" validate:"omitempty,isbn"`
+	Title        string             `json:"title" bson:"title,omitempty" validate:"required,max=256"`
+	Author       string             `json:"author" bson:"author,omitempty" validate:"max=256"`
+	Contributors []Contributor      `json:"contributors,omitempty" bson:"contributors,omitempty" validate:"max=64,dive"`
+	Publisher    string             `json:"publisher" bson:"publisher,omitempty" validate:"max=256"`
+	Genres       []string           `json:"genres,omitempty" bson:"genres,omitempty" validate:"max=16,dive,max=64"`
+	Tags         []string           `json:"tags,omitempty" bson:"tags,omitempty" validate:"max=64,dive,max=64"`
+	Dewey        string             `json:"dewey,omitempty" bson:"dewey,omitempty" validate:"omitempty,dewey"`
+	LCC          string             `json:"lcc,omitempty" bson:"lcc,omitempty" validate:"omitempty,max=64,lcc"`
+	Rating       int                `json:"rating" bson:"rating,omitempty" validate:"min=0,max=5"`
+	Status       BookStatusType     `json:"status" bson:"status,omitempty" validate:"omitempty,oneof=CheckedIn CheckedOut"`
+	Version      int64              `json:"version" bson:"version"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time          `json:"updated_at" bson:"updated_at"`
+	// DeletedAt is set while the book is in the trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// Tenant is the library the book belongs to. It is set by the
+	// repositories and never leaves the server.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+// Validate checks the book against the rules declared in its struct tags
+// and returns a *ValidationError describing every violation. An author
+// that disagrees with the contributors is checked last.
+func (book *Book) Validate() error {
+	if err := validateStruct(book); err != nil {
+		return err
+	}
+
+	return book.checkAuthor()
+}
+
+// Normalize brings fields with several equivalent spellings into their
+// canonical form and derives the author from the contributors. It expects a
+// valid book.
+func (book *Book) Normalize() {
+	if isbn, err := NormalizeISBN(book.ISBN); err == nil {
+		book.ISBN = isbn
+	}
+
+	book.normalizeContributors()
+	book.normalizeClassification()
+}
+
+// CheckOut moves a checked in book to CheckedOut. A book without a status
+// counts as checked in.
+func (book *Book) CheckOut() error {
+	if book.Status == CheckedOut {
+		return ErrAlreadyCheckedOut
+	}
+
+	book.Status = CheckedOut
+
+	return nil
+}
+
+func (book *Book) CheckIn() error {
+	if book.Status != CheckedOut {
+		return ErrNotCheckedOut
+	}
+
+	book.Status = CheckedIn
+
+	return nil
 }
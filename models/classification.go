@@ -0,0 +1,56 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+)
+
+//nolint:gochecknoglobals
+var (
+	// a Dewey Decimal class has three digits and an optional decimal part,
+	// e.g. 823.912
+	deweyPattern = regexp.MustCompile(`^[0-9]{3}(\.[0-9]+)?$`)
+	// a Library of Congress call number starts with a class of one to three
+	// letters followed by a number, e.g. PR6039.O32
+	lccPattern = regexp.MustCompile(`^[A-Z]{1,3} ?[0-9]`)
+)
+
+func ValidDewey(class string) bool {
+	return deweyPattern.MatchString(class)
+}
+
+func ValidLCC(callNumber string) bool {
+	return lccPattern.MatchString(strings.ToUpper(callNumber))
+}
+
+// normalizeClassification trims the classification, upper-cases the LCC
+// call number and lower-cases tags. Duplicate genres and tags are dropped.
+func (book *Book) normalizeClassification() {
+	book.Dewey = strings.TrimSpace(book.Dewey)
+	book.LCC = strings.ToUpper(strings.TrimSpace(book.LCC))
+	book.Genres = uniqueTerms(book.Genres, strings.TrimSpace)
+	book.Tags = uniqueTerms(book.Tags, func(tag string) string {
+		return strings.ToLower(strings.TrimSpace(tag))
+	})
+}
+
+func uniqueTerms(terms []string, normalize func(term string) string) []string {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(terms))
+	result := make([]string, 0, len(terms))
+
+	for _, term := range terms {
+		term = normalize(term)
+		if term == "" || seen[term] {
+			continue
+		}
+
+		seen[term] = true
+		result = append(result, term)
+	}
+
+	return result
+}
@@ -0,0 +1,99 @@
+package models
+
+import "strings"
+
+type ContributorRole string
+
+const (
+	RoleAuthor      ContributorRole = "Author"
+	RoleEditor      ContributorRole = "Editor"
+	RoleTranslator  ContributorRole = "Translator"
+	RoleIllustrator ContributorRole = "Illustrator"
+)
+
+// Contributor credits a person with a role in making a book.
+type Contributor struct {
+	Name string          `json:"name" bson:"name" validate:"required,max=256"`
+	Role ContributorRole `json:"role" bson:"role" validate:"required,oneof=Author Editor Translator Illustrator"`
+	// SortName is the name the contributor is filed under, e.g. "Tolkien,
+	// J. R. R.". It defaults to the name.
+	SortName string `json:"sort_name" bson:"sort_name" validate:"max=256"`
+}
+
+func (contributor *Contributor) normalize() {
+	contributor.Name = strings.TrimSpace(contributor.Name)
+	contributor.SortName = strings.TrimSpace(contributor.SortName)
+
+	if contributor.SortName == "" {
+		contributor.SortName = contributor.Name
+	}
+}
+
+// Credits returns the contributors of the book. Books stored before
+// contributors were introduced only have an author, it is credited as the
+// sole contributor.
+func (book *Book) Credits() []Contributor {
+	if len(book.Contributors) > 0 || book.Author == "" {
+		return book.Contributors
+	}
+
+	return []Contributor{{Name: book.Author, Role: RoleAuthor, SortName: book.Author}}
+}
+
+// DeriveAuthor clears an author the book kept unchanged from stored, its
+// stored version, if the contributors of the book credit authors. Normalize
+// derives the author from them then, so changing just the contributors of a
+// book changes its author too, while changing just the author fails
+// Validate.
+func (book *Book) DeriveAuthor(stored *Book) {
+	if book.Author == stored.Author && book.creditedAuthors() != "" {
+		book.Author = ""
+	}
+}
+
+// normalizeContributors keeps Author and Contributors in step: a book with
+// only an author gets it as its contributor, and the author of a book with
+// contributors lists the names of those credited as authors.
+func (book *Book) normalizeContributors() {
+	for i := range book.Contributors {
+		book.Contributors[i].normalize()
+	}
+
+	if len(book.Contributors) == 0 {
+		book.Contributors = book.Credits()
+		return
+	}
+
+	if authors := book.creditedAuthors(); authors != "" {
+		book.Author = authors
+	}
+}
+
+// checkAuthor rejects an author that normalizeContributors would replace.
+func (book *Book) checkAuthor() error {
+	authors := book.creditedAuthors()
+	author := strings.TrimSpace(book.Author)
+
+	if authors == "" || author == "" || author == authors {
+		return nil
+	}
+
+	return &ValidationError{Fields: []FieldError{{
+		Field:   "author",
+		Rule:    "contributors",
+		Message: "must be empty or name the authors among the contributors, " + authors,
+	}}}
+}
+
+// creditedAuthors lists the names of the contributors credited as authors.
+func (book *Book) creditedAuthors() string {
+	var authors []string
+
+	for _, contributor := range book.Contributors {
+		if contributor.Role == RoleAuthor {
+			authors = append(authors, strings.TrimSpace(contributor.Name))
+		}
+	}
+
+	return strings.Join(authors, ", ")
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type CopyStatus string
+
+const (
+	CopyAvailable  CopyStatus = "Available"
+	CopyCheckedOut CopyStatus = "CheckedOut"
+	CopyInRepair   CopyStatus = "InRepair"
+	CopyLost       CopyStatus = "Lost"
+	CopyWithdrawn  CopyStatus = "Withdrawn"
+)
+
+var (
+	ErrCopyCheckedOut    = errors.New("copy is checked out")
+	ErrCopyNotAvailable  = errors.New("copy is not available for loan")
+	ErrCopyNotCheckedOut = errors.New("copy is not checked out")
+	ErrNoCopyAvailable   = errors.New("no copy of the book is available")
+)
+
+type CopyCondition string
+
+const (
+	ConditionNew     CopyCondition = "New"
+	ConditionGood    CopyCondition = "Good"
+	ConditionFair    CopyCondition = "Fair"
+	ConditionPoor    CopyCondition = "Poor"
+	ConditionDamaged CopyCondition = "Damaged"
+)
+
+// Copy is a physical item of a book the library owns. The book holds the
+// bibliographic record, every copy has its own barcode, shelf and status.
+// A copy is lent by checking it out, which is the only way in and out of
+// CopyCheckedOut.
+type Copy struct {
+	ID      primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookID  primitive.ObjectID `json:"book_id" bson:"book_id"`
+	Barcode string             `json:"barcode" bson:"barcode" validate:"required,max=64"`
+	// Location is the shelf mark or branch the copy is kept at.
+	Location   string        `json:"location" bson:"location" validate:"max=256"`
+	Condition  CopyCondition `json:"condition" bson:"condition" validate:"omitempty,oneof=New Good Fair Poor Damaged"`
+	Status     CopyStatus    `json:"status" bson:"status" validate:"oneof=Available CheckedOut InRepair Lost Withdrawn"`
+	AcquiredAt *time.Time    `json:"acquired_at,omitempty" bson:"acquired_at,omitempty"`
+	Version    int64         `json:"version" bson:"version"`
+	CreatedAt  time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at" bson:"updated_at"`
+	// Tenant is the library the copy belongs to, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+func (item *Copy) Validate() error {
+	return validateStruct(item)
+}
+
+// Normalize trims the barcode and makes new copies available.
+func (item *Copy) Normalize() {
+	item.Barcode = strings.TrimSpace(item.Barcode)
+
+	if item.Status == "" {
+		item.Status = CopyAvailable
+	}
+}
+
+// CheckOut lends an available copy.
+func (item *Copy) CheckOut() error {
+	switch item.Status {
+	case CopyAvailable:
+		item.Status = CopyCheckedOut
+		return nil
+	case CopyCheckedOut:
+		return ErrCopyCheckedOut
+	}
+
+	return ErrCopyNotAvailable
+}
+
+// CheckIn puts a returned copy back on the shelf.
+func (item *Copy) CheckIn() error {
+	if item.Status != CopyCheckedOut {
+		return ErrCopyNotCheckedOut
+	}
+
+	item.Status = CopyAvailable
+
+	return nil
+}
+
+// Availability counts the copies of a book. Withdrawn copies are no longer
+// part of the collection and aren't counted.
+type Availability struct {
+	Total      int `json:"total"`
+	Available  int `json:"available"`
+	CheckedOut int `json:"checked_out"`
+	// Unavailable copies are in repair or lost.
+	Unavailable int `json:"unavailable"`
+}
+
+func CountCopies(copies []*Copy) Availability {
+	var availability Availability
+
+	for _, item := range copies {
+		switch item.Status {
+		case CopyWithdrawn:
+			continue
+		case CopyAvailable:
+			availability.Available++
+		case CopyCheckedOut:
+			availability.CheckedOut++
+		case CopyInRepair, CopyLost:
+			availability.Unavailable++
+		}
+
+		availability.Total++
+	}
+
+	return availability
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultFinePolicy charges 25 cents a day after a day of grace, at most 10
+// dollars a loan.
+var DefaultFinePolicy = FinePolicy{ //nolint:gochecknoglobals
+	DailyRate:   25,
+	GracePeriod: 24 * time.Hour,
+	Cap:         1000,
+}
+
+// FinePolicy decides what a late return costs. Amounts are in the minor
+// unit of the currency, e.g. cents.
+type FinePolicy struct {
+	DailyRate int64
+	// GracePeriod is how long a loan may be overdue without a fine. Once
+	// it is over, every started day since the due date is charged.
+	GracePeriod time.Duration
+	// Cap limits the fine of a single loan, zero means no limit.
+	Cap int64
+}
+
+// Assess returns the fine of a loan, counting until now for loans that
+// are still open.
+func (policy FinePolicy) Assess(loan *Loan, now time.Time) int64 {
+	end := now
+	if loan.ReturnedAt != nil {
+		end = *loan.ReturnedAt
+	}
+
+	overdue := end.Sub(loan.DueAt)
+	if overdue <= 0 || overdue <= policy.GracePeriod {
+		return 0
+	}
+
+	days := int64((overdue + 24*time.Hour - 1) / (24 * time.Hour))
+
+	amount := days * policy.DailyRate
+	if policy.Cap > 0 && amount > policy.Cap {
+		amount = policy.Cap
+	}
+
+	return amount
+}
+
+type FineStatus string
+
+const (
+	FineOutstanding FineStatus = "Outstanding"
+	FinePaid        FineStatus = "Paid"
+	FineWaived      FineStatus = "Waived"
+)
+
+var ErrFineSettled = errors.New("fine is already settled")
+
+// Fine is what a member owes for returning a book late. There is at most
+// one fine per loan, it grows while the loan stays open.
+type Fine struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	LoanID   primitive.ObjectID `json:"loan_id" bson:"loan_id"`
+	MemberID primitive.ObjectID `json:"member_id" bson:"member_id"`
+	BookID   primitive.ObjectID `json:"book_id" bson:"book_id"`
+	// Amount and Paid are in the minor unit of the currency.
+	Amount       int64      `json:"amount" bson:"amount"`
+	Paid         int64      `json:"paid" bson:"paid"`
+	Status       FineStatus `json:"status" bson:"status"`
+	WaiverReason string     `json:"waiver_reason,omitempty" bson:"waiver_reason,omitempty"`
+	AssessedAt   time.Time  `json:"assessed_at" bson:"assessed_at"`
+	SettledAt    *time.Time `json:"settled_at,omitempty" bson:"settled_at,omitempty"`
+	Version      int64      `json:"version" bson:"version"`
+	// Tenant is the library the fine belongs to, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+// Outstanding is the part of the fine that is still to be paid.
+func (fine *Fine) Outstanding() int64 {
+	if fine.Status != FineOutstanding {
+		return 0
+	}
+
+	return fine.Amount - fine.Paid
+}
+
+// Assess updates the amount of the fine and reports whether it changed. A
+// fine that was paid in full opens again if the amount grew since, a waived
+// fine stays waived.
+func (fine *Fine) Assess(amount int64, now time.Time) bool {
+	if fine.Status == FineWaived || fine.Amount == amount {
+		return false
+	}
+
+	fine.Amount = amount
+	fine.AssessedAt = now
+
+	if fine.Paid >= fine.Amount {
+		if fine.Status != FinePaid {
+			fine.settle(FinePaid, now)
+		}
+
+		return true
+	}
+
+	fine.Status = FineOutstanding
+	fine.SettledAt = nil
+
+	return true
+}
+
+// Payment is a payment towards a fine.
+type Payment struct {
+	Amount int64 `json:"amount" validate:"min=1"`
+}
+
+func (payment *Payment) Validate() error {
+	return validateStruct(payment)
+}
+
+// Pay takes a payment of at most the outstanding amount.
+func (fine *Fine) Pay(payment Payment, now time.Time) error {
+	if fine.Status != FineOutstanding {
+		return ErrFineSettled
+	}
+
+	if err := payment.Validate(); err != nil {
+		return err
+	}
+
+	if payment.Amount > fine.Outstanding() {
+		return &ValidationError{Fields: []FieldError{{
+			Field:   "amount",
+			Rule:    "max",
+			Message: "must be at most " + strconv.FormatInt(fine.Outstanding(), 10),
+		}}}
+	}
+
+	fine.Paid += payment.Amount
+	if fine.Paid == fine.Amount {
+		fine.settle(FinePaid, now)
+	}
+
+	return nil
+}
+
+// Waiver lets a member off a fine.
+type Waiver struct {
+	Reason string `json:"reason" validate:"required,max=1024"`
+}
+
+func (waiver *Waiver) Validate() error {
+	return validateStruct(waiver)
+}
+
+// Waive lets the member off the rest of the fine.
+func (fine *Fine) Waive(waiver Waiver, now time.Time) error {
+	if fine.Status != FineOutstanding {
+		return ErrFineSettled
+	}
+
+	if err := waiver.Validate(); err != nil {
+		return err
+	}
+
+	fine.WaiverReason = waiver.Reason
+	fine.settle(FineWaived, now)
+
+	return nil
+}
+
+func (fine *Fine) settle(status FineStatus, now time.Time) {
+	fine.Status = status
+	fine.SettledAt = &now
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultHoldPickupPeriod is how long a book waits for the member whose
+// hold became ready before the hold expires.
+const DefaultHoldPickupPeriod = 3 * 24 * time.Hour
+
+type HoldStatus string
+
+// A hold waits in the queue of its book until the book is free, is then
+// ready for pickup and ends up fulfilled by a checkout, cancelled or
+// expired.
+const (
+	HoldWaiting   HoldStatus = "Waiting"
+	HoldReady     HoldStatus = "Ready"
+	HoldFulfilled HoldStatus = "Fulfilled"
+	HoldCancelled HoldStatus = "Cancelled"
+	HoldExpired   HoldStatus = "Expired"
+)
+
+var (
+	ErrOnHold         = errors.New("book is on hold for another member")
+	ErrAlreadyOnHold  = errors.New("member already has a hold on the book")
+	ErrHoldClosed     = errors.New("hold is no longer active")
+	ErrHoldNotWaiting = errors.New("hold is not waiting")
+	ErrHoldNotReady   = errors.New("hold is not ready")
+	ErrHoldNotOverdue = errors.New("hold is still within its pickup period")
+)
+
+// Hold is a member's place in the queue for a book.
+type Hold struct {
+	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookID   primitive.ObjectID `json:"book_id" bson:"book_id"`
+	MemberID primitive.ObjectID `json:"member_id" bson:"member_id" validate:"required"`
+	Status   HoldStatus         `json:"status" bson:"status"`
+	PlacedAt time.Time          `json:"placed_at" bson:"placed_at"`
+	// ReadyAt and PickupBy are set when the book starts waiting for the
+	// member.
+	ReadyAt  *time.Time `json:"ready_at,omitempty" bson:"ready_at,omitempty"`
+	PickupBy *time.Time `json:"pickup_by,omitempty" bson:"pickup_by,omitempty"`
+	ClosedAt *time.Time `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+	Version  int64      `json:"version" bson:"version"`
+	// Tenant is the library the hold belongs to, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+func (hold *Hold) Validate() error {
+	return validateStruct(hold)
+}
+
+// Active reports whether the hold is still in the queue of its book.
+func (hold *Hold) Active() bool {
+	return hold.Status == HoldWaiting || hold.Status == HoldReady
+}
+
+// Overdue reports whether a ready hold wasn't collected in time.
+func (hold *Hold) Overdue(now time.Time) bool {
+	return hold.Status == HoldReady && hold.PickupBy != nil && !now.Before(*hold.PickupBy)
+}
+
+// MakeReady keeps the book for the member until now plus the pickup period.
+func (hold *Hold) MakeReady(now time.Time, pickupPeriod time.Duration) error {
+	if hold.Status != HoldWaiting {
+		return ErrHoldNotWaiting
+	}
+
+	pickupBy := now.Add(pickupPeriod)
+	hold.Status = HoldReady
+	hold.ReadyAt = &now
+	hold.PickupBy = &pickupBy
+
+	return nil
+}
+
+// Fulfil closes a ready hold when the member checks the book out.
+func (hold *Hold) Fulfil(now time.Time) error {
+	if hold.Status != HoldReady {
+		return ErrHoldNotReady
+	}
+
+	hold.close(HoldFulfilled, now)
+
+	return nil
+}
+
+func (hold *Hold) Cancel(now time.Time) error {
+	if !hold.Active() {
+		return ErrHoldClosed
+	}
+
+	hold.close(HoldCancelled, now)
+
+	return nil
+}
+
+func (hold *Hold) Expire(now time.Time) error {
+	if !hold.Overdue(now) {
+		return ErrHoldNotOverdue
+	}
+
+	hold.close(HoldExpired, now)
+
+	return nil
+}
+
+func (hold *Hold) close(status HoldStatus, now time.Time) {
+	hold.Status = status
+	hold.ClosedAt = &now
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidISBN = errors.New("invalid ISBN")
+
+// NormalizeISBN accepts an ISBN-10 or ISBN-13 with optional hyphens or
+// spaces and returns it as a bare ISBN-13, the form books are stored and
+// looked up by.
+func NormalizeISBN(isbn string) (string, error) {
+	compact := compactISBN(isbn)
+
+	switch {
+	case ValidISBN13(compact):
+		return compact, nil
+	case ValidISBN10(compact):
+		return ISBN10To13(compact)
+	}
+
+	return "", ErrInvalidISBN
+}
+
+// compactISBN drops separators and upper-cases the ISBN-10 check digit.
+func compactISBN(isbn string) string {
+	return strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(isbn))
+}
+
+func ValidISBN10(isbn string) bool {
+	if len(isbn) != 10 || !allDigits(isbn[:9]) {
+		return false
+	}
+
+	last := isbn[9]
+	if last != 'X' && (last < '0' || last > '9') {
+		return false
+	}
+
+	return isbn10CheckDigit(isbn[:9]) == last
+}
+
+func ValidISBN13(isbn string) bool {
+	if len(isbn) != 13 || !allDigits(isbn) {
+		return false
+	}
+
+	return isbn13CheckDigit(isbn[:12]) == isbn[12]
+}
+
+// ISBN10To13 converts an ISBN-10 into the equivalent 978-prefixed ISBN-13.
+func ISBN10To13(isbn string) (string, error) {
+	isbn = compactISBN(isbn)
+	if !ValidISBN10(isbn) {
+		return "", ErrInvalidISBN
+	}
+
+	body := "978" + isbn[:9]
+
+	return body + string(isbn13CheckDigit(body)), nil
+}
+
+// ISBN13To10 converts an ISBN-13 back to ISBN-10. Only the 978 prefix has
+// ISBN-10 equivalents.
+func ISBN13To10(isbn string) (string, error) {
+	isbn = compactISBN(isbn)
+	if !ValidISBN13(isbn) || !strings.HasPrefix(isbn, "978") {
+		return "", ErrInvalidISBN
+	}
+
+	body := isbn[3:12]
+
+	return body + string(isbn10CheckDigit(body)), nil
+}
+
+// isbn10CheckDigit computes the check digit of the first nine digits, with
+// weights 10 down to 2, modulo 11.
+func isbn10CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(body[i]-'0')
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+
+	return byte('0' + check)
+}
+
+// isbn13CheckDigit computes the check digit of the first twelve digits, with
+// alternating weights 1 and 3, modulo 10.
+func isbn13CheckDigit(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+
+		sum += weight * int(body[i]-'0')
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/iho/booksdb/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		isbn string
+		want string
+		err  error
+	}{
+		{name: "ISBN-13", isbn: "9780306406157", want: "9780306406157"},
+		{name: "ISBN-13 with hyphens", isbn: "978-0-306-40615-7", want: "9780306406157"},
+		{name: "ISBN-13 with spaces", isbn: " 978 0 306 40615 7 ", want: "9780306406157"},
+		{name: "ISBN-10", isbn: "0306406152", want: "9780306406157"},
+		{name: "ISBN-10 with hyphens", isbn: "0-306-40615-2", want: "9780306406157"},
+		{name: "ISBN-10 with spaces", isbn: "0 306 40615 2", want: "9780306406157"},
+		{name: "ISBN-10 check digit X", isbn: "0-8044-2957-X", want: "9780804429573"},
+		{name: "ISBN-10 check digit x", isbn: "080442957x", want: "9780804429573"},
+		{name: "ISBN-13 bad checksum", isbn: "9780306406158", err: models.ErrInvalidISBN},
+		{name: "ISBN-10 bad checksum", isbn: "0306406153", err: models.ErrInvalidISBN},
+		{name: "ISBN-10 X instead of a digit", isbn: "030640615X", err: models.ErrInvalidISBN},
+		{name: "ISBN-10 X not last", isbn: "08044X2957", err: models.ErrInvalidISBN},
+		{name: "ISBN-13 with X", isbn: "978030640615X", err: models.ErrInvalidISBN},
+		{name: "letters", isbn: "978030640615a", err: models.ErrInvalidISBN},
+		{name: "too short", isbn: "030640615", err: models.ErrInvalidISBN},
+		{name: "too long", isbn: "97803064061570", err: models.ErrInvalidISBN},
+		{name: "other separators", isbn: "0.306.40615.2", err: models.ErrInvalidISBN},
+		{name: "empty", isbn: "", err: models.ErrInvalidISBN},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := models.NormalizeISBN(test.isbn)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestISBN13To10(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		isbn string
+		want string
+		err  error
+	}{
+		{name: "978 prefix", isbn: "9780306406157", want: "0306406152"},
+		{name: "check digit X", isbn: "978-0-8044-2957-3", want: "080442957X"},
+		{name: "979 prefix", isbn: "9791090636071", err: models.ErrInvalidISBN},
+		{name: "bad checksum", isbn: "9780306406158", err: models.ErrInvalidISBN},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := models.ISBN13To10(test.isbn)
+			assert.ErrorIs(t, err, test.err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
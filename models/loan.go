@@ -0,0 +1,52 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DefaultLoanPeriod is used when a checkout doesn't ask for a due date.
+const DefaultLoanPeriod = 14 * 24 * time.Hour
+
+// Loan records a single checkout of a book. It is open until ReturnedAt is
+// set by the checkin. CopyID is the copy that was lent, it is zero for books
+// without copies, which are lent as a whole.
+type Loan struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookID       primitive.ObjectID `json:"book_id" bson:"book_id"`
+	CopyID       primitive.ObjectID `json:"copy_id,omitempty" bson:"copy_id,omitempty"`
+	MemberID     primitive.ObjectID `json:"member_id" bson:"member_id" validate:"required"`
+	CheckedOutAt time.Time          `json:"checked_out_at" bson:"checked_out_at"`
+	DueAt        time.Time          `json:"due_at" bson:"due_at"`
+	ReturnedAt   *time.Time         `json:"returned_at,omitempty" bson:"returned_at,omitempty"`
+	// Tenant is the library the loan belongs to, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+// Validate checks the struct tags and that the loan is due after it
+// started.
+func (loan *Loan) Validate() error {
+	err := validateStruct(loan)
+	if loan.DueAt.After(loan.CheckedOutAt) {
+		return err
+	}
+
+	due := FieldError{Field: "due_at", Rule: "after", Message: "must be after checked_out_at"}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		validationErr.Fields = append(validationErr.Fields, due)
+
+		return validationErr
+	} else if err != nil {
+		return err
+	}
+
+	return &ValidationError{Fields: []FieldError{due}}
+}
+
+func (loan *Loan) Open() bool {
+	return loan.ReturnedAt == nil
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Defaults for members created without an expiry date or a borrowing limit.
+const (
+	DefaultMembershipPeriod = 365 * 24 * time.Hour
+	DefaultBorrowingLimit   = 5
+)
+
+var (
+	ErrMembershipExpired     = errors.New("membership has expired")
+	ErrBorrowingLimitReached = errors.New("borrowing limit reached")
+)
+
+type Member struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name" validate:"required,max=256"`
+	Email     string             `json:"email" bson:"email" validate:"required,email,max=256"`
+	ExpiresAt time.Time          `json:"expires_at" bson:"expires_at"`
+	// BorrowingLimit is the number of books the member may have checked out
+	// at the same time.
+	BorrowingLimit int `json:"borrowing_limit" bson:"borrowing_limit" validate:"min=1,max=100"`
+	// Loans counts the books the member has checked out right now. It is
+	// maintained by checkouts and checkins, clients can't change it.
+	Loans     int       `json:"loans" bson:"loans"`
+	Version   int64     `json:"version" bson:"version"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Tenant is the library the member belongs to, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+func (member *Member) Validate() error {
+	return validateStruct(member)
+}
+
+// Normalize fills in the defaults and lower-cases the email so that it can
+// be compared.
+func (member *Member) Normalize(now time.Time) {
+	member.Email = strings.ToLower(strings.TrimSpace(member.Email))
+
+	if member.ExpiresAt.IsZero() {
+		member.ExpiresAt = now.Add(DefaultMembershipPeriod).UTC()
+	}
+
+	if member.BorrowingLimit == 0 {
+		member.BorrowingLimit = DefaultBorrowingLimit
+	}
+}
+
+func (member *Member) Expired(now time.Time) bool {
+	return !now.Before(member.ExpiresAt)
+}
+
+// Borrow takes up one of the member's loans if the membership is active and
+// below its borrowing limit.
+func (member *Member) Borrow(now time.Time) error {
+	if member.Expired(now) {
+		return ErrMembershipExpired
+	}
+
+	if member.Loans >= member.BorrowingLimit {
+		return ErrBorrowingLimitReached
+	}
+
+	member.Loans++
+
+	return nil
+}
+
+// Return gives a loan back.
+func (member *Member) Return() {
+	if member.Loans > 0 {
+		member.Loans--
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type RevisionAction string
+
+const (
+	RevisionCreate  RevisionAction = "create"
+	RevisionUpdate  RevisionAction = "update"
+	RevisionDelete  RevisionAction = "delete"
+	RevisionRestore RevisionAction = "restore"
+	RevisionRevert  RevisionAction = "revert"
+)
+
+// Revision records a single change of a book. Revisions are never changed
+// once stored, together they are the audit trail of the book.
+type Revision struct {
+	ID     primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	BookID primitive.ObjectID `json:"book_id" bson:"book_id"`
+	// Version is the version of the book the change produced.
+	Version int64          `json:"version" bson:"version"`
+	Action  RevisionAction `json:"action" bson:"action"`
+	Actor   string         `json:"actor" bson:"actor"`
+	At      time.Time      `json:"at" bson:"at"`
+	// RevertedTo is the version a revert went back to.
+	RevertedTo int64         `json:"reverted_to,omitempty" bson:"reverted_to,omitempty"`
+	Changes    []FieldChange `json:"changes" bson:"changes"`
+	// Book is the state of the book after the change.
+	Book *Book `json:"book" bson:"book"`
+	// Tenant owns the book, see Book.Tenant.
+	Tenant string `json:"-" bson:"tenant"`
+}
+
+// FieldChange is the JSON value of a book field before and after a change.
+// From is empty for fields that were set and To for fields that were
+// cleared.
+type FieldChange struct {
+	Field string          `json:"field" bson:"field"`
+	From  json.RawMessage `json:"from,omitempty" bson:"from,omitempty"`
+	To    json.RawMessage `json:"to,omitempty" bson:"to,omitempty"`
+}
+
+// unversionedFields change with every revision and are left out of diffs.
+var unversionedFields = map[string]bool{ //nolint:gochecknoglobals
+	"id": true, "version": true, "created_at": true, "updated_at": true, "deleted_at": true,
+}
+
+// DiffBooks lists the fields that differ between two states of a book in
+// the order Book declares them. A nil from stands for a book that didn't
+// exist yet.
+func DiffBooks(from, to *Book) ([]FieldChange, error) {
+	before, err := bookFields(from)
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := bookFields(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]FieldChange, 0)
+
+	bookType := reflect.TypeOf(Book{})
+	for i := 0; i < bookType.NumField(); i++ {
+		field := strings.Split(bookType.Field(i).Tag.Get("json"), ",")[0]
+		if field == "" || field == "-" || unversionedFields[field] {
+			continue
+		}
+
+		if !bytes.Equal(before[field], after[field]) {
+			changes = append(changes, FieldChange{Field: field, From: before[field], To: after[field]})
+		}
+	}
+
+	return changes, nil
+}
+
+// bookFields encodes every field of a book that isn't empty as compact
+// JSON. Zero values count as empty.
+func bookFields(book *Book) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if book == nil {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(book)
+	if err != nil {
+		return nil, fmt.Errorf("can't encode a book: %w", err)
+	}
+
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("can't decode a book: %w", err)
+	}
+
+	for field, value := range fields {
+		switch string(value) {
+		case `""`, "0", "null", "[]", "{}":
+			delete(fields, field)
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validation rules are declared with `validate` struct tags on the models.
+var validate = newValidator() //nolint:gochecknoglobals
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+
+	// report fields under the names clients know them by
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	// replaces the built-in rule, which rejects hyphenated ISBNs
+	_ = v.RegisterValidation("isbn", func(field validator.FieldLevel) bool {
+		_, err := NormalizeISBN(field.Field().String())
+
+		return err == nil
+	})
+
+	_ = v.RegisterValidation("dewey", func(field validator.FieldLevel) bool {
+		return ValidDewey(strings.TrimSpace(field.Field().String()))
+	})
+
+	_ = v.RegisterValidation("lcc", func(field validator.FieldLevel) bool {
+		return ValidLCC(strings.TrimSpace(field.Field().String()))
+	})
+
+	return v
+}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every rule a model violates.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Field+" "+field.Message)
+	}
+
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// validateStruct runs the tag based rules on a model and converts failures
+// into a *ValidationError.
+func validateStruct(model interface{}) error {
+	err := validate.Struct(model)
+
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+
+	result := &ValidationError{Fields: make([]FieldError, 0, len(fieldErrors))}
+
+	for _, fieldError := range fieldErrors {
+		// drop the struct name, e.g. "Book.title" becomes "title"
+		field := fieldError.Namespace()
+		if i := strings.Index(field, "."); i >= 0 {
+			field = field[i+1:]
+		}
+
+		result.Fields = append(result.Fields, FieldError{
+			Field:   field,
+			Rule:    fieldError.Tag(),
+			Message: ruleMessage(fieldError),
+		})
+	}
+
+	return result
+}
+
+func ruleMessage(fieldError validator.FieldError) string {
+	unit := ""
+	if fieldError.Kind() == reflect.String {
+		unit = " characters"
+	}
+
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return fmt.Sprintf("must be at most %s%s", fieldError.Param(), unit)
+	case "min":
+		return fmt.Sprintf("must be at least %s%s", fieldError.Param(), unit)
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fieldError.Param()), ", ")
+	case "isbn":
+		return "is not a valid ISBN-10 or ISBN-13"
+	case "dewey":
+		return "is not a valid Dewey Decimal class"
+	case "lcc":
+		return "is not a valid Library of Congress call number"
+	case "email":
+		return "is not a valid email address"
+	}
+
+	return "fails the " + fieldError.Tag() + " rule"
+}